@@ -1,10 +1,29 @@
-use antislop::{config::Config, Scanner};
+mod packages;
+
+use antislop::{config::Config, Finding, Scanner};
+use packages::Packages;
+use std::collections::HashMap;
+use std::path::{Path, PathBuf};
+use std::sync::{Arc, Mutex};
+use std::time::Duration;
 use tower_lsp::jsonrpc::Result;
 use tower_lsp::lsp_types::*;
 use tower_lsp::{Client, LanguageServer, LspService, Server};
 
+/// Quiet period after an edit before package-wide checks rerun.
+const RESOLVE_DELAY: Duration = Duration::from_millis(500);
+
 struct Backend {
     client: Client,
+    state: Arc<State>,
+}
+
+/// State shared with the debounced package resolutions.
+struct State {
+    scanner: Scanner,
+    /// Text of each open document, by path.
+    documents: Mutex<HashMap<PathBuf, String>>,
+    packages: Mutex<Packages>,
 }
 
 #[tower_lsp::async_trait]
@@ -35,17 +54,20 @@ impl LanguageServer for Backend {
     }
 
     async fn did_save(&self, params: DidSaveTextDocumentParams) {
+        // Other files of the package may have changed on disk too
+        if let Ok(path) = params.text_document.uri.to_file_path() {
+            let dir = packages::parent(&path);
+            let generation = self.state.packages.lock().unwrap().invalidate(&dir);
+            self.schedule_resolve(dir, generation);
+        }
         if let Some(text) = params.text {
             self.validate_document(params.text_document.uri, text).await;
-        } else {
-            // If text is not included in didSave (capability dependent), we might need to read from file system
-            // But for now, we rely on sync being FULL or just ignore if text is missing,
-            // relying on did_change having updated us.
-            // Actually, if we want to support on-save validation specifically, we might want to re-trigger.
-            // However, with FULL sync, did_change usually keeps us up to date.
-            // But let's verify if we need to implement it.
-            // The prompt asks for "didSave" specifically.
-            // implementation_plan says: "Implement textDocument/didOpen and textDocument/didSave"
+        }
+    }
+
+    async fn did_close(&self, params: DidCloseTextDocumentParams) {
+        if let Ok(path) = params.text_document.uri.to_file_path() {
+            self.state.documents.lock().unwrap().remove(&path);
         }
     }
 
@@ -55,71 +77,134 @@ impl LanguageServer for Backend {
 }
 
 impl Backend {
+    /// Rescan an edited document and publish its diagnostics.
+    ///
+    /// Only this file is rescanned. Package-check findings are carried over
+    /// from the last resolution until the debounced one publishes fresh ones.
     async fn validate_document(&self, uri: Url, text: String) {
-        // TODO: Try to find a config file in the workspace or parent directories of the file.
-        // For now, we use the default configuration.
-        let config = Config::default();
-
-        let scanner =
-            Scanner::new(config.patterns).expect("Failed to create scanner from default patterns");
-
-        // Convert URI to path string for display/logging if needed,
-        // though scanner mostly checks content.
         let path_buf = uri
             .to_file_path()
-            .unwrap_or_else(|_| std::path::PathBuf::from("unknown"));
+            .unwrap_or_else(|_| PathBuf::from("unknown"));
         let path_str = path_buf.to_str().unwrap_or("unknown");
 
-        let result = scanner.scan_file(path_str, &text);
+        let result = self.state.scanner.scan_file(path_str, &text);
+        if result.package.is_none() {
+            publish(&self.client, uri, &result.findings).await;
+            return;
+        }
 
-        let diagnostics: Vec<Diagnostic> = result
+        let local = result
             .findings
             .iter()
-            .map(|f| {
-                // Find start and end column (antislop uses 1-based indexing, LSP uses 0-based)
-                let start_line = (f.line).saturating_sub(1) as u32;
-                let start_col = (f.column).saturating_sub(1) as u32;
-                let end_col = start_col + f.match_text.chars().count() as u32; // basic char count approximation
-
-                Diagnostic {
-                    range: Range {
-                        start: Position {
-                            line: start_line,
-                            character: start_col,
-                        },
-                        end: Position {
-                            line: start_line,
-                            character: end_col,
-                        },
-                    },
-                    severity: Some(match f.severity.as_str() {
-                        "CRITICAL" => DiagnosticSeverity::ERROR,
-                        "HIGH" => DiagnosticSeverity::ERROR,
-                        "MEDIUM" => DiagnosticSeverity::WARNING,
-                        "LOW" => DiagnosticSeverity::INFORMATION,
-                        _ => DiagnosticSeverity::HINT,
-                    }),
-                    code: Some(NumberOrString::String(
-                        format!("{:?}", f.category).to_lowercase(),
-                    )),
-                    source: Some("antislop".to_string()),
-                    message: f.message.clone(),
-                    ..Default::default()
+            .filter(|f| !self.state.scanner.is_package_finding(f))
+            .cloned();
+        let (findings, generation) = {
+            self.state
+                .documents
+                .lock()
+                .unwrap()
+                .insert(path_buf.clone(), text);
+            let mut packages = self.state.packages.lock().unwrap();
+            let findings: Vec<Finding> = local.chain(packages.resolved(&path_buf)).collect();
+            (findings, packages.update(&path_buf, result))
+        };
+        publish(&self.client, uri, &findings).await;
+        self.schedule_resolve(packages::parent(&path_buf), generation);
+    }
+
+    /// Rerun package-wide checks for `dir` once no edit has touched the
+    /// package for [`RESOLVE_DELAY`], then publish every open file in it.
+    fn schedule_resolve(&self, dir: PathBuf, generation: u64) {
+        let client = self.client.clone();
+        let state = Arc::clone(&self.state);
+        tokio::spawn(async move {
+            tokio::time::sleep(RESOLVE_DELAY).await;
+            let resolving = Arc::clone(&state);
+            let resolved = tokio::task::spawn_blocking(move || {
+                let documents = resolving.documents.lock().unwrap();
+                let mut packages = resolving.packages.lock().unwrap();
+                if packages.generation(&dir) != generation {
+                    return Vec::new();
                 }
+                packages
+                    .resolve(&dir, &resolving.scanner, &documents)
+                    .into_iter()
+                    .filter(|r| documents.contains_key(Path::new(&r.path)))
+                    .filter_map(|r| {
+                        Url::from_file_path(&r.path)
+                            .ok()
+                            .map(|uri| (uri, r.findings))
+                    })
+                    .collect()
             })
-            .collect();
+            .await
+            .unwrap_or_default();
 
-        self.client
-            .publish_diagnostics(uri, diagnostics, None)
-            .await;
+            for (uri, findings) in resolved {
+                publish(&client, uri, &findings).await;
+            }
+        });
     }
 }
 
+async fn publish(client: &Client, uri: Url, findings: &[Finding]) {
+    let diagnostics: Vec<Diagnostic> = findings
+        .iter()
+        .map(|f| {
+            // Find start and end column (antislop uses 1-based indexing, LSP uses 0-based)
+            let start_line = (f.line).saturating_sub(1) as u32;
+            let start_col = (f.column).saturating_sub(1) as u32;
+            let end_col = start_col + f.match_text.chars().count() as u32; // basic char count approximation
+
+            Diagnostic {
+                range: Range {
+                    start: Position {
+                        line: start_line,
+                        character: start_col,
+                    },
+                    end: Position {
+                        line: start_line,
+                        character: end_col,
+                    },
+                },
+                severity: Some(match f.severity.as_str() {
+                    "CRITICAL" => DiagnosticSeverity::ERROR,
+                    "HIGH" => DiagnosticSeverity::ERROR,
+                    "MEDIUM" => DiagnosticSeverity::WARNING,
+                    "LOW" => DiagnosticSeverity::INFORMATION,
+                    _ => DiagnosticSeverity::HINT,
+                }),
+                code: Some(NumberOrString::String(
+                    format!("{:?}", f.category).to_lowercase(),
+                )),
+                source: Some("antislop".to_string()),
+                message: f.message.clone(),
+                ..Default::default()
+            }
+        })
+        .collect();
+
+    client.publish_diagnostics(uri, diagnostics, None).await;
+}
+
 #[tokio::main]
 async fn main() {
     let stdin = tokio::io::stdin();
     let stdout = tokio::io::stdout();
 
-    let (service, socket) = LspService::new(|client| Backend { client });
+    // Built once and shared by every document and package resolution
+    let config = Config::default();
+    let scanner =
+        Scanner::new(config.patterns).expect("Failed to create scanner from default patterns");
+    let state = Arc::new(State {
+        scanner,
+        documents: Mutex::new(HashMap::new()),
+        packages: Mutex::new(Packages::default()),
+    });
+
+    let (service, socket) = LspService::new(|client| Backend {
+        client,
+        state: Arc::clone(&state),
+    });
     Server::new(stdin, stdout, socket).serve(service).await;
 }
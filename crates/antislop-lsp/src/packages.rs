@@ -0,0 +1,114 @@
+//! Go package state kept between edits.
+//!
+//! Single-file patterns only need the edited buffer, so every change rescans
+//! that one file. Package-wide checks such as `go-unused-function` need every
+//! file of the package; the scan results of the other files are cached here
+//! and reused until a save invalidates them.
+
+use antislop::detector::go_files;
+use antislop::{FileScanResult, Finding, Scanner};
+use std::collections::HashMap;
+use std::fs;
+use std::path::{Path, PathBuf};
+
+/// Cached packages, by directory.
+#[derive(Default)]
+pub struct Packages {
+    dirs: HashMap<PathBuf, Package>,
+}
+
+#[derive(Default)]
+struct Package {
+    /// Scan result of each `.go` file, before package checks are resolved.
+    files: HashMap<PathBuf, FileScanResult>,
+    /// Package-check findings from the last resolution, by file.
+    resolved: HashMap<PathBuf, Vec<Finding>>,
+    /// Bumped on every edit or save; a pending resolution runs only if it
+    /// is still current.
+    generation: u64,
+}
+
+impl Packages {
+    /// Store a fresh scan of an edited file and return the package's new
+    /// generation.
+    pub fn update(&mut self, path: &Path, result: FileScanResult) -> u64 {
+        let package = self.dirs.entry(parent(path)).or_default();
+        package.files.insert(path.to_path_buf(), result);
+        package.generation += 1;
+        package.generation
+    }
+
+    /// Forget the cached files of the package in `dir`, so the next
+    /// resolution reads them again.
+    pub fn invalidate(&mut self, dir: &Path) -> u64 {
+        let package = self.dirs.entry(dir.to_path_buf()).or_default();
+        package.files.clear();
+        package.generation += 1;
+        package.generation
+    }
+
+    /// Current generation of the package in `dir`.
+    pub fn generation(&self, dir: &Path) -> u64 {
+        self.dirs.get(dir).map_or(0, |p| p.generation)
+    }
+
+    /// Package-check findings for `path` from the last resolution.
+    pub fn resolved(&self, path: &Path) -> Vec<Finding> {
+        self.dirs
+            .get(&parent(path))
+            .and_then(|p| p.resolved.get(path))
+            .cloned()
+            .unwrap_or_default()
+    }
+
+    /// Resolve package checks for the package in `dir`.
+    ///
+    /// Files missing from the cache are scanned from `open` buffers or, for
+    /// files not open in the editor, from disk. Returns the resolved result
+    /// of every file in the package.
+    pub fn resolve(
+        &mut self,
+        dir: &Path,
+        scanner: &Scanner,
+        open: &HashMap<PathBuf, String>,
+    ) -> Vec<FileScanResult> {
+        let package = self.dirs.entry(dir.to_path_buf()).or_default();
+        let paths = go_files(dir);
+        package.files.retain(|path, _| paths.contains(path));
+        for path in paths {
+            if package.files.contains_key(&path) {
+                continue;
+            }
+            let content = match open.get(&path) {
+                Some(text) => text.clone(),
+                None => match fs::read_to_string(&path) {
+                    Ok(content) => content,
+                    Err(_) => continue,
+                },
+            };
+            let result = scanner.scan_file(&path.to_string_lossy(), &content);
+            package.files.insert(path, result);
+        }
+
+        let mut results: Vec<FileScanResult> = package.files.values().cloned().collect();
+        scanner.resolve_packages(&mut results);
+        package.resolved = results
+            .iter()
+            .map(|r| {
+                let findings = r
+                    .findings
+                    .iter()
+                    .filter(|f| scanner.is_package_finding(f))
+                    .cloned()
+                    .collect();
+                (PathBuf::from(&r.path), findings)
+            })
+            .collect();
+        results
+    }
+}
+
+/// Directory of the package `path` belongs to.
+pub fn parent(path: &Path) -> PathBuf {
+    path.parent().map(Path::to_path_buf).unwrap_or_default()
+}
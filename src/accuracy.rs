@@ -0,0 +1,198 @@
+//! Detector accuracy against a labeled corpus (`antislop bench --accuracy`).
+//!
+//! A labels file lists source files and, for each, the findings a correct
+//! detector reports there. Every listed file is scanned; findings that match
+//! a label are true positives, other findings false positives, and labels
+//! nothing matched false negatives.
+
+use crate::{Error, Result, Scanner};
+use serde::Deserialize;
+use std::collections::{BTreeMap, HashMap};
+use std::fs;
+use std::path::Path;
+
+/// Ground truth for a corpus.
+#[derive(Debug, Clone, Deserialize)]
+pub struct Labels {
+    /// Labeled files.
+    #[serde(default)]
+    pub files: Vec<LabeledFile>,
+}
+
+/// One labeled file.
+#[derive(Debug, Clone, Deserialize)]
+pub struct LabeledFile {
+    /// Path relative to the labels file.
+    pub path: String,
+    /// Expected findings as `LINE:PATTERN-ID`; empty for a clean file.
+    #[serde(default)]
+    pub expect: Vec<String>,
+}
+
+impl Labels {
+    /// Read a labels file, rejecting expectations not shaped `LINE:ID`.
+    pub fn load(path: &Path) -> Result<Self> {
+        let content = fs::read_to_string(path).map_err(|e| {
+            Error::ConfigInvalid(format!("Failed to open labels '{}': {}", path.display(), e))
+        })?;
+        let labels: Self = toml::from_str(&content).map_err(|e| {
+            Error::ConfigInvalid(format!("Invalid labels '{}': {}", path.display(), e))
+        })?;
+        for file in &labels.files {
+            for label in &file.expect {
+                if parse_label(label).is_none() {
+                    return Err(Error::ConfigInvalid(format!(
+                        "Invalid label '{}' for '{}': expected LINE:PATTERN-ID",
+                        label, file.path
+                    )));
+                }
+            }
+        }
+        Ok(labels)
+    }
+}
+
+fn parse_label(label: &str) -> Option<(usize, &str)> {
+    let (line, id) = label.split_once(':')?;
+    let line = line.trim().parse().ok()?;
+    let id = id.trim();
+    (!id.is_empty()).then_some((line, id))
+}
+
+/// Counts for one detector.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct DetectorScore {
+    /// Pattern id.
+    pub id: String,
+    /// Findings that match a label.
+    pub true_positives: usize,
+    /// Findings without a label.
+    pub false_positives: usize,
+    /// Labels no finding matched.
+    pub false_negatives: usize,
+}
+
+impl DetectorScore {
+    /// Share of findings that were expected, or None without findings.
+    pub fn precision(&self) -> Option<f64> {
+        ratio(self.true_positives, self.false_positives)
+    }
+
+    /// Share of labels that were found, or None without labels.
+    pub fn recall(&self) -> Option<f64> {
+        ratio(self.true_positives, self.false_negatives)
+    }
+}
+
+fn ratio(hits: usize, misses: usize) -> Option<f64> {
+    let total = hits + misses;
+    (total > 0).then(|| hits as f64 / total as f64)
+}
+
+/// Scan every file in `labels`, resolving paths against `root`, and score
+/// each detector that either reported something or was expected to.
+///
+/// Package-wide checks are resolved across the labeled files. Scores come
+/// back sorted by id.
+pub fn measure(scanner: &Scanner, labels: &Labels, root: &Path) -> Result<Vec<DetectorScore>> {
+    let mut results = Vec::new();
+    for file in &labels.files {
+        let path = root.join(&file.path);
+        let content = fs::read_to_string(&path).map_err(|e| {
+            Error::ConfigInvalid(format!("Failed to read '{}': {}", path.display(), e))
+        })?;
+        results.push(scanner.scan_file(&path.to_string_lossy(), &content));
+    }
+    scanner.resolve_packages(&mut results);
+
+    let mut scores: BTreeMap<String, DetectorScore> = BTreeMap::new();
+    for (file, result) in labels.files.iter().zip(&results) {
+        // (line, id) -> (expected, found)
+        let mut counts: HashMap<(usize, &str), (usize, usize)> = HashMap::new();
+        for key in file.expect.iter().filter_map(|l| parse_label(l)) {
+            counts.entry(key).or_default().0 += 1;
+        }
+        for finding in &result.findings {
+            counts
+                .entry((finding.line, finding.pattern_key()))
+                .or_default()
+                .1 += 1;
+        }
+
+        for ((_, id), (expected, found)) in counts {
+            let score = scores
+                .entry(id.to_string())
+                .or_insert_with(|| DetectorScore {
+                    id: id.to_string(),
+                    ..Default::default()
+                });
+            let matched = expected.min(found);
+            score.true_positives += matched;
+            score.false_positives += found - matched;
+            score.false_negatives += expected - matched;
+        }
+    }
+    Ok(scores.into_values().collect())
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::Config;
+
+    #[test]
+    fn test_measure_counts_hits_and_misses() {
+        let dir = tempfile::TempDir::new().unwrap();
+        fs::write(
+            dir.path().join("a.py"),
+            "# TODO: one\nx = 1\n# FIXME: two\n# HACK: three\n",
+        )
+        .unwrap();
+        fs::write(dir.path().join("clean.py"), "x = 1\n").unwrap();
+        let labels: Labels = toml::from_str(
+            r#"
+[[files]]
+path = "a.py"
+expect = ["1:todo-marker", "2:fixme-marker", "4:hack-marker"]
+
+[[files]]
+path = "clean.py"
+"#,
+        )
+        .unwrap();
+        let scanner = Scanner::new(Config::default().patterns).unwrap();
+        let scores = measure(&scanner, &labels, dir.path()).unwrap();
+        let score = |id: &str| scores.iter().find(|s| s.id == id).unwrap().clone();
+
+        let todo = score("todo-marker");
+        assert_eq!((todo.true_positives, todo.false_positives), (1, 0));
+        assert_eq!(todo.precision(), Some(1.0));
+
+        // Labeled on the wrong line: one miss and one unexpected finding
+        let fixme = score("fixme-marker");
+        assert_eq!(
+            (
+                fixme.true_positives,
+                fixme.false_positives,
+                fixme.false_negatives
+            ),
+            (0, 1, 1)
+        );
+        assert_eq!(fixme.recall(), Some(0.0));
+
+        assert_eq!(score("hack-marker").recall(), Some(1.0));
+    }
+
+    #[test]
+    fn test_invalid_label_rejected() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let path = dir.path().join("labels.toml");
+        fs::write(
+            &path,
+            "[[files]]\npath = \"a.py\"\nexpect = [\"todo-marker\"]\n",
+        )
+        .unwrap();
+        let error = Labels::load(&path).unwrap_err().to_string();
+        assert!(error.contains("expected LINE:PATTERN-ID"), "{}", error);
+    }
+}
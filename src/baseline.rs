@@ -0,0 +1,533 @@
+//! Comparing findings against a reference scan or a baseline file by
+//! fingerprint.
+
+use crate::report::output::write_atomic;
+use crate::{Error, Finding, Result};
+use serde::{Deserialize, Serialize};
+use std::collections::HashMap;
+use std::fs;
+use std::io::Write;
+use std::path::{Component, Path, PathBuf};
+
+/// How a baseline names the files of its findings.
+///
+/// By default a file is named as it was scanned, so a baseline written
+/// from `/ci/work/src` does not match a scan of `/home/me/repo/src`.
+/// [`BaselinePaths::relative_to`] names files relative to a base directory
+/// instead, which both checkouts share.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct BaselinePaths {
+    base: Option<PathBuf>,
+}
+
+impl BaselinePaths {
+    /// Name files relative to `base`. Files outside it keep their
+    /// absolute path.
+    pub fn relative_to(base: &Path) -> Self {
+        Self {
+            base: Some(absolute(base)),
+        }
+    }
+
+    /// `file` as the baseline records it, with `/` separators when relative.
+    pub fn file(&self, file: &str) -> String {
+        let Some(ref base) = self.base else {
+            return file.to_string();
+        };
+        let path = absolute(Path::new(file));
+        match path.strip_prefix(base) {
+            Ok(relative) => relative
+                .components()
+                .map(|c| c.as_os_str().to_string_lossy())
+                .collect::<Vec<_>>()
+                .join("/"),
+            Err(_) => path.to_string_lossy().into_owned(),
+        }
+    }
+
+    /// Where a file recorded as `file` is on disk.
+    pub fn resolve(&self, file: &str) -> PathBuf {
+        match self.base {
+            Some(ref base) => base.join(file),
+            None => PathBuf::from(file),
+        }
+    }
+
+    /// [`Finding::fingerprint`], with the file named as the baseline
+    /// records it.
+    pub fn fingerprint(&self, finding: &Finding) -> String {
+        match self.base {
+            Some(_) => finding.fingerprint_as(&self.file(&finding.file)),
+            None => finding.fingerprint(),
+        }
+    }
+}
+
+/// `path` made absolute, with symlinks resolved when it exists.
+fn absolute(path: &Path) -> PathBuf {
+    if let Ok(canonical) = path.canonicalize() {
+        return canonical;
+    }
+    let joined = std::env::current_dir()
+        .map(|dir| dir.join(path))
+        .unwrap_or_else(|_| path.to_path_buf());
+    let mut normal = PathBuf::new();
+    for component in joined.components() {
+        match component {
+            Component::CurDir => {}
+            Component::ParentDir => {
+                normal.pop();
+            }
+            other => normal.push(other),
+        }
+    }
+    normal
+}
+
+/// Multiset of finding fingerprints from a reference scan.
+///
+/// Each reference finding can excuse at most one current finding, so adding
+/// a second identical TODO next to an old one still counts as new.
+#[derive(Debug, Default)]
+pub struct Fingerprints {
+    counts: HashMap<String, usize>,
+    paths: BaselinePaths,
+}
+
+impl Fingerprints {
+    /// Collect fingerprints from reference findings.
+    pub fn from_findings<'a>(findings: impl IntoIterator<Item = &'a Finding>) -> Self {
+        Self::from_findings_in(findings, BaselinePaths::default())
+    }
+
+    /// Collect fingerprints from reference findings, naming their files
+    /// with `paths`. Findings later claimed are named the same way.
+    pub fn from_findings_in<'a>(
+        findings: impl IntoIterator<Item = &'a Finding>,
+        paths: BaselinePaths,
+    ) -> Self {
+        let mut counts = HashMap::new();
+        for finding in findings {
+            *counts.entry(paths.fingerprint(finding)).or_insert(0) += 1;
+        }
+        Self { counts, paths }
+    }
+
+    /// Consume one reference entry matching `finding`.
+    ///
+    /// Returns `true` when the finding was already present in the reference.
+    pub fn claim(&mut self, finding: &Finding) -> bool {
+        match self.counts.get_mut(&self.paths.fingerprint(finding)) {
+            Some(count) if *count > 0 => {
+                *count -= 1;
+                true
+            }
+            _ => false,
+        }
+    }
+
+    /// Keep only the findings not present in the reference.
+    pub fn retain_new(&mut self, findings: &mut Vec<Finding>) {
+        findings.retain(|f| !self.claim(f));
+    }
+}
+
+/// Current baseline file format.
+const BASELINE_VERSION: u32 = 1;
+
+/// First line of a baseline in the text format.
+const TEXT_HEADER: &str = "# antislop baseline v1";
+
+/// How a baseline file is written.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, Default, clap::ValueEnum)]
+pub enum BaselineFormat {
+    /// Pretty-printed JSON with the line of every entry.
+    #[default]
+    Json,
+    /// One `fingerprint<TAB>file<TAB>pattern` line per entry, sorted, with
+    /// no line numbers, so moving code does not change the file.
+    Text,
+}
+
+impl BaselineFormat {
+    /// Format of an existing baseline file's content.
+    pub fn detect(content: &str) -> Self {
+        if content.trim_start().starts_with('{') {
+            Self::Json
+        } else {
+            Self::Text
+        }
+    }
+}
+
+/// Findings accepted as existing debt, stored in a baseline file.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct Baseline {
+    /// File format version.
+    pub version: u32,
+    /// One entry per accepted finding, sorted by file and line.
+    pub entries: Vec<BaselineEntry>,
+    /// How entries name files; not stored in the file.
+    #[serde(skip)]
+    paths: BaselinePaths,
+}
+
+/// One accepted finding.
+///
+/// Only the fingerprint is matched; the rest says where the finding was
+/// when the baseline was written.
+#[derive(Debug, Clone, PartialEq, Eq, Serialize, Deserialize)]
+pub struct BaselineEntry {
+    /// See [`Finding::fingerprint`].
+    pub fingerprint: String,
+    /// File path as scanned, or relative to the base of
+    /// [`BaselinePaths::relative_to`].
+    pub file: String,
+    /// Line when recorded, or 0 in the text format, which does not keep it.
+    pub line: usize,
+    /// Pattern id, or regex for patterns without one.
+    pub pattern: String,
+}
+
+impl Baseline {
+    /// Record `findings` as the accepted set.
+    pub fn from_findings<'a>(findings: impl IntoIterator<Item = &'a Finding>) -> Self {
+        Self::from_findings_in(findings, BaselinePaths::default())
+    }
+
+    /// Record `findings` as the accepted set, naming their files with
+    /// `paths`.
+    pub fn from_findings_in<'a>(
+        findings: impl IntoIterator<Item = &'a Finding>,
+        paths: BaselinePaths,
+    ) -> Self {
+        let mut entries: Vec<BaselineEntry> = findings
+            .into_iter()
+            .map(|f| BaselineEntry {
+                fingerprint: paths.fingerprint(f),
+                file: paths.file(&f.file),
+                line: f.line,
+                pattern: f.pattern_key().to_string(),
+            })
+            .collect();
+        entries.sort_by(|a, b| {
+            (&a.file, a.line, &a.pattern, &a.fingerprint).cmp(&(
+                &b.file,
+                b.line,
+                &b.pattern,
+                &b.fingerprint,
+            ))
+        });
+        Self {
+            version: BASELINE_VERSION,
+            entries,
+            paths,
+        }
+    }
+
+    /// Match findings against this baseline with their files named by
+    /// `paths`, as when it was written.
+    pub fn with_paths(mut self, paths: BaselinePaths) -> Self {
+        self.paths = paths;
+        self
+    }
+
+    /// Read a baseline file in either format.
+    pub fn load(path: &Path) -> Result<Self> {
+        let content = fs::read_to_string(path).map_err(|e| {
+            Error::ConfigInvalid(format!(
+                "Failed to open baseline '{}': {}",
+                path.display(),
+                e
+            ))
+        })?;
+        let invalid = |e: String| {
+            Error::ConfigInvalid(format!("Invalid baseline '{}': {}", path.display(), e))
+        };
+        let baseline: Self = match BaselineFormat::detect(&content) {
+            BaselineFormat::Json => {
+                serde_json::from_str(&content).map_err(|e| invalid(e.to_string()))?
+            }
+            BaselineFormat::Text => Self::parse_text(&content).map_err(invalid)?,
+        };
+        if baseline.version != BASELINE_VERSION {
+            return Err(Error::ConfigInvalid(format!(
+                "Unsupported baseline version {} in '{}'",
+                baseline.version,
+                path.display()
+            )));
+        }
+        Ok(baseline)
+    }
+
+    /// Write the baseline as JSON, replacing `path` only once it is complete.
+    pub fn save(&self, path: &Path) -> Result<()> {
+        self.save_as(path, BaselineFormat::Json)
+    }
+
+    /// Write the baseline in `format`, replacing `path` only once it is
+    /// complete.
+    pub fn save_as(&self, path: &Path, format: BaselineFormat) -> Result<()> {
+        let content = match format {
+            BaselineFormat::Json => serde_json::to_string_pretty(self)
+                .map_err(|e| Error::ConfigInvalid(e.to_string()))?,
+            BaselineFormat::Text => self.to_text(),
+        };
+        write_atomic(path, |w| {
+            writeln!(w, "{}", content)?;
+            Ok(())
+        })
+    }
+
+    /// The text format, sorted by file, pattern and fingerprint.
+    fn to_text(&self) -> String {
+        let mut entries: Vec<&BaselineEntry> = self.entries.iter().collect();
+        entries.sort_by(|a, b| {
+            (&a.file, &a.pattern, &a.fingerprint).cmp(&(&b.file, &b.pattern, &b.fingerprint))
+        });
+        let mut text = format!("{}\n# fingerprint\tfile\tpattern", TEXT_HEADER);
+        for entry in entries {
+            text.push_str(&format!(
+                "\n{}\t{}\t{}",
+                entry.fingerprint, entry.file, entry.pattern
+            ));
+        }
+        text
+    }
+
+    /// Parse the text format written by [`Baseline::save_as`].
+    fn parse_text(content: &str) -> std::result::Result<Self, String> {
+        let mut lines = content.lines();
+        if lines.next().map(str::trim_end) != Some(TEXT_HEADER) {
+            return Err(format!("expected '{}' on the first line", TEXT_HEADER));
+        }
+        let mut entries = Vec::new();
+        for (i, line) in lines.enumerate() {
+            if line.trim().is_empty() || line.starts_with('#') {
+                continue;
+            }
+            let mut fields = line.splitn(3, '\t');
+            match (fields.next(), fields.next(), fields.next()) {
+                (Some(fingerprint), Some(file), Some(pattern)) => entries.push(BaselineEntry {
+                    fingerprint: fingerprint.to_string(),
+                    file: file.to_string(),
+                    line: 0,
+                    pattern: pattern.to_string(),
+                }),
+                _ => {
+                    return Err(format!(
+                        "line {}: expected three tab-separated fields",
+                        i + 2
+                    ))
+                }
+            }
+        }
+        Ok(Self {
+            version: BASELINE_VERSION,
+            entries,
+            paths: BaselinePaths::default(),
+        })
+    }
+
+    /// Fingerprints of every entry, for hiding known findings.
+    pub fn fingerprints(&self) -> Fingerprints {
+        let mut counts = HashMap::new();
+        for entry in &self.entries {
+            *counts.entry(entry.fingerprint.clone()).or_insert(0) += 1;
+        }
+        Fingerprints {
+            counts,
+            paths: self.paths.clone(),
+        }
+    }
+
+    /// Entries no current finding matches.
+    ///
+    /// Entries count like [`Fingerprints`]: each finding matches at most
+    /// one. An entry is only reported when `in_scope` holds for its file, so
+    /// scanning part of the tree does not make the rest look stale.
+    pub fn stale<'a, 'f>(
+        &'a self,
+        findings: impl IntoIterator<Item = &'f Finding>,
+        in_scope: impl Fn(&str) -> bool,
+    ) -> Vec<&'a BaselineEntry> {
+        let mut current = Fingerprints::from_findings_in(findings, self.paths.clone());
+        self.entries
+            .iter()
+            .filter(|entry| match current.counts.get_mut(&entry.fingerprint) {
+                Some(count) if *count > 0 => {
+                    *count -= 1;
+                    false
+                }
+                _ => in_scope(&entry.file),
+            })
+            .collect()
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::{PatternCategory, Severity};
+
+    fn finding(line: usize, source: &str) -> Finding {
+        Finding {
+            file: "a.go".to_string(),
+            line,
+            column: 1,
+            severity: Severity::Medium,
+            category: PatternCategory::Placeholder,
+            message: "TODO".to_string(),
+            match_text: "TODO:".to_string(),
+            pattern_regex: "TODO:".to_string(),
+            pattern_id: Some("todo-marker".to_string()),
+            tags: vec![],
+            confidence: Default::default(),
+            source_line: Some(source.to_string()),
+            context_before: None,
+            context_after: None,
+            fix: None,
+            suggestion: None,
+        }
+    }
+
+    #[test]
+    fn test_moved_finding_is_not_new() {
+        let base = [finding(3, "// TODO: old")];
+        let mut known = Fingerprints::from_findings(&base);
+        let mut current = vec![finding(10, "    // TODO: old"), finding(11, "// TODO: new")];
+        known.retain_new(&mut current);
+        assert_eq!(current.len(), 1);
+        assert_eq!(current[0].line, 11);
+    }
+
+    #[test]
+    fn test_baseline_round_trip() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let path = dir.path().join("baseline.json");
+        let findings = [finding(7, "// TODO: b"), finding(2, "// TODO: a")];
+
+        let baseline = Baseline::from_findings(&findings);
+        assert_eq!(baseline.entries[0].line, 2);
+        baseline.save(&path).unwrap();
+        assert_eq!(Baseline::load(&path).unwrap(), baseline);
+
+        let mut current = vec![finding(9, "// TODO: b"), finding(10, "// TODO: c")];
+        baseline.fingerprints().retain_new(&mut current);
+        assert_eq!(current.len(), 1);
+        assert_eq!(current[0].line, 10);
+    }
+
+    #[test]
+    fn test_text_baseline_round_trip() {
+        let dir = tempfile::TempDir::new().unwrap();
+        let path = dir.path().join("baseline.txt");
+        let findings = [
+            finding(7, "// TODO: b"),
+            finding(2, "// TODO: a"),
+            finding(9, "// TODO: a"),
+        ];
+
+        let baseline = Baseline::from_findings(&findings);
+        baseline.save_as(&path, BaselineFormat::Text).unwrap();
+        let text = fs::read_to_string(&path).unwrap();
+        assert!(text.starts_with("# antislop baseline v1\n"));
+        assert_eq!(text.lines().filter(|l| !l.starts_with('#')).count(), 3);
+        assert_eq!(BaselineFormat::detect(&text), BaselineFormat::Text);
+
+        let loaded = Baseline::load(&path).unwrap();
+        assert!(loaded.entries.iter().all(|e| e.line == 0));
+        let mut current = vec![
+            finding(20, "// TODO: a"),
+            finding(21, "// TODO: a"),
+            finding(22, "// TODO: a"),
+        ];
+        loaded.fingerprints().retain_new(&mut current);
+        assert_eq!(current.len(), 1);
+
+        // Moving findings around leaves the file unchanged
+        let moved = [
+            finding(1, "// TODO: a"),
+            finding(30, "// TODO: b"),
+            finding(31, "// TODO: a"),
+        ];
+        Baseline::from_findings(&moved)
+            .save_as(&path, BaselineFormat::Text)
+            .unwrap();
+        assert_eq!(fs::read_to_string(&path).unwrap(), text);
+    }
+
+    #[test]
+    fn test_stale_entries() {
+        let baseline = Baseline::from_findings(&[
+            finding(1, "// TODO: fixed"),
+            finding(2, "// TODO: kept"),
+            finding(3, "// TODO: kept"),
+        ]);
+        let current = [finding(5, "// TODO: kept")];
+
+        let stale: Vec<_> = baseline
+            .stale(&current, |_| true)
+            .iter()
+            .map(|e| e.line)
+            .collect();
+        assert_eq!(stale, vec![1, 3]);
+        assert!(baseline.stale(&current, |_| false).is_empty());
+    }
+
+    #[test]
+    fn test_relative_paths_match_across_roots() {
+        let checkouts = [
+            tempfile::TempDir::new().unwrap(),
+            tempfile::TempDir::new().unwrap(),
+        ];
+        let findings: Vec<Finding> = checkouts
+            .iter()
+            .map(|dir| {
+                fs::create_dir(dir.path().join("src")).unwrap();
+                let file = dir.path().join("src").join("a.go");
+                fs::write(&file, "// TODO: old\n").unwrap();
+                Finding {
+                    file: file.to_string_lossy().into_owned(),
+                    ..finding(1, "// TODO: old")
+                }
+            })
+            .collect();
+        let paths = |i: usize| BaselinePaths::relative_to(checkouts[i].path());
+
+        let baseline = Baseline::from_findings_in(&findings[..1], paths(0));
+        assert_eq!(baseline.entries[0].file, "src/a.go");
+        assert_eq!(
+            paths(1).resolve("src/a.go"),
+            paths(1).base.unwrap().join("src/a.go")
+        );
+
+        let mut current = vec![findings[1].clone()];
+        baseline
+            .clone()
+            .with_paths(paths(1))
+            .fingerprints()
+            .retain_new(&mut current);
+        assert!(current.is_empty());
+
+        // Named as scanned, the two checkouts differ
+        let mut current = vec![findings[1].clone()];
+        Baseline::from_findings(&findings[..1])
+            .fingerprints()
+            .retain_new(&mut current);
+        assert_eq!(current.len(), 1);
+        assert!(baseline
+            .with_paths(paths(1))
+            .stale(&findings[1..], |_| true)
+            .is_empty());
+    }
+
+    #[test]
+    fn test_duplicate_counts_once() {
+        let base = [finding(3, "// TODO: same")];
+        let mut known = Fingerprints::from_findings(&base);
+        let mut current = vec![finding(3, "// TODO: same"), finding(4, "// TODO: same")];
+        known.retain_new(&mut current);
+        assert_eq!(current.len(), 1);
+    }
+}
@@ -2,16 +2,28 @@
 //!
 //! A blazing-fast, multi-language linter for detecting AI-generated code slop.
 
+use antislop::accuracy::{self, Labels};
+use antislop::baseline::{Baseline, BaselineEntry, BaselineFormat, BaselinePaths, Fingerprints};
+use antislop::compare::{Comparison, Report, ReportFinding};
+use antislop::config::DETECTORS;
+use antislop::detector::{is_file_ignored, is_test_file, Overrides};
+use antislop::select::Selector;
+use antislop::serve::Server;
+use antislop::walker::FileEntry;
 use antislop::{
-    Config, FilenameCheckConfig, FilenameChecker, Format, Profile, ProfileLoader, ProfileSource,
-    Reporter, Scanner, Walker, CONFIG_FILES, VERSION,
+    embed, fix, git, Confidence, Config, FileScanResult, FilenameCheckConfig, FilenameChecker,
+    Finding, Findings, Format, ParseError, Profile, ProfileLoader, ProfileSource, Reporter,
+    Scanner, Sections, Template, Walker, CONFIG_FILES, VERSION,
 };
 use anyhow::{Context, Result};
-use clap::{CommandFactory, Parser};
+use clap::{CommandFactory, Parser, Subcommand};
 use clap_complete::{generate, Shell};
+use std::collections::{HashMap, HashSet};
 use std::fs;
 use std::io;
-use std::path::PathBuf;
+use std::net::TcpListener;
+use std::path::{Path, PathBuf};
+use std::time::{SystemTime, UNIX_EPOCH};
 
 /// AntiSlop - A blazing-fast linter for detecting AI-generated code slop.
 #[derive(Parser, Debug)]
@@ -20,7 +32,11 @@ use std::path::PathBuf;
 #[command(version = VERSION)]
 #[command(about = "Detect AI-generated code slop: placeholders, hedging, stubs, and deferrals", long_about = None)]
 #[command(propagate_version = true)]
+#[command(args_conflicts_with_subcommands = true)]
 struct Args {
+    #[command(subcommand)]
+    command: Option<Command>,
+
     /// Path(s) to scan (defaults to current directory)
     #[arg(value_name = "PATH", default_value = ".")]
     paths: Vec<PathBuf>,
@@ -53,10 +69,22 @@ struct Args {
     #[arg(long)]
     list_languages: bool,
 
-    /// Output format (human, json, sarif)
+    /// Output format (human, json, sarif, ndjson, template, metrics)
     #[arg(long, value_name = "FORMAT")]
     format: Option<String>,
 
+    /// Template file for --format template
+    #[arg(long, value_name = "FILE", conflicts_with = "template")]
+    template_file: Option<PathBuf>,
+
+    /// Inline template for --format template
+    #[arg(long, value_name = "TEMPLATE")]
+    template: Option<String>,
+
+    /// Write the report to FILE instead of stdout, replacing it only once complete
+    #[arg(long, value_name = "FILE")]
+    output: Option<PathBuf>,
+
     /// Print default configuration
     #[arg(long)]
     print_config: bool,
@@ -73,21 +101,383 @@ struct Args {
     #[arg(long)]
     list_profiles: bool,
 
-    /// Disable pattern categories (comma-separated: placeholder,stub,deferral,hedging)
+    /// Disable pattern categories (comma-separated, e.g. stub,hedging,security)
     #[arg(long, value_delimiter = ',', value_name = "CATEGORIES")]
     disable: Option<Vec<String>>,
 
-    /// Only enable specific categories (comma-separated: placeholder,stub,deferral,hedging)
-    #[arg(long, value_delimiter = ',', value_name = "CATEGORIES")]
+    /// Only enable specific categories (comma-separated, e.g. security,concurrency)
+    #[arg(
+        long,
+        visible_alias = "category",
+        value_delimiter = ',',
+        value_name = "CATEGORIES"
+    )]
     only: Option<Vec<String>>,
 
+    /// Run only these detectors: pattern ids, or `filename` (repeatable or comma-separated)
+    #[arg(long, value_delimiter = ',', value_name = "ID")]
+    only_detector: Option<Vec<String>>,
+
+    /// Only enable patterns carrying any of these tags (comma-separated: correctness,security,...)
+    #[arg(long, value_delimiter = ',', value_name = "TAGS")]
+    tags: Option<Vec<String>>,
+
+    /// Only enable patterns at or above this confidence (low, medium, high)
+    #[arg(long, value_enum, value_name = "LEVEL")]
+    min_confidence: Option<Confidence>,
+
+    /// Only enable patterns matching an expression over tags, categories and confidence,
+    /// e.g. "security or (performance and not heuristic)"
+    #[arg(long, value_name = "EXPR")]
+    select: Option<Selector>,
+
+    /// Print the active patterns (after profile and filters) and exit
+    #[arg(long)]
+    list_patterns: bool,
+
+    /// Explain a pattern by id: rationale, examples and how to suppress it
+    #[arg(long, value_name = "ID")]
+    explain: Option<String>,
+
     /// Run a code hygiene survey (detect project types, suggest linters/formatters)
     #[arg(long)]
     hygiene_survey: bool,
+
+    /// Target Go version (e.g. 1.21); selects the modernizations and version-specific checks that apply
+    #[arg(long = "go", value_name = "VERSION")]
+    go_version: Option<String>,
+
+    /// Tokens a function body must share with an earlier one in its file to be reported as duplicated
+    #[arg(long, value_name = "N")]
+    dup_min_tokens: Option<usize>,
+
+    /// Only report (and fail on) findings not present at the merge base with --base
+    #[arg(long)]
+    fail_on_new: bool,
+
+    /// Revision to compare against with --fail-on-new
+    #[arg(long, value_name = "REV", default_value = "origin/main")]
+    base: String,
+
+    /// Hide findings recorded in this baseline file
+    #[arg(long, value_name = "FILE")]
+    baseline: Option<PathBuf>,
+
+    /// Write the current findings to the --baseline file and exit
+    #[arg(long, requires = "baseline")]
+    update_baseline: bool,
+
+    /// Format for --update-baseline (default: that of the existing file, else json)
+    #[arg(long, value_enum, value_name = "FORMAT", requires = "update_baseline")]
+    baseline_format: Option<BaselineFormat>,
+
+    /// Name --baseline files relative to DIR (default: the first scanned path), so other checkouts match
+    #[arg(
+        long,
+        value_name = "DIR",
+        num_args = 0..=1,
+        require_equals = true,
+        default_missing_value = "",
+        requires = "baseline"
+    )]
+    baseline_path_relative: Option<PathBuf>,
+
+    /// Report --baseline entries whose finding no longer occurs
+    #[arg(long, requires = "baseline")]
+    baseline_stale_check: bool,
+
+    /// With --baseline-stale-check, fail when more than N entries are stale
+    #[arg(long, value_name = "N", requires = "baseline_stale_check")]
+    max_stale: Option<usize>,
+
+    /// Report these pattern ids at info severity, excluded from score and exit code (comma-separated)
+    #[arg(long, value_delimiter = ',', value_name = "IDS")]
+    info_only: Option<Vec<String>>,
+
+    /// Limit API checks such as go-too-many-params to exported Go declarations
+    #[arg(long)]
+    exported_only: bool,
+
+    /// Only report todo-comment findings on lines older than DAYS, dated with git blame
+    #[arg(long, value_name = "DAYS")]
+    todo_max_age: Option<u64>,
+
+    /// Also scan Go source marked //antislop:embed-go: the next raw string literal or //go:embed file
+    #[arg(long)]
+    scan_embed_strings: bool,
+
+    /// Parse every file before scanning, list parse errors first and skip those files
+    #[arg(long)]
+    warmup: bool,
+
+    /// Exit with code 3 when any file fails to parse (implies --warmup)
+    #[arg(long)]
+    fail_on_parse_error: bool,
+
+    /// Exit with the number of findings, capped at 125, instead of 0 or 1; failures exit 126
+    #[arg(long)]
+    exit_code_count: bool,
+
+    /// Scan test files such as `_test.go` with the config's [tests] rules, or skip them
+    #[arg(long, value_enum, value_name = "MODE", default_value = "on")]
+    tests: TestFiles,
+
+    /// Print the peak resident memory of the run to stderr (Linux only)
+    #[arg(long)]
+    profile_memory: bool,
+
+    /// Number of files to scan in parallel (0 = one per CPU)
+    #[arg(short = 'j', long, value_name = "N", default_value = "0")]
+    concurrency: usize,
+
+    /// Stop listing after N findings, most severe first (0 = unlimited)
+    #[arg(long, value_name = "N", default_value = "0")]
+    max_findings: usize,
+
+    /// Show each finding's fingerprint, for a [suppress] table in the config
+    #[arg(long)]
+    print_fingerprint: bool,
+
+    /// Print the unified diff that applying suggested fixes would make, without changing files
+    #[arg(long)]
+    fix_dry_run: bool,
+
+    /// Apply suggested fixes to the files in place; overlapping fixes are skipped as in --fix-dry-run
+    #[arg(long, conflicts_with = "fix_dry_run")]
+    fix: bool,
+
+    /// Print the finding-count trailer line to stdout instead of stderr
+    #[arg(long)]
+    count_trailer: bool,
+
+    /// Leave the summary out of the report, in every format
+    #[arg(long, conflicts_with = "summary_only")]
+    no_summary: bool,
+
+    /// Write only the summary, without listing findings
+    #[arg(long)]
+    summary_only: bool,
+}
+
+#[derive(Subcommand, Debug)]
+enum Command {
+    /// Compare two `--json` reports: findings fixed, introduced and unchanged
+    Compare {
+        /// Earlier report
+        old: PathBuf,
+
+        /// Later report
+        new: PathBuf,
+
+        /// Exit 1 when the later report has findings the earlier one lacks
+        #[arg(long)]
+        fail_on_regression: bool,
+    },
+    /// Measure detectors against a labeled corpus (timing benchmarks run with `cargo bench`)
+    Bench {
+        /// Report precision and recall per detector
+        #[arg(long)]
+        accuracy: bool,
+
+        /// Labels file naming the corpus files and their expected findings
+        #[arg(
+            long,
+            value_name = "FILE",
+            default_value = "benches/fixtures/labels.toml"
+        )]
+        labels: PathBuf,
+
+        /// Exit 1 when any detector's precision is below this fraction (0.0-1.0)
+        #[arg(long, value_name = "FRACTION")]
+        min_precision: Option<f64>,
+    },
+    /// Write a commented starter antislop.toml to the current directory
+    Init {
+        /// Replace an existing config file
+        #[arg(long)]
+        force: bool,
+    },
+    /// Serve findings over HTTP (`POST /analyze`, `GET /detectors`, `GET /health`)
+    Serve {
+        /// Address to listen on; `:PORT` listens on all interfaces
+        #[arg(long, value_name = "ADDR", default_value = "127.0.0.1:8080")]
+        addr: String,
+
+        /// Largest accepted request body in KB
+        #[arg(long, value_name = "KB", default_value_t = 1024)]
+        max_body_kb: usize,
+
+        /// Requests answered at once
+        #[arg(long, value_name = "N", default_value_t = 4)]
+        workers: usize,
+    },
 }
 
+/// The reporter for `--format`, with its template loaded and checked.
+fn build_reporter(args: &Args) -> Result<Reporter> {
+    let format = if let Some(ref fmt) = args.format {
+        match fmt.as_str() {
+            "json" => Format::Json,
+            "sarif" => Format::Sarif,
+            "ndjson" => Format::Ndjson,
+            "template" => Format::Template,
+            "metrics" => Format::Metrics,
+            _ => Format::Human,
+        }
+    } else if args.json {
+        Format::Json
+    } else {
+        Format::Human
+    };
+
+    let sections = if args.no_summary {
+        if format == Format::Metrics {
+            anyhow::bail!("--no-summary leaves nothing for --format metrics to write");
+        }
+        Sections::Findings
+    } else if args.summary_only {
+        if format == Format::Sarif {
+            anyhow::bail!("--summary-only needs a format with a summary; SARIF has none");
+        }
+        Sections::Summary
+    } else {
+        Sections::All
+    };
+    let mut reporter = Reporter::new(format)
+        .with_max_findings(args.max_findings)
+        .with_sections(sections)
+        .with_fingerprints(args.print_fingerprint);
+    let text = match (&args.template_file, &args.template) {
+        (Some(path), _) => Some(
+            fs::read_to_string(path)
+                .with_context(|| format!("Failed to read template '{}'", path.display()))?,
+        ),
+        (None, Some(inline)) => Some(inline.clone()),
+        (None, None) => None,
+    };
+    match text {
+        Some(text) => {
+            if format != Format::Template {
+                anyhow::bail!("--template and --template-file need --format template");
+            }
+            let template = Template::parse(&text).context("Invalid template")?;
+            reporter = reporter.with_template(template);
+        }
+        None if format == Format::Template => {
+            anyhow::bail!("--format template needs --template-file or --template");
+        }
+        None => {}
+    }
+    if format == Format::Metrics {
+        if let Some(name) = repository_name(&args.paths) {
+            reporter = reporter.with_root(name);
+        }
+    }
+    Ok(reporter)
+}
+
+/// How `--baseline` names files: relative to `--baseline-path-relative`,
+/// whose default is the first scanned path, or as scanned.
+fn baseline_paths(args: &Args) -> BaselinePaths {
+    let Some(ref base) = args.baseline_path_relative else {
+        return BaselinePaths::default();
+    };
+    if !base.as_os_str().is_empty() {
+        return BaselinePaths::relative_to(base);
+    }
+    let root = args
+        .paths
+        .first()
+        .map(PathBuf::as_path)
+        .unwrap_or(Path::new("."));
+    if root.is_dir() {
+        BaselinePaths::relative_to(root)
+    } else {
+        BaselinePaths::relative_to(
+            root.parent()
+                .filter(|p| !p.as_os_str().is_empty())
+                .unwrap_or(Path::new(".")),
+        )
+    }
+}
+
+/// Name of the git repository holding the first scanned path.
+fn repository_name(paths: &[PathBuf]) -> Option<String> {
+    let path = paths.first()?;
+    let dir = if path.is_dir() {
+        path.as_path()
+    } else {
+        path.parent()
+            .filter(|p| !p.as_os_str().is_empty())
+            .unwrap_or(Path::new("."))
+    };
+    let root = git::toplevel(dir).ok()?;
+    Some(root.file_name()?.to_string_lossy().into_owned())
+}
+
+/// What `--tests` does with test files.
+#[derive(Debug, Clone, Copy, PartialEq, Eq, clap::ValueEnum)]
+enum TestFiles {
+    /// Scan them, applying the config's `[tests]` table
+    On,
+    /// Leave them out of the scan
+    Off,
+}
+
+/// Highest finding count `--exit-code-count` exits with.
+const COUNT_EXIT_MAX: usize = 125;
+
+/// Exit code of `--exit-code-count` when the run itself fails.
+const COUNT_EXIT_ERROR: i32 = 126;
+
 fn main() -> Result<()> {
-    let args = Args::parse();
+    let count_exit = std::env::args_os().any(|a| a == "--exit-code-count");
+    let args = match Args::try_parse() {
+        Ok(args) => args,
+        Err(err) if count_exit && err.use_stderr() => {
+            let _ = err.print();
+            std::process::exit(COUNT_EXIT_ERROR);
+        }
+        Err(err) => err.exit(),
+    };
+    let count_exit = args.exit_code_count;
+    match run(args) {
+        Err(err) if count_exit => {
+            eprintln!("Error: {:?}", err);
+            std::process::exit(COUNT_EXIT_ERROR);
+        }
+        result => result,
+    }
+}
+
+fn run(args: Args) -> Result<()> {
+    if let Some(Command::Compare {
+        ref old,
+        ref new,
+        fail_on_regression,
+    }) = args.command
+    {
+        return compare_reports(old, new, fail_on_regression);
+    }
+
+    if let Some(Command::Init { force }) = args.command {
+        return init_config(force);
+    }
+
+    if let Some(Command::Bench {
+        accuracy,
+        ref labels,
+        min_precision,
+    }) = args.command
+    {
+        if !accuracy {
+            anyhow::bail!(
+                "Nothing to run: pass --accuracy (timing benchmarks run with `cargo bench`)"
+            );
+        }
+        let config = load_config(&args.config)?;
+        return bench_accuracy(config, labels, min_precision);
+    }
 
     if args.list_languages {
         print_languages();
@@ -118,12 +508,18 @@ fn main() -> Result<()> {
 
     init_tracing(args.verbose);
 
+    // Before scanning, so a bad template fails fast
+    let reporter = build_reporter(&args)?;
+
     let mut config = load_config(&args.config)?;
 
-    if let Some(extensions) = args.extensions {
-        config.file_extensions = extensions;
+    if let Some(ref extensions) = args.extensions {
+        config.file_extensions = extensions.clone();
     }
     config.max_file_size_kb = args.max_size;
+    if let Some(ref go_version) = args.go_version {
+        config.go_version = Some(go_version.clone());
+    }
 
     config
         .validate_patterns()
@@ -146,6 +542,36 @@ fn main() -> Result<()> {
         }
     }
 
+    // Before any filtering, so every configured id still names a pattern
+    config
+        .apply_detector_options()
+        .context("Invalid detector options")?;
+    if let Some(min) = args.dup_min_tokens {
+        for pattern in config
+            .patterns
+            .iter_mut()
+            .filter(|p| p.min_tokens.is_some())
+        {
+            pattern.min_tokens = Some(min);
+        }
+    }
+
+    // Narrow to the named detectors (--only-detector); later filters still apply
+    if let Some(ref names) = args.only_detector {
+        let before = config.patterns.len();
+        config
+            .retain_detectors(names)
+            .context("Invalid --only-detector")?;
+        if args.verbose >= 1 {
+            eprintln!(
+                "Filtered to detectors {}: {} -> {} patterns",
+                names.join(","),
+                before,
+                config.patterns.len()
+            );
+        }
+    }
+
     // Apply category filters (--disable and --only)
     let original_count = config.patterns.len();
     if let Some(ref only_categories) = args.only {
@@ -182,27 +608,555 @@ fn main() -> Result<()> {
         }
     }
 
-    let scanner = Scanner::new(config.patterns.clone()).context("Failed to initialize scanner")?;
+    // Apply tag filter (--tags)
+    if let Some(ref tags) = args.tags {
+        let before = config.patterns.len();
+        config
+            .patterns
+            .retain(|p| p.tags.iter().any(|t| tags.contains(t)));
+        if args.verbose >= 1 {
+            eprintln!(
+                "Filtered to tags {}: {} -> {} patterns",
+                tags.join(","),
+                before,
+                config.patterns.len()
+            );
+        }
+    }
+
+    // Apply confidence filter (--min-confidence)
+    if let Some(min) = args.min_confidence {
+        let before = config.patterns.len();
+        config.patterns.retain(|p| p.confidence >= min);
+        if args.verbose >= 1 {
+            eprintln!(
+                "Filtered to {} confidence and above: {} -> {} patterns",
+                min.as_str().to_lowercase(),
+                before,
+                config.patterns.len()
+            );
+        }
+    }
+
+    // Apply the expression filter (--select)
+    if let Some(ref selector) = args.select {
+        for term in selector.unknown_terms(&config.patterns) {
+            eprintln!(
+                "Warning: no pattern has tag or category '{}' in --select",
+                term
+            );
+        }
+        let before = config.patterns.len();
+        config.patterns.retain(|p| selector.matches(p));
+        if args.verbose >= 1 {
+            eprintln!(
+                "Filtered by --select: {} -> {} patterns",
+                before,
+                config.patterns.len()
+            );
+        }
+    }
+
+    config.retain_supported_patterns();
+
+    if let Some(ref ids) = args.info_only {
+        config.info_only.extend(ids.iter().cloned());
+    }
+    config.apply_info_only();
+    if args.exported_only {
+        config.exported_only = true;
+    }
+    config.apply_exported_only();
+
+    if args.list_patterns {
+        print_patterns(&config.patterns);
+        return Ok(());
+    }
+
+    if let Some(ref id) = args.explain {
+        let pattern = config
+            .patterns
+            .iter()
+            .find(|p| p.id.as_deref() == Some(id.as_str()))
+            .with_context(|| format!("Unknown pattern id '{}' (see --list-patterns)", id))?;
+        print_explanation(pattern);
+        return Ok(());
+    }
+
+    // After filtering, so the server applies the same flags as a scan
+    if let Some(Command::Serve {
+        ref addr,
+        max_body_kb,
+        workers,
+    }) = args.command
+    {
+        return serve(config, addr, max_body_kb, workers);
+    }
+
+    let scanner = Scanner::new(config.patterns.clone())
+        .context("Failed to initialize scanner")?
+        .with_test_rules(config.tests.clone());
+    let overrides = Overrides::new(&config.overrides)?;
 
     let walker = Walker::new(&config);
-    let entries = walker.walk(&args.paths);
+    let mut entries = walker.walk(&args.paths);
+    if args.tests == TestFiles::Off {
+        entries.retain(|e| !is_test_file(&e.path, &e.root));
+    }
 
     if entries.is_empty() {
         eprintln!("No files found to scan");
+        std::process::exit(if args.exit_code_count {
+            COUNT_EXIT_ERROR
+        } else {
+            1
+        });
+    }
+
+    // Parse everything first, so files that cannot be analyzed are listed
+    // before any finding and kept out of the scan
+    let mut parse_failed = false;
+    if args.warmup || args.fail_on_parse_error {
+        let parse_errors = warmup(&entries, &scanner, args.concurrency);
+        if !parse_errors.is_empty() {
+            eprintln!("Parse errors ({}):", parse_errors.len());
+            for error in &parse_errors {
+                eprintln!("  {}", error);
+            }
+            eprintln!();
+            let failed: HashSet<&str> = parse_errors.iter().map(|e| e.file.as_str()).collect();
+            entries.retain(|e| !failed.contains(e.path.to_string_lossy().as_ref()));
+            parse_failed = true;
+        }
+    }
+
+    let ScanOutput {
+        mut scan_results,
+        mut filename_findings,
+        has_errors,
+    } = scan_entries(
+        &entries,
+        &scanner,
+        filename_checker(&config, &args)?,
+        args.scan_embed_strings,
+        args.concurrency,
+        args.verbose,
+    );
+
+    if let Some(min) = args.min_confidence {
+        filename_findings.retain(|f| f.confidence >= min);
+    }
+
+    if let Some(days) = args.todo_max_age {
+        let newer = age_todos(&mut scan_results, days);
+        if args.verbose >= 1 {
+            eprintln!(
+                "TODO age {} days: {} newer TODO comments ignored",
+                days, newer
+            );
+        }
+    }
+
+    // Re-rate or drop findings by path, id and category ([[overrides]]),
+    // package and filename findings included
+    overrides.apply_results(&mut scan_results);
+    overrides.apply(&mut filename_findings);
+
+    // Findings accepted for good in the config's [suppress] table
+    if !config.suppress.fingerprints.is_empty() {
+        let accepted: HashSet<&str> = config
+            .suppress
+            .fingerprints
+            .iter()
+            .map(String::as_str)
+            .collect();
+        let suppressed = drop_suppressed(&accepted, &mut scan_results, &mut filename_findings);
+        if args.verbose >= 1 {
+            eprintln!("Suppressed by fingerprint: {} findings", suppressed);
+        }
+    }
+
+    // Record or hide findings from the baseline file (--baseline)
+    let mut too_many_stale = false;
+    if let Some(ref path) = args.baseline {
+        let paths = baseline_paths(&args);
+        let findings = scan_results
+            .iter()
+            .flat_map(|r| &r.findings)
+            .chain(&filename_findings);
+        if args.update_baseline {
+            let format = args.baseline_format.unwrap_or_else(|| {
+                fs::read_to_string(path)
+                    .map(|content| BaselineFormat::detect(&content))
+                    .unwrap_or_default()
+            });
+            let baseline = Baseline::from_findings_in(findings, paths);
+            baseline
+                .save_as(path, format)
+                .with_context(|| format!("Failed to write baseline '{}'", path.display()))?;
+            eprintln!(
+                "Wrote {} entries to baseline {}",
+                baseline.entries.len(),
+                path.display()
+            );
+            return Ok(());
+        }
+
+        let baseline = Baseline::load(path)
+            .context("Failed to load baseline")?
+            .with_paths(paths.clone());
+        if args.baseline_stale_check {
+            let scanned: HashSet<String> =
+                scan_results.iter().map(|r| paths.file(&r.path)).collect();
+            let stale = baseline.stale(findings, |file| {
+                scanned.contains(file) || !paths.resolve(file).exists()
+            });
+            report_stale(&stale);
+            too_many_stale = args.max_stale.is_some_and(|max| stale.len() > max);
+        }
+
+        let known = drop_known(
+            &mut baseline.fingerprints(),
+            &mut scan_results,
+            &mut filename_findings,
+        );
+        eprintln!(
+            "Baseline {}: {} known findings ignored",
+            path.display(),
+            known
+        );
+    }
+
+    // Drop findings already present at the merge base (--fail-on-new)
+    if args.fail_on_new {
+        let base_findings = scan_base(&args, &config, &scanner)?;
+        let existing = drop_known(
+            &mut Fingerprints::from_findings(&base_findings),
+            &mut scan_results,
+            &mut filename_findings,
+        );
+        eprintln!(
+            "Comparing against merge base of {}: {} existing findings ignored",
+            args.base, existing
+        );
+    }
+
+    let all_findings: Vec<_> = scan_results
+        .iter()
+        .flat_map(|r| r.findings.iter().cloned())
+        .chain(filename_findings)
+        .collect();
+
+    if args.fix_dry_run || args.fix {
+        return fix_files(&all_findings, args.fix);
+    }
+
+    let roots: HashMap<&Path, String> = entries
+        .iter()
+        .map(|e| (e.path.as_path(), e.root.to_string_lossy().to_string()))
+        .collect();
+    let summary = antislop::ScanSummary::summarize(&all_findings, scan_results.len()).with_roots(
+        scan_results.iter().filter_map(|r| {
+            roots
+                .get(Path::new(&r.path))
+                .map(|root| (root.as_str(), r.path.as_str()))
+        }),
+        &all_findings,
+    );
+    let exit_code = if summary.total_score > 0 || has_errors || too_many_stale {
+        1
+    } else {
+        0
+    };
+
+    let trailer = antislop::count_trailer(&summary);
+    let finding_count = summary.total_findings;
+
+    match args.output {
+        Some(ref path) => reporter.report_to_file(path, all_findings, summary)?,
+        None => reporter.report(all_findings, summary)?,
+    }
+
+    if args.profile_memory {
+        match peak_memory_kib() {
+            Some(kib) => eprintln!("Peak memory: {:.1} MiB", kib as f64 / 1024.0),
+            None => eprintln!("Peak memory: unavailable on this platform"),
+        }
+    }
+
+    if args.count_trailer {
+        println!("{}", trailer);
+    } else {
+        eprintln!("{}", trailer);
+    }
+
+    if args.exit_code_count {
+        if has_errors || too_many_stale || (args.fail_on_parse_error && parse_failed) {
+            std::process::exit(COUNT_EXIT_ERROR);
+        }
+        std::process::exit(finding_count.min(COUNT_EXIT_MAX) as i32);
+    }
+    if args.fail_on_parse_error && parse_failed {
+        std::process::exit(3);
+    }
+    if exit_code != 0 {
+        std::process::exit(exit_code);
+    }
+
+    Ok(())
+}
+
+/// Findings from one pass over a set of files.
+struct ScanOutput {
+    scan_results: Vec<FileScanResult>,
+    filename_findings: Vec<Finding>,
+    has_errors: bool,
+}
+
+/// Remove findings `known` already accounts for, returning how many.
+fn drop_known(
+    known: &mut Fingerprints,
+    scan_results: &mut [FileScanResult],
+    filename_findings: &mut Vec<Finding>,
+) -> usize {
+    let mut dropped = 0;
+    for result in scan_results {
+        let before = result.findings.len();
+        known.retain_new(&mut result.findings);
+        dropped += before - result.findings.len();
+        result.score = result.findings.iter().map(|f| f.severity.score()).sum();
+    }
+    let before = filename_findings.len();
+    known.retain_new(filename_findings);
+    dropped + before - filename_findings.len()
+}
+
+/// Drop every finding whose fingerprint is in `accepted`, returning how
+/// many were dropped.
+///
+/// Unlike a baseline, one fingerprint hides all findings that share it.
+fn drop_suppressed(
+    accepted: &HashSet<&str>,
+    scan_results: &mut [FileScanResult],
+    filename_findings: &mut Vec<Finding>,
+) -> usize {
+    let mut dropped = 0;
+    for result in scan_results {
+        let before = result.findings.len();
+        result
+            .findings
+            .retain(|f| !accepted.contains(f.fingerprint().as_str()));
+        dropped += before - result.findings.len();
+        result.score = result.findings.iter().map(|f| f.severity.score()).sum();
+    }
+    let before = filename_findings.len();
+    filename_findings.retain(|f| !accepted.contains(f.fingerprint().as_str()));
+    dropped + before - filename_findings.len()
+}
+
+/// Pattern whose findings --todo-max-age dates (profile `todo-comments`).
+const TODO_COMMENT_ID: &str = "todo-comment";
+
+/// Keep `todo-comment` findings whose line git blame dates at least
+/// `max_age` days back, adding the age to the message.
+///
+/// Lines git cannot date, such as those in untracked files, count as new.
+/// Returns the number of findings dropped.
+fn age_todos(scan_results: &mut [FileScanResult], max_age: u64) -> usize {
+    let now = SystemTime::now()
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.as_secs() as i64)
+        .unwrap_or(0);
+    let is_todo = |f: &Finding| f.pattern_id.as_deref() == Some(TODO_COMMENT_ID);
+
+    let mut dropped = 0;
+    for result in scan_results {
+        if !result.findings.iter().any(is_todo) {
+            continue;
+        }
+        let times = git::blame_times(Path::new(&result.path)).unwrap_or_default();
+        let before = result.findings.len();
+        result.findings.retain_mut(|finding| {
+            if !is_todo(finding) {
+                return true;
+            }
+            let days = times
+                .get(finding.line - 1)
+                .map_or(0, |time| (now - time).max(0) as u64 / 86_400);
+            if days < max_age {
+                return false;
+            }
+            finding.message = format!("{} ({} days old)", finding.message, days);
+            true
+        });
+        dropped += before - result.findings.len();
+        result.score = result.findings.iter().map(|f| f.severity.score()).sum();
+    }
+    dropped
+}
+
+/// Print baseline entries whose finding no longer occurs.
+fn report_stale(stale: &[&BaselineEntry]) {
+    if stale.is_empty() {
+        eprintln!("Baseline: no stale entries");
+        return;
+    }
+    eprintln!(
+        "Baseline: {} stale entries (finding no longer occurs):",
+        stale.len()
+    );
+    for entry in stale {
+        // Text baselines do not record lines
+        match entry.line {
+            0 => eprintln!("  {}  {}", entry.file, entry.pattern),
+            line => eprintln!("  {}:{}  {}", entry.file, line, entry.pattern),
+        }
+    }
+    eprintln!("Prune them with --update-baseline.");
+}
+
+/// Print what changed between two JSON reports (`antislop compare`).
+fn compare_reports(old: &Path, new: &Path, fail_on_regression: bool) -> Result<()> {
+    let comparison = Comparison::new(&Report::load(old)?, &Report::load(new)?);
+
+    let list = |title: &str, findings: &[ReportFinding]| {
+        if findings.is_empty() {
+            return;
+        }
+        println!("{} ({}):", title, findings.len());
+        for f in findings {
+            let id = f.id.as_deref().unwrap_or("-");
+            println!(
+                "  {}:{}  {}  [{}] {}",
+                f.file, f.line, f.severity, id, f.message
+            );
+        }
+        println!();
+    };
+    list("Introduced", &comparison.added);
+    list("Fixed", &comparison.removed);
+    println!("{}", comparison.headline());
+
+    if fail_on_regression && !comparison.added.is_empty() {
         std::process::exit(1);
     }
+    Ok(())
+}
 
-    let mut all_findings = Vec::new();
-    let mut scan_results = Vec::new();
-    let mut has_errors = false;
+/// Answer scans over HTTP on `addr` until `POST /shutdown`.
+fn serve(config: Config, addr: &str, max_body_kb: usize, workers: usize) -> Result<()> {
+    let addr = match addr.strip_prefix(':') {
+        Some(port) => format!("0.0.0.0:{}", port),
+        None => addr.to_string(),
+    };
+    let server = Server::new(config)
+        .context("Failed to initialize scanner")?
+        .with_max_body(max_body_kb * 1024);
+    let listener =
+        TcpListener::bind(&addr).with_context(|| format!("Failed to listen on '{}'", addr))?;
+    eprintln!("Listening on http://{}", listener.local_addr()?);
+    server.run(listener, workers)?;
+    eprintln!("Server stopped");
+    Ok(())
+}
 
-    // Set up filename checker for convention analysis (disabled by default)
-    let filename_check_config = FilenameCheckConfig {
-        check_duplicates: false,     // Requires opt-in via config
-        min_files_for_convention: 5, // Need 5+ files to establish pattern
-        convention_threshold: 0.7,   // 70% must follow convention
-        use_language_hints: false,   // Require project convention before flagging
+/// Print precision and recall per detector against `labels` (bench --accuracy).
+fn bench_accuracy(config: Config, labels: &Path, min_precision: Option<f64>) -> Result<()> {
+    let scanner = Scanner::new(config.patterns).context("Failed to initialize scanner")?;
+    let corpus = Labels::load(labels)?;
+    let root = labels.parent().unwrap_or(Path::new(""));
+    let scores = accuracy::measure(&scanner, &corpus, root)?;
+
+    let percent = |value: Option<f64>| match value {
+        Some(v) => format!("{:.1}%", v * 100.0),
+        None => "-".to_string(),
     };
+    let width = scores.iter().map(|s| s.id.len()).max().unwrap_or(0).max(8);
+    println!(
+        "{:<width$}  {:>4}  {:>4}  {:>4}  {:>9}  {:>6}",
+        "detector", "tp", "fp", "fn", "precision", "recall"
+    );
+    let mut below = Vec::new();
+    for score in &scores {
+        println!(
+            "{:<width$}  {:>4}  {:>4}  {:>4}  {:>9}  {:>6}",
+            score.id,
+            score.true_positives,
+            score.false_positives,
+            score.false_negatives,
+            percent(score.precision()),
+            percent(score.recall())
+        );
+        if let (Some(floor), Some(precision)) = (min_precision, score.precision()) {
+            if precision < floor {
+                below.push(score.id.as_str());
+            }
+        }
+    }
+    println!("\n{} files, {} detectors", corpus.files.len(), scores.len());
+
+    if !below.is_empty() {
+        eprintln!(
+            "Precision below {}: {}",
+            percent(min_precision),
+            below.join(", ")
+        );
+        std::process::exit(1);
+    }
+    Ok(())
+}
+
+/// Print the diff that applying every suggested fix would make
+/// (--fix-dry-run), or with `write` apply the fixes to the files (--fix).
+///
+/// The exit code does not depend on the findings.
+fn fix_files(findings: &[Finding], write: bool) -> Result<()> {
+    let mut fixable = 0;
+    let mut unfixable = 0;
+    for (file, findings) in findings.by_file() {
+        let with_fix = findings.iter().filter(|f| f.fix.is_some()).count();
+        unfixable += findings.len() - with_fix;
+        if with_fix == 0 {
+            continue;
+        }
+        let original =
+            fs::read_to_string(file).with_context(|| format!("Failed to read '{}'", file))?;
+        let fixed = fix::apply(&original, findings.iter().copied());
+        fixable += fixed.applied;
+        unfixable += fixed.skipped;
+        if !write {
+            print!("{}", fixed.diff(file, &original));
+        } else if fixed.applied > 0 {
+            fixed
+                .write(Path::new(file))
+                .with_context(|| format!("Failed to write '{}'", file))?;
+        }
+    }
+    if write {
+        eprintln!("{} findings fixed, {} unfixable", fixable, unfixable);
+    } else {
+        eprintln!(
+            "{} findings would be fixed, {} unfixable",
+            fixable, unfixable
+        );
+    }
+    Ok(())
+}
+
+/// Build the filename convention checker, unless disabled.
+fn filename_checker(config: &Config, args: &Args) -> Result<Option<FilenameChecker>> {
+    let excluded = args
+        .only_detector
+        .as_ref()
+        .is_some_and(|names| !names.iter().any(|n| n == "filename"));
+    if args.no_filename_check || excluded {
+        return Ok(None);
+    }
+
+    // Tuned under [detectors.filename]; duplicate checks are opt-in
+    let filename_check_config =
+        FilenameCheckConfig::from_options(config.detector_options("filename"))
+            .context("Invalid [detectors.filename] options")?;
 
     // Extract naming patterns for duplicate detection
     let naming_patterns: Vec<_> = config
@@ -212,121 +1166,188 @@ fn main() -> Result<()> {
         .cloned()
         .collect();
 
-    let mut filename_checker = if args.no_filename_check {
-        None
-    } else {
-        Some(FilenameChecker::with_config_and_patterns(
-            filename_check_config,
-            &naming_patterns,
-        ))
-    };
+    Ok(Some(FilenameChecker::with_config_and_patterns(
+        filename_check_config,
+        &naming_patterns,
+    )))
+}
 
-    for entry in &entries {
-        let path = entry.path.to_string_lossy().to_string();
+/// The first syntax error of every entry that has one, in entry order.
+///
+/// Files that cannot be read are left for the scan to report.
+fn warmup(entries: &[FileEntry], scanner: &Scanner, concurrency: usize) -> Vec<ParseError> {
+    map_entries(entries, concurrency, |entry| {
+        let content = fs::read_to_string(&entry.path).ok()?;
+        scanner.parse_error(&entry.path.to_string_lossy(), &content)
+    })
+    .into_iter()
+    .flatten()
+    .collect()
+}
 
-        // Add to filename checker for convention analysis
-        if let Some(ref mut checker) = filename_checker {
+/// Scan every entry, then run package-wide checks and the filename checker
+/// over the same files.
+///
+/// Results keep the order of `entries` whatever the concurrency.
+fn scan_entries(
+    entries: &[FileEntry],
+    scanner: &Scanner,
+    mut filename_checker: Option<FilenameChecker>,
+    scan_embeds: bool,
+    concurrency: usize,
+    verbose: u8,
+) -> ScanOutput {
+    // Add to filename checker for convention analysis
+    if let Some(ref mut checker) = filename_checker {
+        for entry in entries {
             checker.add_file(&entry.path);
         }
+    }
 
-        let content = match fs::read_to_string(&entry.path) {
-            Ok(c) => c,
-            Err(e) => {
-                eprintln!("Error reading file '{}': {}", path, e);
-                has_errors = true;
-                continue;
-            }
-        };
-
-        if args.verbose >= 2 {
+    let scanned = map_entries(entries, concurrency, |entry| {
+        let path = entry.path.to_string_lossy().to_string();
+        if verbose >= 2 {
             eprintln!("Scanning: {}", entry.path.display());
         }
+        match fs::read_to_string(&entry.path) {
+            Ok(content) => {
+                let ignored = is_file_ignored(&content);
+                if ignored && verbose >= 1 {
+                    eprintln!("Skipped (antislop:file-ignore): {}", entry.path.display());
+                }
+                let mut result = scanner.scan_file_under(&path, &entry.root, &content);
+                if scan_embeds && !ignored && path.ends_with(".go") {
+                    for finding in embed::scan(scanner, &path, &content) {
+                        result.score += finding.severity.score();
+                        result.findings.push(finding);
+                    }
+                }
+                Ok(result)
+            }
+            Err(e) => Err(format!("Error reading file '{}': {}", path, e)),
+        }
+    });
 
-        let result = scanner.scan_file(&path, &content);
-        for finding in &result.findings {
-            all_findings.push(finding.clone());
+    let mut scan_results = Vec::new();
+    let mut has_errors = false;
+    for result in scanned {
+        match result {
+            Ok(result) => scan_results.push(result),
+            Err(message) => {
+                eprintln!("{}", message);
+                has_errors = true;
+            }
         }
-        scan_results.push(result);
     }
 
+    // Package-wide checks need every file's symbols
+    scanner.resolve_packages(&mut scan_results);
+
     // Check for naming convention violations
-    let filename_findings = if let Some(ref checker) = filename_checker {
-        checker.check()
-    } else {
-        Vec::new()
-    };
-    for finding in &filename_findings {
-        all_findings.push(finding.clone());
+    let filename_findings = filename_checker
+        .map(|checker| checker.check())
+        .unwrap_or_default();
+
+    ScanOutput {
+        scan_results,
+        filename_findings,
+        has_errors,
     }
+}
 
-    // Recalculate summary including filename findings
-    let summary = antislop::ScanSummary::new(&scan_results);
-
-    // Add filename findings to the total score
-    let filename_score: u32 = filename_findings.iter().map(|f| f.severity.score()).sum();
-    let total_with_filenames = summary.total_score + filename_score;
-    let exit_code = if total_with_filenames > 0 || has_errors {
-        1
-    } else {
-        0
-    };
+/// Apply `f` to every entry on up to `concurrency` threads (0 = one per CPU),
+/// returning results in entry order.
+#[cfg(feature = "parallel")]
+fn map_entries<T, F>(entries: &[FileEntry], concurrency: usize, f: F) -> Vec<T>
+where
+    T: Send,
+    F: Fn(&FileEntry) -> T + Sync + Send,
+{
+    use rayon::prelude::*;
+
+    match rayon::ThreadPoolBuilder::new()
+        .num_threads(concurrency)
+        .build()
+    {
+        Ok(pool) => pool.install(|| entries.par_iter().map(&f).collect()),
+        Err(_) => entries.iter().map(f).collect(),
+    }
+}
 
-    // Create a modified summary that includes filename findings
-    let mut summary_with_filenames = summary.clone();
-    summary_with_filenames.total_score = total_with_filenames;
-    summary_with_filenames.total_findings += filename_findings.len();
-
-    // Add filename findings to category counts
-    for finding in &filename_findings {
-        *summary_with_filenames
-            .by_category
-            .entry(finding.category.clone())
-            .or_insert(0) += 1;
-        *summary_with_filenames
-            .by_severity
-            .entry(finding.severity.clone())
-            .or_insert(0) += 1;
-    }
-
-    // Update files_with_findings if filename findings exist in previously clean files
-    if !filename_findings.is_empty() {
-        let files_with_filename_issues: std::collections::HashSet<_> =
-            filename_findings.iter().map(|f| f.file.clone()).collect();
-        let files_with_content_issues: std::collections::HashSet<_> = scan_results
-            .iter()
-            .filter(|r| !r.findings.is_empty())
-            .map(|r| r.path.clone())
-            .collect();
+#[cfg(not(feature = "parallel"))]
+fn map_entries<T, F>(entries: &[FileEntry], _concurrency: usize, f: F) -> Vec<T>
+where
+    F: Fn(&FileEntry) -> T,
+{
+    entries.iter().map(f).collect()
+}
 
-        let total_files_with_issues = files_with_filename_issues
-            .union(&files_with_content_issues)
-            .count();
-        summary_with_filenames.files_with_findings = total_files_with_issues;
+/// Scan the merge base of HEAD and `--base` in a temporary worktree.
+///
+/// Paths in the returned findings are rewritten to their form in the
+/// current tree, so fingerprints line up with the current scan.
+fn scan_base(args: &Args, config: &Config, scanner: &Scanner) -> Result<Vec<Finding>> {
+    let cwd = std::env::current_dir().context("Failed to read current directory")?;
+    let root = git::toplevel(&cwd).context("--fail-on-new requires a git repository")?;
+    let prefix = git::prefix(&cwd)?;
+    let rev = git::merge_base(&root, &args.base)
+        .context(format!("Failed to find merge base with '{}'", args.base))?;
+    let worktree = git::Worktree::checkout(&root, &rev)
+        .context(format!("Failed to check out merge base {}", rev))?;
+
+    // (path in the worktree, same path as given on the command line)
+    let mut mapping = Vec::new();
+    for path in &args.paths {
+        let base_path = if path.is_absolute() {
+            match path.strip_prefix(&root) {
+                Ok(rest) => worktree.path().join(rest),
+                Err(_) => continue,
+            }
+        } else {
+            worktree.path().join(&prefix).join(path)
+        };
+        mapping.push((base_path, path.clone()));
     }
 
-    let format = if let Some(fmt) = args.format {
-        match fmt.as_str() {
-            "json" => Format::Json,
-            "sarif" => Format::Sarif,
-            _ => Format::Human,
+    let base_paths: Vec<PathBuf> = mapping.iter().map(|(base, _)| base.clone()).collect();
+    let entries = Walker::new(config).walk(&base_paths);
+    let output = scan_entries(
+        &entries,
+        scanner,
+        filename_checker(config, args)?,
+        args.scan_embed_strings,
+        args.concurrency,
+        0,
+    );
+
+    let rewrite = |file: &str| -> String {
+        for (base, current) in &mapping {
+            if let Some(rest) = file.strip_prefix(base.to_string_lossy().as_ref()) {
+                return format!("{}{}", current.display(), rest);
+            }
         }
-    } else if args.json {
-        Format::Json
-    } else {
-        Format::Human
+        file.to_string()
     };
 
-    let reporter = Reporter::new(format);
-
-    all_findings.sort_by_key(|f| (f.file.clone(), f.line));
-
-    reporter.report(all_findings, summary_with_filenames)?;
-
-    if exit_code != 0 {
-        std::process::exit(exit_code);
-    }
+    Ok(output
+        .scan_results
+        .into_iter()
+        .flat_map(|r| r.findings)
+        .chain(output.filename_findings)
+        .map(|mut f| {
+            f.file = rewrite(&f.file);
+            f
+        })
+        .collect())
+}
 
-    Ok(())
+/// Peak resident set size of this process in KiB, where the OS reports it.
+fn peak_memory_kib() -> Option<u64> {
+    let status = fs::read_to_string("/proc/self/status").ok()?;
+    status
+        .lines()
+        .find_map(|line| line.strip_prefix("VmHWM:"))
+        .and_then(|rest| rest.trim().trim_end_matches("kB").trim().parse().ok())
 }
 
 fn init_tracing(verbose: u8) {
@@ -385,6 +1406,98 @@ fn print_default_config() {
     println!("{}", toml);
 }
 
+/// Write [`starter_config`] to `antislop.toml`, or over the config file
+/// already in the current directory when `force` is set.
+fn init_config(force: bool) -> Result<()> {
+    let existing = CONFIG_FILES.iter().map(PathBuf::from).find(|p| p.is_file());
+    if let (Some(path), false) = (&existing, force) {
+        anyhow::bail!(
+            "Config file '{}' already exists; pass --force to replace it",
+            path.display()
+        );
+    }
+    let path = existing.unwrap_or_else(|| PathBuf::from(CONFIG_FILES[0]));
+    fs::write(&path, starter_config()?)
+        .with_context(|| format!("Failed to write '{}'", path.display()))?;
+    eprintln!("Wrote {}", path.display());
+    Ok(())
+}
+
+/// A config equal to the built-in defaults, with the optional keys shown
+/// commented out and every detector id listed before the patterns.
+fn starter_config() -> Result<String> {
+    let config = Config::default();
+    let mut table = toml::Table::try_from(&config)?;
+    let patterns = table
+        .remove("patterns")
+        .unwrap_or(toml::Value::Array(Vec::new()));
+
+    let mut out = String::from(
+        "# antislop configuration, written by `antislop init`.\n\
+         #\n\
+         # A config file replaces the built-in patterns, so all of them are\n\
+         # listed at the end: delete the ones you do not want, or change their\n\
+         # severity. docs/configuration.md describes every key.\n\n",
+    );
+    out.push_str(&toml::to_string_pretty(&table)?);
+    out.push_str(
+        "\n# Report these ids at info severity: shown, but never failing the run\n\
+         # info_only = [\"todo-marker\"]\n\
+         \n\
+         # Limit API-shape Go patterns to exported declarations\n\
+         # exported_only = true\n\
+         \n\
+         # Findings accepted for good, by fingerprint (see --print-fingerprint)\n\
+         # [suppress]\n\
+         # fingerprints = []\n\
+         \n\
+         # Other rules for test files, such as _test.go\n\
+         # [tests]\n\
+         # disable = [\"go-swallowed-error-default\"]\n\
+         # severity = { go-goroutine-panic = \"info\" }\n\
+         \n\
+         # Severity changes and drops by path, pattern id and category or tag\n\
+         # [[overrides]]\n\
+         # path = \"payments/**\"\n\
+         # category = \"security\"\n\
+         # severity = \"critical\"\n\
+         \n\
+         # Per-detector options\n\
+         # [detectors.filename]\n\
+         # check_duplicates = true\n\
+         #\n\
+         # [detectors.go-too-many-params]\n\
+         # max_params = 6\n\n",
+    );
+
+    let mut detectors: Vec<(&str, &str)> = DETECTORS.to_vec();
+    detectors.extend(
+        config
+            .patterns
+            .iter()
+            .filter_map(|p| Some((p.id.as_deref()?, p.message.as_str()))),
+    );
+    let width = detectors.iter().map(|(id, _)| id.len()).max().unwrap_or(0);
+    out.push_str("# Detectors, for --only-detector, info_only and [detectors.<id>]:\n");
+    for (id, description) in detectors {
+        out.push_str(&format!(
+            "#   {:<width$}  {}\n",
+            id,
+            description,
+            width = width
+        ));
+    }
+    out.push_str(
+        "\n# To lower a pattern, change its severity below, e.g. severity = \"low\";\n\
+         # \"info\" keeps it in reports without adding to the score.\n\n",
+    );
+
+    let mut rest = toml::Table::new();
+    rest.insert("patterns".to_string(), patterns);
+    out.push_str(&toml::to_string_pretty(&rest)?);
+    Ok(out)
+}
+
 fn generate_completions(shell: Shell) {
     let mut cmd = Args::command();
     let name = "antislop".to_string();
@@ -401,6 +1514,81 @@ fn load_profile(source: &str) -> Result<Profile> {
         .context(format!("Failed to load profile from '{}'", source))
 }
 
+fn print_patterns(patterns: &[antislop::Pattern]) {
+    println!("Active patterns ({}):", patterns.len());
+    println!();
+    for pattern in patterns {
+        let tags = if pattern.tags.is_empty() {
+            String::new()
+        } else {
+            format!(" [{}]", pattern.tags.join(", "))
+        };
+        println!(
+            "  {:<36} {:<8} {:<6} {:<16}{}",
+            pattern.id.as_deref().unwrap_or("-"),
+            pattern.severity.as_str().to_lowercase(),
+            pattern.confidence.as_str().to_lowercase(),
+            format!("{:?}", pattern.category).to_lowercase(),
+            tags
+        );
+        println!("    {}", pattern.message);
+    }
+}
+
+fn print_explanation(pattern: &antislop::Pattern) {
+    let id = pattern.id.as_deref().unwrap_or("-");
+    println!("{}", id);
+    println!();
+    println!("  {}", pattern.message);
+    println!();
+    println!("  Severity:   {}", pattern.severity.as_str().to_lowercase());
+    println!(
+        "  Confidence: {}",
+        pattern.confidence.as_str().to_lowercase()
+    );
+    println!(
+        "  Category:   {}",
+        format!("{:?}", pattern.category).to_lowercase()
+    );
+    if !pattern.tags.is_empty() {
+        println!("  Tags:       {}", pattern.tags.join(", "));
+    }
+    if !pattern.languages.is_empty() {
+        println!("  Languages:  {}", pattern.languages.join(", "));
+    }
+
+    let print_block = |title: &str, text: &str| {
+        println!();
+        println!("{}:", title);
+        for line in text.trim_end().lines() {
+            println!("    {}", line);
+        }
+    };
+    if let Some(ref suggestion) = pattern.suggestion {
+        print_block("Fix", suggestion);
+    }
+    if let Some(ref docs) = pattern.docs {
+        print_block("Why", &docs.rationale);
+        if let Some(ref bad) = docs.bad {
+            print_block("Flagged", bad);
+        }
+        if let Some(ref good) = docs.good {
+            print_block("Instead", good);
+        }
+    }
+
+    println!();
+    println!("Suppress:");
+    println!(
+        "    antislop:ignore {}     in a comment on the line, or the line above",
+        id
+    );
+    println!(
+        "    --info-only {}         keep reporting it without failing the run",
+        id
+    );
+}
+
 fn print_profiles() -> Result<()> {
     let loader = ProfileLoader::new().context("Failed to initialize profile loader")?;
 
@@ -442,6 +1630,13 @@ fn parse_category(s: &str) -> Option<antislop::PatternCategory> {
         "hedging" => Some(PatternCategory::Hedging),
         "stub" => Some(PatternCategory::Stub),
         "namingconvention" | "naming" => Some(PatternCategory::NamingConvention),
+        "modernize" => Some(PatternCategory::Modernize),
+        "correctness" => Some(PatternCategory::Correctness),
+        "concurrency" => Some(PatternCategory::Concurrency),
+        "security" => Some(PatternCategory::Security),
+        "performance" => Some(PatternCategory::Performance),
+        "maintainability" => Some(PatternCategory::Maintainability),
+        "style" => Some(PatternCategory::Style),
         _ => {
             eprintln!("Warning: unknown category '{}', ignoring", s);
             None
@@ -0,0 +1,215 @@
+//! Comparing two JSON reports by finding fingerprint.
+//!
+//! Works on the saved output of `antislop --json` alone, so no file is
+//! scanned again.
+
+use crate::detector::fingerprint;
+use crate::{Error, Result};
+use serde::Deserialize;
+use std::collections::HashMap;
+use std::fs;
+use std::path::Path;
+
+/// The parts of a JSON report a comparison reads.
+#[derive(Debug, Clone, Deserialize)]
+pub struct Report {
+    /// Report totals.
+    pub summary: ReportSummary,
+    /// Every listed finding.
+    pub findings: Vec<ReportFinding>,
+}
+
+/// Totals of a JSON report.
+#[derive(Debug, Clone, Deserialize)]
+pub struct ReportSummary {
+    /// Sum of finding severities.
+    pub total_score: u32,
+}
+
+/// One finding of a JSON report.
+#[derive(Debug, Clone, Deserialize)]
+pub struct ReportFinding {
+    /// File path as scanned.
+    pub file: String,
+    /// Line of the match.
+    pub line: usize,
+    /// Severity, in lowercase.
+    pub severity: String,
+    /// Finding message.
+    pub message: String,
+    /// Matched text.
+    pub match_text: String,
+    /// Pattern id, when the pattern has one.
+    #[serde(default)]
+    pub id: Option<String>,
+    /// See [`Finding::fingerprint`](crate::Finding::fingerprint). Reports
+    /// written before the field existed fall back to a fingerprint of the
+    /// file, id and matched text.
+    #[serde(default)]
+    pub fingerprint: Option<String>,
+}
+
+impl ReportFinding {
+    /// Fingerprint this finding is matched by.
+    pub fn key(&self) -> String {
+        self.fingerprint.clone().unwrap_or_else(|| {
+            fingerprint(
+                &self.file,
+                self.id.as_deref().unwrap_or_default(),
+                &self.match_text,
+            )
+        })
+    }
+}
+
+impl Report {
+    /// Read a report written by `antislop --json`.
+    pub fn load(path: &Path) -> Result<Self> {
+        let content = fs::read_to_string(path).map_err(|e| {
+            Error::ConfigInvalid(format!("Failed to open report '{}': {}", path.display(), e))
+        })?;
+        serde_json::from_str(&content).map_err(|e| {
+            Error::ConfigInvalid(format!("Invalid JSON report '{}': {}", path.display(), e))
+        })
+    }
+}
+
+/// What changed between two reports.
+#[derive(Debug, Clone)]
+pub struct Comparison {
+    /// Findings only in the new report, in its order.
+    pub added: Vec<ReportFinding>,
+    /// Findings only in the old report, in its order.
+    pub removed: Vec<ReportFinding>,
+    /// Number of findings in both.
+    pub unchanged: usize,
+    /// Total score of the old report.
+    pub old_score: u32,
+    /// Total score of the new report.
+    pub new_score: u32,
+}
+
+impl Comparison {
+    /// Match the findings of `old` and `new` by fingerprint.
+    ///
+    /// Matching counts occurrences, as [`Fingerprints`](crate::baseline::Fingerprints)
+    /// does: two identical findings in `new` against one in `old` leave one
+    /// added.
+    pub fn new(old: &Report, new: &Report) -> Self {
+        let mut counts: HashMap<String, usize> = HashMap::new();
+        for finding in &new.findings {
+            *counts.entry(finding.key()).or_insert(0) += 1;
+        }
+
+        let mut removed = Vec::new();
+        let mut unchanged = 0;
+        for finding in &old.findings {
+            match counts.get_mut(&finding.key()) {
+                Some(count) if *count > 0 => {
+                    *count -= 1;
+                    unchanged += 1;
+                }
+                _ => removed.push(finding.clone()),
+            }
+        }
+
+        // What is left in `counts` was not in `old`; take it in report order
+        let mut added = Vec::new();
+        for finding in new.findings.iter().rev() {
+            if let Some(count) = counts.get_mut(&finding.key()).filter(|c| **c > 0) {
+                *count -= 1;
+                added.push(finding.clone());
+            }
+        }
+        added.reverse();
+
+        Self {
+            added,
+            removed,
+            unchanged,
+            old_score: old.summary.total_score,
+            new_score: new.summary.total_score,
+        }
+    }
+
+    /// Change in total score, negative when the new report is better.
+    pub fn score_delta(&self) -> i64 {
+        i64::from(self.new_score) - i64::from(self.old_score)
+    }
+
+    /// One line for a CI comment, e.g. `fixed 5, introduced 2, unchanged 40;
+    /// score 57 -> 52 (-5)`.
+    pub fn headline(&self) -> String {
+        format!(
+            "fixed {}, introduced {}, unchanged {}; score {} -> {} ({:+})",
+            self.removed.len(),
+            self.added.len(),
+            self.unchanged,
+            self.old_score,
+            self.new_score,
+            self.score_delta()
+        )
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn report(score: u32, findings: &[(&str, usize, &str)]) -> Report {
+        Report {
+            summary: ReportSummary { total_score: score },
+            findings: findings
+                .iter()
+                .map(|&(file, line, text)| ReportFinding {
+                    file: file.to_string(),
+                    line,
+                    severity: "medium".to_string(),
+                    message: "m".to_string(),
+                    match_text: text.to_string(),
+                    id: Some("todo-marker".to_string()),
+                    fingerprint: None,
+                })
+                .collect(),
+        }
+    }
+
+    #[test]
+    fn test_compare_counts_occurrences() {
+        let old = report(15, &[("a.py", 1, "TODO: x"), ("a.py", 9, "TODO: gone")]);
+        let new = report(
+            10,
+            &[
+                ("a.py", 3, "TODO: x"),
+                ("a.py", 4, "TODO: x"),
+                ("b.py", 1, "TODO: y"),
+            ],
+        );
+        let comparison = Comparison::new(&old, &new);
+
+        assert_eq!(comparison.unchanged, 1);
+        assert_eq!(
+            comparison
+                .added
+                .iter()
+                .map(|f| (f.file.as_str(), f.line))
+                .collect::<Vec<_>>(),
+            vec![("a.py", 4), ("b.py", 1)]
+        );
+        assert_eq!(comparison.removed.len(), 1);
+        assert_eq!(comparison.removed[0].match_text, "TODO: gone");
+        assert_eq!(
+            comparison.headline(),
+            "fixed 1, introduced 2, unchanged 1; score 15 -> 10 (-5)"
+        );
+    }
+
+    #[test]
+    fn test_report_fingerprint_preferred() {
+        let mut finding = report(0, &[("a.py", 1, "TODO: x")]).findings.remove(0);
+        let derived = finding.key();
+        finding.fingerprint = Some("0123456789abcdef".to_string());
+        assert_ne!(derived, finding.key());
+        assert_eq!(finding.key(), "0123456789abcdef");
+    }
+}
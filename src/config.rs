@@ -5,8 +5,9 @@
 use crate::{Error, Result};
 use regex::Regex;
 use serde::{Deserialize, Serialize};
+use std::collections::BTreeMap;
 use std::fs;
-use std::path::Path;
+use std::path::{Path, PathBuf};
 
 const DEFAULT_CONFIG_TOML: &str = include_str!("../config/default.toml");
 
@@ -28,6 +29,7 @@ impl PatternsDirectory {
             files: &[
                 ("core", include_str!("../config/patterns/core.toml")),
                 ("ast", include_str!("../config/patterns/ast.toml")),
+                ("go", include_str!("../config/patterns/go.toml")),
             ],
         }
     }
@@ -100,9 +102,13 @@ impl std::ops::Deref for RegexPattern {
 }
 
 /// Severity level for a slop finding.
-#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq, Hash, Default)]
+///
+/// Variants are ordered from least to most severe.
+#[derive(Debug, Clone, Serialize, Deserialize, PartialEq, Eq, PartialOrd, Ord, Hash, Default)]
 #[serde(rename_all = "lowercase")]
 pub enum Severity {
+    /// Informational only: reported, but never scored or gated on.
+    Info,
     /// Minor issue, worth addressing but not urgent.
     Low,
     /// Moderate issue, should be fixed.
@@ -118,6 +124,7 @@ impl Severity {
     /// Returns the numeric score for this severity level.
     pub fn score(&self) -> u32 {
         match self {
+            Severity::Info => 0,
             Severity::Low => 1,
             Severity::Medium => 5,
             Severity::High => 15,
@@ -128,6 +135,7 @@ impl Severity {
     /// Returns the display name for this severity.
     pub fn as_str(&self) -> &'static str {
         match self {
+            Severity::Info => "INFO",
             Severity::Low => "LOW",
             Severity::Medium => "MEDIUM",
             Severity::High => "HIGH",
@@ -136,6 +144,45 @@ impl Severity {
     }
 }
 
+/// How likely a pattern's matches are to be real problems.
+///
+/// Variants are ordered from least to most confident.
+#[derive(
+    Debug,
+    Clone,
+    Copy,
+    Serialize,
+    Deserialize,
+    PartialEq,
+    Eq,
+    PartialOrd,
+    Ord,
+    Hash,
+    Default,
+    clap::ValueEnum,
+)]
+#[serde(rename_all = "lowercase")]
+pub enum Confidence {
+    /// Heuristic: expect false positives.
+    Low,
+    /// Usually right, but context can make the match fine.
+    #[default]
+    Medium,
+    /// Precise: a match is almost always a real issue.
+    High,
+}
+
+impl Confidence {
+    /// Returns the display name for this confidence level.
+    pub fn as_str(&self) -> &'static str {
+        match self {
+            Confidence::Low => "LOW",
+            Confidence::Medium => "MEDIUM",
+            Confidence::High => "HIGH",
+        }
+    }
+}
+
 /// Category of slop pattern.
 #[derive(Debug, Clone, Serialize, Deserialize, Default, PartialEq, Eq, Hash)]
 #[serde(rename_all = "lowercase")]
@@ -151,11 +198,61 @@ pub enum PatternCategory {
     Stub,
     /// Filename convention violations: inconsistent naming, suspicious suffixes.
     NamingConvention,
+    /// Outdated idioms with a modern replacement (e.g., `ioutil` in Go).
+    Modernize,
+    /// Code that compiles but misbehaves: swallowed errors, unchecked assertions.
+    Correctness,
+    /// Goroutine, channel and WaitGroup misuse.
+    Concurrency,
+    /// Injection, unbounded input and permission problems.
+    Security,
+    /// Work repeated or allocated where it need not be.
+    Performance,
+    /// Code that works but is hard to change: dead, duplicated or hardcoded.
+    Maintainability,
+    /// API shape and naming conventions.
+    Style,
 }
 
+/// Names accepted by a pattern's `check` field.
+pub const CHECKS: &[&str] = &[
+    "loop-var-capture",
+    "unbounded-body-read",
+    "sprintf-sink",
+    "package-unreferenced",
+    "package-unclosed",
+    "package-erased",
+    "package-type-switch",
+    "package-recover-ratio",
+    "regexp-in-function",
+    "error-string-style",
+    "unchecked-map-chain",
+    "error-assertion",
+    "swallowed-error",
+    "uniform-returns",
+    "append-param-alias",
+    "uniform-any-slice",
+    "logged-error-return",
+    "immediate-join",
+    "goroutine-panic",
+    "waitgroup-misuse",
+    "noop-recover",
+    "not-generated",
+    "inconsistent-errors",
+    "unread-error",
+    "ignored-parse-error",
+    "unclosed-resource",
+    "untagged-json-field",
+    "hardcoded-address",
+];
+
 /// A single slop detection pattern.
 #[derive(Debug, Clone, Serialize, Deserialize)]
 pub struct Pattern {
+    /// Stable identifier (e.g., "todo-marker") used to select patterns and
+    /// referenced in reports.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub id: Option<String>,
     /// Regular expression to match (case-insensitive supported with (?i)).
     pub regex: RegexPattern,
     /// Severity level for matches.
@@ -167,6 +264,12 @@ pub struct Pattern {
     /// Category of slop this pattern detects.
     #[serde(default)]
     pub category: PatternCategory,
+    /// Free-form tags (e.g., "correctness", "security") for filtering.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub tags: Vec<String>,
+    /// How likely a match is to be a real problem; see `--min-confidence`.
+    #[serde(default)]
+    pub confidence: Confidence,
     /// Optional tree-sitter query for AST-level detection.
     /// If provided, this pattern uses AST queries instead of regex.
     #[serde(default)]
@@ -175,6 +278,93 @@ pub struct Pattern {
     /// Only used when ast_query is set.
     #[serde(default)]
     pub languages: Vec<String>,
+    /// Optional regex that suppresses a match when the scanned text (comment
+    /// or AST node) also matches it. Useful for carving out known-good shapes.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub exclude_regex: Option<RegexPattern>,
+    /// Optional regex that suppresses an AST match inside a function whose
+    /// first line matches it, e.g. `main` or `Test*`.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub exclude_enclosing: Option<RegexPattern>,
+    /// Go packages (from the `package` clause) where this AST pattern does
+    /// not apply.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub exclude_packages: Vec<String>,
+    /// Minimum language version the suggested fix needs (e.g., "1.18" for Go
+    /// `any`). The pattern is skipped when the target version is older.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub min_version: Option<String>,
+    /// Language version that fixed the problem this pattern detects (e.g.,
+    /// "1.22" for Go loop variables). The pattern only runs when a target
+    /// version is configured and is older.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub max_version: Option<String>,
+    /// Only match function nodes declaring more than this many parameters
+    /// (AST patterns only). The count is appended to the message.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub max_params: Option<usize>,
+    /// With `max_params`, count only parameters of this type (e.g., "bool").
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub param_type: Option<String>,
+    /// Only match composite literals listing more than this many elements
+    /// (AST patterns only). The count is appended to the message.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub max_elements: Option<usize>,
+    /// Only match Go functions that return nothing but `&T{...}` of a
+    /// struct `T` declared in the file with at most this many fields (AST
+    /// patterns only). The type and count are appended to the message.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub max_fields: Option<usize>,
+    /// Only match Go file-creation and chmod calls whose constant mode sets
+    /// any of these permission bits (AST patterns only), e.g. `0o003` for
+    /// world-writable or world-executable. The bits are appended to the
+    /// message.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub mode_mask: Option<u32>,
+    /// Only match function bodies repeating at least this many tokens of an
+    /// earlier function in the file (AST patterns only). Identifiers and
+    /// literals are normalized, so renamed copies still match.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub min_tokens: Option<usize>,
+    /// With the `package-recover-ratio` check, keep candidates only when
+    /// more than this percentage of the package's functions are candidates.
+    /// The ratio is appended to the message.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub max_percent: Option<usize>,
+    /// Whether matches outside exported Go declarations are dropped (AST
+    /// patterns only). Unset means the pattern does not judge API surface
+    /// and always applies; `--exported-only` turns every `false` to `true`.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub exported_only: Option<bool>,
+    /// Replacement for the text of an AST match, expanding `regex` capture
+    /// groups (`$1`, `${name}`). Reported as a suggested fix.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub fix: Option<String>,
+    /// How to resolve a match, in a sentence or two. Carried on each
+    /// finding and shown under it, apart from the terse `message`.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub suggestion: Option<String>,
+    /// Built-in structural check run on each AST match (see [`CHECKS`]).
+    /// The match is kept only if the check reports something.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub check: Option<String>,
+    /// Longer explanation shown by `--explain`.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub docs: Option<PatternDocs>,
+}
+
+/// Explanation of a pattern: why it matters and what to write instead.
+#[derive(Debug, Clone, Default, Serialize, Deserialize)]
+pub struct PatternDocs {
+    /// Why the matched code is a problem.
+    #[serde(default)]
+    pub rationale: String,
+    /// Example code the pattern flags.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub bad: Option<String>,
+    /// The same example, fixed.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub good: Option<String>,
 }
 
 /// Main configuration structure.
@@ -195,6 +385,177 @@ pub struct Config {
     /// Maximum file size to scan in KB.
     #[serde(default = "default_max_file_size")]
     pub max_file_size_kb: u64,
+    /// Target Go version (e.g., "1.21"). Unset means any modern suggestion applies.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub go_version: Option<String>,
+    /// Pattern ids whose findings are reported at info severity, so they stay
+    /// visible without adding to the score or failing the run.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub info_only: Vec<String>,
+    /// Restrict patterns that support it to exported Go declarations.
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub exported_only: bool,
+    /// Findings accepted for good, from a `[suppress]` table.
+    #[serde(default, skip_serializing_if = "Suppress::is_empty")]
+    pub suppress: Suppress,
+    /// Per-detector options, keyed by a name from [`DETECTORS`] or a pattern id.
+    #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+    pub detectors: BTreeMap<String, DetectorOptions>,
+    /// Overrides for test files, from a `[tests]` table.
+    #[serde(default, skip_serializing_if = "TestRules::is_empty")]
+    pub tests: TestRules,
+    /// Severity changes and drops for selected findings, from
+    /// `[[overrides]]` tables, applied in order.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub overrides: Vec<Override>,
+}
+
+/// Findings dropped from every report, listed in the config so the list is
+/// reviewed with it.
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct Suppress {
+    /// Fingerprints of the findings, as shown by `--print-fingerprint`. See
+    /// [`Finding::fingerprint`](crate::Finding::fingerprint).
+    #[serde(default)]
+    pub fingerprints: Vec<String>,
+}
+
+impl Suppress {
+    fn is_empty(&self) -> bool {
+        self.fingerprints.is_empty()
+    }
+}
+
+/// How findings in test files differ from the rest, from a `[tests]` table.
+///
+/// Test code panics, sleeps and drops errors on purpose, so patterns can be
+/// turned off or given another severity there alone. Which files are tests
+/// is decided by [`is_test_file`](crate::detector::is_test_file).
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct TestRules {
+    /// Pattern ids not reported in test files.
+    #[serde(default, skip_serializing_if = "Vec::is_empty")]
+    pub disable: Vec<String>,
+    /// Severity of a pattern's findings in test files, by pattern id.
+    #[serde(default, skip_serializing_if = "BTreeMap::is_empty")]
+    pub severity: BTreeMap<String, Severity>,
+}
+
+impl TestRules {
+    /// Whether test files are scanned like any other file.
+    pub fn is_empty(&self) -> bool {
+        self.disable.is_empty() && self.severity.is_empty()
+    }
+}
+
+/// One `[[overrides]]` rule: findings matching every key it sets get its
+/// `severity`, or are dropped with `drop = true`.
+///
+/// Rules are compiled and checked by
+/// [`Overrides::new`](crate::detector::Overrides::new).
+#[derive(Debug, Clone, Default, PartialEq, Eq, Serialize, Deserialize)]
+#[serde(deny_unknown_fields)]
+pub struct Override {
+    /// Glob over the finding's file path, such as `payments/**`. Globs not
+    /// starting with `/` or `**` also match below any directory.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub path: Option<String>,
+    /// Pattern id the finding comes from.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub id: Option<String>,
+    /// Category or tag of the finding, such as `stub` or `security`.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub category: Option<String>,
+    /// Severity the finding has before this rule, such as `info`.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub if_severity: Option<Severity>,
+    /// Severity given to matching findings.
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub severity: Option<Severity>,
+    /// Drop matching findings instead.
+    #[serde(default, skip_serializing_if = "std::ops::Not::not")]
+    pub drop: bool,
+}
+
+/// Built-in detectors that read a `[detectors.<name>]` table, with a
+/// one-line description of what each reports.
+///
+/// Patterns are configured the same way under their id.
+pub const DETECTORS: &[(&str, &str)] = &[(
+    "filename",
+    "File names that break their directory's naming convention",
+)];
+
+/// Whether `name` is one of the [`DETECTORS`].
+fn is_detector(name: &str) -> bool {
+    DETECTORS.iter().any(|(detector, _)| *detector == name)
+}
+
+/// The [`DETECTORS`] names, comma-separated, for error messages.
+fn detector_names() -> String {
+    let names: Vec<&str> = DETECTORS.iter().map(|(name, _)| *name).collect();
+    names.join(", ")
+}
+
+/// Options for one detector, from a `[detectors.<name>]` table.
+///
+/// A detector reads its keys with the `take_*` methods when it is built and
+/// then calls [`DetectorOptions::finish`], which rejects any key it did not
+/// read.
+#[derive(Debug, Clone, Default, PartialEq, Serialize, Deserialize)]
+#[serde(transparent)]
+pub struct DetectorOptions(toml::Table);
+
+impl DetectorOptions {
+    /// Remove `key` as a non-negative integer.
+    pub fn take_usize(&mut self, key: &str) -> Result<Option<usize>> {
+        match self.0.remove(key) {
+            None => Ok(None),
+            Some(toml::Value::Integer(n)) if n >= 0 => Ok(Some(n as usize)),
+            Some(other) => Err(invalid_option(key, "a non-negative integer", &other)),
+        }
+    }
+
+    /// Remove `key` as a number; integers are accepted.
+    pub fn take_f64(&mut self, key: &str) -> Result<Option<f64>> {
+        match self.0.remove(key) {
+            None => Ok(None),
+            Some(toml::Value::Float(n)) => Ok(Some(n)),
+            Some(toml::Value::Integer(n)) => Ok(Some(n as f64)),
+            Some(other) => Err(invalid_option(key, "a number", &other)),
+        }
+    }
+
+    /// Remove `key` as a boolean.
+    pub fn take_bool(&mut self, key: &str) -> Result<Option<bool>> {
+        match self.0.remove(key) {
+            None => Ok(None),
+            Some(toml::Value::Boolean(b)) => Ok(Some(b)),
+            Some(other) => Err(invalid_option(key, "true or false", &other)),
+        }
+    }
+
+    /// Fail if any key was not read by the detector `name`.
+    pub fn finish(self, name: &str) -> Result<()> {
+        if self.0.is_empty() {
+            return Ok(());
+        }
+        let keys: Vec<&str> = self.0.keys().map(String::as_str).collect();
+        Err(Error::ConfigInvalid(format!(
+            "Unknown option(s) for detector '{}': {}",
+            name,
+            keys.join(", ")
+        )))
+    }
+}
+
+fn invalid_option(key: &str, expected: &str, got: &toml::Value) -> Error {
+    Error::ConfigInvalid(format!(
+        "Option '{}' must be {}, got {}",
+        key, expected, got
+    ))
 }
 
 fn default_extensions() -> Vec<String> {
@@ -223,15 +584,15 @@ impl Default for Config {
 
 impl Config {
     /// Load configuration from a file.
+    ///
+    /// An `extends = "<path>"` key, relative to the file, loads that config
+    /// first and layers this one on top: pattern and exclude lists are
+    /// appended, `detectors` tables merge key by key and other values are
+    /// replaced. Chains may be any depth but must not loop.
     pub fn load(path: &Path) -> Result<Self> {
-        let content = fs::read_to_string(path).map_err(|e| {
-            Error::ConfigInvalid(format!(
-                "Failed to open config file '{}': {}",
-                path.display(),
-                e
-            ))
-        })?;
-        let config: Self = toml::from_str(&content)
+        let table = load_layer(path, &mut Vec::new())?;
+        let config: Self = toml::Value::Table(table)
+            .try_into()
             .map_err(|e| Error::ConfigInvalid(format!("Parse error: {}", e)))?;
         Ok(config)
     }
@@ -251,10 +612,217 @@ impl Config {
     pub fn validate_patterns(&self) -> Result<()> {
         for pattern in &self.patterns {
             Regex::new(&pattern.regex).map_err(Error::Regex)?;
+            if let Some(ref exclude) = pattern.exclude_regex {
+                Regex::new(exclude).map_err(Error::Regex)?;
+            }
+            if let Some(ref exclude) = pattern.exclude_enclosing {
+                Regex::new(exclude).map_err(Error::Regex)?;
+            }
+            if let Some(ref min) = pattern.min_version {
+                parse_version(min)
+                    .ok_or_else(|| Error::ConfigInvalid(format!("Invalid min_version: {}", min)))?;
+            }
+            if let Some(ref max) = pattern.max_version {
+                parse_version(max)
+                    .ok_or_else(|| Error::ConfigInvalid(format!("Invalid max_version: {}", max)))?;
+            }
+            if let Some(ref check) = pattern.check {
+                if !CHECKS.contains(&check.as_str()) {
+                    return Err(Error::ConfigInvalid(format!(
+                        "Unknown check '{}'. Valid checks: {}",
+                        check,
+                        CHECKS.join(", ")
+                    )));
+                }
+            }
+        }
+        if let Some(ref target) = self.go_version {
+            parse_version(target)
+                .ok_or_else(|| Error::ConfigInvalid(format!("Invalid Go version: {}", target)))?;
         }
         Ok(())
     }
 
+    /// Drop Go patterns that do not apply to `go_version`.
+    ///
+    /// A pattern whose `min_version` is newer than the target is dropped, as
+    /// is one whose `max_version` the target has reached. Without a target,
+    /// only patterns with a `max_version` are dropped, since the fix they
+    /// look for may already be in place.
+    pub fn retain_supported_patterns(&mut self) {
+        let target = self.go_version.clone();
+        self.patterns.retain(|p| {
+            if !p.languages.iter().any(|l| l == "Go") {
+                return true;
+            }
+            let new_enough = match (&p.min_version, &target) {
+                (Some(min), Some(target)) => version_at_least(target, min),
+                _ => true,
+            };
+            let old_enough = match (&p.max_version, &target) {
+                (Some(max), Some(target)) => !version_at_least(target, max),
+                (Some(_), None) => false,
+                (None, _) => true,
+            };
+            new_enough && old_enough
+        });
+    }
+
+    /// Apply `[detectors.<id>]` options to the patterns they name.
+    ///
+    /// Pattern tables accept `max_params`, `max_elements`, `max_fields`,
+    /// `mode_mask`, `min_tokens` and `max_percent` on patterns that already set them, and
+    /// `exported_only` on patterns that set it. Tables for [`DETECTORS`] are
+    /// left for the detector to read when it is built. Any other name is an
+    /// error.
+    pub fn apply_detector_options(&mut self) -> Result<()> {
+        for (name, options) in &self.detectors {
+            if is_detector(name) {
+                continue;
+            }
+            let mut matched = false;
+            for pattern in self
+                .patterns
+                .iter_mut()
+                .filter(|p| p.id.as_deref() == Some(name.as_str()))
+            {
+                matched = true;
+                let mut options = options.clone();
+                if let Some(max) = options.take_usize("max_params")? {
+                    if pattern.max_params.is_none() {
+                        return Err(Error::ConfigInvalid(format!(
+                            "Pattern '{}' does not take max_params",
+                            name
+                        )));
+                    }
+                    pattern.max_params = Some(max);
+                }
+                if let Some(max) = options.take_usize("max_elements")? {
+                    if pattern.max_elements.is_none() {
+                        return Err(Error::ConfigInvalid(format!(
+                            "Pattern '{}' does not take max_elements",
+                            name
+                        )));
+                    }
+                    pattern.max_elements = Some(max);
+                }
+                if let Some(max) = options.take_usize("max_fields")? {
+                    if pattern.max_fields.is_none() {
+                        return Err(Error::ConfigInvalid(format!(
+                            "Pattern '{}' does not take max_fields",
+                            name
+                        )));
+                    }
+                    pattern.max_fields = Some(max);
+                }
+                if let Some(mask) = options.take_usize("mode_mask")? {
+                    if pattern.mode_mask.is_none() {
+                        return Err(Error::ConfigInvalid(format!(
+                            "Pattern '{}' does not take mode_mask",
+                            name
+                        )));
+                    }
+                    let mask = u32::try_from(mask).map_err(|_| {
+                        invalid_option(
+                            "mode_mask",
+                            "a permission mask that fits in 32 bits",
+                            &toml::Value::Integer(mask as i64),
+                        )
+                    })?;
+                    pattern.mode_mask = Some(mask);
+                }
+                if let Some(min) = options.take_usize("min_tokens")? {
+                    if pattern.min_tokens.is_none() {
+                        return Err(Error::ConfigInvalid(format!(
+                            "Pattern '{}' does not take min_tokens",
+                            name
+                        )));
+                    }
+                    pattern.min_tokens = Some(min);
+                }
+                if let Some(max) = options.take_usize("max_percent")? {
+                    if pattern.max_percent.is_none() {
+                        return Err(Error::ConfigInvalid(format!(
+                            "Pattern '{}' does not take max_percent",
+                            name
+                        )));
+                    }
+                    pattern.max_percent = Some(max);
+                }
+                if let Some(exported) = options.take_bool("exported_only")? {
+                    if pattern.exported_only.is_none() {
+                        return Err(Error::ConfigInvalid(format!(
+                            "Pattern '{}' does not take exported_only",
+                            name
+                        )));
+                    }
+                    pattern.exported_only = Some(exported);
+                }
+                options.finish(name)?;
+            }
+            if !matched {
+                return Err(Error::ConfigInvalid(format!(
+                    "Unknown detector '{}'. Use a pattern id or one of: {}",
+                    name,
+                    detector_names()
+                )));
+            }
+        }
+        Ok(())
+    }
+
+    /// Options for the built-in detector `name`, empty when not configured.
+    pub fn detector_options(&self, name: &str) -> DetectorOptions {
+        self.detectors.get(name).cloned().unwrap_or_default()
+    }
+
+    /// Keep only the patterns whose id is in `names`.
+    ///
+    /// `names` may also list [`DETECTORS`], which the caller checks for
+    /// itself. Any other name is an error.
+    pub fn retain_detectors(&mut self, names: &[String]) -> Result<()> {
+        for name in names {
+            let known =
+                is_detector(name) || self.patterns.iter().any(|p| p.id.as_ref() == Some(name));
+            if !known {
+                return Err(Error::ConfigInvalid(format!(
+                    "Unknown detector '{}'. Use a pattern id or one of: {}",
+                    name,
+                    detector_names()
+                )));
+            }
+        }
+        self.patterns
+            .retain(|p| p.id.as_ref().is_some_and(|id| names.contains(id)));
+        Ok(())
+    }
+
+    /// Downgrade patterns listed in `info_only` to [`Severity::Info`].
+    pub fn apply_info_only(&mut self) {
+        for pattern in &mut self.patterns {
+            if pattern
+                .id
+                .as_ref()
+                .is_some_and(|id| self.info_only.contains(id))
+            {
+                pattern.severity = Severity::Info;
+            }
+        }
+    }
+
+    /// With `exported_only` set, restrict every pattern that supports it to
+    /// exported declarations.
+    pub fn apply_exported_only(&mut self) {
+        if !self.exported_only {
+            return;
+        }
+        for pattern in &mut self.patterns {
+            if pattern.exported_only.is_some() {
+                pattern.exported_only = Some(true);
+            }
+        }
+    }
+
     /// Get all patterns for a specific category.
     pub fn patterns_for_category(&self, category: &PatternCategory) -> Vec<&Pattern> {
         self.patterns
@@ -273,6 +841,128 @@ impl Config {
     }
 }
 
+/// Top-level lists that a config adds to its parent's instead of replacing.
+const ACCUMULATED_KEYS: &[&str] = &[
+    "patterns",
+    "exclude",
+    "exclude_patterns",
+    "info_only",
+    "overrides",
+];
+
+/// Read one config file and, through `extends`, everything below it.
+///
+/// `chain` holds the files currently being loaded, to report cycles.
+fn load_layer(path: &Path, chain: &mut Vec<PathBuf>) -> Result<toml::Table> {
+    let canonical = path.canonicalize().unwrap_or_else(|_| path.to_path_buf());
+    if chain.contains(&canonical) {
+        let cycle: Vec<String> = chain
+            .iter()
+            .chain(std::iter::once(&canonical))
+            .map(|p| p.display().to_string())
+            .collect();
+        return Err(Error::ConfigInvalid(format!(
+            "Circular extends: {}",
+            cycle.join(" -> ")
+        )));
+    }
+
+    let content = fs::read_to_string(path).map_err(|e| {
+        Error::ConfigInvalid(format!(
+            "Failed to open config file '{}': {}",
+            path.display(),
+            e
+        ))
+    })?;
+    let mut table: toml::Table = toml::from_str(&content)
+        .map_err(|e| Error::ConfigInvalid(format!("Parse error: {}", e)))?;
+
+    let parent = match table.remove("extends") {
+        None => return Ok(table),
+        Some(toml::Value::String(parent)) => parent,
+        Some(other) => {
+            return Err(Error::ConfigInvalid(format!(
+                "extends in '{}' must be a path, got {}",
+                path.display(),
+                other
+            )))
+        }
+    };
+    let parent = path.parent().unwrap_or(Path::new("")).join(parent);
+
+    chain.push(canonical);
+    let mut merged = load_layer(&parent, chain)?;
+    chain.pop();
+    merge_layer(&mut merged, table);
+    Ok(merged)
+}
+
+/// Layer `child` over `base`.
+///
+/// Lists in [`ACCUMULATED_KEYS`] are appended, except that a child pattern
+/// replaces a parent pattern with the same `id`. Tables such as
+/// `detectors` merge key by key. Any other value in the child replaces the
+/// parent's.
+fn merge_layer(base: &mut toml::Table, child: toml::Table) {
+    for (key, value) in child {
+        match (base.get_mut(&key), value) {
+            (Some(toml::Value::Array(list)), toml::Value::Array(more))
+                if ACCUMULATED_KEYS.contains(&key.as_str()) =>
+            {
+                if key == "patterns" {
+                    let id =
+                        |v: &toml::Value| v.get("id").and_then(|i| i.as_str()).map(str::to_string);
+                    let replaced: Vec<String> = more.iter().filter_map(id).collect();
+                    list.retain(|p| id(p).map_or(true, |i| !replaced.contains(&i)));
+                }
+                list.extend(more);
+            }
+            (Some(toml::Value::Table(table)), toml::Value::Table(more)) => {
+                for (name, value) in more {
+                    match (table.get_mut(&name), value) {
+                        (Some(toml::Value::Table(inner)), toml::Value::Table(over)) => {
+                            inner.extend(over)
+                        }
+                        // Suppressed fingerprints and test-file disables add up
+                        // like the top-level lists
+                        (Some(toml::Value::Array(list)), toml::Value::Array(more))
+                            if key == "suppress" || key == "tests" =>
+                        {
+                            list.extend(more)
+                        }
+                        (_, value) => {
+                            table.insert(name, value);
+                        }
+                    }
+                }
+            }
+            (_, value) => {
+                base.insert(key, value);
+            }
+        }
+    }
+}
+
+/// Parse a dotted version such as "1.21", "1.21.3" or "go1.21".
+fn parse_version(s: &str) -> Option<Vec<u32>> {
+    let s = s.trim().trim_start_matches("go");
+    s.split('.').map(|part| part.parse().ok()).collect()
+}
+
+/// Whether `target` is the same as or newer than `min`.
+///
+/// Missing components compare as zero, so "1.21" equals "1.21.0".
+/// Unparseable versions never exclude a pattern.
+pub fn version_at_least(target: &str, min: &str) -> bool {
+    let (Some(mut target), Some(mut min)) = (parse_version(target), parse_version(min)) else {
+        return true;
+    };
+    let len = target.len().max(min.len());
+    target.resize(len, 0);
+    min.resize(len, 0);
+    target >= min
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -315,6 +1005,7 @@ mod tests {
 
     #[test]
     fn test_severity_scores() {
+        assert_eq!(Severity::Info.score(), 0);
         assert_eq!(Severity::Low.score(), 1);
         assert_eq!(Severity::Medium.score(), 5);
         assert_eq!(Severity::High.score(), 15);
@@ -362,6 +1053,296 @@ mod tests {
         assert_eq!(severity, Severity::Medium);
     }
 
+    #[test]
+    fn test_severity_ordering() {
+        assert!(Severity::Info < Severity::Low);
+        assert!(Severity::Low < Severity::Medium);
+        assert!(Severity::Medium < Severity::High);
+        assert!(Severity::High < Severity::Critical);
+    }
+
+    #[test]
+    fn test_confidence_levels() {
+        assert!(Confidence::Low < Confidence::Medium);
+        assert!(Confidence::Medium < Confidence::High);
+        assert_eq!(Confidence::default(), Confidence::Medium);
+
+        let config = Config::default();
+        let confidence = |id: &str| {
+            config
+                .patterns
+                .iter()
+                .find(|p| p.id.as_deref() == Some(id))
+                .map(|p| p.confidence)
+        };
+        assert_eq!(confidence("todo-marker"), Some(Confidence::High));
+        assert_eq!(confidence("go-too-many-params"), Some(Confidence::Low));
+        assert_eq!(confidence("go-sql-concat-query"), Some(Confidence::Medium));
+    }
+
+    #[test]
+    fn test_version_at_least() {
+        assert!(version_at_least("1.21", "1.18"));
+        assert!(version_at_least("1.18", "1.18"));
+        assert!(version_at_least("go1.16.4", "1.16"));
+        assert!(!version_at_least("1.15", "1.16"));
+        assert!(!version_at_least("1.9", "1.12"));
+    }
+
+    #[test]
+    fn test_retain_supported_patterns() {
+        let mut config = Config::default();
+        let has_any = |c: &Config| {
+            c.patterns
+                .iter()
+                .any(|p| p.min_version.as_deref() == Some("1.18"))
+        };
+        assert!(has_any(&config), "Default config should gate on Go 1.18");
+
+        config.go_version = Some("1.17".to_string());
+        config.retain_supported_patterns();
+        assert!(!has_any(&config));
+        assert!(config.patterns.iter().any(|p| p.min_version.is_some()));
+    }
+
+    #[test]
+    fn test_retain_max_version_patterns() {
+        let has_loop_var = |c: &Config| {
+            c.patterns
+                .iter()
+                .any(|p| p.id.as_deref() == Some("go-loop-var-goroutine"))
+        };
+
+        let mut config = Config::default();
+        config.retain_supported_patterns();
+        assert!(!has_loop_var(&config), "Needs an explicit target version");
+
+        let mut config = Config::default();
+        config.go_version = Some("1.21".to_string());
+        config.retain_supported_patterns();
+        assert!(has_loop_var(&config));
+
+        let mut config = Config::default();
+        config.go_version = Some("1.22".to_string());
+        config.retain_supported_patterns();
+        assert!(!has_loop_var(&config));
+    }
+
+    #[test]
+    fn test_apply_detector_options() {
+        let mut config = Config::default();
+        config.detectors = Config::from_toml_str(
+            r#"
+[detectors.go-too-many-params]
+max_params = 7
+
+[detectors.filename]
+min_files = 3
+"#,
+        )
+        .unwrap()
+        .detectors;
+        config.apply_detector_options().unwrap();
+
+        let max = config
+            .patterns
+            .iter()
+            .find(|p| p.id.as_deref() == Some("go-too-many-params"))
+            .and_then(|p| p.max_params);
+        assert_eq!(max, Some(7));
+        assert_eq!(
+            config
+                .detector_options("filename")
+                .take_usize("min_files")
+                .unwrap(),
+            Some(3)
+        );
+    }
+
+    #[test]
+    fn test_apply_detector_options_rejects_unknown() {
+        let with = |toml: &str| {
+            let mut config = Config::default();
+            config.detectors = Config::from_toml_str(toml).unwrap().detectors;
+            config.apply_detector_options()
+        };
+        assert!(with("[detectors.no-such-pattern]\nmax_params = 3").is_err());
+        assert!(with("[detectors.go-too-many-params]\nthreshold = 3").is_err());
+        assert!(with("[detectors.go-too-many-params]\nmax_params = \"six\"").is_err());
+        assert!(with("[detectors.todo-marker]\nmax_params = 3").is_err());
+        assert!(with("[detectors.go-too-many-params]\nmax_elements = 3").is_err());
+        assert!(with("[detectors.go-large-literal]\nmax_elements = 500").is_ok());
+        assert!(with("[detectors.go-duplicated-block]\nmin_tokens = 80").is_ok());
+        assert!(with("[detectors.go-large-literal]\nmin_tokens = 80").is_err());
+        assert!(with("[detectors.go-permissive-file-mode]\nmode_mask = 0o023").is_ok());
+        let err = with("[detectors.go-permissive-file-mode]\nmode_mask = 4294967299").unwrap_err();
+        assert!(err.to_string().contains("mode_mask"), "{}", err);
+        assert!(with("[detectors.go-recover-everywhere]\nmax_percent = 75").is_ok());
+        assert!(with("[detectors.go-large-literal]\nmax_percent = 75").is_err());
+    }
+
+    #[test]
+    fn test_load_extends() {
+        let dir = tempfile::TempDir::new().unwrap();
+        fs::write(
+            dir.path().join("base.toml"),
+            r#"
+max_file_size_kb = 512
+go_version = "1.20"
+exclude = ["vendor/**"]
+
+[detectors.filename]
+min_files_for_convention = 3
+convention_threshold = 0.9
+
+[suppress]
+fingerprints = ["0123456789abcdef"]
+
+[[patterns]]
+id = "shared"
+regex = "(?i)base"
+severity = "low"
+message = "Base"
+category = "placeholder"
+
+[[patterns]]
+id = "kept"
+regex = "(?i)kept"
+severity = "low"
+message = "Kept"
+category = "placeholder"
+"#,
+        )
+        .unwrap();
+        fs::create_dir(dir.path().join("service")).unwrap();
+        fs::write(
+            dir.path().join("service/antislop.toml"),
+            r#"
+extends = "../base.toml"
+go_version = "1.22"
+exclude = ["gen/**"]
+
+[detectors.filename]
+convention_threshold = 0.8
+
+[suppress]
+fingerprints = ["fedcba9876543210"]
+
+[[patterns]]
+id = "shared"
+regex = "(?i)service"
+severity = "high"
+message = "Service"
+category = "placeholder"
+"#,
+        )
+        .unwrap();
+
+        let config = Config::load(&dir.path().join("service/antislop.toml")).unwrap();
+        assert_eq!(config.max_file_size_kb, 512);
+        assert_eq!(config.go_version.as_deref(), Some("1.22"));
+        assert_eq!(config.exclude, vec!["vendor/**", "gen/**"]);
+        assert_eq!(
+            config.suppress.fingerprints,
+            vec!["0123456789abcdef", "fedcba9876543210"]
+        );
+
+        let ids: Vec<_> = config
+            .patterns
+            .iter()
+            .filter_map(|p| p.id.as_deref())
+            .collect();
+        assert_eq!(ids, vec!["kept", "shared"]);
+        assert_eq!(config.patterns[1].severity, Severity::High);
+
+        let mut filename = config.detector_options("filename");
+        assert_eq!(
+            filename.take_usize("min_files_for_convention").unwrap(),
+            Some(3)
+        );
+        assert_eq!(
+            filename.take_f64("convention_threshold").unwrap(),
+            Some(0.8)
+        );
+    }
+
+    #[test]
+    fn test_load_extends_cycle() {
+        let dir = tempfile::TempDir::new().unwrap();
+        fs::write(dir.path().join("a.toml"), "extends = \"b.toml\"\n").unwrap();
+        fs::write(dir.path().join("b.toml"), "extends = \"a.toml\"\n").unwrap();
+
+        let err = Config::load(&dir.path().join("a.toml")).unwrap_err();
+        assert!(err.to_string().contains("Circular extends"), "{}", err);
+    }
+
+    #[test]
+    fn test_retain_detectors() {
+        let mut config = Config::default();
+        config
+            .retain_detectors(&["todo-marker".to_string(), "filename".to_string()])
+            .unwrap();
+        let ids: Vec<_> = config
+            .patterns
+            .iter()
+            .filter_map(|p| p.id.as_deref())
+            .collect();
+        assert_eq!(ids, vec!["todo-marker"]);
+
+        let err = Config::default()
+            .retain_detectors(&["no-such-pattern".to_string()])
+            .unwrap_err();
+        assert!(
+            err.to_string()
+                .contains("Unknown detector 'no-such-pattern'"),
+            "{}",
+            err
+        );
+    }
+
+    #[test]
+    fn test_apply_info_only() {
+        let mut config = Config::default();
+        config.info_only = vec!["todo-marker".to_string()];
+        config.apply_info_only();
+
+        let severity = |id: &str| {
+            config
+                .patterns
+                .iter()
+                .find(|p| p.id.as_deref() == Some(id))
+                .map(|p| p.severity.clone())
+        };
+        assert_eq!(severity("todo-marker"), Some(Severity::Info));
+        assert_eq!(severity("fixme-marker"), Some(Severity::Medium));
+    }
+
+    #[test]
+    fn test_apply_exported_only() {
+        let exported = |config: &Config, id: &str| {
+            config
+                .patterns
+                .iter()
+                .find(|p| p.id.as_deref() == Some(id))
+                .and_then(|p| p.exported_only)
+        };
+        let mut config = Config::default();
+        assert_eq!(exported(&config, "go-too-many-params"), Some(false));
+        config.apply_exported_only();
+        assert_eq!(exported(&config, "go-too-many-params"), Some(false));
+
+        config.exported_only = true;
+        config.apply_exported_only();
+        assert_eq!(exported(&config, "go-too-many-params"), Some(true));
+        assert_eq!(exported(&config, "go-unread-error"), None);
+
+        let mut config =
+            Config::from_toml_str("[detectors.go-unread-error]\nexported_only = true\n").unwrap();
+        config.patterns = Config::default().patterns;
+        let err = config.apply_detector_options().unwrap_err().to_string();
+        assert!(err.contains("does not take exported_only"), "{}", err);
+    }
+
     #[test]
     fn test_severity_as_str() {
         assert_eq!(Severity::Low.as_str(), "LOW");
@@ -0,0 +1,2606 @@
+//! Structural checks for AST patterns.
+//!
+//! Some problems cannot be expressed as a query plus a regex because they
+//! relate nodes at arbitrary depth. A pattern names one of these checks in
+//! its `check` field; the check runs on each captured node and either
+//! rejects the match or returns a detail appended to the message.
+
+use super::{ErasedFlow, TypeSwitch};
+use tree_sitter::Node;
+
+/// Run the named check on `node`.
+///
+/// Returns `None` when the match should be dropped, otherwise a short
+/// detail for the message (empty for none). Unknown names drop the match; config validation
+/// rejects them earlier.
+pub(crate) fn run(name: &str, node: &Node, source: &str) -> Option<String> {
+    match name {
+        "loop-var-capture" => loop_var_capture(node, source),
+        "unbounded-body-read" => unbounded_body_read(node, source),
+        "sprintf-sink" => sprintf_sink(node, source),
+        "regexp-in-function" => regexp_in_function(node, source),
+        "error-string-style" => error_string_style(node, source).map(|(detail, _)| detail),
+        "unchecked-map-chain" => unchecked_map_chain(node, source),
+        "error-assertion" => error_assertion(node, source),
+        "swallowed-error" => swallowed_error(node, source),
+        "uniform-returns" => uniform_returns(node, source),
+        "append-param-alias" => append_param_alias(node, source),
+        "uniform-any-slice" => uniform_any_slice(node, source),
+        "logged-error-return" => logged_error_return(node, source),
+        "immediate-join" => immediate_join(node, source),
+        "goroutine-panic" => goroutine_panic(node, source),
+        "waitgroup-misuse" => waitgroup_misuse(node, source),
+        "noop-recover" => noop_recover(node, source),
+        "inconsistent-errors" => inconsistent_errors(node, source),
+        "unread-error" => unread_error(node, source),
+        "ignored-parse-error" => ignored_parse_error(node, source),
+        "unclosed-resource" => unclosed_resource(node, source),
+        "untagged-json-field" => untagged_json_field(node, source),
+        "hardcoded-address" => hardcoded_address(node, source),
+        "not-generated" => (!is_generated(source)).then(String::new),
+        // Resolved across the package once every file is scanned.
+        "package-unreferenced" => Some(String::new()),
+        "package-unclosed" => ranged_channel(node, source),
+        "package-erased" => erased_flow(node, source)
+            .filter(|f| !f.passes_to.is_empty())
+            .map(|_| String::new()),
+        "package-type-switch" => type_switch(node, source).map(|_| String::new()),
+        "package-recover-ratio" => Some(String::new()),
+        _ => None,
+    }
+}
+
+/// Replacement text from checks that can fix what they find, with the node
+/// it replaces: `node` itself or a statement around it.
+pub(crate) fn replacement<'t>(
+    name: &str,
+    node: &Node<'t>,
+    source: &str,
+) -> Option<(Node<'t>, String)> {
+    match name {
+        "error-string-style" => error_string_style(node, source).map(|(_, fixed)| (*node, fixed)),
+        "error-assertion" => error_assertion_fix(node, source),
+        _ => None,
+    }
+}
+
+/// Loop variables a `go func() { ... }()` closure reads without taking them
+/// as parameters.
+///
+/// Before Go 1.22 every iteration shares one variable, so the goroutine may
+/// see a later value. A parameter of the closure or a `x := x` copy in the
+/// loop body before the `go` statement shadows the variable and is fine.
+fn loop_var_capture(node: &Node, source: &str) -> Option<String> {
+    let closure = node
+        .named_child(0)
+        .filter(|call| call.kind() == "call_expression")
+        .and_then(|call| call.child_by_field_name("function"))
+        .filter(|f| f.kind() == "func_literal")?;
+
+    let params = closure
+        .child_by_field_name("parameters")
+        .map(|p| declared_names(&p, source))
+        .unwrap_or_default();
+    let body = closure.child_by_field_name("body")?;
+
+    let mut captured = Vec::new();
+    let mut inner = *node;
+    while let Some(parent) = inner.parent() {
+        match parent.kind() {
+            "function_declaration" | "method_declaration" | "func_literal" => break,
+            "for_statement" => {
+                for var in loop_vars(&parent, source) {
+                    if params.contains(&var)
+                        || copied_before(&parent, node, &var, source)
+                        || captured.contains(&var)
+                    {
+                        continue;
+                    }
+                    if uses_identifier(&body, &var, source) {
+                        captured.push(var);
+                    }
+                }
+            }
+            _ => {}
+        }
+        inner = parent;
+    }
+
+    if captured.is_empty() {
+        return None;
+    }
+    let names: Vec<String> = captured.iter().map(|v| format!("`{}`", v)).collect();
+    Some(format!("captures {}", names.join(", ")))
+}
+
+/// The first call or assertion in a `go func() { ... }()` body that can
+/// panic, when no deferred call in the body mentions `recover`.
+///
+/// Panic sources are `panic`, `log.Panic*`, `Must*` helpers such as
+/// `regexp.MustCompile`, and type assertions not written in the two-value
+/// `v, ok :=` form. Nested function literals are skipped, since they may
+/// run elsewhere.
+fn goroutine_panic(node: &Node, source: &str) -> Option<String> {
+    let body = node
+        .named_child(0)
+        .and_then(|call| call.child_by_field_name("function"))
+        .filter(|f| f.kind() == "func_literal")
+        .and_then(|closure| closure.child_by_field_name("body"))?;
+    let text = |n: Node| n.utf8_text(source.as_bytes()).unwrap_or("");
+
+    let mut cursor = body.walk();
+    let recovers = body.named_children(&mut cursor).any(|stmt| {
+        stmt.kind() == "defer_statement" && text(stmt).to_lowercase().contains("recover")
+    });
+    if recovers {
+        return None;
+    }
+
+    let mut sources = Vec::new();
+    let mut nodes = vec![body];
+    while let Some(current) = nodes.pop() {
+        match current.kind() {
+            "func_literal" => continue,
+            "call_expression" => {
+                let function = current.child_by_field_name("function");
+                let panics = function.is_some_and(|f| match f.kind() {
+                    "identifier" => text(f) == "panic",
+                    "selector_expression" => {
+                        let field = f.child_by_field_name("field").map(text).unwrap_or("");
+                        let operand = f.child_by_field_name("operand").map(text);
+                        field.starts_with("Must")
+                            || (operand == Some("log") && field.starts_with("Panic"))
+                    }
+                    _ => false,
+                });
+                if let (true, Some(function)) = (panics, function) {
+                    sources.push((current, format!("`{}`", text(function))));
+                }
+            }
+            "type_assertion_expression" => {
+                let checked = current
+                    .parent()
+                    .filter(|p| p.kind() == "expression_list")
+                    .and_then(|p| p.parent())
+                    .filter(|d| {
+                        matches!(d.kind(), "short_var_declaration" | "assignment_statement")
+                    })
+                    .and_then(|d| d.child_by_field_name("left"))
+                    .is_some_and(|left| left.named_child_count() == 2);
+                if !checked {
+                    sources.push((current, format!("unchecked assertion `{}`", text(current))));
+                }
+            }
+            _ => {}
+        }
+        let mut inner = current.walk();
+        nodes.extend(current.named_children(&mut inner));
+    }
+
+    let (at, what) = sources.into_iter().min_by_key(|(n, _)| n.start_byte())?;
+    Some(format!("{} on line {}", what, at.start_position().row + 1))
+}
+
+/// A `go func() { ... }()` that calls `Add` on a WaitGroup it is counted
+/// by, or that follows an `Add` with no `Done` on the same WaitGroup.
+///
+/// WaitGroups are names the enclosing function calls `Wait()` on and
+/// names declared with a `WaitGroup` type, including struct fields of the
+/// file. A closure passing the WaitGroup on, as in `work(&wg)`, may call
+/// `Done` elsewhere and is not reported; neither is one with another `go`
+/// statement between it and the `Add`, which the `Add` may be for.
+fn waitgroup_misuse(node: &Node, source: &str) -> Option<String> {
+    let body = node
+        .named_child(0)
+        .and_then(|call| call.child_by_field_name("function"))
+        .filter(|f| f.kind() == "func_literal")
+        .and_then(|closure| closure.child_by_field_name("body"))?;
+    let function = std::iter::successors(node.parent(), |n| n.parent()).find(|n| {
+        matches!(
+            n.kind(),
+            "function_declaration" | "method_declaration" | "func_literal"
+        )
+    })?;
+    let line = |n: &Node| n.start_position().row + 1;
+
+    let mut groups = Vec::new();
+    waitgroups(&function, source, &mut groups);
+    let mut root = function;
+    while let Some(parent) = root.parent() {
+        root = parent;
+    }
+    let mut fields = Vec::new();
+    waitgroup_fields(&root, source, &mut fields);
+    let is_group = |name: &str| {
+        groups.iter().any(|g| g == name)
+            || name
+                .rsplit_once('.')
+                .is_some_and(|(_, field)| fields.iter().any(|f| f == field))
+    };
+
+    let added_inside = method_calls(&body, "Add", source, false)
+        .into_iter()
+        .find(|(_, name)| is_group(name));
+    if let Some((add, name)) = added_inside {
+        return Some(format!(
+            "`{}.Add` on line {} runs inside the goroutine it counts, so Wait can return first",
+            name,
+            line(&add)
+        ));
+    }
+
+    let (add, name) = method_calls(&function, "Add", source, true)
+        .into_iter()
+        .rev()
+        .find(|(call, name)| call.end_byte() <= node.start_byte() && is_group(name))?;
+    let mut launches = Vec::new();
+    collect_kind(&function, "go_statement", &mut launches);
+    if launches
+        .iter()
+        .any(|g| g.start_byte() > add.end_byte() && g.start_byte() < node.start_byte())
+    {
+        return None;
+    }
+    let done = method_calls(&body, "Done", source, true)
+        .iter()
+        .any(|(_, n)| *n == name);
+    let address = format!("&{}", name);
+    let mut calls = Vec::new();
+    collect_kind(&body, "call_expression", &mut calls);
+    let passed = calls.iter().any(|call| {
+        call.child_by_field_name("arguments").is_some_and(|args| {
+            let mut cursor = args.walk();
+            let found = args.named_children(&mut cursor).any(|a| {
+                let text = a.utf8_text(source.as_bytes()).unwrap_or("");
+                text == name || text == address
+            });
+            found
+        })
+    });
+    if done || passed {
+        return None;
+    }
+    Some(format!(
+        "no `{}.Done` for the `{}.Add` on line {}, so Wait blocks forever",
+        name,
+        name,
+        line(&add)
+    ))
+}
+
+/// Calls `x.<method>(...)` under `node` with the text of `x`, in source
+/// order. Function literals are skipped unless `closures` is set.
+fn method_calls<'t>(
+    node: &Node<'t>,
+    method: &str,
+    source: &str,
+    closures: bool,
+) -> Vec<(Node<'t>, String)> {
+    let mut found = Vec::new();
+    let mut nodes = vec![*node];
+    while let Some(current) = nodes.pop() {
+        if current.kind() == "func_literal" && !closures && current.id() != node.id() {
+            continue;
+        }
+        if current.kind() == "call_expression" {
+            let selector = current
+                .child_by_field_name("function")
+                .filter(|f| f.kind() == "selector_expression");
+            let field = selector.and_then(|f| f.child_by_field_name("field"));
+            let operand = selector.and_then(|f| f.child_by_field_name("operand"));
+            if let (Some(field), Some(operand)) = (field, operand) {
+                if field.utf8_text(source.as_bytes()) == Ok(method) {
+                    if let Ok(text) = operand.utf8_text(source.as_bytes()) {
+                        found.push((current, text.to_string()));
+                    }
+                }
+            }
+        }
+        let mut cursor = current.walk();
+        nodes.extend(current.named_children(&mut cursor));
+    }
+    found.sort_by_key(|(call, _)| call.start_byte());
+    found
+}
+
+/// Names under `function` that are called with `Wait()` or declared with a
+/// `WaitGroup` type.
+fn waitgroups(function: &Node, source: &str, groups: &mut Vec<String>) {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).unwrap_or("");
+    for (call, name) in method_calls(function, "Wait", source, true) {
+        if call
+            .child_by_field_name("arguments")
+            .is_some_and(|a| a.named_child_count() == 0)
+        {
+            groups.push(name);
+        }
+    }
+    let mut nodes = vec![*function];
+    while let Some(current) = nodes.pop() {
+        match current.kind() {
+            "var_spec" | "parameter_declaration" => {
+                if current
+                    .child_by_field_name("type")
+                    .is_some_and(|t| text(t).contains("WaitGroup"))
+                {
+                    let mut cursor = current.walk();
+                    let names = current.children_by_field_name("name", &mut cursor);
+                    groups.extend(names.map(|n| text(n).to_string()));
+                }
+            }
+            "short_var_declaration" => {
+                if current
+                    .child_by_field_name("right")
+                    .is_some_and(|r| text(r).contains("WaitGroup"))
+                {
+                    if let Some(left) = current.child_by_field_name("left") {
+                        groups.extend(identifiers(&left, source));
+                    }
+                }
+            }
+            _ => {}
+        }
+        let mut cursor = current.walk();
+        nodes.extend(current.named_children(&mut cursor));
+    }
+}
+
+/// Struct fields under `node` declared with a `WaitGroup` type.
+fn waitgroup_fields(node: &Node, source: &str, fields: &mut Vec<String>) {
+    if node.kind() == "field_declaration" {
+        let waitgroup = node
+            .child_by_field_name("type")
+            .and_then(|t| t.utf8_text(source.as_bytes()).ok())
+            .is_some_and(|t| t.contains("WaitGroup"));
+        if waitgroup {
+            let mut cursor = node.walk();
+            for name in node.children_by_field_name("name", &mut cursor) {
+                fields.extend(name.utf8_text(source.as_bytes()).ok().map(str::to_string));
+            }
+        }
+        return;
+    }
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        waitgroup_fields(&child, source, fields);
+    }
+}
+
+/// A `recover()` call that cannot stop a panic, because the function
+/// calling it is not the one a `defer` runs.
+///
+/// The call works in the literal of `defer func() { ... }()`, in a literal
+/// assigned to a name the enclosing function later defers, and in a
+/// declared function the file defers by name. Declared functions with
+/// `recover` or `panic` in their name are taken to be helpers deferred
+/// elsewhere. `defer recover()` is flagged too, since the runtime calls it
+/// rather than a deferred function.
+fn noop_recover(node: &Node, source: &str) -> Option<String> {
+    if node.parent()?.kind() == "defer_statement" {
+        return Some("`defer recover()` is not called by a deferred function".to_string());
+    }
+    let function = std::iter::successors(node.parent(), |n| n.parent()).find(|n| {
+        matches!(
+            n.kind(),
+            "function_declaration" | "method_declaration" | "func_literal"
+        )
+    })?;
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    if function.kind() != "func_literal" {
+        let name = function.child_by_field_name("name").and_then(text)?;
+        let lower = name.to_ascii_lowercase();
+        if lower.contains("recover") || lower.contains("panic") || defers(source, name) {
+            return None;
+        }
+        return Some(format!("`{}` is not deferred in this file", name));
+    }
+
+    let call = function
+        .parent()
+        .filter(|c| c.kind() == "call_expression")
+        .filter(|c| c.child_by_field_name("function") == Some(function));
+    if call
+        .and_then(|c| c.parent())
+        .is_some_and(|p| p.kind() == "defer_statement")
+    {
+        return None;
+    }
+    if call
+        .and_then(|c| c.parent())
+        .is_some_and(|p| p.kind() == "go_statement")
+    {
+        return Some("the goroutine's function is not deferred".to_string());
+    }
+    let stored = function
+        .parent()
+        .filter(|l| l.kind() == "expression_list" && l.named_child_count() == 1)
+        .and_then(|l| l.parent())
+        .filter(|s| matches!(s.kind(), "short_var_declaration" | "assignment_statement"))
+        .and_then(|s| s.child_by_field_name("left"))
+        .map(|left| identifiers(&left, source));
+    if let Some([name]) = stored.as_deref() {
+        let outer = std::iter::successors(function.parent(), |n| n.parent()).find(|n| {
+            matches!(
+                n.kind(),
+                "function_declaration" | "method_declaration" | "func_literal"
+            )
+        });
+        let scope = outer.and_then(text).unwrap_or(source);
+        if defers(scope, name) {
+            return None;
+        }
+    }
+    Some("the function literal calling it is not deferred".to_string())
+}
+
+/// Whether a line of `text` defers a call to `name`, or to a method `name`.
+fn defers(text: &str, name: &str) -> bool {
+    let call = format!("{}(", name);
+    let method = format!(".{}(", name);
+    text.lines().any(|line| {
+        line.trim_start()
+            .strip_prefix("defer ")
+            .is_some_and(|rest| rest.starts_with(&call) || rest.contains(&method))
+    })
+}
+
+/// A `go func() { ... }()` that the very next statement waits for, with a
+/// receive from a channel or a `Wait()` call on a name the closure uses,
+/// either on its own or as the value assigned or returned.
+///
+/// Only the function's one `go` statement counts, and not inside a loop,
+/// so nothing else can run while the caller waits.
+fn immediate_join(node: &Node, source: &str) -> Option<String> {
+    let body = node
+        .named_child(0)
+        .and_then(|call| call.child_by_field_name("function"))
+        .and_then(|closure| closure.child_by_field_name("body"))?;
+
+    let function = std::iter::successors(node.parent(), |n| n.parent())
+        .take_while(|n| n.kind() != "for_statement")
+        .find(|n| {
+            matches!(
+                n.kind(),
+                "function_declaration" | "method_declaration" | "func_literal"
+            )
+        })?;
+    if count_kind(&function, "go_statement") != 1 {
+        return None;
+    }
+
+    let mut next = node.next_named_sibling();
+    while next.is_some_and(|n| n.kind() == "comment") {
+        next = next.and_then(|n| n.next_named_sibling());
+    }
+    let next = next?;
+    let expression = match next.kind() {
+        "expression_statement" => next.named_child(0),
+        "short_var_declaration" | "assignment_statement" => next
+            .child_by_field_name("right")
+            .filter(|r| r.named_child_count() == 1)
+            .and_then(|r| r.named_child(0)),
+        "return_statement" => next
+            .named_child(0)
+            .filter(|r| r.named_child_count() == 1)
+            .and_then(|r| r.named_child(0)),
+        _ => None,
+    }?;
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let joined = match expression.kind() {
+        "unary_expression"
+            if expression.child_by_field_name("operator").and_then(text) == Some("<-") =>
+        {
+            expression.child_by_field_name("operand")
+        }
+        "call_expression" => expression
+            .child_by_field_name("function")
+            .filter(|f| f.kind() == "selector_expression")
+            .filter(|f| f.child_by_field_name("field").and_then(text) == Some("Wait"))
+            .filter(|_| {
+                expression
+                    .child_by_field_name("arguments")
+                    .is_some_and(|a| a.named_child_count() == 0)
+            })
+            .and_then(|f| f.child_by_field_name("operand")),
+        _ => None,
+    }
+    .filter(|n| n.kind() == "identifier")
+    .and_then(text)?;
+    if !uses_identifier(&body, joined, source) {
+        return None;
+    }
+    Some(format!("joined by `{}` right after", text(next)?))
+}
+
+/// Number of nodes of `kind` in the tree under `node`, `node` included.
+fn count_kind(node: &Node, kind: &str) -> usize {
+    let mut cursor = node.walk();
+    let nested: usize = node
+        .named_children(&mut cursor)
+        .map(|child| count_kind(&child, kind))
+        .sum();
+    nested + usize::from(node.kind() == kind)
+}
+
+/// Nodes of `kind` under `node`, itself included.
+fn collect_kind<'t>(node: &Node<'t>, kind: &str, found: &mut Vec<Node<'t>>) {
+    if node.kind() == kind {
+        found.push(*node);
+    }
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        collect_kind(&child, kind, found);
+    }
+}
+
+/// A callee whose error the function handles in more than one way, e.g.
+/// returned at one call site and assigned to `_` at another.
+///
+/// A site counts as `returned`, `panicked` or `logged` by what the
+/// `if err != nil` right after it does, and as `ignored` when the error
+/// goes to `_` or the call is a bare statement. Sites checked later, or
+/// not at all, carry no verdict. Deferred calls are not sites. Reports the
+/// first callee in source order.
+fn inconsistent_errors(node: &Node, source: &str) -> Option<String> {
+    let mut sites: Vec<(String, usize, &'static str)> = Vec::new();
+    error_sites(&node.child_by_field_name("body")?, source, &mut sites);
+
+    let mut callees: Vec<&str> = Vec::new();
+    for (callee, _, _) in &sites {
+        if !callees.contains(&callee.as_str()) {
+            callees.push(callee);
+        }
+    }
+    let callee = callees.into_iter().find(|callee| {
+        let mut kinds = sites.iter().filter(|s| s.0 == *callee).map(|s| s.2);
+        let first = kinds.next();
+        kinds.any(|k| Some(k) != first)
+    })?;
+
+    let divergent: Vec<String> = sites
+        .iter()
+        .filter(|s| s.0 == callee)
+        .map(|(_, line, kind)| format!("{} on line {}", kind, line))
+        .collect();
+    Some(format!("`{}` errors: {}", callee, divergent.join(", ")))
+}
+
+/// Collect the error-handling sites in the blocks under `node`, outside
+/// nested function literals, as `(callee, line, verdict)`.
+fn error_sites(node: &Node, source: &str, sites: &mut Vec<(String, usize, &'static str)>) {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    if node.kind() == "block" {
+        let statements = statements(node);
+        for (i, statement) in statements.iter().enumerate() {
+            let site = match statement.kind() {
+                "expression_statement" => statement
+                    .named_child(0)
+                    .filter(|c| c.kind() == "call_expression")
+                    .map(|call| (call, "ignored")),
+                "short_var_declaration" | "assignment_statement" => {
+                    assigned_call(statement, source).and_then(|(call, err)| {
+                        if err == "_" {
+                            return Some((call, "ignored"));
+                        }
+                        let check = statements.get(i + 1)?;
+                        Some((call, checked_verdict(check, err, source)?))
+                    })
+                }
+                "if_statement" => statement
+                    .child_by_field_name("initializer")
+                    .and_then(|init| {
+                        let (call, err) = assigned_call(&init, source)?;
+                        Some((call, checked_verdict(statement, err, source)?))
+                    }),
+                _ => None,
+            };
+            if let Some((call, verdict)) = site {
+                if let Some(callee) = call.child_by_field_name("function").and_then(text) {
+                    sites.push((callee.to_string(), call.start_position().row + 1, verdict));
+                }
+            }
+        }
+    }
+    let mut cursor = node.walk();
+    let children: Vec<Node> = node
+        .named_children(&mut cursor)
+        .filter(|c| c.kind() != "func_literal")
+        .collect();
+    for child in children {
+        error_sites(&child, source, sites);
+    }
+}
+
+/// The call of `x, err := f()` or `err = f()` and the name its last result
+/// goes to, when that name looks like an error or is `_`.
+fn assigned_call<'a>(statement: &Node<'a>, source: &'a str) -> Option<(Node<'a>, &'a str)> {
+    let left = statement.child_by_field_name("left")?;
+    let call = statement
+        .child_by_field_name("right")
+        .filter(|r| r.named_child_count() == 1)
+        .and_then(|r| r.named_child(0))
+        .filter(|c| c.kind() == "call_expression")?;
+    let last = left.named_child(left.named_child_count().checked_sub(1)?)?;
+    let name = last.utf8_text(source.as_bytes()).ok()?;
+    let is_error = name == "_" || name.ends_with("err") || name.ends_with("Err");
+    is_error.then_some((call, name))
+}
+
+/// How `if <err> != nil { ... }` deals with the error, or None when
+/// `statement` is not such a check.
+fn checked_verdict(statement: &Node, err: &str, source: &str) -> Option<&'static str> {
+    if statement.kind() != "if_statement" {
+        return None;
+    }
+    let condition = statement.child_by_field_name("condition")?;
+    let condition = condition.utf8_text(source.as_bytes()).ok()?;
+    if condition.replace(' ', "") != format!("{}!=nil", err) {
+        return None;
+    }
+    let consequence = statement.child_by_field_name("consequence")?;
+    let body = consequence.utf8_text(source.as_bytes()).ok()?;
+    let verdict = if body.contains("panic(") || body.contains(".Fatal") || body.contains("os.Exit(")
+    {
+        "panicked"
+    } else if body.contains("return") {
+        "returned"
+    } else {
+        "logged"
+    };
+    Some(verdict)
+}
+
+/// Standard library calls whose last result is an error, with their number
+/// of results.
+const ERROR_RESULTS: &[(&str, usize)] = &[
+    ("os.Chdir", 1),
+    ("os.Mkdir", 1),
+    ("os.MkdirAll", 1),
+    ("os.Remove", 1),
+    ("os.RemoveAll", 1),
+    ("os.Rename", 1),
+    ("os.Setenv", 1),
+    ("os.WriteFile", 1),
+    ("json.Unmarshal", 1),
+    ("os.Create", 2),
+    ("os.Open", 2),
+    ("os.ReadFile", 2),
+    ("io.ReadAll", 2),
+    ("io.Copy", 2),
+    ("json.Marshal", 2),
+    ("strconv.Atoi", 2),
+    ("strconv.ParseBool", 2),
+    ("strconv.ParseFloat", 2),
+    ("strconv.ParseInt", 2),
+    ("strconv.ParseUint", 2),
+    ("time.Parse", 2),
+    ("url.Parse", 2),
+    ("http.NewRequest", 2),
+    ("http.NewRequestWithContext", 2),
+];
+
+/// Node kinds whose children run one statement after another.
+const STATEMENT_CONTAINERS: &[&str] = &[
+    "block",
+    "statement_list",
+    "expression_case",
+    "default_case",
+    "type_case",
+    "communication_case",
+];
+
+/// An error assigned to a variable that is overwritten or left behind
+/// before anything reads it, whatever the variable is called.
+///
+/// There is no type checker, so the call must be one of [`ERROR_RESULTS`]
+/// or a function or method of the same file whose last result is `error`.
+/// Later statements are followed out of enclosing blocks, and any read,
+/// even in a branch that may not run, counts. Loops, named results and
+/// closures that mention the name could read it later and are skipped.
+fn unread_error(node: &Node, source: &str) -> Option<String> {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let left = node.child_by_field_name("left")?;
+    let call = node
+        .child_by_field_name("right")
+        .filter(|r| r.named_child_count() == 1)
+        .and_then(|r| r.named_child(0))
+        .filter(|c| c.kind() == "call_expression")?;
+    let callee = call.child_by_field_name("function")?;
+    let count = left.named_child_count();
+    let last = left
+        .named_child(count.checked_sub(1)?)
+        .filter(|n| n.kind() == "identifier")?;
+    let name = text(last).filter(|n| *n != "_")?;
+    let in_block = node
+        .parent()
+        .is_some_and(|p| STATEMENT_CONTAINERS.contains(&p.kind()));
+    if !in_block || error_results(&callee, source)? != count {
+        return None;
+    }
+
+    let function = std::iter::successors(node.parent(), |n| n.parent()).find(|n| {
+        matches!(
+            n.kind(),
+            "function_declaration" | "method_declaration" | "func_literal"
+        )
+    })?;
+    let named_result = function
+        .child_by_field_name("result")
+        .filter(|r| r.kind() == "parameter_list")
+        .is_some_and(|r| declared_names(&r, source).iter().any(|n| n == name));
+    if named_result || closure_mentions(&function.child_by_field_name("body")?, name, source) {
+        return None;
+    }
+
+    let mut current = *node;
+    while current.id() != function.id() {
+        let parent = current.parent()?;
+        if parent.kind() == "for_statement" {
+            return None;
+        }
+        if STATEMENT_CONTAINERS.contains(&parent.kind()) {
+            let mut sibling = current.next_named_sibling();
+            while let Some(statement) = sibling {
+                let assigned = matches!(
+                    statement.kind(),
+                    "short_var_declaration" | "assignment_statement"
+                ) && statement
+                    .child_by_field_name("left")
+                    .is_some_and(|l| identifiers(&l, source).iter().any(|n| n == name))
+                    && !statement
+                        .child_by_field_name("right")
+                        .is_some_and(|r| uses_identifier(&r, name, source));
+                if assigned {
+                    return Some(format!(
+                        "`{}` from `{}` is overwritten on line {}",
+                        name,
+                        text(callee)?,
+                        statement.start_position().row + 1
+                    ));
+                }
+                if uses_identifier(&statement, name, source) {
+                    return None;
+                }
+                sibling = statement.next_named_sibling();
+            }
+        }
+        current = parent;
+    }
+    Some(format!("`{}` from `{}` is never read", name, text(callee)?))
+}
+
+/// `strconv` parsers and the value they return when the input does not
+/// parse.
+const PARSE_ZERO_VALUES: &[(&str, &str)] = &[
+    ("strconv.Atoi", "0"),
+    ("strconv.ParseBool", "false"),
+    ("strconv.ParseFloat", "0"),
+    ("strconv.ParseInt", "0"),
+    ("strconv.ParseUint", "0"),
+];
+
+/// A `strconv` parse whose error is assigned to `_`, so bad input carries
+/// on as a zero value.
+///
+/// Uses the same discard test as [`swallowed_error`], but on each
+/// assignment, whatever the enclosing function returns. The detail names
+/// the variable and what it silently becomes.
+fn ignored_parse_error(node: &Node, source: &str) -> Option<String> {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let callee = blank_discard(node, source)?.child_by_field_name("function")?;
+    let called = text(callee)?;
+    let &(_, zero) = PARSE_ZERO_VALUES.iter().find(|(name, _)| *name == called)?;
+    let left = node.child_by_field_name("left")?;
+    if error_results(&callee, source)? != left.named_child_count() {
+        return None;
+    }
+    // `_, _ =` throws the value away too, so nothing runs on with a zero
+    let value = left.named_child(0).and_then(text).filter(|v| *v != "_")?;
+    Some(format!(
+        "`{}` is `{}` whenever `{}` fails",
+        value, zero, called
+    ))
+}
+
+/// The number of results of `callee` when its last one is an error.
+fn error_results(callee: &Node, source: &str) -> Option<usize> {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let called = text(*callee)?;
+    if let Some(&(_, count)) = ERROR_RESULTS.iter().find(|(name, _)| *name == called) {
+        return Some(count);
+    }
+    // Functions by name, methods by their selector's field
+    let (kind, name) = match callee.kind() {
+        "identifier" => ("function_declaration", called),
+        "selector_expression" => (
+            "method_declaration",
+            callee.child_by_field_name("field").and_then(text)?,
+        ),
+        _ => return None,
+    };
+    let root = std::iter::successors(Some(*callee), |n| n.parent()).last()?;
+    let mut cursor = root.walk();
+    let declaration = root
+        .named_children(&mut cursor)
+        .find(|d| d.kind() == kind && d.child_by_field_name("name").and_then(text) == Some(name))?;
+    let result = declaration.child_by_field_name("result")?;
+    if result.kind() != "parameter_list" {
+        return (text(result) == Some("error")).then_some(1);
+    }
+    let mut cursor = result.walk();
+    let params: Vec<Node> = result.named_children(&mut cursor).collect();
+    let last_type = params.last()?.child_by_field_name("type").and_then(text);
+    if last_type != Some("error") {
+        return None;
+    }
+    let count = params
+        .iter()
+        .map(|p| {
+            let mut inner = p.walk();
+            p.children_by_field_name("name", &mut inner).count().max(1)
+        })
+        .sum();
+    Some(count)
+}
+
+/// Whether a function literal under `node` mentions `name`.
+fn closure_mentions(node: &Node, name: &str, source: &str) -> bool {
+    if node.kind() == "func_literal" {
+        return uses_identifier(node, name, source);
+    }
+    let mut cursor = node.walk();
+    let found = node
+        .named_children(&mut cursor)
+        .any(|child| closure_mentions(&child, name, source));
+    found
+}
+
+/// Whether `source` carries Go's `// Code generated ... DO NOT EDIT.` line.
+fn is_generated(source: &str) -> bool {
+    source.lines().any(|line| {
+        line.starts_with("// Code generated ") && line.trim_end().ends_with(" DO NOT EDIT.")
+    })
+}
+
+/// A `ReadAll(x.Body)` call where `x` is an HTTP request and nothing in the
+/// enclosing function limits `x.Body` first.
+///
+/// There is no type checker, so `x` counts as a request when an enclosing
+/// function declares it as a `*http.Request` parameter. When `x` is not a
+/// parameter at all, the usual names `r`, `req` and `request` are accepted.
+/// Any earlier line of the declaring function that passes `x.Body` to
+/// `MaxBytesReader` or `LimitReader` counts as a limit.
+fn unbounded_body_read(node: &Node, source: &str) -> Option<String> {
+    let arg = node
+        .child_by_field_name("arguments")
+        .filter(|args| args.named_child_count() == 1)
+        .and_then(|args| args.named_child(0))
+        .filter(|a| a.kind() == "selector_expression")?;
+    let field = arg.child_by_field_name("field")?;
+    let operand = arg
+        .child_by_field_name("operand")
+        .filter(|o| o.kind() == "identifier")?;
+    if field.utf8_text(source.as_bytes()).ok()? != "Body" {
+        return None;
+    }
+    let name = operand.utf8_text(source.as_bytes()).ok()?;
+
+    let mut is_request = None;
+    let mut function = None;
+    let mut current = *node;
+    while let Some(parent) = current.parent() {
+        if matches!(
+            parent.kind(),
+            "function_declaration" | "method_declaration" | "func_literal"
+        ) {
+            function = Some(parent);
+            if let Some(ty) = parameter_type(&parent, name, source) {
+                is_request = Some(ty.trim_start_matches('*') == "http.Request");
+                break;
+            }
+        }
+        current = parent;
+    }
+    if !is_request.unwrap_or(matches!(name, "r" | "req" | "request")) {
+        return None;
+    }
+
+    let body = format!("{}.Body", name);
+    let start = function.map(|f| f.start_byte()).unwrap_or(0);
+    let limited = source[start..node.start_byte()].lines().any(|line| {
+        line.contains(&body) && (line.contains("MaxBytesReader(") || line.contains("LimitReader("))
+    });
+    if limited {
+        return None;
+    }
+    Some(format!("reads `{}`", body))
+}
+
+/// Calls taking a file path, for the `sprintf-sink` check.
+const PATH_SINKS: &[&str] = &[
+    "os.Open",
+    "os.OpenFile",
+    "os.Create",
+    "os.ReadFile",
+    "os.WriteFile",
+    "os.Remove",
+    "os.RemoveAll",
+    "os.Mkdir",
+    "os.MkdirAll",
+    "ioutil.ReadFile",
+    "ioutil.WriteFile",
+    "filepath.Join",
+    "path.Join",
+    "http.ServeFile",
+];
+
+/// A `fmt.Sprintf` call formatting request data whose result becomes a
+/// file path or a map key.
+///
+/// Request data is a parameter an enclosing function declares as
+/// `*http.Request`, or a local assigned earlier from an expression using
+/// one. The result counts when it is an argument of one of the
+/// [`PATH_SINKS`] or an index, directly or through the one name it is
+/// assigned to; a string index is always a map key. The detail names the
+/// first sink and its line.
+fn sprintf_sink(node: &Node, source: &str) -> Option<String> {
+    let mut function = None;
+    let mut tainted = Vec::new();
+    for ancestor in std::iter::successors(node.parent(), |n| n.parent()) {
+        if !matches!(
+            ancestor.kind(),
+            "function_declaration" | "method_declaration" | "func_literal"
+        ) {
+            continue;
+        }
+        function.get_or_insert(ancestor);
+        let Some(params) = ancestor.child_by_field_name("parameters") else {
+            continue;
+        };
+        for name in declared_names(&params, source) {
+            let ty = parameter_type(&ancestor, &name, source).unwrap_or_default();
+            if ty == "*http.Request" {
+                tainted.push(name);
+            }
+        }
+    }
+    let function = function?;
+    if tainted.is_empty() {
+        return None;
+    }
+
+    let mut assignments = Vec::new();
+    collect_kind(&function, "short_var_declaration", &mut assignments);
+    collect_kind(&function, "assignment_statement", &mut assignments);
+    assignments.sort_by_key(|a| a.start_byte());
+    for assignment in assignments
+        .iter()
+        .filter(|a| a.end_byte() <= node.start_byte())
+    {
+        let (Some(left), Some(right)) = (
+            assignment.child_by_field_name("left"),
+            assignment.child_by_field_name("right"),
+        ) else {
+            continue;
+        };
+        if tainted.iter().any(|t| uses_identifier(&right, t, source)) {
+            for name in identifiers(&left, source) {
+                if name != "_" && !tainted.contains(&name) {
+                    tainted.push(name);
+                }
+            }
+        }
+    }
+
+    let args = node.child_by_field_name("arguments")?;
+    let mut cursor = args.walk();
+    let untrusted = args
+        .named_children(&mut cursor)
+        .skip(1)
+        .any(|arg| tainted.iter().any(|t| uses_identifier(&arg, t, source)));
+    if !untrusted {
+        return None;
+    }
+    if let Some(sink) = path_or_key(node, source) {
+        return Some(sink);
+    }
+
+    let list = node
+        .parent()
+        .filter(|l| l.kind() == "expression_list" && l.named_child_count() == 1)?;
+    let statement = list.parent().filter(|s| {
+        matches!(s.kind(), "short_var_declaration" | "assignment_statement")
+            && s.child_by_field_name("right") == Some(list)
+    })?;
+    let names = identifiers(&statement.child_by_field_name("left")?, source);
+    let [name] = names.as_slice() else {
+        return None;
+    };
+    let mut uses = Vec::new();
+    collect_kind(&function, "identifier", &mut uses);
+    uses.iter()
+        .filter(|u| u.start_byte() >= statement.end_byte())
+        .filter(|u| u.utf8_text(source.as_bytes()) == Ok(name.as_str()))
+        .find_map(|u| path_or_key(u, source))
+}
+
+/// What `value` is used as when it is a path argument or a map key, with
+/// the line, e.g. "reaches `os.Open` on line 12".
+fn path_or_key(value: &Node, source: &str) -> Option<String> {
+    let parent = value.parent()?;
+    let line = value.start_position().row + 1;
+    match parent.kind() {
+        "index_expression" if parent.child_by_field_name("index") == Some(*value) => {
+            Some(format!("is a map key on line {}", line))
+        }
+        "argument_list" => {
+            let callee = parent
+                .parent()?
+                .child_by_field_name("function")?
+                .utf8_text(source.as_bytes())
+                .ok()?;
+            PATH_SINKS
+                .contains(&callee)
+                .then(|| format!("reaches `{}` on line {}", callee, line))
+        }
+        _ => None,
+    }
+}
+
+/// Constructors whose first result must be closed, and whether the closer
+/// is its `Body` field rather than the value itself. Names starting with
+/// `.` are methods on any receiver.
+const RESOURCE_CONSTRUCTORS: &[(&str, bool)] = &[
+    ("os.Open", false),
+    ("os.OpenFile", false),
+    ("os.Create", false),
+    ("http.Get", true),
+    ("http.Head", true),
+    ("http.Post", true),
+    ("http.PostForm", true),
+    (".Do", true),
+    (".Query", false),
+    (".QueryContext", false),
+];
+
+/// `x, err := os.Open(...)` (or another [`RESOURCE_CONSTRUCTORS`] call)
+/// where the enclosing function never calls `x.Close()`, or
+/// `x.Body.Close()` for HTTP responses.
+///
+/// Without type information the value is followed by name. Any use of `x`
+/// other than a field or method access (returning it, passing it on,
+/// storing it) hands it to code that may close it, so the match is
+/// dropped; so is passing `x.Body` to a function with `close` in its name.
+fn unclosed_resource(node: &Node, source: &str) -> Option<String> {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let call = node
+        .child_by_field_name("right")
+        .filter(|r| r.named_child_count() == 1)
+        .and_then(|r| r.named_child(0))
+        .filter(|c| c.kind() == "call_expression")?;
+    let callee = call.child_by_field_name("function").and_then(text)?;
+    let has_arguments = call
+        .child_by_field_name("arguments")
+        .is_some_and(|a| a.named_child_count() > 0);
+    // Methods only count with arguments, so `u.Query()` on a URL does not
+    let &(_, body) = RESOURCE_CONSTRUCTORS.iter().find(|(constructor, _)| {
+        if constructor.starts_with('.') {
+            has_arguments && callee.len() > constructor.len() && callee.ends_with(constructor)
+        } else {
+            callee == *constructor
+        }
+    })?;
+    let name = node
+        .child_by_field_name("left")
+        .and_then(|l| l.named_child(0))
+        .filter(|n| n.kind() == "identifier")
+        .and_then(text)
+        .filter(|n| *n != "_")?;
+
+    let function = std::iter::successors(node.parent(), |n| n.parent()).find(|n| {
+        matches!(
+            n.kind(),
+            "function_declaration" | "method_declaration" | "func_literal"
+        )
+    })?;
+    if resource_released(&function.child_by_field_name("body")?, name, body, source) {
+        return None;
+    }
+    let closer = if body {
+        format!("{}.Body.Close()", name)
+    } else {
+        format!("{}.Close()", name)
+    };
+    Some(format!("from `{}`, no `{}`", callee, closer))
+}
+
+/// Whether anything under `node` closes `name` (or `name.Body`) or lets it
+/// escape the function.
+fn resource_released(node: &Node, name: &str, body: bool, source: &str) -> bool {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    if node.kind() == "identifier" && text(*node) == Some(name) {
+        let Some(parent) = node.parent() else {
+            return true;
+        };
+        let is_left = parent.kind() == "expression_list"
+            && parent
+                .parent()
+                .and_then(|p| p.child_by_field_name("left"))
+                .is_some_and(|l| l.id() == parent.id());
+        if is_left {
+            return false;
+        }
+        if parent.kind() != "selector_expression" {
+            return true;
+        }
+        let field = |n: Node| n.child_by_field_name("field").and_then(text);
+        let closed = |selector: Node| {
+            field(selector) == Some("Close")
+                && selector
+                    .parent()
+                    .is_some_and(|p| p.kind() == "call_expression")
+        };
+        if !body {
+            return closed(parent);
+        }
+        if field(parent) != Some("Body") {
+            return false;
+        }
+        return match parent.parent() {
+            Some(outer) if outer.kind() == "selector_expression" => closed(outer),
+            Some(args) if args.kind() == "argument_list" => args
+                .parent()
+                .and_then(|c| c.child_by_field_name("function"))
+                .and_then(text)
+                .is_some_and(|f| f.to_lowercase().contains("close")),
+            _ => false,
+        };
+    }
+    let mut cursor = node.walk();
+    let children: Vec<Node> = node.named_children(&mut cursor).collect();
+    children
+        .iter()
+        .any(|child| resource_released(child, name, body, source))
+}
+
+/// An exported struct field without a `json` tag in a struct where other
+/// fields have one.
+///
+/// Tagging some fields shows the struct is marshaled to JSON, so an
+/// untagged exported field leaks its Go name as the key. Embedded fields
+/// and fields tagged `json:"-"` are left alone.
+fn untagged_json_field(node: &Node, source: &str) -> Option<String> {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let has_json_tag = |field: &Node| {
+        field
+            .child_by_field_name("tag")
+            .and_then(text)
+            .is_some_and(|tag| tag.contains("json:\""))
+    };
+    if node.child_by_field_name("tag").is_some() {
+        return None;
+    }
+    let name = node
+        .child_by_field_name("name")
+        .and_then(text)
+        .filter(|n| n.starts_with(|c: char| c.is_ascii_uppercase()))?;
+
+    let list = node.parent()?;
+    let mut cursor = list.walk();
+    let tagged = list
+        .named_children(&mut cursor)
+        .filter(|f| f.kind() == "field_declaration")
+        .filter(|f| has_json_tag(f))
+        .count();
+    if tagged == 0 {
+        return None;
+    }
+    let owner = std::iter::successors(list.parent(), |n| n.parent())
+        .find(|n| n.kind() == "type_spec")
+        .and_then(|spec| spec.child_by_field_name("name"))
+        .and_then(text);
+    Some(match owner {
+        Some(owner) => format!("`{}.{}`", owner, name),
+        None => format!("`{}`", name),
+    })
+}
+
+/// Hosts whose URLs name a standard or documentation rather than a
+/// service to connect to.
+const WELL_KNOWN_HOSTS: &[&str] = &[
+    "www.w3.org",
+    "w3.org",
+    "schema.org",
+    "json-schema.org",
+    "purl.org",
+    "example.com",
+    "example.org",
+    "example.net",
+    "www.example.com",
+    "www.example.org",
+    "www.example.net",
+];
+
+/// A URL, `host:port` or port literal outside test files, unless a URL
+/// names one of the [`WELL_KNOWN_HOSTS`].
+///
+/// A file importing `testing` counts as a test file, which covers every
+/// `_test.go` file with tests or benchmarks.
+fn hardcoded_address(node: &Node, source: &str) -> Option<String> {
+    let root = std::iter::successors(Some(*node), |n| n.parent()).last()?;
+    if import_name(&root, "testing", source).is_some() {
+        return None;
+    }
+    let text = node.utf8_text(source.as_bytes()).ok()?;
+    if node.kind() == "interpreted_string_literal" {
+        let host = text
+            .trim_matches('"')
+            .split_once("://")
+            .map(|(_, rest)| rest.split(['/', ':', '?', '#']).next().unwrap_or(rest));
+        if host.is_some_and(|h| WELL_KNOWN_HOSTS.contains(&h.to_ascii_lowercase().as_str())) {
+            return None;
+        }
+    }
+    Some(String::new())
+}
+
+/// `os` and `io/ioutil` functions taking a permission, with the index of
+/// the mode argument and whether they create directories.
+const MODE_CALLS: &[(&str, &str, usize, bool)] = &[
+    ("os", "WriteFile", 2, false),
+    ("os", "OpenFile", 2, false),
+    ("os", "Chmod", 1, false),
+    ("os", "Mkdir", 1, true),
+    ("os", "MkdirAll", 1, true),
+    ("io/ioutil", "WriteFile", 2, false),
+];
+
+/// Permission bits `mode_mask` can select, with how the detail names them.
+const MODE_BITS: &[(u32, &str)] = &[
+    (0o040, "group-readable"),
+    (0o020, "group-writable"),
+    (0o010, "group-executable"),
+    (0o004, "world-readable"),
+    (0o002, "world-writable"),
+    (0o001, "world-executable"),
+];
+
+/// A [`MODE_CALLS`] call through the file's import of its package whose
+/// constant mode sets any bit of `mask`, for `mode_mask`.
+///
+/// Directories need their execute bits to be entered, so those are never
+/// reported for `Mkdir` and `MkdirAll`. The detail names the call, the mode
+/// as written and the bits it grants.
+pub(crate) fn permissive_mode(node: &Node, source: &str, mask: u32) -> Option<String> {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let function = node
+        .child_by_field_name("function")
+        .filter(|f| f.kind() == "selector_expression")?;
+    let operand = function
+        .child_by_field_name("operand")
+        .filter(|o| o.kind() == "identifier")
+        .and_then(text)?;
+    let field = function.child_by_field_name("field").and_then(text)?;
+    let root = std::iter::successors(Some(*node), |n| n.parent()).last()?;
+    let &(_, name, index, dir) = MODE_CALLS.iter().find(|(package, name, _, _)| {
+        *name == field && import_name(&root, package, source).as_deref() == Some(operand)
+    })?;
+
+    let arg = node.child_by_field_name("arguments")?.named_child(index)?;
+    let mut mode = mode_value(&arg, source)? & mask;
+    if dir {
+        mode &= !0o111;
+    }
+    let granted: Vec<&str> = MODE_BITS
+        .iter()
+        .filter(|(bit, _)| mode & bit != 0)
+        .map(|(_, label)| *label)
+        .collect();
+    if granted.is_empty() {
+        return None;
+    }
+    Some(format!(
+        "`{}.{}` with {}: {}",
+        operand,
+        name,
+        text(arg)?,
+        granted.join(", ")
+    ))
+}
+
+/// Value of a constant file mode: an integer literal, a `FileMode`
+/// conversion, `ModePerm`, or an `|` of those.
+fn mode_value(node: &Node, source: &str) -> Option<u32> {
+    let text = node.utf8_text(source.as_bytes()).ok()?;
+    match node.kind() {
+        "int_literal" => {
+            let digits = text.replace('_', "");
+            let lower = digits.to_ascii_lowercase();
+            if let Some(hex) = lower.strip_prefix("0x") {
+                u32::from_str_radix(hex, 16).ok()
+            } else if let Some(bin) = lower.strip_prefix("0b") {
+                u32::from_str_radix(bin, 2).ok()
+            } else if let Some(octal) = lower.strip_prefix("0o") {
+                u32::from_str_radix(octal, 8).ok()
+            } else if lower.len() > 1 && lower.starts_with('0') {
+                u32::from_str_radix(&lower[1..], 8).ok()
+            } else {
+                lower.parse().ok()
+            }
+        }
+        "parenthesized_expression" => mode_value(&node.named_child(0)?, source),
+        "selector_expression" if text.ends_with(".ModePerm") => Some(0o777),
+        "call_expression" => {
+            let callee = node
+                .child_by_field_name("function")?
+                .utf8_text(source.as_bytes())
+                .ok()?;
+            let args = node.child_by_field_name("arguments")?;
+            if !(callee == "FileMode" || callee.ends_with(".FileMode"))
+                || args.named_child_count() != 1
+            {
+                return None;
+            }
+            mode_value(&args.named_child(0)?, source)
+        }
+        "binary_expression" => {
+            let operator = node
+                .child_by_field_name("operator")?
+                .utf8_text(source.as_bytes())
+                .ok()?;
+            if operator != "|" {
+                return None;
+            }
+            let left = mode_value(&node.child_by_field_name("left")?, source)?;
+            let right = mode_value(&node.child_by_field_name("right")?, source)?;
+            Some(left | right)
+        }
+        _ => None,
+    }
+}
+
+/// A `regexp.Compile` call inside a function body rather than a package
+/// level var, where the operand is the name the file imports `regexp` as.
+///
+/// The detail says when the call also sits in a loop.
+fn regexp_in_function(node: &Node, source: &str) -> Option<String> {
+    let operand = node
+        .child_by_field_name("function")?
+        .child_by_field_name("operand")?;
+    let name = operand.utf8_text(source.as_bytes()).ok()?;
+    let root = std::iter::successors(Some(*node), |n| n.parent()).last()?;
+    if import_name(&root, "regexp", source).as_deref() != Some(name) {
+        return None;
+    }
+
+    let mut in_loop = false;
+    let mut current = *node;
+    while let Some(parent) = current.parent() {
+        match parent.kind() {
+            "for_statement" => in_loop = true,
+            "function_declaration" | "method_declaration" | "func_literal" => {
+                let detail = if in_loop { "inside a loop" } else { "" };
+                return Some(detail.to_string());
+            }
+            _ => {}
+        }
+        current = parent;
+    }
+    None
+}
+
+/// The name a Go file imports `path` under, if it can be called through one.
+///
+/// Blank and dot imports have no usable name.
+fn import_name(root: &Node, path: &str, source: &str) -> Option<String> {
+    let quoted = format!("\"{}\"", path);
+    let mut specs = Vec::new();
+    let mut cursor = root.walk();
+    for decl in root.named_children(&mut cursor) {
+        if decl.kind() != "import_declaration" {
+            continue;
+        }
+        let mut inner = decl.walk();
+        for child in decl.named_children(&mut inner) {
+            match child.kind() {
+                "import_spec" => specs.push(child),
+                "import_spec_list" => {
+                    let mut list = child.walk();
+                    specs.extend(
+                        child
+                            .named_children(&mut list)
+                            .filter(|n| n.kind() == "import_spec"),
+                    );
+                }
+                _ => {}
+            }
+        }
+    }
+
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let spec = specs
+        .into_iter()
+        .find(|s| s.child_by_field_name("path").and_then(text) == Some(quoted.as_str()))?;
+    match spec.child_by_field_name("name").and_then(text) {
+        Some("_") | Some(".") => None,
+        Some(alias) => Some(alias.to_string()),
+        None => path.rsplit('/').next().map(str::to_string),
+    }
+}
+
+/// An error string literal that starts with a capital or ends in `.` or
+/// `!`, with the reason and the corrected literal.
+///
+/// A leading word that is an initialism (`HTTP`), has a capital after its
+/// first letter (`GetUser`, `PostgreSQL`) or is a single letter other than
+/// `A` is taken for a name and left alone. A trailing `...` is kept.
+fn error_string_style(node: &Node, source: &str) -> Option<(String, String)> {
+    let literal = node.utf8_text(source.as_bytes()).ok()?;
+    let quote = literal.chars().next()?;
+    let body = literal.strip_prefix(quote)?.strip_suffix(quote)?;
+
+    let word = body
+        .split(|c: char| !c.is_alphanumeric())
+        .next()
+        .unwrap_or("");
+    let mut chars = word.chars();
+    let capitalized = chars.next().is_some_and(char::is_uppercase)
+        && !chars.any(char::is_uppercase)
+        && (word.chars().count() > 1 || word == "A");
+    let trimmed = body.trim_end_matches(['.', '!']);
+    let punctuated = trimmed.len() != body.len() && !body.ends_with("...");
+
+    let detail = match (capitalized, punctuated) {
+        (true, true) => "capitalized, trailing punctuation",
+        (true, false) => "capitalized",
+        (false, true) => "trailing punctuation",
+        (false, false) => return None,
+    };
+
+    let mut fixed = String::with_capacity(literal.len());
+    fixed.push(quote);
+    let rest = if punctuated { trimmed } else { body };
+    let mut rest_chars = rest.chars();
+    if capitalized {
+        if let Some(first) = rest_chars.next() {
+            fixed.extend(first.to_lowercase());
+        }
+    }
+    fixed.push_str(rest_chars.as_str());
+    fixed.push(quote);
+    Some((detail.to_string(), fixed))
+}
+
+/// An index chain like `cfg["a"].(map[string]any)["b"]`, where a value
+/// read from a map is asserted to a map type without the comma-ok form.
+///
+/// The assertion panics when the key is missing or holds another type.
+/// Only the outermost index of a chain is reported; the detail lists its
+/// keys.
+fn unchecked_map_chain(node: &Node, source: &str) -> Option<String> {
+    let continues = node
+        .parent()
+        .filter(|p| p.kind() == "type_assertion_expression")
+        .filter(|p| {
+            p.child_by_field_name("type")
+                .is_some_and(|t| t.kind() == "map_type")
+        })
+        .and_then(|p| p.parent())
+        .is_some_and(|p| p.kind() == "index_expression");
+    if continues {
+        return None;
+    }
+
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let mut keys = Vec::new();
+    let mut current = *node;
+    loop {
+        keys.push(current.child_by_field_name("index").and_then(text)?);
+        let inner = current
+            .child_by_field_name("operand")
+            .filter(|o| o.kind() == "type_assertion_expression")
+            .filter(|a| {
+                a.child_by_field_name("type")
+                    .is_some_and(|t| t.kind() == "map_type")
+            })
+            .and_then(|a| a.child_by_field_name("operand"))
+            .filter(|o| o.kind() == "index_expression");
+        match inner {
+            Some(inner) => current = inner,
+            None => break,
+        }
+    }
+    if keys.len() < 2 {
+        return None;
+    }
+    keys.reverse();
+    Some(format!("keys {}", keys.join(", ")))
+}
+
+/// A single-value type assertion on an error, such as `err.(*NotFound)`,
+/// which panics when the error has another type or wraps the one wanted.
+///
+/// There is no type checker, so the operand must be declared `error` in an
+/// enclosing function, as a parameter, named result or `var`, or be an
+/// error-like name taking the last result of a call. The nearest function
+/// declaring the name decides.
+fn error_assertion(node: &Node, source: &str) -> Option<String> {
+    let comma_ok = node
+        .parent()
+        .filter(|p| p.kind() == "expression_list")
+        .and_then(|p| p.parent())
+        .is_some_and(|s| match s.kind() {
+            "short_var_declaration" | "assignment_statement" => s
+                .child_by_field_name("left")
+                .is_some_and(|l| l.named_child_count() == 2),
+            "var_spec" => {
+                let mut cursor = s.walk();
+                let names = s.children_by_field_name("name", &mut cursor).count();
+                names == 2
+            }
+            _ => false,
+        });
+    if comma_ok {
+        return None;
+    }
+    let name = node
+        .child_by_field_name("operand")
+        .filter(|o| o.kind() == "identifier")?
+        .utf8_text(source.as_bytes())
+        .ok()?;
+
+    let mut current = node.parent();
+    while let Some(n) = current {
+        if matches!(
+            n.kind(),
+            "function_declaration" | "method_declaration" | "func_literal"
+        ) {
+            if let Some(is_error) = declares_error(&n, name, source) {
+                if !is_error {
+                    return None;
+                }
+                // Returning the error changes behaviour, so it is advice
+                // rather than part of the suggested fix
+                let detail = if returns_only_error(node, source) {
+                    format!("return {} when errors.As finds no match", name)
+                } else {
+                    String::new()
+                };
+                return Some(detail);
+            }
+        }
+        current = n.parent();
+    }
+    None
+}
+
+/// Whether `function` declares `name` as an error, or None when it does not
+/// declare `name` at all.
+fn declares_error(function: &Node, name: &str, source: &str) -> Option<bool> {
+    if let Some(ty) = parameter_type(function, name, source) {
+        return Some(ty == "error");
+    }
+    let results = function
+        .child_by_field_name("result")
+        .filter(|r| r.kind() == "parameter_list");
+    if let Some(ty) = results.and_then(|r| list_type(&r, name, source)) {
+        return Some(ty == "error");
+    }
+
+    let body = function.child_by_field_name("body")?;
+    let mut specs = Vec::new();
+    collect_kind(&body, "var_spec", &mut specs);
+    for spec in specs {
+        let mut cursor = spec.walk();
+        let declares = spec
+            .children_by_field_name("name", &mut cursor)
+            .any(|n| n.utf8_text(source.as_bytes()) == Ok(name));
+        if let Some(ty) = spec.child_by_field_name("type").filter(|_| declares) {
+            return Some(ty.utf8_text(source.as_bytes()) == Ok("error"));
+        }
+    }
+    let mut statements = Vec::new();
+    collect_kind(&body, "short_var_declaration", &mut statements);
+    collect_kind(&body, "assignment_statement", &mut statements);
+    statements
+        .iter()
+        .any(|s| assigned_call(s, source).is_some_and(|(_, err)| err == name))
+        .then_some(true)
+}
+
+/// Whether the function around `node` has `error` as its only result.
+fn returns_only_error(node: &Node, source: &str) -> bool {
+    let mut current = node.parent();
+    while let Some(n) = current {
+        if matches!(
+            n.kind(),
+            "function_declaration" | "method_declaration" | "func_literal"
+        ) {
+            return n
+                .child_by_field_name("result")
+                .and_then(|r| r.utf8_text(source.as_bytes()).ok())
+                .is_some_and(|r| matches!(r.replace(' ', "").as_str(), "error" | "(error)"));
+        }
+        current = n.parent();
+    }
+    false
+}
+
+/// The `errors.As` form of `target := err.(T)` or `target = err.(T)`, with
+/// the statement it replaces. It keeps the panic the assertion had when the
+/// chain holds no `T`, so behaviour only changes for wrapped errors.
+///
+/// There is no fix in a function whose only result is an error, where
+/// returning `err` is the better rewrite but not one to apply unread, nor
+/// in a file that does not import `errors`, since the fix cannot add the
+/// import. The package is called by the name the file imports it under.
+fn error_assertion_fix<'t>(node: &Node<'t>, source: &str) -> Option<(Node<'t>, String)> {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let statement = node
+        .parent()
+        .filter(|p| p.kind() == "expression_list" && p.named_child_count() == 1)
+        .and_then(|p| p.parent())
+        .filter(|s| match s.kind() {
+            "short_var_declaration" => true,
+            "assignment_statement" => s.child_by_field_name("operator").and_then(text) == Some("="),
+            _ => false,
+        })?;
+    let target = statement
+        .child_by_field_name("left")
+        .filter(|l| l.named_child_count() == 1)
+        .and_then(|l| l.named_child(0))
+        .filter(|t| t.kind() == "identifier")
+        .and_then(text)?;
+    let err = node.child_by_field_name("operand").and_then(text)?;
+    let ty = node.child_by_field_name("type").and_then(text)?;
+    if target == "_" || target == err || returns_only_error(node, source) {
+        return None;
+    }
+
+    let line_start = source[..statement.start_byte()]
+        .rfind('\n')
+        .map_or(0, |i| i + 1);
+    let indent = &source[line_start..statement.start_byte()];
+    if !indent.chars().all(char::is_whitespace) {
+        return None;
+    }
+    let mut root = *node;
+    while let Some(parent) = root.parent() {
+        root = parent;
+    }
+    let errors = import_name(&root, "errors", source)?;
+
+    let mut fixed = String::new();
+    if statement.kind() == "short_var_declaration" {
+        fixed.push_str(&format!("var {} {}\n{}", target, ty, indent));
+    }
+    fixed.push_str(&format!(
+        "if !{}.As({}, &{}) {{\n{}\tpanic({})\n{}}}",
+        errors, err, target, indent, err, indent
+    ));
+    Some((statement, fixed))
+}
+
+/// A function that returns a value, has no `error` or `bool` result to
+/// signal failure, and discards the error of a call or the ok of a type
+/// assertion with `_`.
+///
+/// Only statements of the function itself count, not of closures in it.
+/// The detail names the first discarded result.
+fn swallowed_error(node: &Node, source: &str) -> Option<String> {
+    let result = node.child_by_field_name("result")?;
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let signals = if result.kind() == "parameter_list" {
+        let mut cursor = result.walk();
+        let signals = result
+            .named_children(&mut cursor)
+            .filter_map(|p| p.child_by_field_name("type"))
+            .any(|t| matches!(text(t), Some("error" | "bool")));
+        signals
+    } else {
+        text(result) == Some("error")
+    };
+    if signals {
+        return None;
+    }
+
+    let discarded = discarded_result(&node.child_by_field_name("body")?, source)?;
+    match discarded.kind() {
+        "type_assertion_expression" => Some("ignores a type assertion's ok".to_string()),
+        _ => {
+            let callee = discarded.child_by_field_name("function").and_then(text)?;
+            Some(format!("ignores the error from `{}`", callee))
+        }
+    }
+}
+
+/// The first call or type assertion under `node` whose last result is
+/// assigned to `_`, outside nested function literals.
+fn discarded_result<'a>(node: &Node<'a>, source: &str) -> Option<Node<'a>> {
+    if matches!(
+        node.kind(),
+        "short_var_declaration" | "assignment_statement"
+    ) {
+        return blank_discard(node, source);
+    }
+    let mut cursor = node.walk();
+    let found = node
+        .named_children(&mut cursor)
+        .filter(|child| child.kind() != "func_literal")
+        .find_map(|child| discarded_result(&child, source));
+    found
+}
+
+/// The call or type assertion on the right of an assignment `node` whose
+/// last result goes to `_`, as in `n, _ := strconv.Atoi(s)`.
+fn blank_discard<'a>(node: &Node<'a>, source: &str) -> Option<Node<'a>> {
+    let left = node.child_by_field_name("left")?;
+    let right = node
+        .child_by_field_name("right")
+        .filter(|r| r.named_child_count() == 1)
+        .and_then(|r| r.named_child(0))
+        .filter(|r| matches!(r.kind(), "call_expression" | "type_assertion_expression"));
+    let count = left.named_child_count();
+    let blank = count >= 2
+        && left
+            .named_child(count - 1)
+            .is_some_and(|last| last.utf8_text(source.as_bytes()) == Ok("_"));
+    if blank {
+        right
+    } else {
+        None
+    }
+}
+
+/// A function whose `if` and `switch` branches all return the same thing,
+/// as in `if a { return nil }; if b { return nil }; return nil`.
+///
+/// The body may hold nothing but those branches and returns, nested as
+/// deep as they like, so no branch does anything of its own before
+/// returning. Bare returns do not count; the detail gives the value and
+/// how many returns give it.
+fn uniform_returns(node: &Node, source: &str) -> Option<String> {
+    let mut returns = Vec::new();
+    if !branch_returns(&node.child_by_field_name("body")?, &mut returns) || returns.len() < 2 {
+        return None;
+    }
+    let values: Vec<String> = returns
+        .iter()
+        .map(|r| {
+            let value = r.named_child(0)?.utf8_text(source.as_bytes()).ok()?;
+            Some(value.split_whitespace().collect::<Vec<_>>().join(" "))
+        })
+        .collect::<Option<_>>()?;
+    if values.iter().any(|v| *v != values[0]) {
+        return None;
+    }
+    Some(format!("all {} returns give `{}`", values.len(), values[0]))
+}
+
+/// Push the returns of a block or case to `returns`, or return false when
+/// it holds a statement other than a return, `if`, `switch` or block.
+fn branch_returns<'a>(node: &Node<'a>, returns: &mut Vec<Node<'a>>) -> bool {
+    let mut cursor = node.walk();
+    if !cursor.goto_first_child() {
+        return true;
+    }
+    loop {
+        let child = cursor.node();
+        // A case's values and types are not statements
+        let label = matches!(cursor.field_name(), Some("value" | "type"));
+        if child.is_named()
+            && !label
+            && child.kind() != "comment"
+            && !statement_returns(&child, returns)
+        {
+            return false;
+        }
+        if !cursor.goto_next_sibling() {
+            return true;
+        }
+    }
+}
+
+/// [`branch_returns`] for one statement.
+fn statement_returns<'a>(node: &Node<'a>, returns: &mut Vec<Node<'a>>) -> bool {
+    match node.kind() {
+        "return_statement" => {
+            returns.push(*node);
+            true
+        }
+        "block" | "statement_list" => branch_returns(node, returns),
+        "if_statement" => {
+            node.child_by_field_name("consequence")
+                .is_some_and(|c| branch_returns(&c, returns))
+                && node
+                    .child_by_field_name("alternative")
+                    .is_none_or(|a| statement_returns(&a, returns))
+        }
+        "expression_switch_statement" | "type_switch_statement" => {
+            let mut cursor = node.walk();
+            let cases: Vec<Node> = node
+                .named_children(&mut cursor)
+                .filter(|c| c.kind().ends_with("_case"))
+                .collect();
+            cases.iter().all(|c| branch_returns(c, returns))
+        }
+        _ => false,
+    }
+}
+
+/// A `return` of zero values and a nil error that ends an `if err != nil`
+/// block after a log call, in a function whose last result is `error`.
+///
+/// The error reaches the log but not the caller, which carries on as if the
+/// call had worked. Fatal and panic log calls never reach the return, so
+/// they do not count.
+fn logged_error_return(node: &Node, source: &str) -> Option<String> {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let is_zero = |v: &Node| match v.kind() {
+        "nil" | "false" => true,
+        "interpreted_string_literal" | "raw_string_literal" => {
+            matches!(text(*v), Some("\"\"" | "``"))
+        }
+        "int_literal" | "float_literal" => {
+            text(*v).and_then(|t| t.parse::<f64>().ok()) == Some(0.0)
+        }
+        "composite_literal" => v
+            .child_by_field_name("body")
+            .is_some_and(|b| b.named_child_count() == 0),
+        _ => false,
+    };
+    let values = node.named_child(0)?;
+    let mut cursor = values.walk();
+    let returned: Vec<Node> = values.named_children(&mut cursor).collect();
+    if !returned.iter().all(is_zero) || returned.last().and_then(|v| text(*v)) != Some("nil") {
+        return None;
+    }
+
+    // The return must close the block of an `if <name>err != nil`
+    let mut block = node.parent()?;
+    if block.kind() == "statement_list" {
+        block = block.parent()?;
+    }
+    let if_stmt = block.parent().filter(|p| p.kind() == "if_statement")?;
+    if if_stmt.child_by_field_name("consequence")?.id() != block.id() {
+        return None;
+    }
+    let condition = if_stmt.child_by_field_name("condition")?;
+    let checks_error = condition.kind() == "binary_expression"
+        && condition.child_by_field_name("operator").and_then(text) == Some("!=")
+        && condition.child_by_field_name("right").and_then(text) == Some("nil")
+        && condition
+            .child_by_field_name("left")
+            .and_then(text)
+            .is_some_and(|name| name.ends_with("err") || name.ends_with("Err"));
+    let statements = statements(&block);
+    if !checks_error || statements.last().map(|s| s.id()) != Some(node.id()) {
+        return None;
+    }
+
+    let (logger, line) = statements.iter().find_map(|statement| {
+        let call = statement.named_child(0).filter(|c| {
+            statement.kind() == "expression_statement" && c.kind() == "call_expression"
+        })?;
+        let callee = call.child_by_field_name("function")?;
+        let operand = callee.child_by_field_name("operand").and_then(text)?;
+        let method = callee.child_by_field_name("field").and_then(text)?;
+        let is_log = operand.to_lowercase().contains("log")
+            && ["Print", "Error", "Warn", "Info", "Debug"]
+                .iter()
+                .any(|prefix| method.starts_with(prefix));
+        is_log.then(|| (text(callee), call.start_position().row + 1))
+    })?;
+
+    let function = std::iter::successors(node.parent(), |n| n.parent()).find(|n| {
+        matches!(
+            n.kind(),
+            "function_declaration" | "method_declaration" | "func_literal"
+        )
+    })?;
+    let result = function.child_by_field_name("result")?;
+    let last_type = if result.kind() == "parameter_list" {
+        result
+            .named_child(result.named_child_count().checked_sub(1)?)
+            .and_then(|p| p.child_by_field_name("type"))
+    } else {
+        Some(result)
+    };
+    if last_type.and_then(text) != Some("error") {
+        return None;
+    }
+    Some(format!("logged with `{}` on line {}", logger?, line))
+}
+
+/// The statements of a block, whether or not the grammar wraps them in a
+/// `statement_list`.
+fn statements<'a>(block: &Node<'a>) -> Vec<Node<'a>> {
+    let mut cursor = block.walk();
+    let children: Vec<Node> = block.named_children(&mut cursor).collect();
+    match children.as_slice() {
+        [list] if list.kind() == "statement_list" => {
+            let mut cursor = list.walk();
+            let statements = list.named_children(&mut cursor).collect();
+            statements
+        }
+        _ => children,
+    }
+}
+
+/// An `append(x, ...)` where `x` is a slice parameter of an enclosing
+/// function and the result is returned or stored under another name.
+///
+/// With spare capacity, append writes into the caller's backing array, so
+/// the caller's slice and the result share memory. `x = append(x, ...)`
+/// only grows the local copy and is left alone.
+fn append_param_alias(node: &Node, source: &str) -> Option<String> {
+    let arg = node
+        .child_by_field_name("arguments")
+        .and_then(|args| args.named_child(0))
+        .filter(|a| a.kind() == "identifier")?;
+    let name = arg.utf8_text(source.as_bytes()).ok()?;
+
+    let mut current = *node;
+    let declared = loop {
+        let parent = current.parent()?;
+        if matches!(
+            parent.kind(),
+            "function_declaration" | "method_declaration" | "func_literal"
+        ) {
+            if let Some(ty) = parameter_type(&parent, name, source) {
+                break ty;
+            }
+        }
+        current = parent;
+    };
+    if !declared.starts_with("[]") {
+        return None;
+    }
+
+    let list = node.parent().filter(|p| p.kind() == "expression_list")?;
+    let statement = list.parent()?;
+    let target = match statement.kind() {
+        "return_statement" => return Some(format!("returns parameter `{}`", name)),
+        "assignment_statement" | "short_var_declaration" => statement.child_by_field_name("left"),
+        "var_spec" => statement.child_by_field_name("name"),
+        _ => None,
+    }?;
+    let target = target.utf8_text(source.as_bytes()).ok()?;
+    if target == name {
+        return None;
+    }
+    Some(format!("stores parameter `{}` in `{}`", name, target))
+}
+
+/// A function returning `[]interface{}` or `[]any` whose returned slice is
+/// only ever appended values of one concrete type.
+///
+/// Without a type checker, an appended value has a type when it is a
+/// composite literal, `&T{...}`, a type assertion, a basic literal or
+/// conversion, a typed parameter, or a variable assigned one of these with
+/// `:=` in the function. Appends inside closures count; any value of
+/// unknown type, or a spread `xs...`, keeps the function out.
+fn uniform_any_slice(node: &Node, source: &str) -> Option<String> {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let result = text(node.child_by_field_name("result")?)?;
+    if !matches!(result.replace(' ', "").as_str(), "[]interface{}" | "[]any") {
+        return None;
+    }
+    let body = node.child_by_field_name("body")?;
+    let mut returned = Vec::new();
+    returned_identifiers(&body, source, &mut returned);
+
+    for name in returned {
+        let mut sites = Vec::new();
+        append_sites(&body, name, source, &mut sites);
+        if sites.is_empty() {
+            continue;
+        }
+        let mut element: Option<String> = None;
+        let mut lines = Vec::new();
+        for value in &sites {
+            let found = value_type(node, &body, value, source, true)?;
+            if element.as_ref().is_some_and(|e| *e != found) {
+                return None;
+            }
+            element = Some(found);
+            let line = value.start_position().row + 1;
+            if !lines.contains(&line) {
+                lines.push(line);
+            }
+        }
+        let lines: Vec<String> = lines.iter().map(ToString::to_string).collect();
+        let at = match lines.as_slice() {
+            [line] => format!("line {}", line),
+            _ => format!("lines {}", lines.join(", ")),
+        };
+        return Some(format!(
+            "`{}` only holds `{}`, appended on {}",
+            result, element?, at
+        ));
+    }
+    None
+}
+
+/// Names returned on their own by `return x`, outside nested closures.
+fn returned_identifiers<'a>(node: &Node, source: &'a str, names: &mut Vec<&'a str>) {
+    if node.kind() == "return_statement" {
+        let value = node
+            .named_child(0)
+            .filter(|list| list.named_child_count() == 1)
+            .and_then(|list| list.named_child(0))
+            .filter(|v| v.kind() == "identifier")
+            .and_then(|v| v.utf8_text(source.as_bytes()).ok());
+        if let Some(name) = value.filter(|n| !names.contains(n)) {
+            names.push(name);
+        }
+        return;
+    }
+    let mut cursor = node.walk();
+    let children: Vec<Node> = node
+        .named_children(&mut cursor)
+        .filter(|c| c.kind() != "func_literal")
+        .collect();
+    for child in children {
+        returned_identifiers(&child, source, names);
+    }
+}
+
+/// The values appended by every `append(name, ...)` under `node`, closures
+/// included. A spread argument is kept as is, so it fails to get a type.
+fn append_sites<'a>(node: &Node<'a>, name: &str, source: &str, values: &mut Vec<Node<'a>>) {
+    if node.kind() == "call_expression"
+        && node
+            .child_by_field_name("function")
+            .is_some_and(|f| f.utf8_text(source.as_bytes()) == Ok("append"))
+    {
+        if let Some(args) = node.child_by_field_name("arguments") {
+            let mut cursor = args.walk();
+            let args: Vec<Node> = args.named_children(&mut cursor).collect();
+            if args
+                .first()
+                .is_some_and(|a| a.utf8_text(source.as_bytes()) == Ok(name))
+            {
+                values.extend(args.into_iter().skip(1));
+            }
+        }
+    }
+    let mut cursor = node.walk();
+    let children: Vec<Node> = node.named_children(&mut cursor).collect();
+    for child in children {
+        append_sites(&child, name, source, values);
+    }
+}
+
+/// Basic types a conversion like `string(b)` produces.
+const BASIC_TYPES: &[&str] = &[
+    "bool", "byte", "rune", "string", "int", "int8", "int16", "int32", "int64", "uint", "uint8",
+    "uint16", "uint32", "uint64", "float32", "float64",
+];
+
+/// The concrete type of `value`, when it is visible without a type checker.
+///
+/// With `follow`, an identifier is looked up once among the parameters of
+/// `function` and the `x := ...` declarations in `body`.
+fn value_type(
+    function: &Node,
+    body: &Node,
+    value: &Node,
+    source: &str,
+    follow: bool,
+) -> Option<String> {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let concrete = |t: &str| !matches!(t.replace(' ', "").as_str(), "interface{}" | "any");
+    let found = match value.kind() {
+        "composite_literal" | "type_assertion_expression" => {
+            text(value.child_by_field_name("type")?)?.to_string()
+        }
+        "unary_expression" => {
+            let operand = value
+                .child_by_field_name("operand")
+                .filter(|o| o.kind() == "composite_literal")?;
+            if value.child_by_field_name("operator").and_then(text) != Some("&") {
+                return None;
+            }
+            format!("*{}", text(operand.child_by_field_name("type")?)?)
+        }
+        "interpreted_string_literal" | "raw_string_literal" => "string".to_string(),
+        "int_literal" => "int".to_string(),
+        "float_literal" => "float64".to_string(),
+        "rune_literal" => "rune".to_string(),
+        "true" | "false" => "bool".to_string(),
+        "call_expression" => {
+            let callee = text(value.child_by_field_name("function")?)?;
+            BASIC_TYPES.contains(&callee).then(|| callee.to_string())?
+        }
+        "identifier" if follow => {
+            let name = text(*value)?;
+            if let Some(declared) = parameter_type(function, name, source) {
+                return concrete(declared).then(|| declared.to_string());
+            }
+            let assigned = short_var_value(body, name, source)?;
+            return value_type(function, body, &assigned, source, false);
+        }
+        _ => return None,
+    };
+    concrete(&found).then_some(found)
+}
+
+/// The value of the only `name := value` declaration under `node`.
+fn short_var_value<'a>(node: &Node<'a>, name: &str, source: &str) -> Option<Node<'a>> {
+    let mut found = Vec::new();
+    collect_short_vars(node, name, source, &mut found);
+    match found.as_slice() {
+        [value] => Some(*value),
+        _ => None,
+    }
+}
+
+fn collect_short_vars<'a>(node: &Node<'a>, name: &str, source: &str, found: &mut Vec<Node<'a>>) {
+    if node.kind() == "short_var_declaration" {
+        let left = node.child_by_field_name("left");
+        let right = node.child_by_field_name("right");
+        if let (Some(left), Some(right)) = (left, right) {
+            if left.named_child_count() == 1
+                && right.named_child_count() == 1
+                && left.utf8_text(source.as_bytes()) == Ok(name)
+            {
+                found.extend(right.named_child(0));
+            }
+        }
+    }
+    let mut cursor = node.walk();
+    let children: Vec<Node> = node.named_children(&mut cursor).collect();
+    for child in children {
+        collect_short_vars(&child, name, source, found);
+    }
+}
+
+/// A ranged expression that is visibly a channel: a parameter of channel
+/// type, a name the file assigns `make(chan ...)` to or declares as
+/// `var name chan`, or a field of a struct in the file with channel type.
+///
+/// Whether anything closes it is settled once the package is scanned.
+fn ranged_channel(node: &Node, source: &str) -> Option<String> {
+    let text = node.utf8_text(source.as_bytes()).ok()?;
+    let channel = match node.kind() {
+        "identifier" => {
+            let mut current = *node;
+            let mut declared = None;
+            while let Some(parent) = current.parent() {
+                if matches!(
+                    parent.kind(),
+                    "function_declaration" | "method_declaration" | "func_literal"
+                ) {
+                    declared = parameter_type(&parent, text, source);
+                    if declared.is_some() {
+                        break;
+                    }
+                }
+                current = parent;
+            }
+            match declared {
+                Some(ty) => ty.starts_with("chan") || ty.starts_with("<-chan"),
+                None => [
+                    format!("{} := make(chan", text),
+                    format!("{} = make(chan", text),
+                    format!("var {} chan", text),
+                ]
+                .iter()
+                .any(|decl| source.contains(decl.as_str())),
+            }
+        }
+        "selector_expression" => {
+            let field = node
+                .child_by_field_name("field")
+                .and_then(|f| f.utf8_text(source.as_bytes()).ok())?;
+            let root = std::iter::successors(Some(*node), |n| n.parent()).last()?;
+            has_channel_field(&root, field, source)
+        }
+        _ => false,
+    };
+    channel.then(|| format!("channel `{}`", text))
+}
+
+/// Whether a struct under `node` declares field `name` with a channel type.
+fn has_channel_field(node: &Node, name: &str, source: &str) -> bool {
+    if node.kind() == "field_declaration" {
+        let is_chan = node
+            .child_by_field_name("type")
+            .is_some_and(|t| t.kind() == "channel_type");
+        let mut cursor = node.walk();
+        let named = node
+            .children_by_field_name("name", &mut cursor)
+            .any(|n| n.utf8_text(source.as_bytes()) == Ok(name));
+        return is_chan && named;
+    }
+    let mut cursor = node.walk();
+    let found = node
+        .named_children(&mut cursor)
+        .any(|child| has_channel_field(&child, name, source));
+    found
+}
+
+/// Declared type of parameter `name` of `function`, if it has one.
+fn parameter_type<'a>(function: &Node, name: &str, source: &'a str) -> Option<&'a str> {
+    list_type(&function.child_by_field_name("parameters")?, name, source)
+}
+
+/// Declared type of `name` in a parameter or result list, if it has one.
+fn list_type<'a>(params: &Node, name: &str, source: &'a str) -> Option<&'a str> {
+    let mut cursor = params.walk();
+    for param in params.named_children(&mut cursor) {
+        let mut inner = param.walk();
+        let declares = param
+            .children_by_field_name("name", &mut inner)
+            .any(|n| n.utf8_text(source.as_bytes()) == Ok(name));
+        if declares {
+            return param
+                .child_by_field_name("type")
+                .and_then(|t| t.utf8_text(source.as_bytes()).ok());
+        }
+    }
+    None
+}
+
+/// Types that erase what a value holds, compared without spaces.
+const ERASED_TYPES: &[&str] = &[
+    "interface{}",
+    "any",
+    "map[string]interface{}",
+    "map[string]any",
+];
+
+/// How a Go function declaration `node` handles erased values, for the
+/// `package-erased` check. Methods and other nodes give None.
+///
+/// Erased values are parameters of an [`ERASED_TYPES`] type and locals
+/// declared with one or made from a literal or `make` of one. Only calls by
+/// bare name count, since those are the functions of the same package.
+pub(crate) fn erased_flow(node: &Node, source: &str) -> Option<ErasedFlow> {
+    if node.kind() != "function_declaration" {
+        return None;
+    }
+    let text = |n: Node| n.utf8_text(source.as_bytes()).unwrap_or("");
+    let erased = |n: Node| {
+        let compact: String = text(n).split_whitespace().collect();
+        ERASED_TYPES.contains(&compact.as_str())
+    };
+
+    let mut params = Vec::new();
+    let list = node.child_by_field_name("parameters")?;
+    let mut cursor = list.walk();
+    for param in list.named_children(&mut cursor) {
+        if param.child_by_field_name("type").is_some_and(erased) {
+            let mut inner = param.walk();
+            params.extend(param.children_by_field_name("name", &mut inner).map(text));
+        }
+    }
+
+    let body = node.child_by_field_name("body")?;
+    let mut values: Vec<String> = params.iter().map(|p| p.to_string()).collect();
+    let mut nodes = vec![body];
+    let mut calls = Vec::new();
+    let mut narrows = false;
+    while let Some(current) = nodes.pop() {
+        match current.kind() {
+            "short_var_declaration" | "var_spec" => {
+                let declared = current.child_by_field_name("type").is_some_and(erased)
+                    || current
+                        .child_by_field_name("right")
+                        .or_else(|| current.child_by_field_name("value"))
+                        .is_some_and(|r| {
+                            r.named_child(0).is_some_and(|v| match v.kind() {
+                                "composite_literal" => {
+                                    v.child_by_field_name("type").is_some_and(erased)
+                                }
+                                "call_expression" => {
+                                    v.child_by_field_name("function").map(text) == Some("make")
+                                        && v.child_by_field_name("arguments")
+                                            .and_then(|a| a.named_child(0))
+                                            .is_some_and(erased)
+                                }
+                                _ => false,
+                            })
+                        });
+                if declared {
+                    match current.child_by_field_name("left") {
+                        Some(left) => values.extend(identifiers(&left, source)),
+                        None => {
+                            let mut inner = current.walk();
+                            let names = current.children_by_field_name("name", &mut inner);
+                            values.extend(names.map(|n| text(n).to_string()));
+                        }
+                    }
+                }
+            }
+            "type_assertion_expression" => {
+                narrows |= current
+                    .child_by_field_name("operand")
+                    .is_some_and(|o| params.contains(&text(o)));
+            }
+            "type_switch_statement" => {
+                narrows |= current
+                    .child_by_field_name("value")
+                    .is_some_and(|v| params.contains(&text(v)));
+            }
+            "call_expression" => calls.push(current),
+            _ => {}
+        }
+        let mut inner = current.walk();
+        nodes.extend(current.named_children(&mut inner));
+    }
+
+    // Calls are collected depth first from the end; restore source order
+    calls.sort_by_key(|c| c.start_byte());
+    let mut passes_to: Vec<String> = Vec::new();
+    for call in calls {
+        let Some(callee) = call
+            .child_by_field_name("function")
+            .filter(|f| f.kind() == "identifier")
+            .map(text)
+        else {
+            continue;
+        };
+        let passes = call.child_by_field_name("arguments").is_some_and(|args| {
+            let mut inner = args.walk();
+            let found = args
+                .named_children(&mut inner)
+                .any(|a| a.kind() == "identifier" && values.iter().any(|v| v == text(a)));
+            found
+        });
+        if passes && !passes_to.iter().any(|p| p == callee) {
+            passes_to.push(callee.to_string());
+        }
+    }
+
+    Some(ErasedFlow {
+        line: node.start_position().row + 1,
+        name: text(node.child_by_field_name("name")?).to_string(),
+        receives: !params.is_empty(),
+        narrows,
+        passes_to,
+    })
+}
+
+/// A type switch `node` without a `default`, on a parameter declared with
+/// a bare type name, for the `package-type-switch` check. Whether that
+/// type is one of the package's interfaces is settled once the package is
+/// scanned.
+pub(crate) fn type_switch(node: &Node, source: &str) -> Option<TypeSwitch> {
+    if node.kind() != "type_switch_statement" {
+        return None;
+    }
+    let value = node.child_by_field_name("value")?;
+    if value.kind() != "identifier" {
+        return None;
+    }
+    let name = value.utf8_text(source.as_bytes()).ok()?;
+
+    let mut interface = None;
+    let mut current = *node;
+    while let Some(parent) = current.parent() {
+        if matches!(
+            parent.kind(),
+            "function_declaration" | "method_declaration" | "func_literal"
+        ) {
+            if let Some(ty) = parameter_type(&parent, name, source) {
+                interface = Some(ty);
+                break;
+            }
+        }
+        current = parent;
+    }
+    let interface = interface.filter(|ty| ty.chars().all(|c| c.is_alphanumeric() || c == '_'))?;
+
+    let mut cases = Vec::new();
+    let mut cursor = node.walk();
+    for clause in node.named_children(&mut cursor) {
+        match clause.kind() {
+            "default_case" => return None,
+            "type_case" => {
+                let mut inner = clause.walk();
+                for ty in clause.children_by_field_name("type", &mut inner) {
+                    let text = ty.utf8_text(source.as_bytes()).unwrap_or("");
+                    cases.push(text.trim_start_matches('*').to_string());
+                }
+            }
+            _ => {}
+        }
+    }
+
+    Some(TypeSwitch {
+        line: node.start_position().row + 1,
+        interface: interface.to_string(),
+        cases,
+    })
+}
+
+/// The struct `T` and its field count when the Go function `node` is
+/// declared to return `*T` and every return statement is `&T{...}`, for
+/// `max_fields`.
+///
+/// `T` must be a struct type of the same file. A pointer-receiver method
+/// on it, or a `sync` field, means callers need the one shared value, so
+/// either gives None. Returns inside function literals are not counted.
+pub(crate) fn pointer_return(node: &Node, source: &str) -> Option<(String, usize)> {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).ok();
+    let name = node
+        .child_by_field_name("result")
+        .filter(|r| r.kind() == "pointer_type")
+        .and_then(|r| r.named_child(0))
+        .filter(|t| t.kind() == "type_identifier")
+        .and_then(text)?;
+
+    let mut returns = Vec::new();
+    own_returns(&node.child_by_field_name("body")?, &mut returns);
+    if returns.is_empty() {
+        return None;
+    }
+    for statement in &returns {
+        let value = statement
+            .named_child(0)
+            .filter(|list| list.named_child_count() == 1)
+            .and_then(|list| list.named_child(0))
+            .filter(|v| v.kind() == "unary_expression")?;
+        if value.child_by_field_name("operator").and_then(text) != Some("&") {
+            return None;
+        }
+        let literal = value
+            .child_by_field_name("operand")
+            .filter(|o| o.kind() == "composite_literal")?;
+        if literal.child_by_field_name("type").and_then(text) != Some(name) {
+            return None;
+        }
+    }
+
+    let root = std::iter::successors(Some(*node), |n| n.parent()).last()?;
+    let mut methods = Vec::new();
+    collect_kind(&root, "method_declaration", &mut methods);
+    let pointer = format!("*{}", name);
+    let shared = methods.iter().any(|m| {
+        m.child_by_field_name("receiver")
+            .and_then(|r| r.named_child(0))
+            .and_then(|p| p.child_by_field_name("type"))
+            .and_then(text)
+            == Some(pointer.as_str())
+    });
+    if shared {
+        return None;
+    }
+
+    let mut specs = Vec::new();
+    collect_kind(&root, "type_spec", &mut specs);
+    let spec = specs
+        .iter()
+        .find(|s| s.child_by_field_name("name").and_then(text) == Some(name))?;
+    let list = spec
+        .child_by_field_name("type")
+        .filter(|t| t.kind() == "struct_type")?
+        .named_child(0)?;
+    let mut count = 0;
+    let mut cursor = list.walk();
+    for field in list.named_children(&mut cursor) {
+        if field.kind() != "field_declaration" {
+            continue;
+        }
+        let ty = field
+            .child_by_field_name("type")
+            .and_then(text)
+            .unwrap_or("");
+        if ty.trim_start_matches('*').starts_with("sync.") {
+            return None;
+        }
+        let mut inner = field.walk();
+        count += field
+            .children_by_field_name("name", &mut inner)
+            .count()
+            .max(1);
+    }
+    Some((name.to_string(), count))
+}
+
+/// Return statements of a function body, leaving out function literals.
+fn own_returns<'t>(node: &Node<'t>, found: &mut Vec<Node<'t>>) {
+    if node.kind() == "return_statement" {
+        found.push(*node);
+    }
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        if child.kind() != "func_literal" {
+            own_returns(&child, found);
+        }
+    }
+}
+
+/// Variables declared with `:=` by a range or three-clause for loop.
+fn loop_vars(for_stmt: &Node, source: &str) -> Vec<String> {
+    let mut cursor = for_stmt.walk();
+    let Some(clause) = for_stmt
+        .named_children(&mut cursor)
+        .find(|n| matches!(n.kind(), "range_clause" | "for_clause"))
+    else {
+        return Vec::new();
+    };
+
+    let left = match clause.kind() {
+        "range_clause" => {
+            let declares = (0..clause.child_count())
+                .filter_map(|i| clause.child(i))
+                .any(|c| c.kind() == ":=");
+            if !declares {
+                return Vec::new();
+            }
+            clause.child_by_field_name("left")
+        }
+        _ => clause
+            .child_by_field_name("initializer")
+            .filter(|init| init.kind() == "short_var_declaration")
+            .and_then(|init| init.child_by_field_name("left")),
+    };
+    left.map(|l| identifiers(&l, source))
+        .unwrap_or_default()
+        .into_iter()
+        .filter(|name| name != "_")
+        .collect()
+}
+
+/// Whether a `var := var` (or any `:=` redeclaring `var`) precedes `stmt`
+/// in one of the blocks between `for_stmt` and `stmt`.
+fn copied_before(for_stmt: &Node, stmt: &Node, var: &str, source: &str) -> bool {
+    let mut current = *stmt;
+    while current.id() != for_stmt.id() {
+        let mut sibling = current.prev_named_sibling();
+        while let Some(s) = sibling {
+            if s.kind() == "short_var_declaration"
+                && s.child_by_field_name("left")
+                    .is_some_and(|l| identifiers(&l, source).iter().any(|n| n == var))
+            {
+                return true;
+            }
+            sibling = s.prev_named_sibling();
+        }
+        match current.parent() {
+            Some(parent) => current = parent,
+            None => break,
+        }
+    }
+    false
+}
+
+/// Parameter names declared in a parameter list.
+fn declared_names(params: &Node, source: &str) -> Vec<String> {
+    let mut names = Vec::new();
+    let mut cursor = params.walk();
+    for param in params.named_children(&mut cursor) {
+        let mut inner = param.walk();
+        for name in param.children_by_field_name("name", &mut inner) {
+            if let Ok(text) = name.utf8_text(source.as_bytes()) {
+                names.push(text.to_string());
+            }
+        }
+    }
+    names
+}
+
+/// Identifier texts directly inside an expression list.
+fn identifiers(list: &Node, source: &str) -> Vec<String> {
+    let mut cursor = list.walk();
+    list.named_children(&mut cursor)
+        .filter(|n| n.kind() == "identifier")
+        .filter_map(|n| n.utf8_text(source.as_bytes()).ok())
+        .map(str::to_string)
+        .collect()
+}
+
+/// Whether `name` is read anywhere under `node`.
+fn uses_identifier(node: &Node, name: &str, source: &str) -> bool {
+    if node.kind() == "identifier" {
+        return node.utf8_text(source.as_bytes()) == Ok(name);
+    }
+    let mut cursor = node.walk();
+    let found = node
+        .named_children(&mut cursor)
+        .any(|child| uses_identifier(&child, name, source));
+    found
+}
@@ -0,0 +1,129 @@
+//! Copy-paste detection for AST patterns with `min_tokens`.
+//!
+//! Each captured body is reduced to a token stream in which identifiers and
+//! literals are replaced by their kind, so a copy with renamed variables or
+//! other constants still matches. Every window of `min_tokens` tokens is
+//! hashed; a body sharing a window with an earlier body of the same file is
+//! reported once, with the repeated lines of both.
+
+use std::collections::hash_map::DefaultHasher;
+use std::collections::HashMap;
+use std::hash::{Hash, Hasher};
+use std::ops::Range;
+use tree_sitter::Node;
+
+/// Bodies seen so far in one file, for one pattern.
+pub(crate) struct Duplicates {
+    min_tokens: usize,
+    bodies: Vec<Body>,
+    /// Window hash -> (index into `bodies`, first token of the window).
+    windows: HashMap<u64, (usize, usize)>,
+}
+
+struct Body {
+    bytes: Range<usize>,
+    /// Normalized token hash and 1-based line of each token.
+    tokens: Vec<(u64, usize)>,
+}
+
+impl Duplicates {
+    pub(crate) fn new(min_tokens: usize) -> Self {
+        Self {
+            min_tokens: min_tokens.max(1),
+            bodies: Vec::new(),
+            windows: HashMap::new(),
+        }
+    }
+
+    /// Record `body` and describe where it repeats an earlier body, as
+    /// `lines 30-41 repeat lines 12-23`, or None when it does not.
+    ///
+    /// Bodies must arrive in source order. A body nested in an earlier one,
+    /// such as a closure inside a function, is not compared with it.
+    pub(crate) fn check(&mut self, body: &Node, source: &str) -> Option<String> {
+        let mut tokens = Vec::new();
+        collect_tokens(body, source, &mut tokens);
+        let bytes = body.byte_range();
+        let hashes: Vec<u64> = tokens.windows(self.min_tokens).map(window_hash).collect();
+
+        let mut detail = None;
+        for (start, hash) in hashes.iter().enumerate() {
+            let Some(&(index, other_start)) = self.windows.get(hash) else {
+                continue;
+            };
+            let other = &self.bodies[index];
+            if other.bytes.start <= bytes.start && bytes.end <= other.bytes.end {
+                continue;
+            }
+            // Hashes can collide, so the run is measured on the tokens
+            let run = tokens[start..]
+                .iter()
+                .zip(&other.tokens[other_start..])
+                .take_while(|(a, b)| a.0 == b.0)
+                .count();
+            if run < self.min_tokens {
+                continue;
+            }
+            detail = Some(format!(
+                "lines {}-{} repeat lines {}-{}",
+                tokens[start].1,
+                tokens[start + run - 1].1,
+                other.tokens[other_start].1,
+                other.tokens[other_start + run - 1].1
+            ));
+            break;
+        }
+
+        let index = self.bodies.len();
+        for (start, hash) in hashes.into_iter().enumerate() {
+            self.windows.entry(hash).or_insert((index, start));
+        }
+        self.bodies.push(Body { bytes, tokens });
+        detail
+    }
+}
+
+/// Literal kinds holding code or other expressions, tokenized like any node.
+const COMPOUND_LITERALS: &[&str] = &["func_literal", "composite_literal"];
+
+/// Push the normalized tokens under `node`, skipping comments.
+fn collect_tokens(node: &Node, source: &str, tokens: &mut Vec<(u64, usize)>) {
+    let kind = node.kind();
+    if kind == "comment" {
+        return;
+    }
+    let line = node.start_position().row + 1;
+    // Literals are one token whatever their inner structure
+    if kind.ends_with("literal") && node.is_named() && !COMPOUND_LITERALS.contains(&kind) {
+        tokens.push((token_hash(kind), line));
+        return;
+    }
+    if node.child_count() == 0 {
+        let token = if node.is_named() && !kind.ends_with("identifier") {
+            // Named leaves other than identifiers, like `true` or `nil`, keep their text
+            node.utf8_text(source.as_bytes()).unwrap_or(kind)
+        } else {
+            kind
+        };
+        tokens.push((token_hash(token), line));
+        return;
+    }
+    let mut cursor = node.walk();
+    for child in node.children(&mut cursor) {
+        collect_tokens(&child, source, tokens);
+    }
+}
+
+fn token_hash(token: &str) -> u64 {
+    let mut hasher = DefaultHasher::new();
+    token.hash(&mut hasher);
+    hasher.finish()
+}
+
+fn window_hash(window: &[(u64, usize)]) -> u64 {
+    let mut hasher = DefaultHasher::new();
+    for (token, _) in window {
+        token.hash(&mut hasher);
+    }
+    hasher.finish()
+}
@@ -0,0 +1,171 @@
+//! Filtering and grouping over a list of findings.
+
+use super::Finding;
+use crate::config::Severity;
+use crate::report::compare_findings;
+use std::borrow::Borrow;
+use std::collections::BTreeMap;
+
+/// Helpers for building custom reports from a list of findings.
+///
+/// Implemented for slices of [`Finding`] and of `&Finding`, so the result
+/// of [`Findings::filter`] can be grouped again. Every returned list is in
+/// report order (file, line, column, pattern).
+///
+/// ```
+/// use antislop::{Config, Findings, Scanner, Severity};
+///
+/// let scanner = Scanner::new(Config::default().patterns).unwrap();
+/// let result = scanner.scan_file("example.py", "# TODO: implement\n# FIXME: broken\n");
+///
+/// let serious = result.findings.filter(|f| f.severity >= Severity::Medium);
+/// for (pattern, findings) in serious.by_pattern() {
+///     println!("{}: {}", pattern, findings.len());
+/// }
+/// ```
+pub trait Findings {
+    /// Findings for which `pred` returns true.
+    fn filter(&self, pred: impl FnMut(&Finding) -> bool) -> Vec<&Finding>;
+
+    /// Findings grouped by severity, least severe first.
+    fn by_severity(&self) -> BTreeMap<Severity, Vec<&Finding>>;
+
+    /// Findings grouped by pattern id (the regex for patterns without one).
+    fn by_pattern(&self) -> BTreeMap<&str, Vec<&Finding>>;
+
+    /// Findings grouped by file path.
+    fn by_file(&self) -> BTreeMap<&str, Vec<&Finding>>;
+}
+
+impl<F: Borrow<Finding>> Findings for [F] {
+    fn filter(&self, mut pred: impl FnMut(&Finding) -> bool) -> Vec<&Finding> {
+        let mut kept: Vec<&Finding> = self
+            .iter()
+            .map(Borrow::borrow)
+            .filter(|f| pred(f))
+            .collect();
+        kept.sort_by(|a, b| compare_findings(a, b));
+        kept
+    }
+
+    fn by_severity(&self) -> BTreeMap<Severity, Vec<&Finding>> {
+        group(self, |f| f.severity.clone())
+    }
+
+    fn by_pattern(&self) -> BTreeMap<&str, Vec<&Finding>> {
+        group(self, Finding::pattern_key)
+    }
+
+    fn by_file(&self) -> BTreeMap<&str, Vec<&Finding>> {
+        group(self, |f| f.file.as_str())
+    }
+}
+
+fn group<'a, F, K>(
+    findings: &'a [F],
+    key: impl Fn(&'a Finding) -> K,
+) -> BTreeMap<K, Vec<&'a Finding>>
+where
+    F: Borrow<Finding>,
+    K: Ord,
+{
+    let mut groups: BTreeMap<K, Vec<&Finding>> = BTreeMap::new();
+    for finding in findings.iter().map(Borrow::borrow) {
+        groups.entry(key(finding)).or_default().push(finding);
+    }
+    for list in groups.values_mut() {
+        list.sort_by(|a, b| compare_findings(a, b));
+    }
+    groups
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::PatternCategory;
+
+    fn finding(file: &str, line: usize, severity: Severity, id: Option<&str>) -> Finding {
+        Finding {
+            file: file.to_string(),
+            line,
+            column: 1,
+            severity,
+            category: PatternCategory::Placeholder,
+            message: "TODO".to_string(),
+            match_text: "TODO".to_string(),
+            pattern_regex: "(?i)todo".to_string(),
+            pattern_id: id.map(str::to_string),
+            tags: vec![],
+            confidence: Default::default(),
+            source_line: None,
+            context_before: None,
+            context_after: None,
+            fix: None,
+            suggestion: None,
+        }
+    }
+
+    fn sample() -> Vec<Finding> {
+        vec![
+            finding("b.go", 3, Severity::High, Some("go-sql-concat-query")),
+            finding("a.py", 9, Severity::Medium, Some("todo-marker")),
+            finding("a.py", 2, Severity::Low, None),
+            finding("b.go", 1, Severity::Medium, Some("todo-marker")),
+        ]
+    }
+
+    fn positions(findings: &[&Finding]) -> Vec<(String, usize)> {
+        findings.iter().map(|f| (f.file.clone(), f.line)).collect()
+    }
+
+    #[test]
+    fn test_filter_keeps_report_order() {
+        let findings = sample();
+        let kept = findings.filter(|f| f.severity >= Severity::Medium);
+        assert_eq!(
+            positions(&kept),
+            vec![
+                ("a.py".to_string(), 9),
+                ("b.go".to_string(), 1),
+                ("b.go".to_string(), 3)
+            ]
+        );
+    }
+
+    #[test]
+    fn test_group_by() {
+        let findings = sample();
+
+        let by_severity = findings.by_severity();
+        let severities: Vec<_> = by_severity.keys().cloned().collect();
+        assert_eq!(
+            severities,
+            vec![Severity::Low, Severity::Medium, Severity::High]
+        );
+        assert_eq!(by_severity[&Severity::Medium].len(), 2);
+
+        let by_pattern = findings.by_pattern();
+        assert_eq!(
+            by_pattern.keys().copied().collect::<Vec<_>>(),
+            vec!["(?i)todo", "go-sql-concat-query", "todo-marker"]
+        );
+        assert_eq!(
+            positions(&by_pattern["todo-marker"]),
+            vec![("a.py".to_string(), 9), ("b.go".to_string(), 1)]
+        );
+
+        let by_file = findings.by_file();
+        assert_eq!(
+            positions(&by_file["b.go"]),
+            vec![("b.go".to_string(), 1), ("b.go".to_string(), 3)]
+        );
+    }
+
+    #[test]
+    fn test_filtered_findings_group_again() {
+        let findings = sample();
+        let go = findings.filter(|f| f.file.ends_with(".go"));
+        assert_eq!(go.by_pattern().len(), 2);
+        assert_eq!(go.by_file().len(), 1);
+    }
+}
@@ -3,18 +3,31 @@
 //! This module provides the core scanning functionality, extracting comments
 //! and matching against slop patterns.
 
+mod findings;
+mod overrides;
+mod package;
 mod patterns;
 mod regex_fallback;
 
+#[cfg(feature = "tree-sitter")]
+mod checks;
+#[cfg(feature = "tree-sitter")]
+mod duplication;
 #[cfg(feature = "tree-sitter")]
 mod tree_sitter;
 
+pub use findings::Findings;
+pub use overrides::Overrides;
+pub use package::{
+    go_files, Declaration, ErasedFlow, PackageContext, PackageDetector, PackageDetectorFactory,
+    PackageSymbols, TypeSwitch,
+};
 pub use patterns::{CompiledPattern, PatternRegistry};
 pub use regex_fallback::RegexExtractor;
 
-use crate::config::{Pattern, PatternCategory, Severity};
+use crate::config::{Confidence, Pattern, PatternCategory, Severity, TestRules};
 use crate::Result;
-use std::collections::HashMap;
+use std::collections::{HashMap, HashSet};
 use std::path::Path;
 
 /// A comment extracted from source code.
@@ -47,6 +60,14 @@ pub struct Finding {
     pub match_text: String,
     /// The regex pattern that matched.
     pub pattern_regex: String,
+    /// Identifier of the pattern that matched, if it declares one.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub pattern_id: Option<String>,
+    /// Tags of the pattern that matched.
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub tags: Vec<String>,
+    /// Confidence of the pattern that matched.
+    pub confidence: Confidence,
     /// The full source line containing the finding.
     #[serde(skip_serializing_if = "Option::is_none")]
     pub source_line: Option<String>,
@@ -56,6 +77,178 @@ pub struct Finding {
     /// Context line(s) after the finding.
     #[serde(skip_serializing_if = "Option::is_none")]
     pub context_after: Option<String>,
+    /// Replacement that resolves the finding, for patterns that define one.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub fix: Option<SuggestedFix>,
+    /// How to resolve the finding, from the pattern's `suggestion`.
+    #[serde(skip_serializing_if = "Option::is_none")]
+    pub suggestion: Option<String>,
+}
+
+/// A replacement of a source range that resolves a finding.
+///
+/// Positions are 1-indexed; columns count bytes like [`Finding::column`],
+/// and the end position is exclusive.
+#[derive(Debug, Clone, PartialEq, Eq, serde::Serialize)]
+pub struct SuggestedFix {
+    /// Line where the replaced range starts.
+    pub start_line: usize,
+    /// Column where the replaced range starts.
+    pub start_column: usize,
+    /// Line where the replaced range ends.
+    pub end_line: usize,
+    /// Column just past the end of the replaced range.
+    pub end_column: usize,
+    /// Text to put in place of the range.
+    pub replacement: String,
+}
+
+impl Finding {
+    /// The pattern id, or the regex for patterns without one.
+    pub fn pattern_key(&self) -> &str {
+        self.pattern_id.as_deref().unwrap_or(&self.pattern_regex)
+    }
+
+    /// Position-independent identity of this finding.
+    ///
+    /// Built from the file, the pattern and the trimmed source line, so the
+    /// fingerprint survives code moving up or down the file.
+    pub fn fingerprint(&self) -> String {
+        self.fingerprint_as(&self.file)
+    }
+
+    /// [`Finding::fingerprint`] as if the finding were in `file`.
+    pub(crate) fn fingerprint_as(&self, file: &str) -> String {
+        let text = self
+            .source_line
+            .as_deref()
+            .map(str::trim)
+            .unwrap_or(&self.match_text);
+        fingerprint(file, self.pattern_key(), text)
+    }
+}
+
+/// Hash of the parts of a [`Finding::fingerprint`].
+pub(crate) fn fingerprint(file: &str, rule: &str, text: &str) -> String {
+    // FNV-1a, which unlike DefaultHasher is stable across Rust releases.
+    let mut hash: u64 = 0xcbf2_9ce4_8422_2325;
+    for part in [file, rule, text] {
+        for byte in part.bytes().chain(std::iter::once(0)) {
+            hash ^= u64::from(byte);
+            hash = hash.wrapping_mul(0x0100_0000_01b3);
+        }
+    }
+    format!("{:016x}", hash)
+}
+
+/// A file the parser could not read cleanly.
+#[derive(Debug, Clone, PartialEq, Eq, serde::Serialize)]
+pub struct ParseError {
+    /// File path as scanned.
+    pub file: String,
+    /// Line of the first syntax error (1-indexed).
+    pub line: usize,
+    /// Column of the first syntax error (1-indexed, in bytes).
+    pub column: usize,
+    /// What is wrong there, e.g. `` missing `}` ``.
+    pub message: String,
+}
+
+impl std::fmt::Display for ParseError {
+    fn fmt(&self, f: &mut std::fmt::Formatter<'_>) -> std::fmt::Result {
+        write!(
+            f,
+            "{}:{}:{}: {}",
+            self.file, self.line, self.column, self.message
+        )
+    }
+}
+
+/// Inline directive that suppresses findings on its line or the next one.
+const IGNORE_DIRECTIVE: &str = "antislop:ignore";
+
+/// Whether an `antislop:ignore` directive covers `finding`.
+///
+/// The directive applies to its own line, or to the line below when nothing
+/// but a comment marker precedes it. On its own it covers every pattern;
+/// followed by comma-separated pattern ids
+/// (`antislop:ignore go-too-many-params,todo-marker`) it covers only those.
+fn is_suppressed(lines: &[&str], finding: &Finding) -> bool {
+    let line = finding.line.saturating_sub(1);
+    let own = lines.get(line).map(|text| (text, false));
+    let above = line
+        .checked_sub(1)
+        .and_then(|i| lines.get(i))
+        .map(|text| (text, true));
+
+    own.into_iter().chain(above).any(|(text, standalone)| {
+        let Some(pos) = text.find(IGNORE_DIRECTIVE) else {
+            return false;
+        };
+        if standalone && text[..pos].chars().any(char::is_alphanumeric) {
+            return false;
+        }
+        match text[pos + IGNORE_DIRECTIVE.len()..]
+            .split_whitespace()
+            .next()
+        {
+            None => true,
+            Some(ids) => finding
+                .pattern_id
+                .as_deref()
+                .is_some_and(|id| ids.split(',').any(|i| i == id)),
+        }
+    })
+}
+
+/// Directive that opts a whole file out of every detector.
+const FILE_IGNORE_DIRECTIVE: &str = "antislop:file-ignore";
+
+/// Lines at the top of a file searched for [`FILE_IGNORE_DIRECTIVE`].
+const FILE_DIRECTIVE_LINES: usize = 20;
+
+/// Whether `path` is a test file by its language's naming convention:
+/// `_test.go`, `test_*.py` and `*_test.py`, `.test.` and `.spec.` files, or
+/// any file under a `tests`, `test` or `__tests__` directory.
+///
+/// Only directories below `root`, the path the scan started from, count, so
+/// a checkout under `/home/u/test/` is not all tests. An empty `root`
+/// counts every directory of `path`.
+pub fn is_test_file(path: impl AsRef<Path>, root: impl AsRef<Path>) -> bool {
+    let path = path.as_ref();
+    let relative = path.strip_prefix(root.as_ref()).unwrap_or(path);
+    let in_tests = relative.parent().is_some_and(|dir| {
+        dir.components()
+            .any(|c| matches!(c.as_os_str().to_str(), Some("tests" | "test" | "__tests__")))
+    });
+    let Some(name) = path.file_name().and_then(|n| n.to_str()) else {
+        return in_tests;
+    };
+    let stem = name.split('.').next().unwrap_or(name);
+    in_tests
+        || name.ends_with("_test.go")
+        || (name.ends_with(".py") && (stem.starts_with("test_") || stem.ends_with("_test")))
+        || name.contains(".test.")
+        || name.contains(".spec.")
+}
+
+/// Whether `content` opts out of scanning with an `antislop:file-ignore`
+/// comment.
+///
+/// The directive must be on a line of its own, with nothing but a comment
+/// marker before it, within the first 20 lines, so a mention of it in
+/// ordinary code or further down does not count.
+pub fn is_file_ignored(content: &str) -> bool {
+    content.lines().take(FILE_DIRECTIVE_LINES).any(|line| {
+        let Some(pos) = line.find(FILE_IGNORE_DIRECTIVE) else {
+            return false;
+        };
+        let before = &line[..pos];
+        let after = &line[pos + FILE_IGNORE_DIRECTIVE.len()..];
+        !before.chars().any(char::is_alphanumeric)
+            && !before.contains('"')
+            && after.trim().trim_end_matches("*/").trim().is_empty()
+    })
 }
 
 /// Result of scanning a single file.
@@ -67,9 +260,14 @@ pub struct FileScanResult {
     pub findings: Vec<Finding>,
     /// Total slop score for this file.
     pub score: u32,
+    /// Symbols of a Go file, kept for checks across its package.
+    #[serde(skip)]
+    pub package: Option<PackageSymbols>,
 }
 
 /// Summary of a scan operation.
+///
+/// Every report format reads its totals from this one type.
 #[derive(Debug, Clone, serde::Serialize)]
 pub struct ScanSummary {
     /// Number of files scanned.
@@ -84,41 +282,118 @@ pub struct ScanSummary {
     pub by_severity: HashMap<Severity, usize>,
     /// Findings grouped by category.
     pub by_category: HashMap<PatternCategory, usize>,
+    /// Findings grouped by pattern id (the regex for patterns without one).
+    pub by_pattern: HashMap<String, usize>,
+    /// Totals per scan root, when the scan started from more than one path.
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    pub roots: Vec<RootSummary>,
+}
+
+/// Totals for the files found under one of the paths a scan started from.
+#[derive(Debug, Clone, PartialEq, Eq, serde::Serialize)]
+pub struct RootSummary {
+    /// The root path as given on the command line.
+    pub path: String,
+    /// Number of files scanned under this root.
+    pub files_scanned: usize,
+    /// Number of those files with findings.
+    pub files_with_findings: usize,
+    /// Total number of findings.
+    pub total_findings: usize,
+    /// Slop score of this root.
+    pub total_score: u32,
 }
 
 impl ScanSummary {
     /// Create a summary from scan results.
     pub fn new(results: &[FileScanResult]) -> Self {
+        let findings: Vec<&Finding> = results.iter().flat_map(|r| &r.findings).collect();
+        Self::summarize(findings, results.len())
+    }
+
+    /// Summarize findings from a scan of `files_scanned` files.
+    ///
+    /// The score is the sum of finding severities, so it reflects any
+    /// findings dropped by suppression or `--fail-on-new` before this call.
+    pub fn summarize<'a>(
+        findings: impl IntoIterator<Item = &'a Finding>,
+        files_scanned: usize,
+    ) -> Self {
         let mut summary = Self {
-            files_scanned: results.len(),
+            files_scanned,
             files_with_findings: 0,
             total_findings: 0,
             total_score: 0,
             by_severity: HashMap::new(),
             by_category: HashMap::new(),
+            by_pattern: HashMap::new(),
+            roots: Vec::new(),
         };
 
-        for result in results {
-            if !result.findings.is_empty() {
-                summary.files_with_findings += 1;
-            }
-            summary.total_findings += result.findings.len();
-            summary.total_score += result.score;
-
-            for finding in &result.findings {
-                *summary
-                    .by_severity
-                    .entry(finding.severity.clone())
-                    .or_insert(0) += 1;
-                *summary
-                    .by_category
-                    .entry(finding.category.clone())
-                    .or_insert(0) += 1;
-            }
+        let mut files = HashSet::new();
+        for finding in findings {
+            files.insert(finding.file.as_str());
+            summary.total_findings += 1;
+            summary.total_score += finding.severity.score();
+            *summary
+                .by_severity
+                .entry(finding.severity.clone())
+                .or_insert(0) += 1;
+            *summary
+                .by_category
+                .entry(finding.category.clone())
+                .or_insert(0) += 1;
+            *summary
+                .by_pattern
+                .entry(finding.pattern_key().to_string())
+                .or_insert(0) += 1;
         }
+        summary.files_with_findings = files.len();
 
         summary
     }
+
+    /// Add per-root totals, given each scanned file as a `(root, file)` pair.
+    ///
+    /// Roots keep the order they first appear in `files`. Nothing is added
+    /// when every file comes from the same root.
+    pub fn with_roots<'a>(
+        mut self,
+        files: impl IntoIterator<Item = (&'a str, &'a str)>,
+        findings: &[Finding],
+    ) -> Self {
+        let mut order: Vec<&str> = Vec::new();
+        let mut root_of: HashMap<&str, &str> = HashMap::new();
+        let mut counts: HashMap<&str, usize> = HashMap::new();
+        for (root, file) in files {
+            if !order.contains(&root) {
+                order.push(root);
+            }
+            root_of.insert(file, root);
+            *counts.entry(root).or_insert(0) += 1;
+        }
+        if order.len() < 2 {
+            return self;
+        }
+
+        self.roots = order
+            .into_iter()
+            .map(|root| {
+                let under = findings
+                    .iter()
+                    .filter(|f| root_of.get(f.file.as_str()) == Some(&root));
+                let totals = Self::summarize(under, counts[root]);
+                RootSummary {
+                    path: root.to_string(),
+                    files_scanned: totals.files_scanned,
+                    files_with_findings: totals.files_with_findings,
+                    total_findings: totals.total_findings,
+                    total_score: totals.total_score,
+                }
+            })
+            .collect();
+        self
+    }
 }
 
 /// Language detection strategy.
@@ -248,30 +523,180 @@ pub trait CommentExtractor {
 /// The main scanner.
 pub struct Scanner {
     registry: PatternRegistry,
+    /// Ids of patterns resolved across a package by [`Scanner::resolve_packages`].
+    package_ids: Vec<String>,
+    /// Ids of patterns whose ranges over a channel are resolved the same way.
+    channel_ids: Vec<String>,
+    /// Ids of patterns whose chains of erased values are followed the same way.
+    erased_ids: Vec<String>,
+    /// Ids of patterns whose type switches are checked against the package's types.
+    switch_ids: Vec<String>,
+    /// Ids of patterns reported when too many of a package's functions
+    /// match, with the percentage each allows.
+    ratio_ids: Vec<(String, usize)>,
+    /// Whole-package detectors added with [`Scanner::with_package_detector`].
+    package_detectors: Vec<PackageDetectorFactory>,
+    /// Overrides for test files, set with [`Scanner::with_test_rules`].
+    tests: TestRules,
 }
 
 impl Scanner {
     /// Create a new scanner with the given patterns.
     pub fn new(patterns: Vec<Pattern>) -> Result<Self> {
+        let ids_with = |check: &str| -> Vec<String> {
+            patterns
+                .iter()
+                .filter(|p| p.check.as_deref() == Some(check))
+                .filter_map(|p| p.id.clone())
+                .collect()
+        };
+        let package_ids = ids_with("package-unreferenced");
+        let channel_ids = ids_with("package-unclosed");
+        let erased_ids = ids_with("package-erased");
+        let switch_ids = ids_with("package-type-switch");
+        let ratio_ids = patterns
+            .iter()
+            .filter(|p| p.check.as_deref() == Some("package-recover-ratio"))
+            .filter_map(|p| Some((p.id.clone()?, p.max_percent.unwrap_or(50))))
+            .collect();
         let registry = PatternRegistry::new(patterns)?;
-        Ok(Self { registry })
+        Ok(Self {
+            registry,
+            package_ids,
+            channel_ids,
+            erased_ids,
+            switch_ids,
+            ratio_ids,
+            package_detectors: Vec::new(),
+            tests: TestRules::default(),
+        })
+    }
+
+    /// Run a [`PackageDetector`] on every Go package, built by `factory`
+    /// once per package, in [`Scanner::resolve_packages`].
+    pub fn with_package_detector(
+        mut self,
+        factory: impl Fn() -> Box<dyn PackageDetector> + Send + Sync + 'static,
+    ) -> Self {
+        self.package_detectors.push(Box::new(factory));
+        self
+    }
+
+    /// Drop or re-rate findings in files [`is_test_file`] accepts, as
+    /// `tests` says.
+    pub fn with_test_rules(mut self, tests: TestRules) -> Self {
+        self.tests = tests;
+        self
+    }
+
+    /// Whether Go files keep their [`PackageSymbols`] for package-wide work.
+    fn needs_symbols(&self) -> bool {
+        !self.package_ids.is_empty()
+            || !self.channel_ids.is_empty()
+            || !self.erased_ids.is_empty()
+            || !self.switch_ids.is_empty()
+            || !self.ratio_ids.is_empty()
+            || !self.package_detectors.is_empty()
+    }
+
+    /// Finish package-wide checks once every file has been scanned.
+    ///
+    /// Candidates from `package-unreferenced` patterns are kept only when no
+    /// scanned file of the same Go package uses the function, and those from
+    /// `package-unclosed` patterns only when none closes the channel.
+    /// `package-erased` candidates are kept when they start a long enough
+    /// chain of calls passing an erased value, and `package-type-switch`
+    /// candidates when the switch leaves out implementers of its interface.
+    /// `package-recover-ratio` candidates are counted, and a package where
+    /// more than the pattern's `max_percent` of functions are candidates
+    /// gets one finding on its `package` clause.
+    /// Detectors from [`Scanner::with_package_detector`] run last, so their
+    /// findings are not filtered.
+    pub fn resolve_packages(&self, results: &mut [FileScanResult]) {
+        package::retain_unresolved(results, &self.package_ids, &self.channel_ids);
+        package::retain_erased_chains(results, &self.erased_ids);
+        package::retain_partial_switches(results, &self.switch_ids);
+        package::report_recover_ratio(results, &self.ratio_ids);
+        package::run_detectors(results, &self.package_detectors);
+    }
+
+    /// Whether `finding` comes from a pattern that [`Scanner::resolve_packages`]
+    /// resolves, and so is only a candidate straight after [`Scanner::scan_file`].
+    pub fn is_package_finding(&self, finding: &Finding) -> bool {
+        finding.pattern_id.as_ref().is_some_and(|id| {
+            self.package_ids.contains(id)
+                || self.channel_ids.contains(id)
+                || self.erased_ids.contains(id)
+                || self.switch_ids.contains(id)
+                || self.ratio_ids.iter().any(|(i, _)| i == id)
+        })
+    }
+
+    /// The first syntax error in `content`, for languages parsed with
+    /// tree-sitter. Other languages are never reported.
+    ///
+    /// [`Scanner::scan_file`] still runs on files with errors, since the
+    /// parser recovers around them; this lets a caller skip them instead.
+    pub fn parse_error(&self, path: &str, content: &str) -> Option<ParseError> {
+        #[cfg(feature = "tree-sitter")]
+        {
+            let lang = Language::from_path(Path::new(path));
+            if lang.has_tree_sitter() {
+                let mut extractor = self::tree_sitter::get_extractor(lang)?;
+                let (line, column, message) = extractor.syntax_error(content)?;
+                return Some(ParseError {
+                    file: path.to_string(),
+                    line,
+                    column,
+                    message,
+                });
+            }
+        }
+        #[cfg(not(feature = "tree-sitter"))]
+        let _ = (path, content);
+        None
     }
 
     /// Scan a single file.
+    ///
+    /// Findings of `package-unreferenced`, `package-unclosed`,
+    /// `package-erased` and `package-type-switch` patterns are only candidates until [`Scanner::resolve_packages`] has seen the rest
+    /// of the package.
+    ///
+    /// A file marked with [`is_file_ignored`] reports nothing, but its Go
+    /// symbols are still collected so its references count for the package.
     pub fn scan_file(&self, path: &str, content: &str) -> FileScanResult {
+        self.scan_file_under(path, "", content)
+    }
+
+    /// Scan a single file found under `root`, the path the scan started
+    /// from, which [`is_test_file`] needs to tell test directories apart.
+    pub fn scan_file_under(
+        &self,
+        path: &str,
+        root: impl AsRef<Path>,
+        content: &str,
+    ) -> FileScanResult {
         let lang = Language::from_path(Path::new(path));
+        if is_file_ignored(content) {
+            return FileScanResult {
+                path: path.to_string(),
+                findings: Vec::new(),
+                score: 0,
+                package: self.ignored_file_symbols(lang, content),
+            };
+        }
+
         let mut comment_findings = self.findings_from_comments(path, lang, content);
 
         // Also run AST-level detection if available
         #[cfg(feature = "tree-sitter")]
         if lang.has_tree_sitter() {
             if let Some(mut extractor) = self::tree_sitter::get_extractor(lang) {
-                // Collect pattern references for AST detection
-                let patterns: Vec<&Pattern> =
-                    self.registry.patterns.iter().map(|p| &p.pattern).collect();
-                // Convert Vec<&Pattern> to a slice that lives long enough
-                let pattern_refs: Vec<Pattern> = patterns.iter().map(|p| (**p).clone()).collect();
-                let ast_findings = extractor.extract_ast_findings(content, &pattern_refs);
+                // The syntax tree is dropped when this returns, so at most one
+                // tree per file is alive at a time.
+                let patterns = self.registry.patterns.iter().map(|p| &p.pattern);
+                let ast_findings = extractor.extract_ast_findings(content, patterns);
 
                 // Set file path and add to results
                 for mut finding in ast_findings {
@@ -279,10 +704,60 @@ impl Scanner {
                     comment_findings.score += finding.severity.score();
                     comment_findings.findings.push(finding);
                 }
+                if lang == Language::Go && self.needs_symbols() {
+                    comment_findings.package = extractor.package_symbols(content);
+                }
             }
         }
 
+        let lines: Vec<&str> = content.lines().collect();
+        let before = comment_findings.findings.len();
         comment_findings
+            .findings
+            .retain(|f| !is_suppressed(&lines, f));
+        let test_rules = !self.tests.is_empty() && is_test_file(path, root);
+        if test_rules {
+            self.apply_test_rules(&mut comment_findings.findings);
+        }
+        if test_rules || comment_findings.findings.len() != before {
+            comment_findings.score = comment_findings
+                .findings
+                .iter()
+                .map(|f| f.severity.score())
+                .sum();
+        }
+
+        comment_findings
+    }
+
+    /// Apply the [`TestRules`] to the findings of one test file.
+    fn apply_test_rules(&self, findings: &mut Vec<Finding>) {
+        findings.retain(|f| {
+            f.pattern_id
+                .as_ref()
+                .map_or(true, |id| !self.tests.disable.contains(id))
+        });
+        for finding in findings {
+            let severity = finding
+                .pattern_id
+                .as_ref()
+                .and_then(|id| self.tests.severity.get(id));
+            if let Some(severity) = severity {
+                finding.severity = severity.clone();
+            }
+        }
+    }
+
+    /// Go symbols of a file marked `antislop:file-ignore`, when package-wide
+    /// checks or detectors need them.
+    fn ignored_file_symbols(&self, lang: Language, content: &str) -> Option<PackageSymbols> {
+        #[cfg(feature = "tree-sitter")]
+        if lang == Language::Go && self.needs_symbols() {
+            return self::tree_sitter::get_extractor(lang)?.package_symbols(content);
+        }
+        #[cfg(not(feature = "tree-sitter"))]
+        let _ = (lang, content);
+        None
     }
 
     /// Extract comments using the best available method.
@@ -315,18 +790,19 @@ impl Scanner {
 
                 if let Some(regex) = &pattern.compiled {
                     if let Some(mat) = regex.find(&comment.content) {
+                        if pattern
+                            .exclude
+                            .as_ref()
+                            .is_some_and(|ex| ex.is_match(&comment.content))
+                        {
+                            continue;
+                        }
+
                         let severity = pattern.pattern.severity.clone();
                         total_score += severity.score();
 
-                        // Extract context lines (1-indexed to 0-indexed)
-                        let line_idx = comment.line.saturating_sub(1);
-                        let source_line = lines.get(line_idx).map(|s| s.to_string());
-                        let context_before = if line_idx > 0 {
-                            lines.get(line_idx - 1).map(|s| s.to_string())
-                        } else {
-                            None
-                        };
-                        let context_after = lines.get(line_idx + 1).map(|s| s.to_string());
+                        let (source_line, context_before, context_after) =
+                            line_context(&lines, comment.line);
 
                         findings.push(Finding {
                             file: path.to_string(),
@@ -337,9 +813,14 @@ impl Scanner {
                             message: pattern.pattern.message.clone(),
                             match_text: mat.as_str().to_string(),
                             pattern_regex: pattern.pattern.regex.to_string(),
+                            pattern_id: pattern.pattern.id.clone(),
+                            tags: pattern.pattern.tags.clone(),
+                            confidence: pattern.pattern.confidence,
                             source_line,
                             context_before,
                             context_after,
+                            fix: None,
+                            suggestion: pattern.pattern.suggestion.clone(),
                         });
                     }
                 }
@@ -350,10 +831,29 @@ impl Scanner {
             path: path.to_string(),
             findings,
             score: total_score,
+            package: None,
         }
     }
 }
 
+/// Get the source line at `line` (1-indexed) plus the lines around it.
+///
+/// Returns `(source_line, context_before, context_after)`.
+pub(crate) fn line_context(
+    lines: &[&str],
+    line: usize,
+) -> (Option<String>, Option<String>, Option<String>) {
+    let line_idx = line.saturating_sub(1);
+    let source_line = lines.get(line_idx).map(|s| s.to_string());
+    let context_before = if line_idx > 0 {
+        lines.get(line_idx - 1).map(|s| s.to_string())
+    } else {
+        None
+    };
+    let context_after = lines.get(line_idx + 1).map(|s| s.to_string());
+    (source_line, context_before, context_after)
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -362,20 +862,60 @@ mod tests {
     fn test_patterns() -> Vec<Pattern> {
         vec![
             Pattern {
+                id: None,
                 regex: RegexPattern::new("(?i)TODO:".to_string()).unwrap(),
                 severity: Severity::Medium,
                 message: "Placeholder comment found".to_string(),
                 category: PatternCategory::Placeholder,
+                tags: vec![],
+                confidence: Default::default(),
                 ast_query: None,
                 languages: vec![],
+                exclude_regex: None,
+                exclude_enclosing: None,
+                exclude_packages: vec![],
+                min_version: None,
+                max_version: None,
+                max_params: None,
+                param_type: None,
+                max_elements: None,
+                max_fields: None,
+                mode_mask: None,
+                min_tokens: None,
+                max_percent: None,
+                exported_only: None,
+                suggestion: None,
+                check: None,
+                fix: None,
+                docs: None,
             },
             Pattern {
+                id: None,
                 regex: RegexPattern::new("(?i)for now".to_string()).unwrap(),
                 severity: Severity::Low,
                 message: "Deferral phrase detected".to_string(),
                 category: PatternCategory::Deferral,
+                tags: vec![],
+                confidence: Default::default(),
                 ast_query: None,
                 languages: vec![],
+                exclude_regex: None,
+                exclude_enclosing: None,
+                exclude_packages: vec![],
+                min_version: None,
+                max_version: None,
+                max_params: None,
+                param_type: None,
+                max_elements: None,
+                max_fields: None,
+                mode_mask: None,
+                min_tokens: None,
+                max_percent: None,
+                exported_only: None,
+                suggestion: None,
+                check: None,
+                fix: None,
+                docs: None,
             },
         ]
     }
@@ -402,6 +942,82 @@ mod tests {
         assert_eq!(result.score, 6);
     }
 
+    #[test]
+    fn test_test_rules_apply_to_test_files() {
+        let mut patterns = test_patterns();
+        patterns[0].id = Some("todo".to_string());
+        patterns[1].id = Some("for-now".to_string());
+        let tests = TestRules {
+            disable: vec!["todo".to_string()],
+            severity: [("for-now".to_string(), Severity::Info)].into(),
+        };
+        let scanner = Scanner::new(patterns).unwrap().with_test_rules(tests);
+        let code = "# TODO: fix this\n# for now we do this\n";
+
+        let result = scanner.scan_file("lib.py", code);
+        assert_eq!(result.findings.len(), 2);
+        assert_eq!(result.score, 6);
+
+        let result = scanner.scan_file("test_lib.py", code);
+        assert_eq!(result.findings.len(), 1);
+        assert_eq!(result.findings[0].severity, Severity::Info);
+        assert_eq!(result.score, 0);
+    }
+
+    #[test]
+    fn test_is_test_file() {
+        for path in [
+            "pkg/server_test.go",
+            "test_lib.py",
+            "lib_test.py",
+            "src/app.test.ts",
+            "src/app.spec.js",
+            "tests/cli.rs",
+            "web/__tests__/app.js",
+        ] {
+            assert!(is_test_file(path, ""), "{}", path);
+        }
+        for path in ["pkg/server.go", "test.py", "testdata.go", "src/contest.rs"] {
+            assert!(!is_test_file(path, ""), "{}", path);
+        }
+
+        // Directories above the scan root do not count
+        assert!(!is_test_file(
+            "/home/u/test/repo/lib.go",
+            "/home/u/test/repo"
+        ));
+        assert!(is_test_file(
+            "/home/u/test/repo/tests/cli.rs",
+            "/home/u/test/repo"
+        ));
+        assert!(!is_test_file("./test/lib.py", "./test/lib.py"));
+        assert!(is_test_file("./tests/cli.rs", "."));
+    }
+
+    #[test]
+    fn test_exclude_regex_suppresses_match() {
+        let mut patterns = test_patterns();
+        patterns[0].exclude_regex = Some(RegexPattern::new(r"\(#\d+\)".to_string()).unwrap());
+        let scanner = Scanner::new(patterns).unwrap();
+        let code = "# TODO: tracked (#123)\n# TODO: untracked\n";
+        let result = scanner.scan_file("test.py", code);
+        assert_eq!(result.findings.len(), 1);
+        assert_eq!(result.findings[0].line, 2);
+    }
+
+    #[test]
+    fn test_line_context() {
+        let lines = ["a", "b", "c"];
+        assert_eq!(
+            line_context(&lines, 1),
+            (Some("a".to_string()), None, Some("b".to_string()))
+        );
+        assert_eq!(
+            line_context(&lines, 3),
+            (Some("c".to_string()), Some("b".to_string()), None)
+        );
+    }
+
     #[test]
     fn test_language_detection() {
         assert_eq!(Language::from_path(Path::new("test.py")), Language::Python);
@@ -489,9 +1105,14 @@ mod tests {
             message: "TODO comment found".to_string(),
             match_text: "TODO".to_string(),
             pattern_regex: "(?i)todo".to_string(),
+            pattern_id: None,
+            tags: vec![],
+            confidence: Default::default(),
             source_line: None,
             context_before: None,
             context_after: None,
+            fix: None,
+            suggestion: None,
         };
         assert_eq!(finding.file, "test.py");
         assert_eq!(finding.line, 10);
@@ -499,12 +1120,62 @@ mod tests {
         assert_eq!(finding.category, PatternCategory::Placeholder);
     }
 
+    #[test]
+    fn test_ignore_directive() {
+        let mut patterns = test_patterns();
+        patterns[0].id = Some("todo".to_string());
+        let scanner = Scanner::new(patterns).unwrap();
+
+        // The trailing directive on line 3 does not reach line 4
+        let code = "# antislop:ignore\n# TODO: a\n# TODO: b  antislop:ignore todo\n# TODO: c  antislop:ignore other\n# TODO: d\n";
+        let result = scanner.scan_file("a.py", code);
+        let lines: Vec<usize> = result.findings.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![4, 5]);
+        assert_eq!(result.score, 10);
+    }
+
+    #[test]
+    fn test_file_ignore_directive() {
+        let scanner = Scanner::new(test_patterns()).unwrap();
+        let ignored = scanner.scan_file(
+            "a.py",
+            "#!/usr/bin/env python\n# antislop:file-ignore\n# TODO: a\n",
+        );
+        assert!(ignored.findings.is_empty());
+        assert_eq!(ignored.score, 0);
+
+        // Mentioned in code, trailing text, or too far down: still scanned
+        let mention = "x = \"antislop:file-ignore\"\n# TODO: a\n";
+        assert_eq!(scanner.scan_file("a.py", mention).findings.len(), 1);
+        let trailing = "# antislop:file-ignore later\n# TODO: a\n";
+        assert_eq!(scanner.scan_file("a.py", trailing).findings.len(), 1);
+        let late = format!(
+            "{}# antislop:file-ignore\n# TODO: a\n",
+            "x = 1\n".repeat(20)
+        );
+        assert_eq!(scanner.scan_file("a.py", &late).findings.len(), 1);
+        assert!(is_file_ignored("/* antislop:file-ignore */\n"));
+    }
+
+    #[test]
+    fn test_fingerprint_ignores_position() {
+        let scanner = Scanner::new(test_patterns()).unwrap();
+        let before = scanner.scan_file("a.py", "# TODO: fix\n");
+        let after = scanner.scan_file("a.py", "x = 1\n\n    # TODO: fix\n");
+        let other = scanner.scan_file("b.py", "# TODO: fix\n");
+
+        let fp = before.findings[0].fingerprint();
+        assert_eq!(fp, after.findings[0].fingerprint());
+        assert_ne!(fp, other.findings[0].fingerprint());
+    }
+
     #[test]
     fn test_file_scan_result_struct() {
         let result = FileScanResult {
             path: "test.py".to_string(),
             findings: vec![],
             score: 0,
+            package: None,
         };
         assert_eq!(result.path, "test.py");
         assert!(result.findings.is_empty());
@@ -534,11 +1205,17 @@ mod tests {
                 message: "TODO".to_string(),
                 match_text: "TODO".to_string(),
                 pattern_regex: "(?i)todo".to_string(),
+                pattern_id: None,
+                tags: vec![],
+                confidence: Default::default(),
                 source_line: None,
                 context_before: None,
                 context_after: None,
+                fix: None,
+                suggestion: None,
             }],
             score: 5,
+            package: None,
         }];
         let summary = ScanSummary::new(&results);
         assert_eq!(summary.files_scanned, 1);
@@ -555,6 +1232,94 @@ mod tests {
         );
     }
 
+    #[test]
+    fn test_scan_summary_summarize() {
+        let finding = |file: &str, id: Option<&str>, severity: Severity| Finding {
+            file: file.to_string(),
+            line: 1,
+            column: 1,
+            severity,
+            category: PatternCategory::Placeholder,
+            message: "m".to_string(),
+            match_text: "m".to_string(),
+            pattern_regex: "(?i)hack".to_string(),
+            pattern_id: id.map(str::to_string),
+            tags: vec![],
+            confidence: Default::default(),
+            source_line: None,
+            context_before: None,
+            context_after: None,
+            fix: None,
+            suggestion: None,
+        };
+        let findings = vec![
+            finding("a.py", Some("todo-marker"), Severity::Medium),
+            finding("a.py", Some("todo-marker"), Severity::Medium),
+            finding("b.py", None, Severity::High),
+            finding("b.py", Some("go-interface-any"), Severity::Info),
+        ];
+
+        let summary = ScanSummary::summarize(&findings, 3);
+        assert_eq!(summary.files_scanned, 3);
+        assert_eq!(summary.files_with_findings, 2);
+        assert_eq!(summary.total_findings, 4);
+        assert_eq!(summary.total_score, 25);
+        assert_eq!(summary.by_severity[&Severity::Medium], 2);
+        assert_eq!(summary.by_severity[&Severity::Info], 1);
+        assert_eq!(summary.by_pattern["todo-marker"], 2);
+        assert_eq!(summary.by_pattern["(?i)hack"], 1);
+        assert_eq!(summary.by_pattern.len(), 3);
+    }
+
+    #[test]
+    fn test_scan_summary_with_roots() {
+        let finding = |file: &str| Finding {
+            file: file.to_string(),
+            line: 1,
+            column: 1,
+            severity: Severity::Medium,
+            category: PatternCategory::Placeholder,
+            message: "m".to_string(),
+            match_text: "m".to_string(),
+            pattern_regex: "(?i)todo".to_string(),
+            pattern_id: None,
+            tags: vec![],
+            confidence: Default::default(),
+            source_line: None,
+            context_before: None,
+            context_after: None,
+            fix: None,
+            suggestion: None,
+        };
+        let findings = vec![finding("b/x.py"), finding("b/x.py"), finding("a/y.py")];
+        let files = [("b", "b/x.py"), ("b", "b/z.py"), ("a", "a/y.py")];
+
+        let summary = ScanSummary::summarize(&findings, 3).with_roots(files, &findings);
+        assert_eq!(summary.total_findings, 3);
+        assert_eq!(
+            summary.roots,
+            vec![
+                RootSummary {
+                    path: "b".to_string(),
+                    files_scanned: 2,
+                    files_with_findings: 1,
+                    total_findings: 2,
+                    total_score: 10,
+                },
+                RootSummary {
+                    path: "a".to_string(),
+                    files_scanned: 1,
+                    files_with_findings: 1,
+                    total_findings: 1,
+                    total_score: 5,
+                },
+            ]
+        );
+
+        let single = ScanSummary::summarize(&findings, 2).with_roots([("b", "b/x.py")], &findings);
+        assert!(single.roots.is_empty());
+    }
+
     #[test]
     fn test_scan_summary_new_empty_results() {
         let results = vec![
@@ -562,11 +1327,13 @@ mod tests {
                 path: "clean.py".to_string(),
                 findings: vec![],
                 score: 0,
+                package: None,
             },
             FileScanResult {
                 path: "sloppy.py".to_string(),
                 findings: vec![],
                 score: 0,
+                package: None,
             },
         ];
         let summary = ScanSummary::new(&results);
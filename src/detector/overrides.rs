@@ -0,0 +1,217 @@
+//! `[[overrides]]`: severity changes and drops chosen by path, id and
+//! category, applied to the findings of a whole run once detection,
+//! package resolution and the filename checks are done.
+
+use super::{FileScanResult, Finding};
+use crate::config::{Override, Severity};
+use crate::{Error, Result};
+use globset::{GlobBuilder, GlobSet, GlobSetBuilder};
+
+/// Compiled `[[overrides]]` rules, in config order.
+#[derive(Debug, Clone, Default)]
+pub struct Overrides {
+    rules: Vec<Rule>,
+}
+
+#[derive(Debug, Clone)]
+struct Rule {
+    path: Option<GlobSet>,
+    id: Option<String>,
+    category: Option<String>,
+    if_severity: Option<Severity>,
+    /// New severity, or None to drop the finding.
+    severity: Option<Severity>,
+}
+
+impl Overrides {
+    /// Compile `rules`. Each needs exactly one of `severity` and `drop`,
+    /// and a `path` that is a valid glob.
+    pub fn new(rules: &[Override]) -> Result<Self> {
+        let rules = rules
+            .iter()
+            .enumerate()
+            .map(|(index, rule)| {
+                let invalid = |reason: String| {
+                    Error::ConfigInvalid(format!("overrides[{}]: {}", index, reason))
+                };
+                if rule.drop == rule.severity.is_some() {
+                    return Err(invalid("set either severity or drop = true".to_string()));
+                }
+                let path = rule
+                    .path
+                    .as_deref()
+                    .map(|glob| path_globs(glob).map_err(|e| invalid(e.to_string())))
+                    .transpose()?;
+                Ok(Rule {
+                    path,
+                    id: rule.id.clone(),
+                    category: rule.category.clone(),
+                    if_severity: rule.if_severity.clone(),
+                    severity: rule.severity.clone(),
+                })
+            })
+            .collect::<Result<_>>()?;
+        Ok(Self { rules })
+    }
+
+    /// Apply the rules to every result, recomputing the score of each file
+    /// whose findings changed.
+    pub fn apply_results(&self, results: &mut [FileScanResult]) {
+        for result in results {
+            if self.apply(&mut result.findings) {
+                result.score = result.findings.iter().map(|f| f.severity.score()).sum();
+            }
+        }
+    }
+
+    /// Apply the rules in order to `findings`, matching paths against each
+    /// finding's file. A later rule sees the severity an earlier one gave.
+    /// Returns whether any finding changed.
+    pub fn apply(&self, findings: &mut Vec<Finding>) -> bool {
+        if self.rules.is_empty() {
+            return false;
+        }
+        let mut changed = false;
+        findings.retain_mut(|finding| {
+            let path = finding.file.replace('\\', "/");
+            let path = path.trim_start_matches("./").to_string();
+            for rule in &self.rules {
+                if !rule.matches(&path, finding) {
+                    continue;
+                }
+                changed = true;
+                match &rule.severity {
+                    Some(severity) => finding.severity = severity.clone(),
+                    None => return false,
+                }
+            }
+            true
+        });
+        changed
+    }
+}
+
+impl Rule {
+    fn matches(&self, path: &str, finding: &Finding) -> bool {
+        let path = self.path.as_ref().map_or(true, |g| g.is_match(path));
+        let id = self
+            .id
+            .as_ref()
+            .map_or(true, |id| finding.pattern_id.as_ref() == Some(id));
+        let category = self.category.as_ref().map_or(true, |c| {
+            format!("{:?}", finding.category).eq_ignore_ascii_case(c)
+                || finding.tags.iter().any(|t| t.eq_ignore_ascii_case(c))
+        });
+        let severity = self
+            .if_severity
+            .as_ref()
+            .map_or(true, |s| &finding.severity == s);
+        path && id && category && severity
+    }
+}
+
+/// `glob`, plus `**/glob` when it is relative, so `payments/**` also
+/// matches `services/payments/charge.go`.
+fn path_globs(glob: &str) -> std::result::Result<GlobSet, globset::Error> {
+    let mut set = GlobSetBuilder::new();
+    let build = |g: &str| GlobBuilder::new(g).literal_separator(true).build();
+    set.add(build(glob.trim_start_matches('/'))?);
+    if !glob.starts_with('/') && !glob.starts_with("**") {
+        set.add(build(&format!("**/{}", glob))?);
+    }
+    set.build()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::PatternCategory;
+
+    fn finding(file: &str, id: &str, severity: Severity, tags: &[&str]) -> Finding {
+        Finding {
+            file: file.to_string(),
+            line: 1,
+            column: 1,
+            severity,
+            category: PatternCategory::Stub,
+            message: String::new(),
+            match_text: String::new(),
+            pattern_regex: String::new(),
+            pattern_id: Some(id.to_string()),
+            tags: tags.iter().map(|t| t.to_string()).collect(),
+            confidence: Default::default(),
+            source_line: None,
+            context_before: None,
+            context_after: None,
+            fix: None,
+            suggestion: None,
+        }
+    }
+
+    fn rules(toml: &str) -> Result<Overrides> {
+        #[derive(serde::Deserialize)]
+        struct Rules {
+            overrides: Vec<Override>,
+        }
+        let rules: Rules = toml::from_str(toml)?;
+        Overrides::new(&rules.overrides)
+    }
+
+    #[test]
+    fn test_overrides_remap_and_drop() {
+        let overrides = rules(
+            r#"
+[[overrides]]
+path = "payments/**"
+category = "security"
+severity = "critical"
+
+[[overrides]]
+path = "legacy/**"
+if_severity = "info"
+drop = true
+"#,
+        )
+        .unwrap();
+
+        let charge = "./svc/payments/charge.go";
+        let mut findings = vec![
+            finding(charge, "go-sql-concat-query", Severity::High, &["security"]),
+            finding(
+                charge,
+                "go-unread-error",
+                Severity::Medium,
+                &["correctness"],
+            ),
+        ];
+        assert!(overrides.apply(&mut findings));
+        assert_eq!(findings[0].severity, Severity::Critical);
+        assert_eq!(findings[1].severity, Severity::Medium);
+
+        let old = "legacy/old.go";
+        let mut findings = vec![
+            finding(old, "go-modernize", Severity::Info, &["style"]),
+            finding(old, "go-unread-error", Severity::Medium, &["correctness"]),
+        ];
+        assert!(overrides.apply(&mut findings));
+        assert_eq!(findings.len(), 1);
+        assert_eq!(findings[0].pattern_id.as_deref(), Some("go-unread-error"));
+
+        let mut findings = vec![finding(
+            "src/legacy.go",
+            "go-modernize",
+            Severity::Info,
+            &[],
+        )];
+        assert!(!overrides.apply(&mut findings));
+        assert_eq!(findings.len(), 1);
+    }
+
+    #[test]
+    fn test_overrides_need_one_action() {
+        let err = rules("[[overrides]]\npath = \"a/**\"\n").unwrap_err();
+        assert!(err.to_string().contains("overrides[0]"), "{}", err);
+        assert!(rules("[[overrides]]\nseverity = \"low\"\ndrop = true\n").is_err());
+        assert!(rules("[[overrides]]\npath = \"a/[\"\ndrop = true\n").is_err());
+    }
+}
@@ -0,0 +1,1120 @@
+//! Checks that need every file of a Go package.
+//!
+//! Files are scanned one at a time, so a pattern whose `check` is
+//! `package-unreferenced` reports every unexported function as a candidate,
+//! and one whose check is `package-unclosed` every range over a channel.
+//! Each Go file also records the names it references and the channels it
+//! closes, and once all files are scanned [`retain_unresolved`] drops the
+//! candidates some file in the same package uses or closes.
+//!
+//! `package-erased` candidates are functions passing an `interface{}` or
+//! `map[string]interface{}` value on; [`retain_erased_chains`] keeps those
+//! that start a chain of such calls through the package.
+//!
+//! `package-type-switch` candidates are type switches without a `default`
+//! on a value of a named type; [`retain_partial_switches`] keeps those
+//! whose type is an interface of the package and whose cases leave out
+//! some of the package's types implementing it.
+//!
+//! Library users can add their own whole-package checks as a
+//! [`PackageDetector`], run by the same step.
+
+use super::{FileScanResult, Finding};
+use std::collections::{BTreeMap, HashMap, HashSet};
+use std::fs;
+use std::path::{Path, PathBuf};
+
+/// What one Go file declares and references.
+#[derive(Debug, Clone, Default, PartialEq, Eq)]
+pub struct PackageSymbols {
+    /// Package name, without the `_test` suffix of an external test package.
+    pub name: String,
+    /// Line of the `package` clause (1-indexed).
+    pub line: usize,
+    /// Unexported top-level functions and methods.
+    pub declared: Vec<Declaration>,
+    /// Unexported identifiers and field names used anywhere except in a
+    /// function's own name or body, plus names from `//go:linkname` and
+    /// `//export`.
+    pub references: HashSet<String>,
+    /// Channels passed to `close`, by their last name (`ch` for `s.ch`).
+    pub closed: HashSet<String>,
+    /// How each top-level function passes erased values on.
+    pub erased: Vec<ErasedFlow>,
+    /// Method names of each interface declared with methods and nothing
+    /// else; interfaces embedding others are left out.
+    pub interfaces: HashMap<String, Vec<String>>,
+    /// Names of the other top-level types.
+    pub types: HashSet<String>,
+    /// Method names declared on each receiver type, pointer or not.
+    pub methods: HashMap<String, HashSet<String>>,
+    /// Type switches without a `default`, on a value of a named type.
+    pub switches: Vec<TypeSwitch>,
+    /// Top-level functions and methods with a body.
+    pub functions: usize,
+}
+
+/// How a function handles `interface{}`, `any` and `map[string]...` of
+/// them, for chains of calls that never name a type.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct ErasedFlow {
+    /// Line of the `func` keyword (1-indexed).
+    pub line: usize,
+    /// Function name.
+    pub name: String,
+    /// Whether a parameter has an erased type.
+    pub receives: bool,
+    /// Whether the function asserts or type-switches on such a parameter.
+    pub narrows: bool,
+    /// Functions called by bare name with an erased value, in call order.
+    pub passes_to: Vec<String>,
+}
+
+/// A type switch without a `default` case.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct TypeSwitch {
+    /// Line of the `switch` keyword (1-indexed).
+    pub line: usize,
+    /// Declared type of the switched value.
+    pub interface: String,
+    /// Types with a case, without a leading `*`.
+    pub cases: Vec<String>,
+}
+
+/// An unexported function or method declaration.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Declaration {
+    /// Line of the `func` keyword (1-indexed).
+    pub line: usize,
+    /// Function or method name.
+    pub name: String,
+    /// Whether this is a method.
+    pub method: bool,
+}
+
+/// The package a [`PackageDetector`] is about to see.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct PackageContext {
+    /// Directory holding the package.
+    pub dir: PathBuf,
+    /// Package name, without the `_test` suffix of an external test package.
+    pub name: String,
+    /// Paths of the scanned files in the package, sorted.
+    pub files: Vec<String>,
+    /// Whether every `.go` file in `dir` was scanned. When false, code
+    /// outside the scanned set may use what the package declares.
+    pub complete: bool,
+}
+
+/// A detector that judges a Go package as a whole, such as one looking for
+/// declarations no file uses.
+///
+/// Files are scanned one at a time, possibly on many threads, and keep the
+/// [`PackageSymbols`] of each Go file. Once every file is scanned,
+/// [`Scanner::resolve_packages`](super::Scanner::resolve_packages) groups
+/// the results by package and, for each package, takes a new detector from
+/// the factory given to
+/// [`Scanner::with_package_detector`](super::Scanner::with_package_detector)
+/// and calls [`init`](Self::init) once, [`file`](Self::file) once per
+/// scanned file in path order, and then [`finish`](Self::finish) once.
+///
+/// Packages may be handled on different threads at the same time, but each
+/// has its own instance, so a detector needs no locking. Findings from
+/// `finish` are added to the result of the file they name, or to the
+/// package's first file when they name none of its files.
+pub trait PackageDetector: Send {
+    /// Start on a package, before any of its files.
+    fn init(&mut self, package: &PackageContext);
+
+    /// See one scanned file of the package.
+    fn file(&mut self, result: &FileScanResult) {
+        let _ = result;
+    }
+
+    /// Report the package's findings, after its last file.
+    fn finish(&mut self) -> Vec<Finding>;
+}
+
+/// Builds one [`PackageDetector`] per package.
+pub type PackageDetectorFactory = Box<dyn Fn() -> Box<dyn PackageDetector> + Send + Sync>;
+
+/// Run every detector from `factories` on each Go package in `results` and
+/// add the findings they report.
+pub(crate) fn run_detectors(results: &mut [FileScanResult], factories: &[PackageDetectorFactory]) {
+    if factories.is_empty() {
+        return;
+    }
+
+    // Keyed by (dir, name) so packages are visited in a stable order
+    let mut groups: BTreeMap<(PathBuf, String), Vec<usize>> = BTreeMap::new();
+    for (index, result) in results.iter().enumerate() {
+        let Some(ref symbols) = result.package else {
+            continue;
+        };
+        let dir = Path::new(&result.path)
+            .parent()
+            .map(Path::to_path_buf)
+            .unwrap_or_default();
+        groups
+            .entry((dir, symbols.name.clone()))
+            .or_default()
+            .push(index);
+    }
+    let packages: Vec<(PackageContext, Vec<usize>)> = groups
+        .into_iter()
+        .map(|((dir, name), mut indices)| {
+            indices.sort_by(|a, b| results[*a].path.cmp(&results[*b].path));
+            let files: Vec<String> = indices.iter().map(|i| results[*i].path.clone()).collect();
+            let scanned: HashSet<PathBuf> = files.iter().map(PathBuf::from).collect();
+            let complete = go_files(&dir).is_subset(&scanned);
+            let context = PackageContext {
+                dir,
+                name,
+                files,
+                complete,
+            };
+            (context, indices)
+        })
+        .collect();
+
+    let shared: &[FileScanResult] = results;
+    let judge = |(context, indices): &(PackageContext, Vec<usize>)| -> Vec<Finding> {
+        let mut findings = Vec::new();
+        for factory in factories {
+            let mut detector = factory();
+            detector.init(context);
+            for index in indices {
+                detector.file(&shared[*index]);
+            }
+            findings.extend(detector.finish());
+        }
+        findings
+    };
+    #[cfg(feature = "parallel")]
+    let reported: Vec<Vec<Finding>> = {
+        use rayon::prelude::*;
+        packages.par_iter().map(judge).collect()
+    };
+    #[cfg(not(feature = "parallel"))]
+    let reported: Vec<Vec<Finding>> = packages.iter().map(judge).collect();
+
+    for ((_, indices), findings) in packages.into_iter().zip(reported) {
+        for finding in findings {
+            let index = indices
+                .iter()
+                .copied()
+                .find(|i| results[*i].path == finding.file)
+                .unwrap_or(indices[0]);
+            results[index].score += finding.severity.score();
+            results[index].findings.push(finding);
+        }
+    }
+}
+
+/// What every file of one package references and closes.
+#[derive(Default)]
+struct Package {
+    references: HashSet<String>,
+    closed: HashSet<String>,
+}
+
+/// Drop candidate findings that some file of the same package answers:
+/// functions from `unreferenced` that are referenced, and ranges from
+/// `unclosed` over a channel that is closed.
+///
+/// A package is only judged when every `.go` file in its directory,
+/// tests included, was scanned; otherwise a caller outside the scanned set
+/// could exist and all its candidates are dropped. Methods are kept when
+/// the package calls `MethodByName`, since reflection can reach them.
+pub(crate) fn retain_unresolved(
+    results: &mut [FileScanResult],
+    unreferenced: &[String],
+    unclosed: &[String],
+) {
+    if unreferenced.is_empty() && unclosed.is_empty() {
+        return;
+    }
+
+    let mut scanned: HashMap<PathBuf, HashSet<PathBuf>> = HashMap::new();
+    let mut packages: HashMap<(PathBuf, String), Package> = HashMap::new();
+    for result in results.iter() {
+        let Some(ref symbols) = result.package else {
+            continue;
+        };
+        let path = Path::new(&result.path);
+        let dir = path.parent().map(Path::to_path_buf).unwrap_or_default();
+        scanned
+            .entry(dir.clone())
+            .or_default()
+            .insert(path.to_path_buf());
+        let package = packages.entry((dir, symbols.name.clone())).or_default();
+        package
+            .references
+            .extend(symbols.references.iter().cloned());
+        package.closed.extend(symbols.closed.iter().cloned());
+    }
+    let complete: HashSet<PathBuf> = scanned
+        .into_iter()
+        .filter(|(dir, files)| go_files(dir).is_subset(files))
+        .map(|(dir, _)| dir)
+        .collect();
+
+    for result in results.iter_mut() {
+        let before = result.findings.len();
+        let path = Path::new(&result.path);
+        let dir = path.parent().map(Path::to_path_buf).unwrap_or_default();
+        let symbols = result.package.as_ref();
+        let package = symbols.and_then(|s| packages.get(&(dir.clone(), s.name.clone())));
+        let reflects = package.is_some_and(|p| p.references.contains("MethodByName"));
+
+        result.findings.retain(|finding| {
+            let Some(id) = finding.pattern_id.as_ref() else {
+                return true;
+            };
+            let function = unreferenced.contains(id);
+            if !function && !unclosed.contains(id) {
+                return true;
+            }
+            let (Some(symbols), Some(package)) = (symbols, package) else {
+                return false;
+            };
+            if !complete.contains(&dir) {
+                return false;
+            }
+            if function {
+                symbols
+                    .declared
+                    .iter()
+                    .find(|d| d.line == finding.line)
+                    .is_some_and(|d| {
+                        !package.references.contains(&d.name) && !(d.method && reflects)
+                    })
+            } else {
+                let channel = finding.match_text.rsplit('.').next().unwrap_or_default();
+                !package.closed.contains(channel)
+            }
+        });
+
+        if result.findings.len() != before {
+            result.score = result.findings.iter().map(|f| f.severity.score()).sum();
+        }
+    }
+}
+
+/// Calls a `package-erased` chain needs before it is reported.
+const MIN_ERASED_CALLS: usize = 3;
+
+/// Keep `package-erased` candidates from `ids` that start a chain of at
+/// least [`MIN_ERASED_CALLS`] calls, each passing an erased value to a
+/// function of the same package that takes one and never narrows it,
+/// and append the chain to their message.
+///
+/// Only chain starts are kept: a function some other link passes the
+/// value to is reported as part of that chain.
+pub(crate) fn retain_erased_chains(results: &mut [FileScanResult], ids: &[String]) {
+    if ids.is_empty() {
+        return;
+    }
+
+    let mut packages: HashMap<(PathBuf, String), HashMap<String, ErasedFlow>> = HashMap::new();
+    for result in results.iter() {
+        let Some(ref symbols) = result.package else {
+            continue;
+        };
+        let dir = Path::new(&result.path)
+            .parent()
+            .map(Path::to_path_buf)
+            .unwrap_or_default();
+        let functions = packages.entry((dir, symbols.name.clone())).or_default();
+        for flow in &symbols.erased {
+            functions.insert(flow.name.clone(), flow.clone());
+        }
+    }
+
+    for result in results.iter_mut() {
+        let before = result.findings.len();
+        let dir = Path::new(&result.path)
+            .parent()
+            .map(Path::to_path_buf)
+            .unwrap_or_default();
+        let symbols = result.package.as_ref();
+        let functions = symbols.and_then(|s| packages.get(&(dir, s.name.clone())));
+
+        result.findings.retain_mut(|finding| {
+            if !finding
+                .pattern_id
+                .as_ref()
+                .is_some_and(|id| ids.contains(id))
+            {
+                return true;
+            }
+            let (Some(symbols), Some(functions)) = (symbols, functions) else {
+                return false;
+            };
+            let Some(flow) = symbols.erased.iter().find(|f| f.line == finding.line) else {
+                return false;
+            };
+            // A caller that could start the chain itself
+            let passed_in = flow.receives
+                && functions
+                    .values()
+                    .any(|f| f.name != flow.name && !f.narrows && f.passes_to.contains(&flow.name));
+            if flow.narrows || passed_in {
+                return false;
+            }
+            let chain = longest_chain(flow, functions, &mut vec![flow.name.as_str()]);
+            if chain.len() <= MIN_ERASED_CALLS {
+                return false;
+            }
+            finding.message = format!("{} ({})", finding.message, chain.join(" -> "));
+            true
+        });
+
+        if result.findings.len() != before {
+            result.score = result.findings.iter().map(|f| f.severity.score()).sum();
+        }
+    }
+}
+
+/// The longest chain of names from `flow` through functions that take an
+/// erased value and do not narrow it, never visiting a name in `path`
+/// twice.
+fn longest_chain<'a>(
+    flow: &'a ErasedFlow,
+    functions: &'a HashMap<String, ErasedFlow>,
+    path: &mut Vec<&'a str>,
+) -> Vec<&'a str> {
+    let mut best = path.clone();
+    for callee in &flow.passes_to {
+        let Some(next) = functions.get(callee) else {
+            continue;
+        };
+        if !next.receives || next.narrows || path.contains(&next.name.as_str()) {
+            continue;
+        }
+        path.push(&next.name);
+        let chain = longest_chain(next, functions, path);
+        path.pop();
+        if chain.len() > best.len() {
+            best = chain;
+        }
+    }
+    best
+}
+
+/// Keep `package-type-switch` candidates from `ids` whose switched type is
+/// an interface declared in the package, whose cases name at least one of
+/// the package's types implementing it, and which leave out others, and
+/// append the missing types to their message.
+///
+/// A type implements an interface when its value and pointer methods
+/// together have every method name; signatures are not compared. A switch
+/// with a case for another interface of the package is dropped, since that
+/// case may cover the rest.
+pub(crate) fn retain_partial_switches(results: &mut [FileScanResult], ids: &[String]) {
+    if ids.is_empty() {
+        return;
+    }
+
+    // The types of every file of each package, merged
+    let mut packages: HashMap<(PathBuf, String), PackageSymbols> = HashMap::new();
+    for result in results.iter() {
+        let Some(ref symbols) = result.package else {
+            continue;
+        };
+        let dir = Path::new(&result.path)
+            .parent()
+            .map(Path::to_path_buf)
+            .unwrap_or_default();
+        let package = packages.entry((dir, symbols.name.clone())).or_default();
+        for (name, methods) in &symbols.interfaces {
+            package.interfaces.insert(name.clone(), methods.clone());
+        }
+        package.types.extend(symbols.types.iter().cloned());
+        for (receiver, methods) in &symbols.methods {
+            package
+                .methods
+                .entry(receiver.clone())
+                .or_default()
+                .extend(methods.iter().cloned());
+        }
+    }
+
+    for result in results.iter_mut() {
+        let before = result.findings.len();
+        let dir = Path::new(&result.path)
+            .parent()
+            .map(Path::to_path_buf)
+            .unwrap_or_default();
+        let symbols = result.package.as_ref();
+        let package = symbols.and_then(|s| packages.get(&(dir, s.name.clone())));
+
+        result.findings.retain_mut(|finding| {
+            if !finding
+                .pattern_id
+                .as_ref()
+                .is_some_and(|id| ids.contains(id))
+            {
+                return true;
+            }
+            let (Some(symbols), Some(package)) = (symbols, package) else {
+                return false;
+            };
+            let Some(switch) = symbols.switches.iter().find(|s| s.line == finding.line) else {
+                return false;
+            };
+            let Some(wanted) = package.interfaces.get(&switch.interface) else {
+                return false;
+            };
+            if wanted.is_empty()
+                || switch
+                    .cases
+                    .iter()
+                    .any(|c| package.interfaces.contains_key(c))
+            {
+                return false;
+            }
+            let mut implementers: Vec<&String> = package
+                .types
+                .iter()
+                .filter(|t| {
+                    package
+                        .methods
+                        .get(*t)
+                        .is_some_and(|m| wanted.iter().all(|w| m.contains(w)))
+                })
+                .collect();
+            implementers.sort();
+            if !implementers.iter().any(|t| switch.cases.contains(t)) {
+                return false;
+            }
+            let missing: Vec<&str> = implementers
+                .into_iter()
+                .filter(|t| !switch.cases.contains(t))
+                .map(String::as_str)
+                .collect();
+            if missing.is_empty() {
+                return false;
+            }
+            finding.message = format!("{} (missing {})", finding.message, missing.join(", "));
+            true
+        });
+
+        if result.findings.len() != before {
+            result.score = result.findings.iter().map(|f| f.severity.score()).sum();
+        }
+    }
+}
+
+/// Report each package where more than the allowed share of functions are
+/// `package-recover-ratio` candidates from `ids`, each with the percentage
+/// its pattern allows.
+///
+/// Candidates are only counted: each package over the limit gets one
+/// finding, on the `package` clause of its first file, whose message
+/// carries the ratio. Single functions are left to other patterns, so an
+/// exported function is reported once, by `go-defensive-recover`.
+pub(crate) fn report_recover_ratio(results: &mut [FileScanResult], ids: &[(String, usize)]) {
+    if ids.is_empty() {
+        return;
+    }
+
+    // Functions and first file (by path) of each package
+    let mut packages: HashMap<(PathBuf, String), (usize, usize)> = HashMap::new();
+    // Candidates of each pattern in each package, with the first one seen
+    let mut candidates: HashMap<(PathBuf, String, String), (usize, Finding)> = HashMap::new();
+    for index in 0..results.len() {
+        let result = &mut results[index];
+        let before = result.findings.len();
+        let package = result.package.as_ref().map(|symbols| {
+            let dir = Path::new(&result.path)
+                .parent()
+                .map(Path::to_path_buf)
+                .unwrap_or_default();
+            (dir, symbols.name.clone(), symbols.functions)
+        });
+
+        result.findings.retain(|finding| {
+            let Some(id) = finding
+                .pattern_id
+                .as_ref()
+                .filter(|id| ids.iter().any(|(i, _)| i == *id))
+            else {
+                return true;
+            };
+            if let Some((ref dir, ref name, _)) = package {
+                candidates
+                    .entry((dir.clone(), name.clone(), id.clone()))
+                    .or_insert_with(|| (0, finding.clone()))
+                    .0 += 1;
+            }
+            false
+        });
+        if result.findings.len() != before {
+            result.score = result.findings.iter().map(|f| f.severity.score()).sum();
+        }
+
+        if let Some((dir, name, functions)) = package {
+            let entry = packages.entry((dir, name)).or_insert((0, index));
+            entry.0 += functions;
+            if results[index].path < results[entry.1].path {
+                entry.1 = index;
+            }
+        }
+    }
+
+    let mut candidates: Vec<_> = candidates.into_iter().collect();
+    candidates.sort_by(|a, b| a.0.cmp(&b.0));
+    for ((dir, name, id), (count, mut finding)) in candidates {
+        let Some(&(total, index)) = packages.get(&(dir, name.clone())) else {
+            continue;
+        };
+        let max_percent = ids
+            .iter()
+            .find(|(i, _)| *i == id)
+            .map_or(0, |(_, max)| *max);
+        if total == 0 || count * 100 <= max_percent * total {
+            continue;
+        }
+        let result = &mut results[index];
+        let Some(ref symbols) = result.package else {
+            continue;
+        };
+        finding.file = result.path.clone();
+        finding.line = symbols.line;
+        finding.column = 1;
+        finding.match_text = format!("package {}", name);
+        finding.source_line = Some(finding.match_text.clone());
+        finding.context_before = None;
+        finding.context_after = None;
+        finding.fix = None;
+        finding.message = format!(
+            "{} ({} of {} functions in package {}, {}%)",
+            finding.message,
+            count,
+            total,
+            name,
+            count * 100 / total
+        );
+        result.score += finding.severity.score();
+        result.findings.push(finding);
+    }
+}
+
+/// The `.go` files directly inside `dir`: the files a package must have
+/// scanned for [`Scanner::resolve_packages`] to treat it as complete.
+///
+/// [`Scanner::resolve_packages`]: super::Scanner::resolve_packages
+pub fn go_files(dir: &Path) -> HashSet<PathBuf> {
+    let dir_to_read = if dir.as_os_str().is_empty() {
+        Path::new(".")
+    } else {
+        dir
+    };
+    let Ok(entries) = fs::read_dir(dir_to_read) else {
+        return HashSet::new();
+    };
+    entries
+        .filter_map(|entry| entry.ok())
+        .map(|entry| entry.file_name())
+        .filter(|name| name.to_string_lossy().ends_with(".go"))
+        .map(|name| dir.join(name))
+        .collect()
+}
+
+#[cfg(all(test, feature = "tree-sitter"))]
+mod tests {
+    use super::*;
+    use crate::config::{Confidence, Override, PatternCategory, Severity};
+    use crate::detector::Overrides;
+    use crate::{Config, Scanner};
+    use std::fs;
+    use tempfile::TempDir;
+
+    const LIB: &str = r#"package lib
+
+func Exported() int { return used() }
+
+func used() int { return 1 }
+
+func unused() int { return unused() }
+
+func onlyTests() int { return 2 }
+
+type T struct{}
+
+func (T) viaField() {}
+
+func (t T) dead() {}
+
+func init() {}
+
+func Call(t T) { t.viaField() }
+"#;
+
+    const LIB_TEST: &str = r#"package lib
+
+func TestOnly(t *testing.T) { _ = onlyTests() }
+"#;
+
+    /// Scan `names` out of a directory holding both files.
+    fn unused_lines(names: &[&str]) -> Vec<usize> {
+        let dir = TempDir::new().unwrap();
+        fs::write(dir.path().join("lib.go"), LIB).unwrap();
+        fs::write(dir.path().join("lib_test.go"), LIB_TEST).unwrap();
+
+        let patterns = Config::default()
+            .patterns
+            .into_iter()
+            .filter(|p| p.id.as_deref() == Some("go-unused-function"))
+            .collect();
+        let scanner = Scanner::new(patterns).unwrap();
+        let mut results: Vec<_> = names
+            .iter()
+            .map(|name| {
+                let path = dir.path().join(name);
+                let content = fs::read_to_string(&path).unwrap();
+                scanner.scan_file(&path.to_string_lossy(), &content)
+            })
+            .collect();
+        scanner.resolve_packages(&mut results);
+        results
+            .iter()
+            .flat_map(|r| r.findings.iter().map(|f| f.line))
+            .collect()
+    }
+
+    #[test]
+    fn test_unreferenced_functions_in_package() {
+        assert_eq!(unused_lines(&["lib.go", "lib_test.go"]), vec![7, 15]);
+    }
+
+    #[test]
+    fn test_incomplete_package_reports_nothing() {
+        assert!(unused_lines(&["lib.go"]).is_empty());
+    }
+
+    const PIPE: &str = r#"package pipe
+
+type worker struct {
+	jobs chan int
+	done chan struct{}
+}
+
+func sum(nums []int) int {
+	ch := make(chan int)
+	go produce(ch, nums)
+	total := 0
+	for n := range ch {
+		total += n
+	}
+	return total
+}
+
+func (w *worker) run() {
+	for j := range w.jobs {
+		_ = j
+	}
+	for range w.done {
+	}
+	for _, n := range []int{1} {
+		_ = n
+	}
+}
+
+func drain(in <-chan string) {
+	for s := range in {
+		_ = s
+	}
+}
+"#;
+
+    const PRODUCER: &str = r#"package pipe
+
+func produce(ch chan int, nums []int) {
+	for _, n := range nums {
+		ch <- n
+	}
+	close(ch)
+}
+
+func (w *worker) stop() { close(w.done) }
+"#;
+
+    #[test]
+    fn test_range_over_unclosed_channel() {
+        let dir = TempDir::new().unwrap();
+        fs::write(dir.path().join("pipe.go"), PIPE).unwrap();
+        fs::write(dir.path().join("producer.go"), PRODUCER).unwrap();
+
+        let patterns = Config::default()
+            .patterns
+            .into_iter()
+            .filter(|p| p.id.as_deref() == Some("go-range-unclosed-channel"))
+            .collect();
+        let scanner = Scanner::new(patterns).unwrap();
+        let mut results: Vec<_> = ["pipe.go", "producer.go"]
+            .iter()
+            .map(|name| {
+                let path = dir.path().join(name);
+                let content = fs::read_to_string(&path).unwrap();
+                scanner.scan_file(&path.to_string_lossy(), &content)
+            })
+            .collect();
+        assert_eq!(results[0].findings.len(), 4, "candidates before resolving");
+
+        scanner.resolve_packages(&mut results);
+        let findings: Vec<_> = results.iter().flat_map(|r| &r.findings).collect();
+        let lines: Vec<_> = findings.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![19, 30]);
+        assert!(findings[0].message.ends_with("(channel `w.jobs`)"));
+    }
+
+    const FLOW: &str = r#"package flow
+
+func Handle(user string) {
+	data := map[string]interface{}{"user": user}
+	process(data)
+	short(data)
+}
+
+func process(data map[string]interface{}) {
+	validate(data)
+}
+
+func short(v any) {
+	typed(v)
+}
+
+func typed(v any) {
+	if s, ok := v.(string); ok {
+		_ = s
+	}
+}
+"#;
+
+    const STORE: &str = r#"package flow
+
+func validate(data map[string]interface{}) {
+	save(data)
+}
+
+func save(data map[string]any) {
+	_ = data["user"]
+}
+"#;
+
+    #[test]
+    fn test_erased_value_chain() {
+        let dir = TempDir::new().unwrap();
+        fs::write(dir.path().join("flow.go"), FLOW).unwrap();
+        fs::write(dir.path().join("store.go"), STORE).unwrap();
+
+        let patterns = Config::default()
+            .patterns
+            .into_iter()
+            .filter(|p| p.id.as_deref() == Some("go-erased-value-chain"))
+            .collect();
+        let scanner = Scanner::new(patterns).unwrap();
+        let mut results: Vec<_> = ["flow.go", "store.go"]
+            .iter()
+            .map(|name| {
+                let path = dir.path().join(name);
+                let content = fs::read_to_string(&path).unwrap();
+                scanner.scan_file(&path.to_string_lossy(), &content)
+            })
+            .collect();
+        let candidates: Vec<_> = results[0].findings.iter().map(|f| f.line).collect();
+        assert_eq!(candidates, vec![3, 9, 13], "candidates before resolving");
+
+        scanner.resolve_packages(&mut results);
+        let findings: Vec<_> = results.iter().flat_map(|r| &r.findings).collect();
+        assert_eq!(findings.len(), 1);
+        assert_eq!(findings[0].line, 3);
+        assert!(
+            findings[0]
+                .message
+                .ends_with("(Handle -> process -> validate -> save)"),
+            "{}",
+            findings[0].message
+        );
+    }
+
+    const SHAPES: &str = r#"package shapes
+
+type Shape interface {
+	Sides() int
+}
+
+type Named interface {
+	Shape
+	Name() string
+}
+
+func Area(s Shape) float64 {
+	switch v := s.(type) {
+	case Circle:
+		return v.r * v.r * 3
+	case *Square:
+		return v.w * v.w
+	}
+	return 0
+}
+
+func Describe(s Shape) string {
+	switch s.(type) {
+	case Circle, *Square, Triangle:
+		return "known"
+	}
+	return "unknown"
+}
+
+func Guarded(s Shape) int {
+	switch s.(type) {
+	case Circle:
+		return 1
+	default:
+		return 0
+	}
+}
+"#;
+
+    const KINDS: &str = r#"package shapes
+
+type Circle struct{ r float64 }
+
+type Square struct{ w float64 }
+
+type Triangle struct{}
+
+type Hexagon struct{}
+
+func (Circle) Sides() int { return 0 }
+
+func (*Square) Sides() int { return 4 }
+
+func (t Triangle) Sides() int { return 3 }
+
+func (h Hexagon) Name() string { return "hexagon" }
+"#;
+
+    #[test]
+    fn test_partial_type_switch() {
+        let dir = TempDir::new().unwrap();
+        fs::write(dir.path().join("shapes.go"), SHAPES).unwrap();
+        fs::write(dir.path().join("kinds.go"), KINDS).unwrap();
+
+        let patterns = Config::default()
+            .patterns
+            .into_iter()
+            .filter(|p| p.id.as_deref() == Some("go-partial-type-switch"))
+            .collect();
+        let scanner = Scanner::new(patterns).unwrap();
+        let mut results: Vec<_> = ["shapes.go", "kinds.go"]
+            .iter()
+            .map(|name| {
+                let path = dir.path().join(name);
+                let content = fs::read_to_string(&path).unwrap();
+                scanner.scan_file(&path.to_string_lossy(), &content)
+            })
+            .collect();
+        let candidates: Vec<_> = results[0].findings.iter().map(|f| f.line).collect();
+        assert_eq!(candidates, vec![13, 23], "candidates before resolving");
+
+        scanner.resolve_packages(&mut results);
+        let findings: Vec<_> = results.iter().flat_map(|r| &r.findings).collect();
+        assert_eq!(findings.len(), 1);
+        assert_eq!(findings[0].line, 13);
+        assert!(
+            findings[0].message.ends_with("(missing Triangle)"),
+            "{}",
+            findings[0].message
+        );
+    }
+
+    const GUARDED: &str = r#"package guard
+
+func Load() {
+	defer func() { recover() }()
+}
+
+func parse() {
+	defer func() {
+		if r := recover(); r != nil {
+		}
+	}()
+}
+
+func read() {}
+"#;
+
+    const CALM: &str = r#"package calm
+
+func Load() {
+	defer func() { recover() }()
+}
+
+func parse() {}
+
+func read() {}
+"#;
+
+    #[test]
+    fn test_recover_ratio() {
+        let dir = TempDir::new().unwrap();
+        for (sub, code) in [("guard", GUARDED), ("calm", CALM)] {
+            fs::create_dir(dir.path().join(sub)).unwrap();
+            fs::write(dir.path().join(sub).join("lib.go"), code).unwrap();
+        }
+
+        let patterns = Config::default()
+            .patterns
+            .into_iter()
+            .filter(|p| p.id.as_deref() == Some("go-recover-everywhere"))
+            .collect();
+        let scanner = Scanner::new(patterns).unwrap();
+        let mut results: Vec<_> = ["guard/lib.go", "calm/lib.go"]
+            .iter()
+            .map(|name| {
+                let path = dir.path().join(name);
+                let content = fs::read_to_string(&path).unwrap();
+                scanner.scan_file(&path.to_string_lossy(), &content)
+            })
+            .collect();
+        assert_eq!(results[1].findings.len(), 1, "candidate before resolving");
+
+        scanner.resolve_packages(&mut results);
+        // One finding for the package, on its `package` clause
+        assert_eq!(results[0].findings.len(), 1);
+        let finding = &results[0].findings[0];
+        assert_eq!(
+            (finding.line, finding.match_text.as_str()),
+            (1, "package guard")
+        );
+        assert!(
+            finding
+                .message
+                .ends_with("(2 of 3 functions in package guard, 66%)"),
+            "{}",
+            finding.message
+        );
+        assert_eq!(results[0].score, finding.severity.score());
+        assert!(results[1].findings.is_empty());
+    }
+
+    /// Reports the calls each package's instance received, in order.
+    struct Census {
+        calls: Vec<String>,
+        package: Option<PackageContext>,
+    }
+
+    impl PackageDetector for Census {
+        fn init(&mut self, package: &PackageContext) {
+            self.calls.push(format!("init {}", package.name));
+            self.package = Some(package.clone());
+        }
+
+        fn file(&mut self, result: &FileScanResult) {
+            let name = Path::new(&result.path).file_name().unwrap();
+            self.calls.push(format!("file {}", name.to_string_lossy()));
+        }
+
+        fn finish(&mut self) -> Vec<Finding> {
+            self.calls.push("finish".to_string());
+            let package = self.package.take().unwrap();
+            vec![Finding {
+                file: package.files.last().cloned().unwrap(),
+                line: 1,
+                column: 1,
+                severity: Severity::Low,
+                category: PatternCategory::Stub,
+                message: format!(
+                    "{} (complete: {}): {}",
+                    package.name,
+                    package.complete,
+                    self.calls.join(", ")
+                ),
+                match_text: String::new(),
+                pattern_regex: String::new(),
+                pattern_id: Some("census".to_string()),
+                tags: Vec::new(),
+                confidence: Confidence::High,
+                source_line: None,
+                context_before: None,
+                context_after: None,
+                fix: None,
+                suggestion: None,
+            }]
+        }
+    }
+
+    #[test]
+    fn test_package_detector_sees_each_package_once() {
+        let dir = TempDir::new().unwrap();
+        fs::create_dir(dir.path().join("pipe")).unwrap();
+        let files = [
+            ("pipe/producer.go", PRODUCER),
+            ("lib.go", LIB),
+            ("pipe/pipe.go", PIPE),
+            ("lib_test.go", LIB_TEST),
+        ];
+        for (name, content) in files {
+            fs::write(dir.path().join(name), content).unwrap();
+        }
+
+        let scanner = Scanner::new(Vec::new()).unwrap().with_package_detector(|| {
+            Box::new(Census {
+                calls: Vec::new(),
+                package: None,
+            })
+        });
+        let mut results: Vec<_> = files
+            .iter()
+            .map(|(name, content)| {
+                let path = dir.path().join(name);
+                scanner.scan_file(&path.to_string_lossy(), content)
+            })
+            .collect();
+        scanner.resolve_packages(&mut results);
+
+        let messages: Vec<_> = results
+            .iter()
+            .map(|r| {
+                r.findings
+                    .iter()
+                    .map(|f| f.message.as_str())
+                    .collect::<Vec<_>>()
+            })
+            .collect();
+        assert_eq!(
+            messages,
+            vec![
+                vec!["pipe (complete: true): init pipe, file pipe.go, file producer.go, finish"],
+                vec![],
+                vec![],
+                vec!["lib (complete: true): init lib, file lib.go, file lib_test.go, finish"],
+            ]
+        );
+        assert_eq!(results[0].score, 1);
+
+        // [[overrides]] reach the findings package detectors add
+        let rules = [
+            Override {
+                path: Some("pipe/**".to_string()),
+                id: Some("census".to_string()),
+                drop: true,
+                ..Override::default()
+            },
+            Override {
+                id: Some("census".to_string()),
+                severity: Some(Severity::Critical),
+                ..Override::default()
+            },
+        ];
+        Overrides::new(&rules).unwrap().apply_results(&mut results);
+        assert!(results[0].findings.is_empty());
+        assert_eq!(results[0].score, 0);
+        assert_eq!(results[3].findings[0].severity, Severity::Critical);
+        assert_eq!(results[3].score, 50);
+    }
+}
@@ -10,6 +10,8 @@ pub struct CompiledPattern {
     pub pattern: Pattern,
     /// Compiled regex for matching.
     pub compiled: Option<Regex>,
+    /// Compiled exclusion regex, if the pattern defines one.
+    pub exclude: Option<Regex>,
 }
 
 /// Registry of slop detection patterns.
@@ -25,8 +27,15 @@ impl PatternRegistry {
             .into_iter()
             .map(|p| {
                 let compiled = Regex::new(&p.regex).map_err(Error::Regex)?;
+                let exclude = p
+                    .exclude_regex
+                    .as_deref()
+                    .map(Regex::new)
+                    .transpose()
+                    .map_err(Error::Regex)?;
                 Ok(CompiledPattern {
                     compiled: Some(compiled),
+                    exclude,
                     pattern: p,
                 })
             })
@@ -59,12 +68,32 @@ mod tests {
     #[test]
     fn test_registry_creation() {
         let patterns = vec![Pattern {
+            id: None,
             regex: RegexPattern::new("(?i)TODO:".to_string()).unwrap(),
             severity: Severity::Medium,
             message: "TODO".to_string(),
             category: PatternCategory::Placeholder,
+            tags: vec![],
+            confidence: Default::default(),
             ast_query: None,
             languages: vec![],
+            exclude_regex: None,
+            exclude_enclosing: None,
+            exclude_packages: vec![],
+            min_version: None,
+            max_version: None,
+            max_params: None,
+            param_type: None,
+            max_elements: None,
+            max_fields: None,
+            mode_mask: None,
+            min_tokens: None,
+            max_percent: None,
+            exported_only: None,
+            suggestion: None,
+            check: None,
+            fix: None,
+            docs: None,
         }];
 
         let registry = PatternRegistry::new(patterns);
@@ -85,28 +114,88 @@ mod tests {
     fn test_by_severity() {
         let patterns = vec![
             Pattern {
+                id: None,
                 regex: RegexPattern::new("(?i)HIGH:".to_string()).unwrap(),
                 severity: Severity::High,
                 message: "HIGH".to_string(),
                 category: PatternCategory::Stub,
+                tags: vec![],
+                confidence: Default::default(),
                 ast_query: None,
                 languages: vec![],
+                exclude_regex: None,
+                exclude_enclosing: None,
+                exclude_packages: vec![],
+                min_version: None,
+                max_version: None,
+                max_params: None,
+                param_type: None,
+                max_elements: None,
+                max_fields: None,
+                mode_mask: None,
+                min_tokens: None,
+                max_percent: None,
+                exported_only: None,
+                suggestion: None,
+                check: None,
+                fix: None,
+                docs: None,
             },
             Pattern {
+                id: None,
                 regex: RegexPattern::new("(?i)MEDIUM:".to_string()).unwrap(),
                 severity: Severity::Medium,
                 message: "MEDIUM".to_string(),
                 category: PatternCategory::Stub,
+                tags: vec![],
+                confidence: Default::default(),
                 ast_query: None,
                 languages: vec![],
+                exclude_regex: None,
+                exclude_enclosing: None,
+                exclude_packages: vec![],
+                min_version: None,
+                max_version: None,
+                max_params: None,
+                param_type: None,
+                max_elements: None,
+                max_fields: None,
+                mode_mask: None,
+                min_tokens: None,
+                max_percent: None,
+                exported_only: None,
+                suggestion: None,
+                check: None,
+                fix: None,
+                docs: None,
             },
             Pattern {
+                id: None,
                 regex: RegexPattern::new("(?i)LOW:".to_string()).unwrap(),
                 severity: Severity::Low,
                 message: "LOW".to_string(),
                 category: PatternCategory::Stub,
+                tags: vec![],
+                confidence: Default::default(),
                 ast_query: None,
                 languages: vec![],
+                exclude_regex: None,
+                exclude_enclosing: None,
+                exclude_packages: vec![],
+                min_version: None,
+                max_version: None,
+                max_params: None,
+                param_type: None,
+                max_elements: None,
+                max_fields: None,
+                mode_mask: None,
+                min_tokens: None,
+                max_percent: None,
+                exported_only: None,
+                suggestion: None,
+                check: None,
+                fix: None,
+                docs: None,
             },
         ];
 
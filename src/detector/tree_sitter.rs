@@ -4,7 +4,11 @@
 //! as well as AST-level pattern matching for code slop that regex cannot detect.
 
 use crate::config::Pattern;
-use crate::detector::{Comment, Finding, Language};
+use crate::detector::duplication::Duplicates;
+use crate::detector::{
+    checks, line_context, Comment, Declaration, Finding, Language, PackageSymbols, SuggestedFix,
+    TypeSwitch,
+};
 use streaming_iterator::StreamingIterator;
 
 // ...
@@ -55,7 +59,11 @@ impl TreeSitterExtractor {
     /// Extract AST-level findings using tree-sitter queries.
     ///
     /// Returns findings from patterns that have `ast_query` set and apply to this language.
-    pub fn extract_ast_findings(&mut self, source: &str, patterns: &[Pattern]) -> Vec<Finding> {
+    pub fn extract_ast_findings<'a>(
+        &mut self,
+        source: &str,
+        patterns: impl IntoIterator<Item = &'a Pattern>,
+    ) -> Vec<Finding> {
         let mut findings = Vec::new();
 
         let tree = match self.parser.parse(source, None) {
@@ -64,6 +72,8 @@ impl TreeSitterExtractor {
         };
 
         let lang_name = self.language_name();
+        let lines: Vec<&str> = source.lines().collect();
+        let package = package_name(&tree.root_node(), source);
 
         for pattern in patterns {
             // Skip patterns without AST queries or that don't apply to this language
@@ -76,6 +86,9 @@ impl TreeSitterExtractor {
             {
                 continue;
             }
+            if package.is_some_and(|p| pattern.exclude_packages.iter().any(|e| e == p)) {
+                continue;
+            }
 
             // Get the language for query compilation
             let ts_lang = match self.parser.language() {
@@ -100,10 +113,32 @@ impl TreeSitterExtractor {
                 Ok(r) => r,
                 Err(_) => continue,
             };
+            let exclude = match pattern.exclude_regex.as_deref().map(regex::Regex::new) {
+                Some(Ok(r)) => Some(r),
+                Some(Err(_)) => continue,
+                None => None,
+            };
+            let exclude_enclosing =
+                match pattern.exclude_enclosing.as_deref().map(regex::Regex::new) {
+                    Some(Ok(r)) => Some(r),
+                    Some(Err(_)) => continue,
+                    None => None,
+                };
+
+            // A query can match the same node in several ways; report it once
+            let mut seen = std::collections::HashSet::new();
+            let mut duplicates = pattern.min_tokens.map(Duplicates::new);
 
             while let Some(mat) = matches.next() {
                 for capture in mat.captures {
+                    // Captures named `@_...` only feed predicates
+                    if query.capture_names()[capture.index as usize].starts_with('_') {
+                        continue;
+                    }
                     let node = capture.node;
+                    if !seen.insert(node.id()) {
+                        continue;
+                    }
                     let text = node.utf8_text(source.as_bytes()).unwrap_or("").to_string();
 
                     // Verify the regex also matches the matched text
@@ -111,9 +146,89 @@ impl TreeSitterExtractor {
                     if !regex.is_match(&text) {
                         continue;
                     }
+                    if exclude.as_ref().is_some_and(|ex| ex.is_match(&text)) {
+                        continue;
+                    }
+                    if let Some(ref ex) = exclude_enclosing {
+                        let signature = |f: Node| {
+                            let text = f.utf8_text(source.as_bytes()).unwrap_or("");
+                            ex.is_match(text.lines().next().unwrap_or(""))
+                        };
+                        if enclosing_functions(&node).any(signature) {
+                            continue;
+                        }
+                    }
+                    if pattern.exported_only == Some(true)
+                        && (package == Some("main") || !exported_context(&node, source))
+                    {
+                        continue;
+                    }
+
+                    let mut message = pattern.message.clone();
+                    if let Some(max) = pattern.max_params {
+                        let count = count_params(&node, source, pattern.param_type.as_deref());
+                        if count <= max {
+                            continue;
+                        }
+                        message = format!("{} ({} parameters, max {})", message, count, max);
+                    }
+                    if let Some(max) = pattern.max_elements {
+                        let count = count_elements(&node);
+                        if count <= max {
+                            continue;
+                        }
+                        message = format!("{} ({} elements, max {})", message, count, max);
+                    }
+                    if let Some(max) = pattern.max_fields {
+                        match checks::pointer_return(&node, source) {
+                            Some((name, count)) if count <= max => {
+                                message = format!(
+                                    "{} (`*{}`, {} fields, max {})",
+                                    message, name, count, max
+                                );
+                            }
+                            _ => continue,
+                        }
+                    }
+                    if let Some(mask) = pattern.mode_mask {
+                        match checks::permissive_mode(&node, source, mask) {
+                            Some(detail) => message = format!("{} ({})", message, detail),
+                            None => continue,
+                        }
+                    }
+                    if let Some(ref mut duplicates) = duplicates {
+                        match duplicates.check(&node, source) {
+                            Some(detail) => message = format!("{} ({})", message, detail),
+                            None => continue,
+                        }
+                    }
+                    if let Some(ref check) = pattern.check {
+                        match checks::run(check, &node, source) {
+                            Some(detail) if !detail.is_empty() => {
+                                message = format!("{} ({})", message, detail)
+                            }
+                            Some(_) => {}
+                            None => continue,
+                        }
+                    }
 
                     let line = node.start_position().row + 1;
                     let column = node.start_position().column + 1;
+                    let (source_line, context_before, context_after) = line_context(&lines, line);
+                    let replacement = match (&pattern.fix, &pattern.check) {
+                        (Some(template), _) => {
+                            Some((node, regex.replace(&text, template.as_str()).into_owned()))
+                        }
+                        (None, Some(check)) => checks::replacement(check, &node, source),
+                        (None, None) => None,
+                    };
+                    let fix = replacement.map(|(span, replacement)| SuggestedFix {
+                        start_line: span.start_position().row + 1,
+                        start_column: span.start_position().column + 1,
+                        end_line: span.end_position().row + 1,
+                        end_column: span.end_position().column + 1,
+                        replacement,
+                    });
 
                     findings.push(Finding {
                         file: String::new(), // Caller will set
@@ -121,12 +236,17 @@ impl TreeSitterExtractor {
                         column,
                         severity: pattern.severity.clone(),
                         category: pattern.category.clone(),
-                        message: pattern.message.clone(),
-                        match_text: text,
+                        message,
+                        match_text: first_line(text),
                         pattern_regex: pattern.regex.to_string(),
-                        source_line: None, // TODO: Extract from source
-                        context_before: None,
-                        context_after: None,
+                        pattern_id: pattern.id.clone(),
+                        tags: pattern.tags.clone(),
+                        confidence: pattern.confidence,
+                        source_line,
+                        context_before,
+                        context_after,
+                        fix,
+                        suggestion: pattern.suggestion.clone(),
                     });
                 }
             }
@@ -135,6 +255,78 @@ impl TreeSitterExtractor {
         findings
     }
 
+    /// Position (1-indexed line and column) and description of the first
+    /// syntax error in `source`, or None when it parses cleanly.
+    pub fn syntax_error(&mut self, source: &str) -> Option<(usize, usize, String)> {
+        let tree = self.parser.parse(source, None)?;
+        let mut node = tree.root_node();
+        if !node.has_error() {
+            return None;
+        }
+        // Descend into the first child that contains the error
+        while !node.is_error() && !node.is_missing() {
+            let mut cursor = node.walk();
+            let next = node
+                .children(&mut cursor)
+                .find(|c| c.has_error() || c.is_missing());
+            match next {
+                Some(child) => node = child,
+                None => break,
+            }
+        }
+        let position = node.start_position();
+        let description = if node.is_missing() {
+            format!("missing `{}`", node.kind())
+        } else {
+            "syntax error".to_string()
+        };
+        Some((position.row + 1, position.column + 1, description))
+    }
+
+    /// Declarations and references of a Go file, for checks across its package.
+    pub fn package_symbols(&mut self, source: &str) -> Option<PackageSymbols> {
+        let tree = self.parser.parse(source, None)?;
+        let root = tree.root_node();
+        let name = package_name(&root, source)?;
+        let mut cursor = root.walk();
+        let line = root
+            .named_children(&mut cursor)
+            .find(|n| n.kind() == "package_clause")
+            .map_or(1, |n| n.start_position().row + 1);
+        let mut symbols = PackageSymbols {
+            name: name.strip_suffix("_test").unwrap_or(name).to_string(),
+            line,
+            ..Default::default()
+        };
+
+        let mut cursor = root.walk();
+        for decl in root.named_children(&mut cursor) {
+            let owner = matches!(decl.kind(), "function_declaration" | "method_declaration")
+                .then(|| decl.child_by_field_name("name"))
+                .flatten();
+            let owner_name = owner.and_then(|n| n.utf8_text(source.as_bytes()).ok());
+            if owner.is_some() && decl.child_by_field_name("body").is_some() {
+                symbols.functions += 1;
+            }
+            if let Some(name) = owner_name {
+                let entry_point = name == "init" || (name == "main" && symbols.name == "main");
+                if !entry_point && name != "_" && !name.starts_with(|c: char| c.is_uppercase()) {
+                    symbols.declared.push(Declaration {
+                        line: decl.start_position().row + 1,
+                        name: name.to_string(),
+                        method: decl.kind() == "method_declaration",
+                    });
+                }
+            }
+            collect_references(&decl, owner_name, source, &mut symbols.references);
+            collect_closed(&decl, source, &mut symbols.closed);
+            symbols.erased.extend(checks::erased_flow(&decl, source));
+            collect_types(&decl, source, &mut symbols);
+            collect_switches(&decl, source, &mut symbols.switches);
+        }
+        Some(symbols)
+    }
+
     fn language_name(&self) -> &'static str {
         match self.language {
             #[cfg(feature = "python")]
@@ -202,6 +394,310 @@ fn get_language_fn(lang: Language) -> Option<tree_sitter::Language> {
     }
 }
 
+/// Keep only the first line of a node's text.
+///
+/// Captures can span a whole function; keeping all of it would hold most of
+/// the file in memory for as long as the finding lives.
+#[cfg(feature = "tree-sitter")]
+fn first_line(mut text: String) -> String {
+    if let Some(end) = text.find('\n') {
+        text.truncate(end);
+        text.shrink_to_fit();
+    }
+    text
+}
+
+/// Function and method declarations containing `node`, innermost first.
+#[cfg(feature = "tree-sitter")]
+fn enclosing_functions<'t>(node: &Node<'t>) -> impl Iterator<Item = Node<'t>> {
+    std::iter::successors(node.parent(), |n| n.parent()).filter(|n| {
+        matches!(
+            n.kind(),
+            "function_declaration"
+                | "method_declaration"
+                | "function_definition"
+                | "function_item"
+                | "method_definition"
+        )
+    })
+}
+
+/// Whether `node` lies in an exported top-level Go declaration: a function,
+/// a method of an exported type, or a type, var or const with an exported
+/// name. In a grouped `var ( ... )`, the spec holding `node` decides.
+/// Closures count as part of their function.
+#[cfg(feature = "tree-sitter")]
+fn exported_context(node: &Node, source: &str) -> bool {
+    let text = |n: Node| n.utf8_text(source.as_bytes()).unwrap_or("");
+    let exported = |name: &str| name.starts_with(|c: char| c.is_uppercase());
+    let Some(declaration) = std::iter::successors(Some(*node), |n| n.parent())
+        .find(|n| n.parent().is_some_and(|p| p.kind() == "source_file"))
+    else {
+        return false;
+    };
+    match declaration.kind() {
+        "function_declaration" => declaration
+            .child_by_field_name("name")
+            .is_some_and(|n| exported(text(n))),
+        "method_declaration" => {
+            // `(s *Store[T])` -> `Store`
+            let receiver = declaration
+                .child_by_field_name("receiver")
+                .and_then(|r| r.named_child(0))
+                .and_then(|p| p.child_by_field_name("type"))
+                .map(|t| text(t).trim_start_matches('*'))
+                .and_then(|t| t.split('[').next())
+                .unwrap_or("");
+            exported(receiver)
+                && declaration
+                    .child_by_field_name("name")
+                    .is_some_and(|n| exported(text(n)))
+        }
+        // The spec of a type, var or const declaration holding `node`, or
+        // its first spec when `node` is the whole declaration
+        _ => std::iter::successors(Some(*node), |n| n.parent())
+            .take_while(|n| n.id() != declaration.id())
+            .find(|n| is_spec(n))
+            .or_else(|| first_spec(&declaration))
+            .and_then(|s| s.child_by_field_name("name"))
+            .is_some_and(|n| exported(text(n))),
+    }
+}
+
+/// Whether `node` is one entry of a type, var or const declaration.
+#[cfg(feature = "tree-sitter")]
+fn is_spec(node: &Node) -> bool {
+    node.kind().ends_with("_spec") || node.kind() == "type_alias"
+}
+
+/// The first spec of a declaration, looking inside a parenthesized list.
+#[cfg(feature = "tree-sitter")]
+fn first_spec<'t>(declaration: &Node<'t>) -> Option<Node<'t>> {
+    let mut cursor = declaration.walk();
+    let children: Vec<_> = declaration.named_children(&mut cursor).collect();
+    children.into_iter().find_map(|child| {
+        if is_spec(&child) {
+            Some(child)
+        } else if child.kind().ends_with("_spec_list") {
+            first_spec(&child)
+        } else {
+            None
+        }
+    })
+}
+
+/// Names used under `node`, skipping `owner` so recursion and the
+/// declaration's own name do not count as a use.
+///
+/// Only unexported names can refer to a candidate, so exported ones are
+/// not kept, apart from `MethodByName`.
+#[cfg(feature = "tree-sitter")]
+fn collect_references(
+    node: &Node,
+    owner: Option<&str>,
+    source: &str,
+    references: &mut std::collections::HashSet<String>,
+) {
+    match node.kind() {
+        "identifier" | "field_identifier" => {
+            if let Ok(text) = node.utf8_text(source.as_bytes()) {
+                let exported = text.starts_with(|c: char| c.is_uppercase());
+                if Some(text) != owner && (!exported || text == "MethodByName") {
+                    references.insert(text.to_string());
+                }
+            }
+        }
+        "comment" => {
+            let text = node.utf8_text(source.as_bytes()).unwrap_or_default();
+            let name = text
+                .strip_prefix("//go:linkname ")
+                .or_else(|| text.strip_prefix("//export "))
+                .and_then(|rest| rest.split_whitespace().next());
+            if let Some(name) = name {
+                references.insert(name.to_string());
+            }
+        }
+        _ => {
+            let mut cursor = node.walk();
+            for child in node.children(&mut cursor) {
+                collect_references(&child, owner, source, references);
+            }
+        }
+    }
+}
+
+/// Channels passed to `close` under `node`, by their last name.
+#[cfg(feature = "tree-sitter")]
+fn collect_closed(node: &Node, source: &str, closed: &mut std::collections::HashSet<String>) {
+    if node.kind() == "call_expression" {
+        let is_close = node.child_by_field_name("function").is_some_and(|f| {
+            f.kind() == "identifier" && f.utf8_text(source.as_bytes()) == Ok("close")
+        });
+        let arg = node
+            .child_by_field_name("arguments")
+            .and_then(|args| args.named_child(0));
+        let name = arg.and_then(|a| match a.kind() {
+            "identifier" => Some(a),
+            "selector_expression" => a.child_by_field_name("field"),
+            _ => None,
+        });
+        if let (true, Some(name)) = (is_close, name) {
+            if let Ok(text) = name.utf8_text(source.as_bytes()) {
+                closed.insert(text.to_string());
+            }
+        }
+    }
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        collect_closed(&child, source, closed);
+    }
+}
+
+/// Record the interfaces, other types and methods a top-level `decl`
+/// declares.
+#[cfg(feature = "tree-sitter")]
+fn collect_types(decl: &Node, source: &str, symbols: &mut PackageSymbols) {
+    let text = |n: Node| {
+        n.utf8_text(source.as_bytes())
+            .unwrap_or_default()
+            .to_string()
+    };
+    match decl.kind() {
+        "type_declaration" => {
+            let mut cursor = decl.walk();
+            for spec in decl.named_children(&mut cursor) {
+                if spec.kind() != "type_spec" {
+                    continue;
+                }
+                let (Some(name), Some(ty)) = (
+                    spec.child_by_field_name("name"),
+                    spec.child_by_field_name("type"),
+                ) else {
+                    continue;
+                };
+                if ty.kind() != "interface_type" {
+                    symbols.types.insert(text(name));
+                    continue;
+                }
+                let mut methods = Vec::new();
+                let mut embeds = false;
+                let mut inner = ty.walk();
+                for elem in ty.named_children(&mut inner) {
+                    match elem.kind() {
+                        "method_elem" | "method_spec" => {
+                            methods.extend(elem.child_by_field_name("name").map(text));
+                        }
+                        "comment" => {}
+                        _ => embeds = true,
+                    }
+                }
+                if !embeds {
+                    symbols.interfaces.insert(text(name), methods);
+                }
+            }
+        }
+        "method_declaration" => {
+            let receiver = decl
+                .child_by_field_name("receiver")
+                .and_then(|r| r.named_child(0))
+                .and_then(|p| p.child_by_field_name("type"));
+            let receiver = receiver.map(|t| match t.kind() {
+                "pointer_type" => t.named_child(0).unwrap_or(t),
+                _ => t,
+            });
+            let receiver = receiver.map(|t| match t.kind() {
+                "generic_type" => t.child_by_field_name("type").unwrap_or(t),
+                _ => t,
+            });
+            if let (Some(receiver), Some(name)) = (receiver, decl.child_by_field_name("name")) {
+                symbols
+                    .methods
+                    .entry(text(receiver))
+                    .or_default()
+                    .insert(text(name));
+            }
+        }
+        _ => {}
+    }
+}
+
+/// Type switches under `node` that the `package-type-switch` check keeps.
+#[cfg(feature = "tree-sitter")]
+fn collect_switches(node: &Node, source: &str, switches: &mut Vec<TypeSwitch>) {
+    switches.extend(checks::type_switch(node, source));
+    let mut cursor = node.walk();
+    for child in node.named_children(&mut cursor) {
+        collect_switches(&child, source, switches);
+    }
+}
+
+/// The Go package a file declares, if any.
+#[cfg(feature = "tree-sitter")]
+fn package_name<'s>(root: &Node, source: &'s str) -> Option<&'s str> {
+    let mut cursor = root.walk();
+    let clause = root
+        .named_children(&mut cursor)
+        .find(|n| n.kind() == "package_clause")?;
+    let mut cursor = clause.walk();
+    let name = clause
+        .named_children(&mut cursor)
+        .find(|n| n.kind() == "package_identifier")?;
+    name.utf8_text(source.as_bytes()).ok()
+}
+
+/// Count the elements of a composite literal node for `max_elements`.
+#[cfg(feature = "tree-sitter")]
+fn count_elements(node: &Node) -> usize {
+    let Some(body) = node.child_by_field_name("body") else {
+        return 0;
+    };
+    let mut cursor = body.walk();
+    let count = body
+        .named_children(&mut cursor)
+        .filter(|n| n.kind() != "comment")
+        .count();
+    count
+}
+
+/// Count the parameters of a function node for `max_params`.
+///
+/// Go declarations like `a, b int` count once per name. A leading
+/// `context.Context` and a variadic tail are not counted. With `only_type`,
+/// only parameters declared with exactly that type count.
+#[cfg(feature = "tree-sitter")]
+fn count_params(node: &Node, source: &str, only_type: Option<&str>) -> usize {
+    let Some(params) = node.child_by_field_name("parameters") else {
+        return 0;
+    };
+
+    let mut count = 0;
+    let mut cursor = params.walk();
+    for (i, param) in params.named_children(&mut cursor).enumerate() {
+        match param.kind() {
+            "variadic_parameter_declaration" | "comment" => {}
+            "parameter_declaration" => {
+                let type_text = param
+                    .child_by_field_name("type")
+                    .and_then(|t| t.utf8_text(source.as_bytes()).ok());
+                if i == 0 && type_text == Some("context.Context") {
+                    continue;
+                }
+                if only_type.is_some() && type_text != only_type {
+                    continue;
+                }
+                let mut names = param.walk();
+                count += param
+                    .children_by_field_name("name", &mut names)
+                    .count()
+                    .max(1);
+            }
+            _ if only_type.is_none() => count += 1,
+            _ => {}
+        }
+    }
+    count
+}
+
 #[cfg(feature = "tree-sitter")]
 fn extract_comments_recursive(node: &Node, source: &str, comments: &mut Vec<Comment>) {
     if node.kind().contains("comment") {
@@ -267,7 +763,21 @@ pub fn get_extractor(_lang: Language) -> Option<TreeSitterExtractor> {
 #[cfg(all(test, feature = "tree-sitter"))]
 mod tests {
     use super::*;
-    use crate::config::{Pattern, PatternCategory, RegexPattern, Severity};
+    use crate::config::{Config, Pattern, PatternCategory, RegexPattern, Severity};
+
+    /// Run the built-in patterns against a Go snippet.
+    fn go_findings(code: &str) -> Vec<Finding> {
+        let mut extractor = get_extractor(Language::Go).expect("Go extractor");
+        extractor.extract_ast_findings(code, &Config::default().patterns)
+    }
+
+    /// Findings whose message starts with `prefix`.
+    fn with_message<'a>(findings: &'a [Finding], prefix: &str) -> Vec<&'a Finding> {
+        findings
+            .iter()
+            .filter(|f| f.message.starts_with(prefix))
+            .collect()
+    }
 
     #[test]
     fn test_python_extractor() {
@@ -327,12 +837,32 @@ fn foo() -> Option<()> {
         let mut extractor = get_extractor(Language::Python).expect("Python extractor");
 
         let patterns = vec![Pattern {
+            id: None,
             regex: RegexPattern::new("raise NotImplementedError".to_string()).unwrap(),
             severity: Severity::Critical,
             message: "NotImplementedError stub detected".to_string(),
             category: PatternCategory::Stub,
+            tags: vec![],
+            confidence: Default::default(),
             ast_query: Some("(raise_statement) @stub".to_string()),
             languages: vec!["Python".to_string()],
+            exclude_regex: None,
+            exclude_enclosing: None,
+            exclude_packages: vec![],
+            min_version: None,
+            max_version: None,
+            max_params: None,
+            param_type: None,
+            max_elements: None,
+            max_fields: None,
+            mode_mask: None,
+            min_tokens: None,
+            max_percent: None,
+            exported_only: None,
+            suggestion: None,
+            check: None,
+            fix: None,
+            docs: None,
         }];
 
         let code = r#"
@@ -350,12 +880,32 @@ def process_data(data):
         let mut extractor = get_extractor(Language::Python).expect("Python extractor");
 
         let patterns = vec![Pattern {
+            id: None,
             regex: RegexPattern::new("pass$".to_string()).unwrap(),
             severity: Severity::Medium,
             message: "Function body contains only 'pass' statement".to_string(),
             category: PatternCategory::Stub,
+            tags: vec![],
+            confidence: Default::default(),
             ast_query: Some("(pass_statement) @stub".to_string()),
             languages: vec!["Python".to_string()],
+            exclude_regex: None,
+            exclude_enclosing: None,
+            exclude_packages: vec![],
+            min_version: None,
+            max_version: None,
+            max_params: None,
+            param_type: None,
+            max_elements: None,
+            max_fields: None,
+            mode_mask: None,
+            min_tokens: None,
+            max_percent: None,
+            exported_only: None,
+            suggestion: None,
+            check: None,
+            fix: None,
+            docs: None,
         }];
 
         let code = r#"
@@ -373,12 +923,32 @@ def stub_function():
         let mut extractor = get_extractor(Language::Rust).expect("Rust extractor");
 
         let patterns = vec![Pattern {
+            id: None,
             regex: RegexPattern::new("todo!".to_string()).unwrap(),
             severity: Severity::Critical,
             message: "todo!() macro stub detected".to_string(),
             category: PatternCategory::Stub,
+            tags: vec![],
+            confidence: Default::default(),
             ast_query: Some("(macro_invocation) @stub".to_string()),
             languages: vec!["Rust".to_string()],
+            exclude_regex: None,
+            exclude_enclosing: None,
+            exclude_packages: vec![],
+            min_version: None,
+            max_version: None,
+            max_params: None,
+            param_type: None,
+            max_elements: None,
+            max_fields: None,
+            mode_mask: None,
+            min_tokens: None,
+            max_percent: None,
+            exported_only: None,
+            suggestion: None,
+            check: None,
+            fix: None,
+            docs: None,
         }];
 
         let code = r#"
@@ -390,4 +960,1755 @@ fn process_data() -> u32 {
         let findings = extractor.extract_ast_findings(code, &patterns);
         assert!(!findings.is_empty());
     }
+
+    #[test]
+    fn test_ast_findings_carry_source_context() {
+        let mut extractor = get_extractor(Language::Python).expect("Python extractor");
+
+        let patterns = vec![Pattern {
+            id: None,
+            regex: RegexPattern::new("pass$".to_string()).unwrap(),
+            severity: Severity::Medium,
+            message: "Function body contains only 'pass' statement".to_string(),
+            category: PatternCategory::Stub,
+            tags: vec![],
+            confidence: Default::default(),
+            ast_query: Some("(pass_statement) @stub".to_string()),
+            languages: vec!["Python".to_string()],
+            exclude_regex: None,
+            exclude_enclosing: None,
+            exclude_packages: vec![],
+            min_version: None,
+            max_version: None,
+            max_params: None,
+            param_type: None,
+            max_elements: None,
+            max_fields: None,
+            mode_mask: None,
+            min_tokens: None,
+            max_percent: None,
+            exported_only: None,
+            suggestion: None,
+            check: None,
+            fix: None,
+            docs: None,
+        }];
+
+        let code = "def stub_function():\n    pass\n";
+        let findings = extractor.extract_ast_findings(code, &patterns);
+        assert_eq!(findings[0].source_line.as_deref(), Some("    pass"));
+        assert_eq!(
+            findings[0].context_before.as_deref(),
+            Some("def stub_function():")
+        );
+    }
+
+    #[test]
+    fn test_go_defensive_recover() {
+        let code = r#"package lib
+
+func Exported() error {
+	defer func() {
+		if r := recover(); r != nil {
+		}
+	}()
+	return nil
+}
+
+func internal() {
+	defer func() {
+		recover()
+	}()
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer func() {
+		recover()
+	}()
+}
+
+func Fetch(url string) {
+	defer func() {
+		recover()
+	}()
+	req, _ := http.NewRequest("GET", url, nil)
+	_ = req.(*http.Request)
+}
+"#;
+        let findings = go_findings(code);
+        let hits = with_message(&findings, "Defensive recover");
+        let lines: Vec<_> = hits.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![3, 23]);
+
+        let main = code.replacen("package lib", "package main", 1);
+        assert!(with_message(&go_findings(&main), "Defensive recover").is_empty());
+    }
+
+    #[test]
+    fn test_go_noop_recover() {
+        let code = r#"package lib
+
+func safeRun(job func()) {
+	if r := recover(); r != nil {
+		log.Print(r)
+	}
+	job()
+}
+
+func nested() {
+	defer func() {
+		func() {
+			recover()
+		}()
+	}()
+}
+
+func worker() {
+	go func() {
+		recover()
+	}()
+	defer recover()
+}
+
+func guarded() {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Print(r)
+		}
+	}()
+	handler := func() { recover() }
+	defer handler()
+}
+
+func recoverPanic() {
+	if r := recover(); r != nil {
+		log.Print(r)
+	}
+}
+
+func cleanup() {
+	recover()
+}
+
+func run() {
+	defer cleanup()
+}
+"#;
+        let findings = go_findings(code);
+        let hits = with_message(&findings, "No-op recover");
+        let lines: Vec<_> = hits.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![4, 13, 20, 22]);
+        assert!(hits[0].message.contains("`safeRun` is not deferred"));
+        assert!(hits[2].message.contains("goroutine"));
+        assert!(hits[3].message.contains("`defer recover()`"));
+    }
+
+    #[test]
+    fn test_go_modernize_suggestions() {
+        let code = r#"package lib
+
+func Load(path string, v interface{}) error {
+	data, _ := ioutil.ReadFile(path)
+	body, _ := ioutil.ReadAll(r)
+	s := strings.Replace(string(data), "a", "b", -1)
+	t := strings.Replace(s, "a", "b", 1)
+	var _ interface{ Close() error }
+	var _ io.Writer = ioutil.Discard
+	return nil
+}
+"#;
+        let findings = go_findings(code);
+        let hits = with_message(&findings, "Modernize");
+        assert!(hits.iter().all(|f| f.severity == Severity::Info));
+        assert!(hits
+            .iter()
+            .all(|f| f.category == PatternCategory::Modernize));
+
+        let lines = |id: &str| -> Vec<usize> {
+            hits.iter()
+                .filter(|f| f.pattern_id.as_deref() == Some(id))
+                .map(|f| f.line)
+                .collect()
+        };
+        assert_eq!(lines("go-interface-any"), vec![3]);
+        assert_eq!(lines("go-ioutil-file"), vec![4]);
+        assert_eq!(lines("go-ioutil-io"), vec![5, 9]);
+        assert_eq!(lines("go-replace-all"), vec![6]);
+
+        let fix = |id: &str| {
+            hits.iter()
+                .find(|f| f.pattern_id.as_deref() == Some(id))
+                .and_then(|f| f.fix.clone())
+        };
+        let replace_all = fix("go-replace-all").unwrap();
+        assert_eq!(
+            replace_all.replacement,
+            r#"strings.ReplaceAll(string(data), "a", "b")"#
+        );
+        assert_eq!((replace_all.start_line, replace_all.start_column), (6, 7));
+        assert_eq!((replace_all.end_line, replace_all.end_column), (6, 49));
+        // `io` is not imported, so rewriting to it would not compile
+        assert!(fix("go-ioutil-io").is_none());
+        assert_eq!(fix("go-interface-any").unwrap().replacement, "any");
+        assert!(fix("go-ioutil-file").is_none());
+    }
+
+    #[test]
+    fn test_go_sql_concatenation() {
+        let code = r#"package store
+
+func Find(db *sql.DB, id string, name string) {
+	db.Query("SELECT * FROM users WHERE id = " + id)
+	db.Query("SELECT * FROM users WHERE id = ?", id)
+	db.Exec(stmt, "prefix-"+name)
+	q := "SELECT * FROM users WHERE name = '" + name + "'"
+	msg := "Hello " + name
+	db.QueryContext(ctx, "DELETE FROM users WHERE id = " + id)
+}
+"#;
+        let findings = go_findings(code);
+        let hits = with_message(&findings, "SQL injection");
+        let lines: Vec<usize> = hits.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![4, 7, 9]);
+        assert!(hits
+            .iter()
+            .all(|f| f.tags.contains(&"security".to_string())));
+    }
+
+    #[test]
+    fn test_go_too_many_params() {
+        let code = r#"package lib
+
+func Six(a, b, c int, d, e string, f bool) {}
+
+func Five(ctx context.Context, a, b, c, d, e int) {}
+
+func Tail(a, b, c, d, e int, rest ...string) {}
+
+func (s *S) Method(a int, b int, c int, d int, e int, f int) {}
+
+func NewClient(a, b, c, d, e, f, g int) *Client { return nil }
+
+func NewServer(a, b, c, d, e, f, g, h, i int) *Server { return nil }
+"#;
+        let findings = go_findings(code);
+        let hits = with_message(&findings, "Too many parameters");
+        let lines: Vec<usize> = hits.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![3, 9, 13]);
+        assert!(hits[0].message.contains("6 parameters, max 5"));
+        assert!(hits[2].message.contains("9 parameters, max 8"));
+    }
+
+    #[test]
+    fn test_go_interface_stubs() {
+        let code = r#"package model
+
+func (u *User) UnmarshalJSON(data []byte) error {
+	return nil
+}
+
+func (u *User) UnmarshalText(data []byte) error {
+	return json.Unmarshal(data, &u.raw)
+}
+
+func (r *Reader) Read(p []byte) (n int, err error) {
+	return 0, nil
+}
+
+func (n *NullID) Scan(value interface{}) error { return nil }
+
+func (u *User) Reset() error { return nil }
+"#;
+        let findings = go_findings(code);
+        let mut hits = with_message(&findings, "Stub: ");
+        hits.sort_by_key(|f| f.line);
+        let ids: Vec<_> = hits
+            .iter()
+            .filter_map(|f| f.pattern_id.as_deref())
+            .collect();
+        assert_eq!(
+            ids,
+            vec!["go-stub-unmarshal-json", "go-stub-read", "go-stub-sql-scan"]
+        );
+        assert!(hits[0].message.contains("json.Unmarshaler"));
+    }
+
+    #[test]
+    fn test_go_bool_flag_params() {
+        let code = r#"package lib
+
+func Process(data []byte, verbose, dryRun bool) {}
+
+func Toggle(on bool) {}
+
+func (s *Server) Serve(tls bool, debug bool) {}
+
+func main() {
+	Process(data, true, false)
+	Toggle(true)
+	Process(load(true), false, x)
+}
+"#;
+        let findings = go_findings(code);
+        let lines = |id: &str| -> Vec<usize> {
+            findings
+                .iter()
+                .filter(|f| f.pattern_id.as_deref() == Some(id))
+                .map(|f| f.line)
+                .collect()
+        };
+        assert_eq!(lines("go-bool-flag-params"), vec![3]);
+        assert_eq!(lines("go-bool-literal-args"), vec![10]);
+    }
+
+    #[test]
+    fn test_go_printf_wrapper_any() {
+        let code = r#"package lib
+
+func Logf(args ...any) {
+	log.Print(fmt.Sprintf(args[0].(string), args[1:]...))
+}
+
+func (l *Logger) Debugf(args ...interface{}) {
+	format, _ := args[0].(string)
+	fmt.Fprintf(l.w, format, args[1:]...)
+}
+
+func Checked(format string, args ...any) {
+	log.Print(fmt.Sprintf(format, args...))
+}
+
+func Join(args ...any) string {
+	return fmt.Sprint(args...)
+}
+"#;
+        let findings = go_findings(code);
+        let lines: Vec<_> = with_message(&findings, "Printf-style helper")
+            .iter()
+            .map(|f| f.line)
+            .collect();
+        assert_eq!(lines, vec![3, 7]);
+    }
+
+    #[test]
+    fn test_go_loop_var_goroutine() {
+        let code = r#"package lib
+
+func fanOut(users []User, jobs []Job) {
+	for i, u := range users {
+		go func() {
+			notify(u, i)
+		}()
+	}
+	for _, u := range users {
+		go func(u User) {
+			notify(u)
+		}(u)
+	}
+	for _, j := range jobs {
+		j := j
+		go func() { run(j) }()
+	}
+	for i := 0; i < 3; i++ {
+		go func() { fmt.Println(i) }()
+	}
+	for _, j := range jobs {
+		go run(j)
+	}
+}
+"#;
+        let findings = go_findings(code);
+        let hits = with_message(&findings, "Goroutine closure");
+        let lines: Vec<_> = hits.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![5, 19]);
+        assert!(hits[0].message.ends_with("(captures `i`, `u`)"));
+        assert!(hits[1].message.ends_with("(captures `i`)"));
+    }
+
+    #[test]
+    fn test_go_unbounded_body_read() {
+        let code = r#"package lib
+
+func Create(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	_ = body
+}
+
+func Update(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, 1<<20)
+	body, _ := io.ReadAll(r.Body)
+	_ = body
+}
+
+func Fetch(resp *http.Response) {
+	body, _ := ioutil.ReadAll(resp.Body)
+	_ = body
+}
+
+func Wrapped(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(io.LimitReader(r.Body, 4096))
+	_ = body
+}
+
+func handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		go func() {
+			body, _ := ioutil.ReadAll(req.Body)
+			_ = body
+		}()
+	}
+}
+"#;
+        let findings = go_findings(code);
+        let hits = with_message(&findings, "Unbounded read");
+        let lines: Vec<_> = hits.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![4, 27]);
+        assert!(hits[0].message.ends_with("(reads `r.Body`)"));
+        assert!(hits[1].message.ends_with("(reads `req.Body`)"));
+    }
+
+    #[test]
+    fn test_go_regexp_compile_in_func() {
+        let code = r#"package lib
+
+import (
+	re "regexp"
+	"strings"
+)
+
+var slug = re.MustCompile(`^[a-z-]+$`)
+
+func IsSlug(s string) bool {
+	return re.MustCompile(`^[a-z-]+$`).MatchString(s)
+}
+
+func Count(lines []string) int {
+	n := 0
+	for _, l := range lines {
+		if ok, _ := re.Compile("^#"); ok != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func Dynamic(p string) *re.Regexp {
+	return re.MustCompile(p)
+}
+
+func init() {
+	_ = re.MustCompile(`x`)
+}
+
+func Other(regexp fakeRegexp) {
+	regexp.MustCompile(`y`)
+}
+"#;
+        let findings = go_findings(code);
+        let hits = with_message(&findings, "Regexp compiled");
+        let lines: Vec<_> = hits.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![11, 17]);
+        assert!(hits[0].message.ends_with("package-level var"));
+        assert!(hits[1].message.ends_with("(inside a loop)"));
+    }
+
+    #[test]
+    fn test_go_error_string_style() {
+        let code = r#"package lib
+
+var (
+	a = errors.New("Failed to open file.")
+	b = fmt.Errorf("read %s: %w!", name, err)
+	c = errors.New("HTTP request failed")
+	d = errors.New("GetUser returned nothing")
+	e = errors.New("connection refused")
+	f = errors.New("waiting...")
+	g = fmt.Errorf(`Bad input`)
+	h = errors.New(msg)
+)
+"#;
+        let findings = go_findings(code);
+        let hits = with_message(&findings, "Error string style");
+        let lines: Vec<_> = hits.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![4, 5, 10]);
+        assert!(hits[0]
+            .message
+            .ends_with("(capitalized, trailing punctuation)"));
+        assert!(hits[1].message.ends_with("(trailing punctuation)"));
+
+        let fixes: Vec<_> = hits
+            .iter()
+            .map(|f| f.fix.as_ref().unwrap().replacement.as_str())
+            .collect();
+        assert_eq!(
+            fixes,
+            vec![
+                r#""failed to open file""#,
+                r#""read %s: %w""#,
+                "`bad input`"
+            ]
+        );
+        assert_eq!(hits[0].column, 17);
+    }
+
+    #[test]
+    fn test_go_panic_in_defer() {
+        let code = r#"package lib
+
+func Load(path string) error {
+	f, _ := os.Open(path)
+	defer mustClose(f)
+	defer util.MustFlush(w)
+	defer func() {
+		if err := f.Sync(); err != nil {
+			panic(err)
+		}
+	}()
+	defer func() {
+		if r := recover(); r != nil {
+			log.Print(r)
+			panic(r)
+		}
+	}()
+	defer f.Close()
+	defer mustard()
+	return nil
+}
+"#;
+        let findings = go_findings(code);
+        let lines: Vec<_> = with_message(&findings, "Panic in defer")
+            .iter()
+            .map(|f| f.line)
+            .collect();
+        assert_eq!(lines, vec![5, 6, 7]);
+    }
+
+    #[test]
+    fn test_go_unchecked_map_chain() {
+        let code = r#"package lib
+
+func Port(cfg map[string]any) any {
+	a := cfg["server"].(map[string]any)["port"]
+	b := cfg["a"].(map[string]any)["b"].(map[string]interface{})["c"]
+	server, ok := cfg["server"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	c := server["port"]
+	d := v.(map[string]any)["port"]
+	e := cfg["name"].(string)
+	return a, b, c, d, e
+}
+"#;
+        let findings = go_findings(code);
+        let chains = with_message(&findings, "Unchecked nested map access");
+        let lines: Vec<_> = chains.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![4, 5]);
+        assert!(chains[1].message.ends_with(r#"(keys "a", "b", "c")"#));
+    }
+
+    #[test]
+    fn test_go_error_type_assertion() {
+        let code = r#"package lib
+
+import stderrors "errors"
+
+func Handle(err error) error {
+	nf := err.(*NotFound)
+	if e, ok := err.(*NotFound); ok {
+		_ = e
+	}
+	log.Print(nf.Key, err.(*NotFound).Key)
+	return nil
+}
+
+func Load(path string) {
+	data, readErr := os.ReadFile(path)
+	var pe *fs.PathError
+	pe = readErr.(*fs.PathError)
+	var w io.Writer
+	f := w.(*os.File)
+	v := data.(fmt.Stringer)
+	go func() {
+		te := readErr.(*TimeoutError)
+		_ = te
+	}()
+	_, _, _ = pe, f, v
+}
+"#;
+        let all = go_findings(code);
+        let findings = with_message(&all, "Unchecked error assertion");
+        let lines: Vec<_> = findings.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![6, 10, 17, 22]);
+
+        let fixes: Vec<_> = findings
+            .iter()
+            .map(|f| f.fix.as_ref().map(|x| x.replacement.as_str()))
+            .collect();
+        assert_eq!(
+            fixes,
+            vec![
+                None,
+                None,
+                Some("if !stderrors.As(readErr, &pe) {\n\t\tpanic(readErr)\n\t}"),
+                Some(
+                    "var te *TimeoutError\n\t\tif !stderrors.As(readErr, &te) {\n\t\t\tpanic(readErr)\n\t\t}"
+                ),
+            ]
+        );
+        assert!(findings[0]
+            .message
+            .ends_with("(return err when errors.As finds no match)"));
+        assert!(findings[2].message.ends_with("use errors.As"));
+        let fix = findings[2].fix.as_ref().unwrap();
+        assert_eq!(
+            (fix.start_line, fix.start_column, fix.end_column),
+            (17, 2, 30)
+        );
+
+        // Without the errors import the fix would not compile
+        let code =
+            "package lib\n\nfunc Load(err error) {\n\tpe := err.(*fs.PathError)\n\t_ = pe\n}\n";
+        let all = go_findings(code);
+        let findings = with_message(&all, "Unchecked error assertion");
+        assert_eq!(findings.len(), 1);
+        assert!(findings[0].fix.is_none());
+    }
+
+    #[test]
+    fn test_go_panic_sprintf() {
+        let code = r#"package lib
+
+func Parse(key string, n int) {
+	panic(fmt.Sprintf("invalid key %q", key))
+	panic("invalid key: " + key)
+	panic(key + ": invalid")
+	panic(err)
+	panic("unreachable")
+	panic(errors.New("bad"))
+	panic(n + 1)
+	panic(fmt.Errorf("bad %d", n))
+}
+"#;
+        let findings = go_findings(code);
+        let lines: Vec<_> = with_message(&findings, "Panic with a formatted message")
+            .iter()
+            .map(|f| f.line)
+            .collect();
+        assert_eq!(lines, vec![4, 5, 6]);
+
+        // Those three are not also reported as possible stubs
+        let stubs: Vec<_> = findings
+            .iter()
+            .filter(|f| f.pattern_id.as_deref() == Some("go-panic"))
+            .map(|f| f.line)
+            .collect();
+        assert_eq!(stubs, vec![7, 8, 9, 10, 11]);
+    }
+
+    #[test]
+    fn test_go_swallowed_error_default() {
+        let code = r#"package lib
+
+func getInt(m map[string]any, k string) int {
+	v, _ := m[k].(int)
+	return v
+}
+
+func port(s string) (p int) {
+	p, _ = strconv.Atoi(s)
+	return p
+}
+
+func parse(s string) (int, error) {
+	n, _ := strconv.Atoi(s)
+	return n, nil
+}
+
+func lookup(m map[string]any, k string) (int, bool) {
+	v, _ := m[k].(int)
+	return v, true
+}
+
+func keys(m map[string]int) []string {
+	var out []string
+	for k, _ := range m {
+		out = append(out, k)
+	}
+	v, _ := m["x"]
+	_ = v
+	return out
+}
+
+func run(s string) {
+	n, _ := strconv.Atoi(s)
+	use(n)
+}
+
+func later() func() int {
+	return func() int {
+		n, _ := strconv.Atoi("1")
+		return n
+	}
+}
+"#;
+        let findings = go_findings(code);
+        let swallowed = with_message(&findings, "Swallowed error");
+        let lines: Vec<_> = swallowed.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![3, 8]);
+        assert!(swallowed[0]
+            .message
+            .ends_with("(ignores a type assertion's ok)"));
+        assert!(swallowed[1]
+            .message
+            .ends_with("(ignores the error from `strconv.Atoi`)"));
+    }
+
+    #[test]
+    fn test_go_append_param_alias() {
+        let code = r#"package lib
+
+func Merge(a, b []int) []int {
+	return append(a, b...)
+}
+
+func (s *Set) Add(items []string, extra string) {
+	s.items = append(items, extra)
+	var all = append(items, "x")
+	items = append(items, extra)
+	use(all)
+}
+
+func Copy(a []int, n int) []int {
+	out := make([]int, 0, n)
+	out = append(out, a...)
+	return append(out, n)
+}
+
+func Outer(xs []int) func() []int {
+	return func() []int {
+		return append(xs, 1)
+	}
+}
+"#;
+        let findings = go_findings(code);
+        let aliased = with_message(&findings, "Append to a caller's slice");
+        let lines: Vec<_> = aliased.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![4, 8, 9, 22]);
+        assert!(aliased[1]
+            .message
+            .ends_with("(stores parameter `items` in `s.items`)"));
+    }
+
+    #[test]
+    fn test_go_log_and_return_zero() {
+        let code = r#"package lib
+
+func LoadName(id int) (string, int, error) {
+	name, err := db.Name(id)
+	if err != nil {
+		log.Printf("load name %d: %v", id, err)
+		return "", 0, nil
+	}
+	return name, 1, nil
+}
+
+func Save(u *User) error {
+	if err := db.Save(u); err != nil {
+		s.logger.Error("save", "err", err)
+		return nil
+	}
+	return nil
+}
+
+func Wrapped(id int) (string, error) {
+	name, err := db.Name(id)
+	if err != nil {
+		log.Printf("load name: %v", err)
+		return "", err
+	}
+	return name, nil
+}
+
+func Fatal() (*Conn, error) {
+	c, err := dial()
+	if err != nil {
+		log.Fatalf("dial: %v", err)
+		return nil, nil
+	}
+	return c, nil
+}
+
+func Quiet(id int) (Item, error) {
+	item, err := fetch(id)
+	if err != nil {
+		return Item{}, nil
+	}
+	return item, nil
+}
+"#;
+        let findings = go_findings(code);
+        let logged = with_message(&findings, "Logged error returned as success");
+        let lines: Vec<_> = logged.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![7, 15]);
+        assert!(logged[0]
+            .message
+            .ends_with("(logged with `log.Printf` on line 6)"));
+        assert!(logged[1]
+            .message
+            .ends_with("(logged with `s.logger.Error` on line 14)"));
+    }
+
+    #[test]
+    fn test_go_sync_goroutine() {
+        let code = r#"package lib
+
+func Save(item Item) error {
+	done := make(chan error)
+	go func() {
+		done <- save(item)
+	}()
+	return <-done
+}
+
+func Load() {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		load()
+	}()
+	// wait for the load
+	wg.Wait()
+}
+
+func Overlap() int {
+	ch := make(chan int)
+	go func() { ch <- slow() }()
+	n := other()
+	return n + <-ch
+}
+
+func Pair() {
+	a, b := make(chan int), make(chan int)
+	go func() { a <- 1 }()
+	go func() { b <- 2 }()
+	<-a
+	<-b
+}
+
+func Loop(items []Item) {
+	for _, it := range items {
+		done := make(chan struct{})
+		go func() { process(it); close(done) }()
+		<-done
+	}
+}
+
+func Unrelated(stop chan struct{}) {
+	go serve()
+	go func() { run() }()
+	<-stop
+}
+"#;
+        let findings = go_findings(code);
+        let joined = with_message(&findings, "Goroutine joined right away");
+        let lines: Vec<_> = joined.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![5, 14]);
+        assert!(joined[0]
+            .message
+            .ends_with("(joined by `return <-done` right after)"));
+        assert!(joined[1]
+            .message
+            .ends_with("(joined by `wg.Wait()` right after)"));
+    }
+
+    #[test]
+    fn test_go_large_literal() {
+        let entries: String = (0..201)
+            .map(|i| format!("\t\"k{}\": {},\n", i, i))
+            .collect();
+        let code = format!(
+            "package lib\n\nvar big = map[string]int{{\n{}}}\n\nvar small = []int{{1, 2, 3}}\n",
+            entries
+        );
+        let findings = go_findings(&code);
+        let large = with_message(&findings, "Large literal");
+        assert_eq!(large.len(), 1);
+        assert_eq!(large[0].line, 3);
+        assert!(large[0].message.ends_with("(201 elements, max 200)"));
+
+        let generated = format!("// Code generated by tablegen. DO NOT EDIT.\n\n{}", code);
+        assert!(with_message(&go_findings(&generated), "Large literal").is_empty());
+    }
+
+    #[test]
+    fn test_go_inconsistent_error_handling() {
+        let code = r#"package lib
+
+func Sync(a, b string) error {
+	if err := os.Remove(a); err != nil {
+		return err
+	}
+	_ = os.Remove(b)
+	return nil
+}
+
+func Parse(xs []string) (int, error) {
+	n, err := strconv.Atoi(xs[0])
+	if err != nil {
+		return 0, err
+	}
+	m, err := strconv.Atoi(xs[1])
+	if err != nil {
+		panic(err)
+	}
+	return n + m, nil
+}
+
+func Consistent(paths []string) error {
+	for _, p := range paths {
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+	}
+	f, err := os.Open(paths[0])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fmt.Println("done")
+	fmt.Println("really")
+	return f.Close()
+}
+"#;
+        let findings = go_findings(code);
+        let mixed = with_message(&findings, "Inconsistent error handling");
+        let lines: Vec<_> = mixed.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![3, 11]);
+        assert!(mixed[0]
+            .message
+            .ends_with("(`os.Remove` errors: returned on line 4, ignored on line 7)"));
+        assert!(mixed[1]
+            .message
+            .ends_with("(`strconv.Atoi` errors: returned on line 12, panicked on line 16)"));
+    }
+
+    #[test]
+    fn test_go_unclosed_resource() {
+        let code = r#"package lib
+
+func Status(url string) (int, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+func Read(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(f)
+}
+
+func Closed(url string) error {
+	resp, err := http.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	f, err := os.Create("out")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	rows, err := db.QueryContext(ctx, "SELECT 1")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+	}
+	q := r.URL.Query()
+	_ = q
+	return nil
+}
+
+func Names(db *sql.DB) error {
+	rows, err := db.Query("SELECT name FROM users")
+	if err != nil {
+		return err
+	}
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+func Open(path string) (*os.File, error) {
+	f, err := os.Open(path)
+	return f, err
+}
+"#;
+        let findings = go_findings(code);
+        let leaks = with_message(&findings, "Resource never closed");
+        let lines: Vec<_> = leaks.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![4, 30, 42]);
+        assert!(leaks[0]
+            .message
+            .ends_with("(from `http.Get`, no `resp.Body.Close()`)"));
+        assert!(leaks[2]
+            .message
+            .ends_with("(from `db.Query`, no `rows.Close()`)"));
+    }
+
+    #[test]
+    fn test_syntax_error() {
+        let mut extractor = get_extractor(Language::Go).expect("Go extractor");
+        assert_eq!(extractor.syntax_error("package lib\n\nfunc f() {}\n"), None);
+        let (line, _, _) = extractor
+            .syntax_error("package lib\n\nfunc f() {\n\tx := \n}\n")
+            .expect("syntax error");
+        assert!(line >= 4, "{}", line);
+    }
+
+    #[test]
+    fn test_go_hardcoded_address() {
+        let code = r#"package server
+
+const schema = "http://json-schema.org/draft-07/schema#"
+
+func Run() error {
+	resp, err := http.Get("http://localhost:8080/api")
+	db, err := sql.Open("postgres", "postgres://app@db:5432/app")
+	addr := ":9090"
+	port := 8080
+	report := 10
+	cfg := Config{Host: "db.internal:6379", Port: 6379}
+	log.Printf("listening on %s", addr)
+	return nil
+}
+"#;
+        let findings = go_findings(code);
+        let addresses: Vec<_> = with_message(&findings, "Hardcoded address")
+            .iter()
+            .map(|f| (f.line, f.match_text.clone()))
+            .collect();
+        assert_eq!(
+            addresses,
+            vec![
+                (6, r#""http://localhost:8080/api""#.to_string()),
+                (7, r#""postgres://app@db:5432/app""#.to_string()),
+                (8, r#"":9090""#.to_string()),
+                (11, r#""db.internal:6379""#.to_string()),
+            ]
+        );
+        let ports: Vec<_> = with_message(&findings, "Hardcoded port")
+            .iter()
+            .map(|f| f.line)
+            .collect();
+        assert_eq!(ports, vec![9, 11]);
+
+        let test = code.replace("package server", "package server\n\nimport \"testing\"");
+        assert!(with_message(&go_findings(&test), "Hardcoded").is_empty());
+    }
+
+    #[test]
+    fn test_go_duplicated_block() {
+        let code = r#"package store
+
+func loadUsers(path string) ([]User, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open users: %w", err)
+	}
+	defer f.Close()
+	var users []User
+	if err := json.NewDecoder(f).Decode(&users); err != nil {
+		return nil, fmt.Errorf("decode users: %w", err)
+	}
+	return users, nil
+}
+
+func loadOrders(file string) ([]Order, error) {
+	in, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open orders: %w", err)
+	}
+	defer in.Close()
+	var orders []Order
+	if err := json.NewDecoder(in).Decode(&orders); err != nil {
+		return nil, fmt.Errorf("decode orders: %w", err)
+	}
+	return orders, nil
+}
+
+func one() int { x := 1; return x }
+
+func two() int { y := 1; return y }
+"#;
+        let findings = go_findings(code);
+        let duplicated = with_message(&findings, "Duplicated block");
+        let lines: Vec<usize> = duplicated.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![16]);
+        assert!(
+            duplicated[0]
+                .message
+                .ends_with("(lines 16-27 repeat lines 3-14)"),
+            "{}",
+            duplicated[0].message
+        );
+    }
+
+    #[test]
+    fn test_go_unread_error() {
+        let code = r#"package store
+
+func save(path string) error { return nil }
+
+func Sync(a, b string, data []byte) error {
+	e := os.Remove(a)
+	e = os.WriteFile(b, data, 0o644)
+	if e != nil {
+		return e
+	}
+	n, err2 := strconv.Atoi(b)
+	_ = n
+	checked := save(a)
+	if checked != nil {
+		return checked
+	}
+	for _, p := range []string{a, b} {
+		err := save(p)
+		log.Print(p)
+	}
+	unused := save(b)
+	return nil
+}
+
+func Named() (err error) {
+	err = save("x")
+	return
+}
+"#;
+        let findings = go_findings(code);
+        let unread: Vec<(usize, String)> = with_message(&findings, "Unread error")
+            .iter()
+            .map(|f| (f.line, f.message.clone()))
+            .collect();
+        let lines: Vec<usize> = unread.iter().map(|u| u.0).collect();
+        assert_eq!(lines, vec![6, 11, 21]);
+        assert!(unread[0]
+            .1
+            .contains("`e` from `os.Remove` is overwritten on line 7"));
+        assert!(unread[1]
+            .1
+            .contains("`err2` from `strconv.Atoi` is never read"));
+    }
+
+    #[test]
+    fn test_go_any_slice_result() {
+        let code = r#"package processor
+
+func (p *DataProcessor) Process(records []Record) []interface{} {
+	var results []interface{}
+	for _, rec := range records {
+		func() {
+			data := rec.Data.(map[string]string)
+			results = append(results, data)
+		}()
+	}
+	return results
+}
+
+func Labels(ids []int) []any {
+	var out []any
+	for _, id := range ids {
+		out = append(out, Label{ID: id})
+		out = append(out, &Label{ID: id})
+	}
+	return out
+}
+
+func Mixed(rec Record) []any {
+	var out []any
+	out = append(out, "name", rec.Value())
+	return out
+}
+"#;
+        let findings = go_findings(code);
+        let untyped = with_message(&findings, "Untyped slice result");
+        let lines: Vec<usize> = untyped.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![3]);
+        assert!(untyped[0]
+            .message
+            .ends_with("(`[]interface{}` only holds `map[string]string`, appended on line 8)"));
+    }
+
+    #[test]
+    fn test_go_ignored_parse_error() {
+        let code = r#"package lib
+
+func handle(q url.Values) {
+	limit, _ := strconv.Atoi(q.Get("limit"))
+	var ratio float64
+	ratio, _ = strconv.ParseFloat(q.Get("ratio"), 64)
+	verbose, _ := strconv.ParseBool(q.Get("v"))
+	_, _ = strconv.ParseInt(q.Get("id"), 10, 64)
+	n, err := strconv.Atoi(q.Get("n"))
+	d, _ := time.ParseDuration(q.Get("d"))
+	use(limit, ratio, verbose, n, err, d)
+}
+"#;
+        let findings = go_findings(code);
+        let ignored = with_message(&findings, "Ignored parse error");
+        let lines: Vec<_> = ignored.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![4, 6, 7]);
+        assert!(ignored[0]
+            .message
+            .ends_with("(`limit` is `0` whenever `strconv.Atoi` fails)"));
+        assert!(ignored[2]
+            .message
+            .ends_with("(`verbose` is `false` whenever `strconv.ParseBool` fails)"));
+        assert_eq!(ignored[0].severity, Severity::Medium);
+    }
+
+    #[test]
+    fn test_go_uniform_returns() {
+        let code = r#"package lib
+
+func canEdit(admin, owner bool) bool {
+	if admin {
+		return true
+	}
+	if owner {
+		return true
+	}
+	return true
+}
+
+func kind(v any) error {
+	switch v.(type) {
+	case int, string:
+		return nil
+	default:
+		// nothing to check
+		return  nil
+	}
+}
+
+func level(n int) string {
+	if n > 10 {
+		return "high"
+	} else if n > 5 {
+		return "high"
+	}
+	return "low"
+}
+
+func save(ok bool) error {
+	if !ok {
+		log.Print("skipped")
+		return nil
+	}
+	return nil
+}
+
+func only() int {
+	return 1
+}
+
+func early(ok bool) error {
+	if !ok {
+		return nil
+	}
+	flush()
+	return nil
+}
+
+func bare(ok bool) {
+	if ok {
+		return
+	}
+	return
+}
+"#;
+        let findings = go_findings(code);
+        let uniform = with_message(&findings, "Uniform returns");
+        let lines: Vec<_> = uniform.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![3, 13]);
+        assert!(uniform[0].message.ends_with("(all 3 returns give `true`)"));
+        assert!(uniform[1].message.ends_with("(all 2 returns give `nil`)"));
+    }
+
+    #[test]
+    fn test_go_exported_only() {
+        let code = r#"package lib
+
+func Open(a, b, c, d, e, f int) {}
+
+func open(a, b, c, d, e, f int) {}
+
+type Store[T any] struct{}
+
+func (s *Store[T]) Put(a, b, c, d, e, f int) {}
+
+type store struct{}
+
+func (s store) Put(a, b, c, d, e, f int) {}
+"#;
+        let lines = |exported_only: bool| {
+            let mut config = Config::default();
+            config.exported_only = exported_only;
+            config.apply_exported_only();
+            let mut extractor = get_extractor(Language::Go).expect("Go extractor");
+            let findings = extractor.extract_ast_findings(code, &config.patterns);
+            with_message(&findings, "Too many parameters")
+                .iter()
+                .map(|f| f.line)
+                .collect::<Vec<_>>()
+        };
+        assert_eq!(lines(false), vec![3, 5, 9, 13]);
+        assert_eq!(lines(true), vec![3, 9]);
+
+        let mut config = Config::default();
+        config.exported_only = true;
+        config.apply_exported_only();
+        let mut extractor = get_extractor(Language::Go).expect("Go extractor");
+        let main = code.replace("package lib", "package main");
+        let findings = extractor.extract_ast_findings(&main, &config.patterns);
+        assert!(with_message(&findings, "Too many parameters").is_empty());
+    }
+
+    #[test]
+    fn test_go_exported_only_grouped_specs() {
+        let code = r#"package lib
+
+var (
+	internal = 1
+	Exported = 2
+)
+
+const (
+	Limit = 3
+	limit = 4
+)
+
+var Single, other = 5, 6
+"#;
+        let mut pattern = Config::default()
+            .patterns
+            .into_iter()
+            .find(|p| p.id.as_deref() == Some("go-too-many-params"))
+            .unwrap();
+        pattern.regex = RegexPattern::new(".".to_string()).unwrap();
+        pattern.ast_query = Some("(int_literal) @lit".to_string());
+        pattern.max_params = None;
+        pattern.exported_only = Some(true);
+        pattern.message = "Literal".to_string();
+
+        let mut extractor = get_extractor(Language::Go).expect("Go extractor");
+        let findings = extractor.extract_ast_findings(code, &[pattern]);
+        let lines: Vec<_> = findings.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![5, 9, 13, 13]);
+    }
+
+    #[test]
+    fn test_go_goroutine_panic() {
+        let source = r#"package main
+
+func run(msgs chan any, re string) {
+	go func() {
+		for m := range msgs {
+			handle(m.(string))
+		}
+	}()
+	go func() {
+		pattern := regexp.MustCompile(re)
+		use(pattern)
+	}()
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				log.Print(r)
+			}
+		}()
+		panic("boom")
+	}()
+	go func() {
+		if s, ok := (<-msgs).(string); ok {
+			use(s)
+		}
+		go func() { panic("nested") }()
+		later := func() { panic("closure") }
+		use(later)
+	}()
+}
+"#;
+        let all = go_findings(source);
+        let findings = with_message(&all, "Unrecovered panic in goroutine");
+        let lines: Vec<_> = findings.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![4, 9, 25]);
+        assert!(
+            findings[0]
+                .message
+                .ends_with("(unchecked assertion `m.(string)` on line 6)"),
+            "{}",
+            findings[0].message
+        );
+        assert!(
+            findings[1]
+                .message
+                .ends_with("(`regexp.MustCompile` on line 10)"),
+            "{}",
+            findings[1].message
+        );
+    }
+
+    #[test]
+    fn test_go_waitgroup_misuse() {
+        let source = r#"package main
+
+type pool struct {
+	wg sync.WaitGroup
+}
+
+func run(jobs []int) {
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		go func() {
+			wg.Add(1)
+			defer wg.Done()
+			work(job)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		work(0)
+	}()
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		work(1)
+	}()
+	wg.Add(1)
+	go func() {
+		helper(&wg)
+	}()
+	wg.Wait()
+}
+
+func (p *pool) start(n int) {
+	p.wg.Add(n)
+	go func() {
+		work(n)
+	}()
+}
+
+func count(c *counter) {
+	c.Add(1)
+	go func() {
+		work(2)
+	}()
+}
+"#;
+        let all = go_findings(source);
+        let findings = with_message(&all, "WaitGroup misuse");
+        let lines: Vec<_> = findings.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![10, 17, 34]);
+        assert!(
+            findings[0]
+                .message
+                .contains("`wg.Add` on line 11 runs inside the goroutine"),
+            "{}",
+            findings[0].message
+        );
+        assert!(
+            findings[1]
+                .message
+                .contains("no `wg.Done` for the `wg.Add` on line 16"),
+            "{}",
+            findings[1].message
+        );
+    }
+
+    #[test]
+    fn test_go_sprintf_path_key() {
+        let source = r#"package api
+
+func (s *Server) Avatar(w http.ResponseWriter, r *http.Request) {
+	name := fmt.Sprintf("%s.png", r.URL.Query().Get("user"))
+	f, _ := os.Open(filepath.Join(s.dir, name))
+	defer f.Close()
+}
+
+func (s *Server) Session(w http.ResponseWriter, r *http.Request) {
+	id := r.FormValue("id")
+	if sess, ok := s.cache[fmt.Sprintf("%s:%s", s.tenant, id)]; ok {
+		s.render(w, sess)
+	}
+}
+
+func (s *Server) Log(w http.ResponseWriter, r *http.Request) {
+	msg := fmt.Sprintf("%s %s", r.Method, r.URL.Path)
+	s.logger.Print(msg)
+}
+
+func report(dir string, n int) error {
+	return os.WriteFile(fmt.Sprintf("%s/%d.txt", dir, n), nil, 0o644)
+}
+
+func (s *Server) Static(w http.ResponseWriter, r *http.Request) {
+	http.ServeFile(w, r, fmt.Sprintf("static/%s", s.theme))
+}
+"#;
+        let all = go_findings(source);
+        let findings = with_message(&all, "Untrusted path or key");
+        let lines: Vec<_> = findings.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![4, 11]);
+        assert!(
+            findings[0]
+                .message
+                .contains("reaches `filepath.Join` on line 5"),
+            "{}",
+            findings[0].message
+        );
+        assert!(
+            findings[1].message.contains("is a map key on line 11"),
+            "{}",
+            findings[1].message
+        );
+    }
+
+    #[test]
+    fn test_go_permissive_file_mode() {
+        let source = r#"package store
+
+import (
+	"io/ioutil"
+	"os"
+)
+
+func save(path string, data []byte) error {
+	if err := os.WriteFile(path, data, 0777); err != nil {
+		return err
+	}
+	if err := os.MkdirAll("cache", 0755); err != nil {
+		return err
+	}
+	if err := os.Mkdir("shared", os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY, os.FileMode(0o666))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := os.Chmod(path, 0o600); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path+".bak", data, 0o755)
+}
+
+func (c *cache) WriteFile(name string, data []byte) error {
+	return c.fs.WriteFile(name, data, 0777)
+}
+"#;
+        let all = go_findings(source);
+        let findings = with_message(&all, "Permissive file mode");
+        let lines: Vec<_> = findings.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![9, 15, 18, 26]);
+        assert!(
+            findings[0]
+                .message
+                .ends_with("(`os.WriteFile` with 0777: world-writable, world-executable)"),
+            "{}",
+            findings[0].message
+        );
+        assert!(
+            findings[1].message.ends_with("world-writable)"),
+            "{}",
+            findings[1].message
+        );
+        assert!(
+            findings[3]
+                .message
+                .ends_with("(`ioutil.WriteFile` with 0o755: world-executable)"),
+            "{}",
+            findings[3].message
+        );
+    }
+
+    #[test]
+    fn test_go_select_busy_loop() {
+        let code = r#"package lib
+
+func spin(msgs chan string) {
+	for {
+		select {
+		case m := <-msgs:
+			handle(m)
+		default:
+		}
+	}
+}
+
+func poll(msgs chan string) {
+	for {
+		select {
+		case m := <-msgs:
+			handle(m)
+		default:
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func drain(msgs chan string) {
+	for len(msgs) > 0 {
+		select {
+		case <-msgs:
+		default:
+		}
+	}
+}
+
+func tryOnce(msgs chan string) {
+	select {
+	case <-msgs:
+	default:
+	}
+}
+"#;
+        let findings = go_findings(code);
+        let hits = with_message(&findings, "Busy loop");
+        assert_eq!(hits.len(), 1, "{:?}", hits);
+        assert_eq!(hits[0].line, 5);
+        assert_eq!(hits[0].match_text, "select {");
+    }
+
+    #[test]
+    fn test_go_missing_json_tag() {
+        let profile: crate::profile::Profile =
+            toml::from_str(include_str!("../../.antislop/profiles/go-interop.toml")).unwrap();
+        let code = r#"package api
+
+type User struct {
+	Base
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	CreatedAt time.Time
+	Secret    string `json:"-"`
+	internal  bool
+}
+
+type Point struct {
+	X, Y int
+}
+"#;
+        let mut extractor = get_extractor(Language::Go).expect("Go extractor");
+        let findings = extractor.extract_ast_findings(code, &profile.patterns);
+        let lines: Vec<_> = findings.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![7]);
+        assert!(findings[0].message.ends_with("(`User.CreatedAt`)"));
+    }
+
+    #[test]
+    fn test_go_small_pointer_return() {
+        let profile: crate::profile::Profile =
+            toml::from_str(include_str!("../../.antislop/profiles/go-performance.toml")).unwrap();
+        let code = r#"package geo
+
+type Point struct {
+	X, Y int
+}
+
+func NewPoint(x, y int) *Point {
+	return &Point{X: x, Y: y}
+}
+
+type Counter struct {
+	mu sync.Mutex
+	n  int
+}
+
+func NewCounter() *Counter {
+	return &Counter{}
+}
+
+type Node struct {
+	Next *Node
+}
+
+func (n *Node) Append(v *Node) { n.Next = v }
+
+func NewNode() *Node {
+	return &Node{}
+}
+
+type Config struct {
+	Name, Host, Path string
+	Port, Retries    int
+}
+
+func Load(name string) *Config {
+	return &Config{Name: name}
+}
+
+func Find(ps []Point, x int) *Point {
+	for i := range ps {
+		if ps[i].X == x {
+			return &ps[i]
+		}
+	}
+	return nil
+}
+"#;
+        let mut extractor = get_extractor(Language::Go).expect("Go extractor");
+        let findings = extractor.extract_ast_findings(code, &profile.patterns);
+        let lines: Vec<_> = findings.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![7]);
+        assert!(
+            findings[0].message.ends_with("(`*Point`, 2 fields, max 4)"),
+            "{}",
+            findings[0].message
+        );
+    }
+
+    #[test]
+    fn test_go_direct_time_now() {
+        let profile: crate::profile::Profile =
+            toml::from_str(include_str!("../../.antislop/profiles/go-testability.toml")).unwrap();
+        let code = r#"package billing
+
+func (s *Service) IsExpired(t Token) bool {
+	return time.Since(t.IssuedAt) > s.ttl
+}
+
+func stamp() int64 {
+	return time.Now().Unix()
+}
+
+func init() {
+	started = time.Now()
+}
+
+func TestExpiry(t *testing.T) {
+	tok := Token{IssuedAt: time.Now()}
+}
+
+func (realClock) Now() time.Time { return time.Now() }
+"#;
+        let mut extractor = get_extractor(Language::Go).expect("Go extractor");
+        let findings = extractor.extract_ast_findings(code, &profile.patterns);
+        let lines: Vec<_> = findings.iter().map(|f| f.line).collect();
+        assert_eq!(lines, vec![4, 8]);
+        assert_eq!(findings[1].match_text, "time.Now()");
+
+        let main = code.replace("package billing", "package main");
+        assert!(extractor
+            .extract_ast_findings(&main, &profile.patterns)
+            .is_empty());
+    }
 }
@@ -0,0 +1,202 @@
+//! Go source embedded in Go files, marked with `//antislop:embed-go`.
+//!
+//! The directive applies to the declaration below it, past any other
+//! comments. A `//go:embed` directive there names a file to scan as Go;
+//! otherwise the first raw string literal (`` `...` ``) of the declaration
+//! is scanned, and its findings are moved to their place in the host file.
+
+use crate::{FileScanResult, Finding, Scanner};
+use std::fs;
+use std::path::Path;
+
+/// Directive marking the next string literal or embedded file as Go.
+pub const EMBED_DIRECTIVE: &str = "//antislop:embed-go";
+
+/// Go source found behind an [`EMBED_DIRECTIVE`].
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub enum Embedded {
+    /// Contents of a raw string literal in the host file.
+    Literal {
+        /// The literal's contents, without the backticks.
+        source: String,
+        /// Line of the opening backtick (1-based).
+        line: usize,
+        /// Column of the first character after the backtick (1-based).
+        column: usize,
+    },
+    /// A file named by `//go:embed`, relative to the host file.
+    File(String),
+}
+
+/// Every embedded Go source `content` marks.
+///
+/// `//go:embed` patterns with wildcards are skipped; only plain paths are
+/// followed.
+pub fn find(content: &str) -> Vec<Embedded> {
+    let lines: Vec<&str> = content.lines().collect();
+    let mut found = Vec::new();
+    for (i, line) in lines.iter().enumerate() {
+        if line.trim() != EMBED_DIRECTIVE {
+            continue;
+        }
+        let mut next = i + 1;
+        let mut file = None;
+        while let Some(comment) = lines.get(next).map(|l| l.trim()) {
+            if !comment.starts_with("//") {
+                break;
+            }
+            if let Some(paths) = comment.strip_prefix("//go:embed ") {
+                file = paths
+                    .split_whitespace()
+                    .next()
+                    .filter(|p| !p.contains(['*', '?', '[']));
+            }
+            next += 1;
+        }
+        match file {
+            Some(path) => found.push(Embedded::File(path.to_string())),
+            None => found.extend(raw_literal(&lines, next)),
+        }
+    }
+    found
+}
+
+/// The first raw string literal starting on line index `start`, if it is
+/// closed.
+fn raw_literal(lines: &[&str], start: usize) -> Option<Embedded> {
+    let first = lines.get(start)?;
+    let open = first.find('`')?;
+    let mut source = String::new();
+    let mut rest = &first[open + 1..];
+    let mut next = start + 1;
+    while !rest.contains('`') {
+        source.push_str(rest);
+        source.push('\n');
+        rest = lines.get(next)?;
+        next += 1;
+    }
+    source.push_str(&rest[..rest.find('`')?]);
+    Some(Embedded::Literal {
+        source,
+        line: start + 1,
+        column: open + 2,
+    })
+}
+
+/// Scan the Go sources embedded in the host file `path` and return their
+/// findings.
+///
+/// Literal findings are reported in the host file, at the position of the
+/// matched text there. Embedded-file findings keep the embedded file's own
+/// path and lines. Package-wide checks do not run on embedded sources.
+pub fn scan(scanner: &Scanner, path: &str, content: &str) -> Vec<Finding> {
+    let dir = Path::new(path).parent().unwrap_or(Path::new(""));
+    let mut findings = Vec::new();
+    for embedded in find(content) {
+        match embedded {
+            Embedded::Literal {
+                source,
+                line,
+                column,
+            } => {
+                let virtual_path = format!("{}#embed.go", path);
+                for mut finding in own_findings(scanner, scanner.scan_file(&virtual_path, &source))
+                {
+                    if finding.line == 1 {
+                        finding.column += column - 1;
+                    }
+                    finding.line += line - 1;
+                    finding.file = path.to_string();
+                    findings.push(finding);
+                }
+            }
+            Embedded::File(name) => {
+                let file = dir.join(&name);
+                if let Ok(source) = fs::read_to_string(&file) {
+                    let file = file.to_string_lossy();
+                    let virtual_path = format!("{}#embed.go", file);
+                    for mut finding in
+                        own_findings(scanner, scanner.scan_file(&virtual_path, &source))
+                    {
+                        finding.file = file.to_string();
+                        findings.push(finding);
+                    }
+                }
+            }
+        }
+    }
+    findings
+}
+
+/// Findings of `result` that need no other file to confirm.
+fn own_findings(scanner: &Scanner, result: FileScanResult) -> impl Iterator<Item = Finding> + '_ {
+    result
+        .findings
+        .into_iter()
+        .filter(move |f| !scanner.is_package_finding(f))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    const HOST: &str = "package tmpl
+
+//antislop:embed-go
+var example = `func Add(a, b int) int {
+	// TODO: handle overflow
+	return a + b
+}`
+
+//antislop:embed-go
+//go:embed testdata/sample.go.txt
+var sample string
+
+//go:embed other.txt
+var other string
+";
+
+    #[test]
+    fn test_find_literal_and_file() {
+        let found = find(HOST);
+        assert_eq!(found.len(), 2);
+        assert_eq!(
+            found[0],
+            Embedded::Literal {
+                source: "func Add(a, b int) int {\n\t// TODO: handle overflow\n\treturn a + b\n}"
+                    .to_string(),
+                line: 4,
+                column: 16,
+            }
+        );
+        assert_eq!(
+            found[1],
+            Embedded::File("testdata/sample.go.txt".to_string())
+        );
+    }
+
+    #[test]
+    fn test_single_line_literal() {
+        let found = find("//antislop:embed-go\nconst s = `x := 1 // TODO: y`\n");
+        assert_eq!(
+            found,
+            vec![Embedded::Literal {
+                source: "x := 1 // TODO: y".to_string(),
+                line: 2,
+                column: 12,
+            }]
+        );
+    }
+
+    #[test]
+    fn test_literal_findings_map_to_host_lines() {
+        let scanner = Scanner::new(crate::Config::default().patterns).unwrap();
+        let findings = scan(&scanner, "tmpl.go", HOST);
+        let todo = findings
+            .iter()
+            .find(|f| f.match_text.contains("TODO"))
+            .expect("TODO in the literal is reported");
+        assert_eq!(todo.file, "tmpl.go");
+        assert_eq!(todo.line, 5);
+    }
+}
@@ -8,8 +8,9 @@
 //!
 //! Philosophy: Don't enforce opinions. Learn what the project does and flag deviations.
 
-use crate::config::{Pattern, PatternCategory, Severity};
+use crate::config::{Confidence, DetectorOptions, Pattern, PatternCategory, Severity};
 use crate::detector::Finding;
+use crate::{Error, Result};
 use std::collections::{HashMap, HashSet};
 use std::path::Path;
 
@@ -27,6 +28,30 @@ pub struct FilenameCheckConfig {
     pub use_language_hints: bool,
 }
 
+impl FilenameCheckConfig {
+    /// Build from `[detectors.filename]` options.
+    ///
+    /// Keys are the field names. Unset keys keep the CLI defaults: no
+    /// duplicate check, 5 files per convention, a 0.7 threshold and no
+    /// language hints.
+    pub fn from_options(mut options: DetectorOptions) -> Result<Self> {
+        let config = Self {
+            check_duplicates: options.take_bool("check_duplicates")?.unwrap_or(false),
+            min_files_for_convention: options.take_usize("min_files_for_convention")?.unwrap_or(5),
+            convention_threshold: options.take_f64("convention_threshold")?.unwrap_or(0.7),
+            use_language_hints: options.take_bool("use_language_hints")?.unwrap_or(false),
+        };
+        options.finish("filename")?;
+        if !(0.0..=1.0).contains(&config.convention_threshold) {
+            return Err(Error::ConfigInvalid(format!(
+                "convention_threshold must be between 0.0 and 1.0, got {}",
+                config.convention_threshold
+            )));
+        }
+        Ok(config)
+    }
+}
+
 /// Extract suffix patterns from naming patterns (for duplicate detection).
 fn extract_duplicate_suffixes(patterns: &[Pattern]) -> Vec<String> {
     patterns
@@ -397,9 +422,17 @@ impl FilenameChecker {
                                 ),
                                 match_text: format!("{}.{}", stem, ext),
                                 pattern_regex: "duplicate_file".to_string(),
+                                pattern_id: None,
+                                tags: vec![],
+                                confidence: Confidence::Medium,
                                 source_line: None,
                                 context_before: None,
                                 context_after: None,
+                                fix: None,
+                                suggestion: Some(
+                                    "Merge the two files into one, or rename the copy to say how it differs"
+                                        .to_string(),
+                                ),
                             });
                         }
                         break;
@@ -440,9 +473,17 @@ impl FilenameChecker {
                                 ),
                                 match_text: format!("{}.{}", stem, ext),
                                 pattern_regex: "duplicate_file".to_string(),
+                                pattern_id: None,
+                                tags: vec![],
+                                confidence: Confidence::Medium,
                                 source_line: None,
                                 context_before: None,
                                 context_after: None,
+                                fix: None,
+                                suggestion: Some(
+                                    "Merge the two files into one, or rename the copy to say how it differs"
+                                        .to_string(),
+                                ),
                             });
                         }
                         break;
@@ -531,9 +572,17 @@ impl FilenameChecker {
                         ),
                         match_text: filename.to_string(),
                         pattern_regex: "naming_convention".to_string(),
+                        pattern_id: None,
+                        tags: vec![],
+                        confidence: Confidence::Medium,
                         source_line: None,
                         context_before: None,
                         context_after: None,
+                        fix: None,
+                        suggestion: Some(format!(
+                            "Rename the file to use {} like the rest of the project",
+                            dominant_convention.description()
+                        )),
                     });
                 }
             }
@@ -631,22 +680,62 @@ mod tests {
         // Create mock patterns for testing
         let patterns = vec![
             Pattern {
+                id: None,
                 regex: crate::config::RegexPattern::new("(?i)_real\\.(rs|py)".to_string())
                     .expect("valid regex"),
                 severity: Severity::High,
                 message: "test".to_string(),
                 category: PatternCategory::NamingConvention,
+                tags: vec![],
+                confidence: Confidence::Medium,
                 ast_query: None,
                 languages: vec![],
+                exclude_regex: None,
+                exclude_enclosing: None,
+                exclude_packages: vec![],
+                min_version: None,
+                max_version: None,
+                max_params: None,
+                param_type: None,
+                max_elements: None,
+                max_fields: None,
+                mode_mask: None,
+                min_tokens: None,
+                max_percent: None,
+                exported_only: None,
+                suggestion: None,
+                check: None,
+                fix: None,
+                docs: None,
             },
             Pattern {
+                id: None,
                 regex: crate::config::RegexPattern::new("(?i)_new\\.(rs|py)".to_string())
                     .expect("valid regex"),
                 severity: Severity::High,
                 message: "test".to_string(),
                 category: PatternCategory::NamingConvention,
+                tags: vec![],
+                confidence: Confidence::Medium,
                 ast_query: None,
                 languages: vec![],
+                exclude_regex: None,
+                exclude_enclosing: None,
+                exclude_packages: vec![],
+                min_version: None,
+                max_version: None,
+                max_params: None,
+                param_type: None,
+                max_elements: None,
+                max_fields: None,
+                mode_mask: None,
+                min_tokens: None,
+                max_percent: None,
+                exported_only: None,
+                suggestion: None,
+                check: None,
+                fix: None,
+                docs: None,
             },
         ];
 
@@ -777,13 +866,33 @@ mod tests {
 
         // Create mock patterns for testing
         let patterns = vec![Pattern {
+            id: None,
             regex: crate::config::RegexPattern::new("(?i)_real\\.(rs|py)".to_string())
                 .expect("valid regex"),
             severity: Severity::High,
             message: "test".to_string(),
             category: PatternCategory::NamingConvention,
+            tags: vec![],
+            confidence: Confidence::Medium,
             ast_query: None,
             languages: vec![],
+            exclude_regex: None,
+            exclude_enclosing: None,
+            exclude_packages: vec![],
+            min_version: None,
+            max_version: None,
+            max_params: None,
+            param_type: None,
+            max_elements: None,
+            max_fields: None,
+            mode_mask: None,
+            min_tokens: None,
+            max_percent: None,
+            exported_only: None,
+            suggestion: None,
+            check: None,
+            fix: None,
+            docs: None,
         }];
 
         let mut checker = FilenameChecker::with_config_and_patterns(config, &patterns);
@@ -800,4 +909,24 @@ mod tests {
         // Should find the duplicate
         assert!(findings.iter().any(|f| f.message.contains("duplicate")));
     }
+
+    #[test]
+    fn test_config_from_options() {
+        let options = |toml: &str| toml::from_str::<DetectorOptions>(toml).unwrap();
+
+        let config = FilenameCheckConfig::from_options(options("")).unwrap();
+        assert_eq!(config.min_files_for_convention, 5);
+        assert_eq!(config.convention_threshold, 0.7);
+
+        let config = FilenameCheckConfig::from_options(options(
+            "check_duplicates = true\nmin_files_for_convention = 3\nconvention_threshold = 1",
+        ))
+        .unwrap();
+        assert!(config.check_duplicates);
+        assert_eq!(config.min_files_for_convention, 3);
+        assert_eq!(config.convention_threshold, 1.0);
+
+        assert!(FilenameCheckConfig::from_options(options("threshold = 0.5")).is_err());
+        assert!(FilenameCheckConfig::from_options(options("convention_threshold = 1.5")).is_err());
+    }
 }
@@ -0,0 +1,283 @@
+//! Applying suggested fixes to source text and showing them as a diff.
+
+use crate::report::output::write_atomic;
+use crate::{Finding, Result, SuggestedFix};
+use std::fmt::Write;
+use std::fs;
+use std::path::Path;
+
+/// Lines of unchanged context around each diff hunk.
+const CONTEXT: usize = 3;
+
+/// The outcome of applying the fixes of one file's findings.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct FixedFile {
+    /// Content with every applied fix spliced in.
+    pub content: String,
+    /// Number of fixes applied.
+    pub applied: usize,
+    /// Fixes left out because they overlap one applied before them, or
+    /// point outside the content.
+    pub skipped: usize,
+    /// Applied edits as `(first line, last line, replacement lines)` of the
+    /// original content, in order.
+    segments: Vec<(usize, usize, String)>,
+}
+
+/// A fix resolved to byte offsets in the original content.
+struct Edit<'a> {
+    start: usize,
+    end: usize,
+    start_line: usize,
+    end_line: usize,
+    replacement: &'a str,
+}
+
+/// Apply the fixes of `findings` to `content`.
+///
+/// Fixes are applied in position order. One that overlaps a fix already
+/// applied is skipped rather than merged, so every applied fix replaces
+/// exactly the text its finding matched.
+pub fn apply<'a>(content: &str, findings: impl IntoIterator<Item = &'a Finding>) -> FixedFile {
+    let line_starts: Vec<usize> = std::iter::once(0)
+        .chain(content.match_indices('\n').map(|(i, _)| i + 1))
+        .collect();
+    let offset = |line: usize, column: usize| -> Option<usize> {
+        let start = *line_starts.get(line.checked_sub(1)?)?;
+        let offset = start + column.checked_sub(1)?;
+        (offset <= content.len() && content.is_char_boundary(offset)).then_some(offset)
+    };
+
+    let mut skipped = 0;
+    let mut edits: Vec<Edit> = Vec::new();
+    for fix in findings.into_iter().filter_map(|f| f.fix.as_ref()) {
+        match (
+            offset(fix.start_line, fix.start_column),
+            offset(fix.end_line, fix.end_column),
+        ) {
+            (Some(start), Some(end)) if start <= end => edits.push(edit(fix, start, end)),
+            _ => skipped += 1,
+        }
+    }
+    edits.sort_by_key(|e| (e.start, e.end));
+
+    let mut kept: Vec<Edit> = Vec::new();
+    for edit in edits {
+        if kept.last().is_some_and(|prev| edit.start < prev.end) {
+            skipped += 1;
+        } else {
+            kept.push(edit);
+        }
+    }
+
+    let mut fixed = String::with_capacity(content.len());
+    let mut copied = 0;
+    for edit in &kept {
+        fixed.push_str(&content[copied..edit.start]);
+        fixed.push_str(edit.replacement);
+        copied = edit.end;
+    }
+    fixed.push_str(&content[copied..]);
+
+    // Group edits that share a line, then rewrite each group's lines
+    let mut segments: Vec<(usize, usize, String)> = Vec::new();
+    let mut group: Vec<&Edit> = Vec::new();
+    for (i, edit) in kept.iter().enumerate() {
+        group.push(edit);
+        let joins_next = kept
+            .get(i + 1)
+            .is_some_and(|next| next.start_line <= group_end(&group));
+        if joins_next {
+            continue;
+        }
+        let first = group[0].start_line;
+        let last = group_end(&group);
+        let from = line_starts[first - 1];
+        let to = line_starts.get(last).copied().unwrap_or(content.len());
+        let mut text = String::new();
+        let mut at = from;
+        for edit in &group {
+            text.push_str(&content[at..edit.start]);
+            text.push_str(edit.replacement);
+            at = edit.end;
+        }
+        text.push_str(&content[at..to]);
+        segments.push((first, last, text));
+        group.clear();
+    }
+
+    FixedFile {
+        content: fixed,
+        applied: kept.len(),
+        skipped,
+        segments,
+    }
+}
+
+fn edit(fix: &SuggestedFix, start: usize, end: usize) -> Edit<'_> {
+    Edit {
+        start,
+        end,
+        start_line: fix.start_line,
+        end_line: fix.end_line,
+        replacement: &fix.replacement,
+    }
+}
+
+fn group_end(group: &[&Edit]) -> usize {
+    group.iter().map(|e| e.end_line).max().unwrap_or(0)
+}
+
+impl FixedFile {
+    /// Write the fixed content over `path`, keeping its permissions. The
+    /// file is replaced only once the new content is completely written.
+    pub fn write(&self, path: &Path) -> Result<()> {
+        let permissions = fs::metadata(path)?.permissions();
+        write_atomic(path, |handle| {
+            std::io::Write::write_all(handle, self.content.as_bytes())?;
+            Ok(())
+        })?;
+        fs::set_permissions(path, permissions)?;
+        Ok(())
+    }
+
+    /// Unified diff from `original` to the fixed content, labelled with
+    /// `path`. Empty when no fix was applied.
+    ///
+    /// `original` must be the content the fixes were applied to.
+    pub fn diff(&self, path: &str, original: &str) -> String {
+        if self.segments.is_empty() {
+            return String::new();
+        }
+        let old: Vec<&str> = original.lines().collect();
+
+        let mut hunks: Vec<Vec<&(usize, usize, String)>> = Vec::new();
+        for segment in &self.segments {
+            match hunks.last_mut() {
+                Some(hunk) if segment.0 <= hunk[hunk.len() - 1].1 + 2 * CONTEXT + 1 => {
+                    hunk.push(segment)
+                }
+                _ => hunks.push(vec![segment]),
+            }
+        }
+
+        let mut out = String::new();
+        let _ = writeln!(out, "--- a/{}", path);
+        let _ = writeln!(out, "+++ b/{}", path);
+        let mut shift: isize = 0;
+        for hunk in hunks {
+            let first = hunk[0].0.saturating_sub(CONTEXT).max(1);
+            let last = (hunk[hunk.len() - 1].1 + CONTEXT).min(old.len());
+            let mut body = String::new();
+            let mut old_len = 0;
+            let mut new_len = 0;
+            let mut line = first;
+            for (start, end, text) in &hunk {
+                for context in &old[line - 1..start - 1] {
+                    let _ = writeln!(body, " {}", context);
+                }
+                old_len += start - line;
+                new_len += start - line;
+                for removed in &old[start - 1..*end] {
+                    let _ = writeln!(body, "-{}", removed);
+                }
+                for added in text.lines() {
+                    let _ = writeln!(body, "+{}", added);
+                }
+                old_len += end - start + 1;
+                new_len += text.lines().count();
+                line = end + 1;
+            }
+            for context in old.get(line - 1..last).unwrap_or_default() {
+                let _ = writeln!(body, " {}", context);
+            }
+            let trailing = (last + 1).saturating_sub(line);
+            old_len += trailing;
+            new_len += trailing;
+
+            let new_first = first as isize + shift;
+            let _ = writeln!(
+                out,
+                "@@ -{},{} +{},{} @@",
+                first, old_len, new_first, new_len
+            );
+            out.push_str(&body);
+            shift += new_len as isize - old_len as isize;
+        }
+        out
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::{PatternCategory, Severity};
+
+    fn finding(line: usize, column: usize, end_column: usize, replacement: &str) -> Finding {
+        Finding {
+            file: "a.go".to_string(),
+            line,
+            column,
+            severity: Severity::Info,
+            category: PatternCategory::Modernize,
+            message: "m".to_string(),
+            match_text: "m".to_string(),
+            pattern_regex: "m".to_string(),
+            pattern_id: None,
+            tags: vec![],
+            confidence: Default::default(),
+            source_line: None,
+            context_before: None,
+            context_after: None,
+            fix: Some(SuggestedFix {
+                start_line: line,
+                start_column: column,
+                end_line: line,
+                end_column,
+                replacement: replacement.to_string(),
+            }),
+            suggestion: None,
+        }
+    }
+
+    const SOURCE: &str = "package a\n\nvar x = old\nvar y = old\n\n\n\n\n\n\n\nvar z = old\n";
+
+    #[test]
+    fn test_apply_skips_overlapping_fixes() {
+        let findings = vec![
+            finding(4, 9, 12, "new"),
+            finding(3, 9, 12, "new"),
+            finding(3, 5, 10, "clash"),
+        ];
+        let fixed = apply(SOURCE, &findings);
+        assert_eq!(fixed.applied, 2);
+        assert_eq!(fixed.skipped, 1);
+        assert!(fixed
+            .content
+            .starts_with("package a\n\nvar x = new\nvar y = new\n"));
+    }
+
+    #[test]
+    fn test_diff_hunks() {
+        let findings = vec![
+            finding(3, 9, 12, "new"),
+            finding(4, 9, 12, "new"),
+            finding(12, 9, 12, "new"),
+        ];
+        let fixed = apply(SOURCE, &findings);
+        let diff = fixed.diff("a.go", SOURCE);
+        assert_eq!(
+            diff,
+            "--- a/a.go\n+++ b/a.go\n\
+             @@ -1,7 +1,7 @@\n package a\n \n-var x = old\n+var x = new\n-var y = old\n+var y = new\n \n \n \n\
+             @@ -9,4 +9,4 @@\n \n \n \n-var z = old\n+var z = new\n"
+        );
+    }
+
+    #[test]
+    fn test_no_fixes_no_diff() {
+        let fixed = apply(SOURCE, &[]);
+        assert_eq!(fixed.content, SOURCE);
+        assert!(fixed.diff("a.go", SOURCE).is_empty());
+    }
+}
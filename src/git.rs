@@ -0,0 +1,90 @@
+//! Git helpers for scanning another revision of the repository.
+
+use crate::{Error, Result};
+use std::path::{Path, PathBuf};
+use std::process::Command;
+
+/// Run `git -C <dir> <args>` and return its trimmed stdout.
+fn git(dir: &Path, args: &[&str]) -> Result<String> {
+    let output = Command::new("git").arg("-C").arg(dir).args(args).output()?;
+    if !output.status.success() {
+        return Err(Error::Git(format!(
+            "git {} failed: {}",
+            args.join(" "),
+            String::from_utf8_lossy(&output.stderr).trim()
+        )));
+    }
+    Ok(String::from_utf8_lossy(&output.stdout).trim().to_string())
+}
+
+/// Root directory of the repository containing `dir`.
+pub fn toplevel(dir: &Path) -> Result<PathBuf> {
+    git(dir, &["rev-parse", "--show-toplevel"]).map(PathBuf::from)
+}
+
+/// Path of `dir` relative to the repository root (empty at the root).
+pub fn prefix(dir: &Path) -> Result<PathBuf> {
+    git(dir, &["rev-parse", "--show-prefix"]).map(PathBuf::from)
+}
+
+/// Commit where HEAD diverged from `base` (e.g., "origin/main").
+pub fn merge_base(dir: &Path, base: &str) -> Result<String> {
+    git(dir, &["merge-base", "HEAD", base])
+}
+
+/// Author time (Unix seconds) of every line of `file`, from `git blame`.
+///
+/// Lines not committed yet get the current time, as git reports them.
+pub fn blame_times(file: &Path) -> Result<Vec<i64>> {
+    let dir = file.parent().filter(|d| !d.as_os_str().is_empty());
+    let name = file.file_name().map(|n| n.to_string_lossy().to_string());
+    let name = name.ok_or_else(|| Error::Git(format!("not a file: {}", file.display())))?;
+    let porcelain = git(
+        dir.unwrap_or(Path::new(".")),
+        &["blame", "--line-porcelain", "--", &name],
+    )?;
+    Ok(porcelain
+        .lines()
+        .filter_map(|line| line.strip_prefix("author-time "))
+        .filter_map(|time| time.parse().ok())
+        .collect())
+}
+
+/// A detached checkout of a revision in a temporary directory.
+///
+/// The worktree is removed again when this value is dropped.
+pub struct Worktree {
+    repo: PathBuf,
+    path: PathBuf,
+}
+
+impl Worktree {
+    /// Check out `rev` from the repository at `repo`.
+    pub fn checkout(repo: &Path, rev: &str) -> Result<Self> {
+        let path = std::env::temp_dir().join(format!("antislop-worktree-{}", std::process::id()));
+        let path_str = path.to_string_lossy().to_string();
+        git(
+            repo,
+            &["worktree", "add", "--detach", "--quiet", &path_str, rev],
+        )?;
+        Ok(Self {
+            repo: repo.to_path_buf(),
+            path,
+        })
+    }
+
+    /// Root directory of the checkout.
+    pub fn path(&self) -> &Path {
+        &self.path
+    }
+}
+
+impl Drop for Worktree {
+    fn drop(&mut self) {
+        let path_str = self.path.to_string_lossy().to_string();
+        if git(&self.repo, &["worktree", "remove", "--force", &path_str]).is_err() {
+            let _ = std::fs::remove_dir_all(&self.path);
+            let _ = git(&self.repo, &["worktree", "prune"]);
+        }
+    }
+}
@@ -24,25 +24,36 @@
 //! - **Hedging**: "hopefully", "should work", "this is a simple"
 //! - **Stub**: Empty functions near placeholder comments
 
+pub mod accuracy;
+pub mod baseline;
+pub mod compare;
 pub mod config;
 pub mod detector;
+pub mod embed;
 pub mod filename_checker;
+pub mod fix;
+pub mod git;
 pub mod hygiene;
 pub mod profile;
 pub mod report;
+pub mod select;
+pub mod serve;
 pub mod walker;
 
 #[doc(inline)]
-pub use config::{Config, Pattern, PatternCategory, Severity};
+pub use config::{Confidence, Config, DetectorOptions, Pattern, PatternCategory, Severity};
 
 #[doc(inline)]
-pub use detector::{Comment, FileScanResult, Finding, ScanSummary, Scanner};
+pub use detector::{
+    Comment, FileScanResult, Finding, Findings, PackageContext, PackageDetector,
+    PackageDetectorFactory, ParseError, RootSummary, ScanSummary, Scanner, SuggestedFix,
+};
 
 #[doc(inline)]
 pub use filename_checker::{FilenameCheckConfig, FilenameChecker};
 
 #[doc(inline)]
-pub use report::{Format, Reporter};
+pub use report::{count_trailer, Format, Reporter, Sections, Sink, Template};
 
 #[doc(inline)]
 pub use walker::Walker;
@@ -73,6 +84,10 @@ pub enum Error {
     #[error("Invalid regex: {0}")]
     Regex(#[from] regex::Error),
 
+    /// Git command failed.
+    #[error("Git error: {0}")]
+    Git(String),
+
     /// Tree-sitter parsing error.
     #[cfg(feature = "tree-sitter")]
     #[error("Parse error: {0}")]
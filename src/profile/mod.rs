@@ -519,12 +519,32 @@ mod tests {
                 ..Default::default()
             },
             patterns: vec![Pattern {
+                id: None,
                 regex: RegexPattern::new("(?i)TODO:".to_string()).unwrap(),
                 severity: Severity::Medium,
                 message: "TODO".to_string(),
                 category: PatternCategory::Placeholder,
+                tags: vec![],
+                confidence: Default::default(),
                 ast_query: None,
                 languages: vec![],
+                exclude_regex: None,
+                exclude_enclosing: None,
+                exclude_packages: vec![],
+                min_version: None,
+                max_version: None,
+                max_params: None,
+                param_type: None,
+                max_elements: None,
+                max_fields: None,
+                mode_mask: None,
+                min_tokens: None,
+                max_percent: None,
+                exported_only: None,
+                suggestion: None,
+                check: None,
+                fix: None,
+                docs: None,
             }],
         };
 
@@ -534,12 +554,32 @@ mod tests {
                 ..Default::default()
             },
             patterns: vec![Pattern {
+                id: None,
                 regex: RegexPattern::new("(?i)FIXME:".to_string()).unwrap(),
                 severity: Severity::High,
                 message: "FIXME".to_string(),
                 category: PatternCategory::Placeholder,
+                tags: vec![],
+                confidence: Default::default(),
                 ast_query: None,
                 languages: vec![],
+                exclude_regex: None,
+                exclude_enclosing: None,
+                exclude_packages: vec![],
+                min_version: None,
+                max_version: None,
+                max_params: None,
+                param_type: None,
+                max_elements: None,
+                max_fields: None,
+                mode_mask: None,
+                min_tokens: None,
+                max_percent: None,
+                exported_only: None,
+                suggestion: None,
+                check: None,
+                fix: None,
+                docs: None,
             }],
         };
 
@@ -0,0 +1,137 @@
+//! `--format metrics`: the summary as one InfluxDB line protocol point.
+//!
+//! The point is measurement `antislop`, an optional `root` tag, integer
+//! fields for the totals and one per category, and a timestamp. Every
+//! category is written, zero or not, so each run adds a point to the
+//! same fields. The timestamp is in nanoseconds since the Unix epoch.
+
+use crate::config::PatternCategory;
+use crate::detector::ScanSummary;
+use crate::Result;
+use std::io::Write;
+use std::time::{SystemTime, UNIX_EPOCH};
+
+/// Measurement name of the point.
+const MEASUREMENT: &str = "antislop";
+
+/// Categories in field order.
+const CATEGORIES: &[PatternCategory] = &[
+    PatternCategory::Placeholder,
+    PatternCategory::Deferral,
+    PatternCategory::Hedging,
+    PatternCategory::Stub,
+    PatternCategory::NamingConvention,
+    PatternCategory::Modernize,
+    PatternCategory::Correctness,
+    PatternCategory::Concurrency,
+    PatternCategory::Security,
+    PatternCategory::Performance,
+    PatternCategory::Maintainability,
+    PatternCategory::Style,
+];
+
+/// Write `summary` as one line, tagged with `root` when there is one.
+pub(super) fn write_metrics(
+    handle: &mut impl Write,
+    summary: &ScanSummary,
+    root: Option<&str>,
+    time: SystemTime,
+) -> Result<()> {
+    let mut line = MEASUREMENT.to_string();
+    if let Some(root) = root {
+        line.push_str(",root=");
+        line.push_str(&escape_tag(root));
+    }
+
+    let mut fields = vec![
+        ("files_scanned".to_string(), summary.files_scanned),
+        (
+            "files_with_findings".to_string(),
+            summary.files_with_findings,
+        ),
+        ("total_findings".to_string(), summary.total_findings),
+        ("total_score".to_string(), summary.total_score as usize),
+    ];
+    for category in CATEGORIES {
+        let count = summary.by_category.get(category).copied().unwrap_or(0);
+        fields.push((format!("{:?}", category).to_lowercase(), count));
+    }
+    let fields: Vec<String> = fields
+        .iter()
+        .map(|(name, value)| format!("{}={}i", name, value))
+        .collect();
+
+    let nanos = time
+        .duration_since(UNIX_EPOCH)
+        .map(|d| d.as_nanos())
+        .unwrap_or(0);
+    writeln!(handle, "{} {} {}", line, fields.join(","), nanos)?;
+    Ok(())
+}
+
+/// Escape the characters line protocol gives a meaning in tag values.
+fn escape_tag(value: &str) -> String {
+    let mut escaped = String::with_capacity(value.len());
+    for c in value.chars() {
+        if matches!(c, ',' | '=' | ' ' | '\\') {
+            escaped.push('\\');
+        }
+        escaped.push(c);
+    }
+    escaped
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::config::Severity;
+    use crate::detector::Finding;
+    use std::time::Duration;
+
+    fn finding(category: PatternCategory, severity: Severity) -> Finding {
+        Finding {
+            file: "a.go".to_string(),
+            line: 1,
+            column: 1,
+            severity,
+            category,
+            message: String::new(),
+            match_text: String::new(),
+            pattern_regex: String::new(),
+            pattern_id: None,
+            tags: vec![],
+            confidence: Default::default(),
+            source_line: None,
+            context_before: None,
+            context_after: None,
+            fix: None,
+            suggestion: None,
+        }
+    }
+
+    #[test]
+    fn test_metrics_line() {
+        let findings = vec![
+            finding(PatternCategory::Stub, Severity::Medium),
+            finding(PatternCategory::Stub, Severity::Low),
+            finding(PatternCategory::Placeholder, Severity::Low),
+        ];
+        let summary = ScanSummary::summarize(&findings, 4);
+        let time = UNIX_EPOCH + Duration::from_secs(1_700_000_000);
+        let mut out = Vec::new();
+        write_metrics(&mut out, &summary, Some("my repo,v2"), time).unwrap();
+        assert_eq!(
+            String::from_utf8(out).unwrap(),
+            "antislop,root=my\\ repo\\,v2 files_scanned=4i,files_with_findings=1i,\
+             total_findings=3i,total_score=7i,placeholder=1i,deferral=0i,hedging=0i,\
+             stub=2i,namingconvention=0i,modernize=0i,correctness=0i,concurrency=0i,\
+             security=0i,performance=0i,maintainability=0i,style=0i 1700000000000000000\n"
+        );
+
+        let mut out = Vec::new();
+        write_metrics(&mut out, &summary, None, time).unwrap();
+        assert!(String::from_utf8(out)
+            .unwrap()
+            .starts_with("antislop files_scanned=4i,"));
+    }
+}
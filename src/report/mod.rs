@@ -1,14 +1,22 @@
 //! Reporting and output formatting.
 
 use crate::config::{PatternCategory, Severity};
-use crate::detector::{Finding, ScanSummary};
+use crate::detector::{Finding, RootSummary, ScanSummary};
 use crate::Error;
 use crate::Result;
 use owo_colors::OwoColorize;
 use serde::Serialize;
 use std::io::{self, Write};
+use std::path::Path;
 
+mod metrics;
+pub(crate) mod output;
 mod sarif;
+mod sink;
+pub mod template;
+
+pub use sink::{emit, NdjsonSink, Sink};
+pub use template::Template;
 
 /// Output format.
 #[derive(Debug, Clone, Copy, clap::ValueEnum, PartialEq, Eq)]
@@ -19,6 +27,12 @@ pub enum Format {
     Json,
     /// SARIF XML/JSON output for integrations.
     Sarif,
+    /// Newline-delimited JSON, one finding per line, streamed.
+    Ndjson,
+    /// A user template, see [`Reporter::with_template`].
+    Template,
+    /// One InfluxDB line protocol point with the summary's totals.
+    Metrics,
 }
 
 impl Format {
@@ -32,11 +46,35 @@ impl Format {
     }
 }
 
+/// Which parts of a report are written.
+#[derive(Debug, Clone, Copy, Default, PartialEq, Eq)]
+pub enum Sections {
+    /// Findings, then the summary.
+    #[default]
+    All,
+    /// Findings without the summary (`--no-summary`).
+    Findings,
+    /// The summary without findings (`--summary-only`).
+    Summary,
+}
+
+impl Sections {
+    fn findings(self) -> bool {
+        self != Sections::Summary
+    }
+
+    fn summary(self) -> bool {
+        self != Sections::Findings
+    }
+}
+
 /// JSON output structure.
 #[derive(Debug, Serialize)]
 struct JsonOutput {
-    summary: JsonSummary,
-    findings: Vec<JsonFinding>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    summary: Option<JsonSummary>,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    findings: Option<Vec<JsonFinding>>,
 }
 
 #[derive(Debug, Serialize)]
@@ -47,6 +85,9 @@ struct JsonSummary {
     total_score: u32,
     by_severity: serde_json::Value,
     by_category: serde_json::Value,
+    by_pattern: serde_json::Value,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    roots: Vec<RootSummary>,
 }
 
 #[derive(Debug, Serialize)]
@@ -58,33 +99,197 @@ struct JsonFinding {
     category: String,
     message: String,
     match_text: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    id: Option<String>,
+    #[serde(skip_serializing_if = "Vec::is_empty")]
+    tags: Vec<String>,
+    confidence: String,
+    fingerprint: String,
+    #[serde(skip_serializing_if = "Option::is_none")]
+    suggestion: Option<String>,
 }
 
 /// Reporter for scan results.
+#[derive(Clone)]
 pub struct Reporter {
     format: Format,
+    /// Maximum number of findings to emit (0 = unlimited).
+    max_findings: usize,
+    /// Template for [`Format::Template`].
+    template: Option<Template>,
+    /// Parts of the report to write.
+    sections: Sections,
+    /// `root` tag of [`Format::Metrics`].
+    root: Option<String>,
+    /// Whether human output shows each finding's fingerprint.
+    fingerprints: bool,
 }
 
 impl Reporter {
     /// Create a new reporter.
     pub fn new(format: Format) -> Self {
-        Self { format }
+        Self {
+            format,
+            max_findings: 0,
+            template: None,
+            sections: Sections::All,
+            root: None,
+            fingerprints: false,
+        }
+    }
+
+    /// Show each finding's fingerprint in human output, for pasting into a
+    /// `[suppress]` table or a baseline. The document formats always
+    /// include it.
+    pub fn with_fingerprints(mut self, fingerprints: bool) -> Self {
+        self.fingerprints = fingerprints;
+        self
+    }
+
+    /// Tag [`Format::Metrics`] points with `root=<name>`, such as the name
+    /// of the repository scanned.
+    pub fn with_root(mut self, name: impl Into<String>) -> Self {
+        self.root = Some(name.into());
+        self
+    }
+
+    /// Write only some `sections` of the report.
+    ///
+    /// In JSON and template data the other key is left out, and NDJSON
+    /// drops the summary line or the finding lines. SARIF has no summary,
+    /// so [`Sections::Findings`] leaves it unchanged.
+    pub fn with_sections(mut self, sections: Sections) -> Self {
+        self.sections = sections;
+        self
     }
 
-    /// Report findings and summary.
+    /// Render [`Format::Template`] with `template`.
+    ///
+    /// The template sees the document [`Format::Json`] writes, with the
+    /// findings already capped.
+    pub fn with_template(mut self, template: Template) -> Self {
+        self.template = Some(template);
+        self
+    }
+
+    /// Cap the number of emitted findings (0 = unlimited).
+    ///
+    /// The summary still reflects every finding; only the listing is truncated.
+    pub fn with_max_findings(mut self, max_findings: usize) -> Self {
+        self.max_findings = max_findings;
+        self
+    }
+
+    /// Report findings and summary to stdout.
     pub fn report(&self, results: Vec<Finding>, summary: ScanSummary) -> Result<()> {
+        let stdout = io::stdout();
+        let mut handle = io::BufWriter::new(stdout.lock());
+        self.report_to(&mut handle, results, summary)?;
+        handle.flush()?;
+        Ok(())
+    }
+
+    /// Report findings and summary to a file, replacing it atomically.
+    ///
+    /// The report is written to a hidden file next to `path` and renamed
+    /// over it once complete, so readers never see partial output. On error
+    /// any existing file at `path` is left untouched.
+    pub fn report_to_file(
+        &self,
+        path: &Path,
+        results: Vec<Finding>,
+        summary: ScanSummary,
+    ) -> Result<()> {
+        output::write_atomic(path, |handle| self.report_to(handle, results, summary))
+    }
+
+    /// Report findings and summary to any writer.
+    ///
+    /// Findings are emitted in [`sort_findings`] order in every format. For
+    /// JSON, SARIF and NDJSON the truncation notice goes to stderr so the
+    /// written document stays machine-readable.
+    pub fn report_to(
+        &self,
+        handle: &mut impl Write,
+        results: Vec<Finding>,
+        summary: ScanSummary,
+    ) -> Result<()> {
+        emit(self.sink(handle).as_mut(), results, summary)
+    }
+
+    /// A [`Sink`] writing this reporter's format to `handle`.
+    ///
+    /// NDJSON lines are written as findings arrive, unless a cap holds them
+    /// back to keep the most severe. The other formats are documents, so
+    /// their sink holds findings until [`Sink::finish`] and then sorts and
+    /// caps them the same way.
+    pub fn sink<'a>(&self, handle: impl Write + 'a) -> Box<dyn Sink + 'a> {
         match self.format {
-            Format::Human => self.report_human(&results, &summary),
-            Format::Json => self.report_json(&results, &summary),
-            Format::Sarif => sarif::report_sarif(&results, &summary),
+            Format::Ndjson => Box::new(
+                NdjsonSink::new(handle)
+                    .with_max_findings(self.max_findings)
+                    .with_sections(self.sections),
+            ),
+            _ => Box::new(sink::FormatSink::new(self.clone(), handle)),
         }
     }
 
-    /// Human-readable terminal output.
-    fn report_human(&self, results: &[Finding], summary: &ScanSummary) -> Result<()> {
-        let stdout = io::stdout();
-        let mut handle = io::BufWriter::new(stdout.lock());
+    /// Write a document format with findings already sorted and capped.
+    fn write(
+        &self,
+        handle: &mut impl Write,
+        results: &[Finding],
+        summary: &ScanSummary,
+        omitted: usize,
+    ) -> Result<()> {
+        match self.format {
+            Format::Human => self.report_human(handle, results, summary, omitted),
+            Format::Json => {
+                self.report_json(handle, results, summary)?;
+                print_omitted_notice(&mut io::stderr(), omitted)
+            }
+            Format::Sarif => {
+                sarif::report_sarif(handle, results, summary)?;
+                print_omitted_notice(&mut io::stderr(), omitted)
+            }
+            Format::Ndjson => {
+                let mut sink = NdjsonSink::new(handle).with_sections(self.sections);
+                for finding in results {
+                    sink.report(finding.clone())?;
+                }
+                sink.finish(summary)?;
+                print_omitted_notice(&mut io::stderr(), omitted)
+            }
+            Format::Metrics => metrics::write_metrics(
+                handle,
+                summary,
+                self.root.as_deref(),
+                std::time::SystemTime::now(),
+            ),
+            Format::Template => {
+                let template = self.template.as_ref().ok_or_else(|| {
+                    Error::ConfigInvalid("The template format needs a template".to_string())
+                })?;
+                let output = json_output(results, summary, self.sections);
+                let data = serde_json::to_value(&output)
+                    .map_err(|e| Error::ConfigInvalid(e.to_string()))?;
+                template.render(handle, &data)?;
+                print_omitted_notice(&mut io::stderr(), omitted)
+            }
+        }
+    }
 
+    /// Human-readable terminal output.
+    fn report_human(
+        &self,
+        handle: &mut impl Write,
+        results: &[Finding],
+        summary: &ScanSummary,
+        omitted: usize,
+    ) -> Result<()> {
+        if self.sections == Sections::Summary {
+            return self.print_summary(handle, summary);
+        }
         if results.is_empty() {
             writeln!(
                 handle,
@@ -95,10 +300,13 @@ impl Reporter {
         }
 
         for finding in results {
-            self.write_finding(&mut handle, finding)?;
+            self.write_finding(handle, finding)?;
         }
 
-        self.print_summary(&mut handle, summary)?;
+        print_omitted_notice(handle, omitted)?;
+        if self.sections.summary() {
+            self.print_summary(handle, summary)?;
+        }
         Ok(())
     }
 
@@ -106,6 +314,7 @@ impl Reporter {
     fn write_finding(&self, handle: &mut impl Write, finding: &Finding) -> Result<()> {
         let severity_color = |s: &Severity| -> &'static str {
             match s {
+                Severity::Info => "\x1b[36m",         // cyan
                 Severity::Low => "\x1b[2m",           // dim
                 Severity::Medium => "\x1b[33m",       // yellow
                 Severity::High => "\x1b[31;1m",       // red bold
@@ -120,6 +329,13 @@ impl Reporter {
                 PatternCategory::Hedging => "\x1b[93m",     // bright yellow
                 PatternCategory::Stub => "\x1b[91m",        // bright red
                 PatternCategory::NamingConvention => "\x1b[38;5;214m", // orange
+                PatternCategory::Modernize => "\x1b[94m",   // bright blue
+                PatternCategory::Correctness => "\x1b[31m", // red
+                PatternCategory::Concurrency => "\x1b[35m", // magenta
+                PatternCategory::Security => "\x1b[91;1m",  // bright red bold
+                PatternCategory::Performance => "\x1b[32m", // green
+                PatternCategory::Maintainability => "\x1b[34m", // blue
+                PatternCategory::Style => "\x1b[37m",       // white
             }
         };
 
@@ -155,6 +371,17 @@ impl Reporter {
 
         // Message
         writeln!(handle, "  {} {}", "│".dimmed(), finding.message.dimmed())?;
+        if self.fingerprints {
+            writeln!(
+                handle,
+                "  {} fingerprint: {}",
+                "│".dimmed(),
+                finding.fingerprint()
+            )?;
+        }
+        if let Some(ref suggestion) = finding.suggestion {
+            writeln!(handle, "  {} suggestion: {}", "│".dimmed(), suggestion)?;
+        }
         writeln!(handle, "  {}", "│".dimmed())?;
 
         // Calculate line number width for padding
@@ -186,9 +413,11 @@ impl Reporter {
                 width = line_width
             )?;
 
-            // Caret line pointing to the match
+            // Caret line pointing to the match (first line only for
+            // AST matches that span a whole declaration)
             let col = finding.column.saturating_sub(1);
-            let match_len = finding.match_text.len().max(1);
+            let first_line = finding.match_text.lines().next().unwrap_or("");
+            let match_len = first_line.len().max(1);
             let padding = " ".repeat(col);
             let caret = "^".repeat(match_len);
             writeln!(
@@ -258,9 +487,11 @@ impl Reporter {
                 Severity::High,
                 Severity::Medium,
                 Severity::Low,
+                Severity::Info,
             ] {
                 if let Some(&count) = summary.by_severity.get(&severity) {
                     let color = match severity {
+                        Severity::Info => "\x1b[36m",
                         Severity::Low => "\x1b[2m",
                         Severity::Medium => "\x1b[33m",
                         Severity::High => "\x1b[31;1m",
@@ -281,6 +512,13 @@ impl Reporter {
                 PatternCategory::Deferral,
                 PatternCategory::Hedging,
                 PatternCategory::NamingConvention,
+                PatternCategory::Modernize,
+                PatternCategory::Correctness,
+                PatternCategory::Concurrency,
+                PatternCategory::Security,
+                PatternCategory::Performance,
+                PatternCategory::Maintainability,
+                PatternCategory::Style,
             ] {
                 if let Some(&count) = summary.by_category.get(&category) {
                     let color = match category {
@@ -289,6 +527,13 @@ impl Reporter {
                         PatternCategory::Deferral => "\x1b[95m",
                         PatternCategory::Hedging => "\x1b[93m",
                         PatternCategory::NamingConvention => "\x1b[38;5;214m",
+                        PatternCategory::Modernize => "\x1b[94m",
+                        PatternCategory::Correctness => "\x1b[31m",
+                        PatternCategory::Concurrency => "\x1b[35m",
+                        PatternCategory::Security => "\x1b[91;1m",
+                        PatternCategory::Performance => "\x1b[32m",
+                        PatternCategory::Maintainability => "\x1b[34m",
+                        PatternCategory::Style => "\x1b[37m",
                     };
                     write!(
                         handle,
@@ -302,6 +547,28 @@ impl Reporter {
             writeln!(handle)?;
         }
 
+        if !summary.roots.is_empty() {
+            writeln!(handle)?;
+            writeln!(handle, "  By root:")?;
+            let width = summary
+                .roots
+                .iter()
+                .map(|r| r.path.len())
+                .max()
+                .unwrap_or(0);
+            for root in &summary.roots {
+                writeln!(
+                    handle,
+                    "    {:<width$}  {} files, {} findings, score {}",
+                    root.path.cyan(),
+                    root.files_scanned,
+                    root.total_findings,
+                    root.total_score,
+                    width = width
+                )?;
+            }
+        }
+
         writeln!(handle)?;
 
         let verdict = match summary.total_score {
@@ -317,53 +584,161 @@ impl Reporter {
     }
 
     /// JSON output.
-    fn report_json(&self, results: &[Finding], summary: &ScanSummary) -> Result<()> {
-        use serde_json::Value;
-
-        let by_severity: Value = summary
-            .by_severity
-            .iter()
-            .map(|(k, v)| (k.as_str().to_lowercase(), Value::from(*v)))
-            .collect();
-
-        let by_category: Value = summary
-            .by_category
-            .iter()
-            .map(|(k, v)| (format!("{:?}", k).to_lowercase(), Value::from(*v)))
-            .collect();
-
-        let output = JsonOutput {
-            summary: JsonSummary {
-                files_scanned: summary.files_scanned,
-                files_with_findings: summary.files_with_findings,
-                total_findings: summary.total_findings,
-                total_score: summary.total_score,
-                by_severity,
-                by_category,
-            },
-            findings: results
-                .iter()
-                .map(|f| JsonFinding {
-                    file: f.file.clone(),
-                    line: f.line,
-                    column: f.column,
-                    severity: f.severity.as_str().to_string().to_lowercase(),
-                    category: format!("{:?}", f.category).to_lowercase(),
-                    message: f.message.clone(),
-                    match_text: f.match_text.clone(),
-                })
-                .collect(),
-        };
+    fn report_json(
+        &self,
+        handle: &mut impl Write,
+        results: &[Finding],
+        summary: &ScanSummary,
+    ) -> Result<()> {
+        let output = json_output(results, summary, self.sections);
 
-        println!(
+        writeln!(
+            handle,
             "{}",
             serde_json::to_string_pretty(&output)
                 .map_err(|e| Error::ConfigInvalid(e.to_string()))?
-        );
+        )?;
         Ok(())
     }
 }
 
+/// The JSON document, shared by the JSON and template formats.
+fn json_output(results: &[Finding], summary: &ScanSummary, sections: Sections) -> JsonOutput {
+    JsonOutput {
+        summary: sections.summary().then(|| json_summary(summary)),
+        findings: sections
+            .findings()
+            .then(|| results.iter().map(JsonFinding::from).collect()),
+    }
+}
+
+/// The JSON form of `summary`, shared by the JSON and NDJSON formats.
+fn json_summary(summary: &ScanSummary) -> JsonSummary {
+    use serde_json::Value;
+
+    let by_severity: Value = summary
+        .by_severity
+        .iter()
+        .map(|(k, v)| (k.as_str().to_lowercase(), Value::from(*v)))
+        .collect();
+
+    let by_category: Value = summary
+        .by_category
+        .iter()
+        .map(|(k, v)| (format!("{:?}", k).to_lowercase(), Value::from(*v)))
+        .collect();
+
+    let by_pattern: Value = summary
+        .by_pattern
+        .iter()
+        .map(|(k, v)| (k.clone(), Value::from(*v)))
+        .collect();
+
+    JsonSummary {
+        files_scanned: summary.files_scanned,
+        files_with_findings: summary.files_with_findings,
+        total_findings: summary.total_findings,
+        total_score: summary.total_score,
+        by_severity,
+        by_category,
+        by_pattern,
+        roots: summary.roots.clone(),
+    }
+}
+
+impl From<&Finding> for JsonFinding {
+    fn from(f: &Finding) -> Self {
+        JsonFinding {
+            file: f.file.clone(),
+            line: f.line,
+            column: f.column,
+            severity: f.severity.as_str().to_string().to_lowercase(),
+            category: format!("{:?}", f.category).to_lowercase(),
+            message: f.message.clone(),
+            match_text: f.match_text.clone(),
+            id: f.pattern_id.clone(),
+            tags: f.tags.clone(),
+            confidence: f.confidence.as_str().to_lowercase(),
+            fingerprint: f.fingerprint(),
+            suggestion: f.suggestion.clone(),
+        }
+    }
+}
+
+/// One-line finding count for scripts, in a format that does not change:
+///
+/// `antislop: <N> findings (<N> critical, <N> high, <N> medium, <N> low, <N> info) in <N> files`
+///
+/// Every severity is listed, most severe first, even when its count is 0.
+pub fn count_trailer(summary: &ScanSummary) -> String {
+    let count = |severity: Severity| summary.by_severity.get(&severity).copied().unwrap_or(0);
+    format!(
+        "antislop: {} findings ({} critical, {} high, {} medium, {} low, {} info) in {} files",
+        summary.total_findings,
+        count(Severity::Critical),
+        count(Severity::High),
+        count(Severity::Medium),
+        count(Severity::Low),
+        count(Severity::Info),
+        summary.files_scanned
+    )
+}
+
+/// Sort findings into the order every report format emits them.
+///
+/// The order is file path, line, column, then pattern id (or regex), with
+/// message and matched text as final tie-breakers. It depends only on the
+/// findings themselves, so output is identical however files were scanned.
+pub fn sort_findings(findings: &mut [Finding]) {
+    findings.sort_by(compare_findings);
+}
+
+pub(crate) fn compare_findings(a: &Finding, b: &Finding) -> std::cmp::Ordering {
+    a.file
+        .cmp(&b.file)
+        .then_with(|| a.line.cmp(&b.line))
+        .then_with(|| a.column.cmp(&b.column))
+        .then_with(|| a.pattern_key().cmp(b.pattern_key()))
+        .then_with(|| a.message.cmp(&b.message))
+        .then_with(|| a.match_text.cmp(&b.match_text))
+}
+
+/// Keep at most `max` findings, preferring the most severe ones.
+///
+/// Findings are ranked by severity (highest first), then in [`sort_findings`]
+/// order, so a capped report surfaces the most relevant issues. The kept
+/// findings are returned in [`sort_findings`] order along with the number
+/// that were dropped. A `max` of 0 keeps everything.
+pub fn select_findings(mut findings: Vec<Finding>, max: usize) -> (Vec<Finding>, usize) {
+    if max == 0 || findings.len() <= max {
+        return (findings, 0);
+    }
+
+    findings.sort_by(|a, b| {
+        b.severity
+            .cmp(&a.severity)
+            .then_with(|| compare_findings(a, b))
+    });
+    let omitted = findings.len() - max;
+    findings.truncate(max);
+    sort_findings(&mut findings);
+
+    (findings, omitted)
+}
+
+/// Write the truncation notice when findings were left out.
+fn print_omitted_notice(handle: &mut impl Write, omitted: usize) -> Result<()> {
+    if omitted > 0 {
+        writeln!(
+            handle,
+            "... and {} more findings (use --max-findings 0 for all)",
+            omitted
+        )?;
+        writeln!(handle)?;
+    }
+    Ok(())
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -388,9 +763,14 @@ mod tests {
             message: message.to_string(),
             match_text: match_text.to_string(),
             pattern_regex: "test".to_string(),
+            pattern_id: None,
+            tags: vec![],
+            confidence: Default::default(),
             source_line: None,
             context_before: None,
             context_after: None,
+            fix: None,
+            suggestion: None,
         }
     }
 
@@ -406,6 +786,8 @@ mod tests {
             total_score,
             by_severity,
             by_category,
+            by_pattern: HashMap::new(),
+            roots: vec![],
         }
     }
 
@@ -435,9 +817,11 @@ mod tests {
         )];
         let summary = make_summary(5, 1);
 
-        // Verify report_json doesn't panic
-        // report_json writes to stdout; capturing it is complex in unit tests
-        let _ = reporter.report_json(&results, &summary);
+        let mut out = Vec::new();
+        reporter.report_json(&mut out, &results, &summary).unwrap();
+        let json: serde_json::Value = serde_json::from_slice(&out).unwrap();
+        assert_eq!(json["findings"][0]["file"], "test.py");
+        assert_eq!(json["summary"]["total_score"], 5);
     }
 
     #[test]
@@ -447,7 +831,7 @@ mod tests {
         let summary = make_summary(0, 0);
 
         // Verify empty results don't panic
-        let _ = reporter.report_json(&results, &summary);
+        let _ = reporter.report_json(&mut Vec::new(), &results, &summary);
     }
 
     #[test]
@@ -465,6 +849,77 @@ mod tests {
         assert_eq!(make_summary(150, 30).total_score, 150);
     }
 
+    #[test]
+    fn test_select_findings_unlimited() {
+        let results = vec![
+            make_finding("a.py", 1, Severity::Low, PatternCategory::Stub, "a", "x"),
+            make_finding("b.py", 1, Severity::High, PatternCategory::Stub, "b", "x"),
+        ];
+        let (kept, omitted) = select_findings(results, 0);
+        assert_eq!(kept.len(), 2);
+        assert_eq!(omitted, 0);
+    }
+
+    #[test]
+    fn test_select_findings_prefers_severity() {
+        let results = vec![
+            make_finding("a.py", 1, Severity::Low, PatternCategory::Stub, "a", "x"),
+            make_finding(
+                "b.py",
+                5,
+                Severity::Critical,
+                PatternCategory::Stub,
+                "b",
+                "x",
+            ),
+            make_finding("c.py", 2, Severity::Medium, PatternCategory::Stub, "c", "x"),
+            make_finding("a.py", 9, Severity::High, PatternCategory::Stub, "d", "x"),
+        ];
+        let (kept, omitted) = select_findings(results, 2);
+        assert_eq!(omitted, 2);
+        // Most severe two, emitted in file order
+        assert_eq!(kept[0].file, "a.py");
+        assert_eq!(kept[0].severity, Severity::High);
+        assert_eq!(kept[1].file, "b.py");
+        assert_eq!(kept[1].severity, Severity::Critical);
+    }
+
+    #[test]
+    fn test_sort_findings_is_total() {
+        let at = |file: &str, line: usize, column: usize, id: &str| {
+            let mut f = make_finding(file, line, Severity::Low, PatternCategory::Stub, "m", "x");
+            f.column = column;
+            f.pattern_id = Some(id.to_string());
+            f
+        };
+        let expected = vec![
+            at("a.go", 3, 1, "todo-marker"),
+            at("a.go", 3, 9, "fixme-marker"),
+            at("a.go", 3, 9, "hack-marker"),
+            at("a.go", 10, 1, "bug-marker"),
+            at("b.go", 1, 1, "bug-marker"),
+        ];
+        let mut findings: Vec<_> = expected.iter().rev().cloned().collect();
+        sort_findings(&mut findings);
+
+        let key = |f: &Finding| (f.file.clone(), f.line, f.column, f.pattern_id.clone());
+        assert_eq!(
+            findings.iter().map(key).collect::<Vec<_>>(),
+            expected.iter().map(key).collect::<Vec<_>>()
+        );
+    }
+
+    #[test]
+    fn test_omitted_notice() {
+        let mut out = Vec::new();
+        print_omitted_notice(&mut out, 0).unwrap();
+        assert!(out.is_empty());
+
+        print_omitted_notice(&mut out, 4213).unwrap();
+        let text = String::from_utf8(out).unwrap();
+        assert!(text.contains("... and 4213 more findings (use --max-findings 0 for all)"));
+    }
+
     #[test]
     fn test_reporter_report_human_empty() {
         let reporter = Reporter::new(Format::Human);
@@ -508,4 +963,158 @@ mod tests {
         // Just check it doesn't error
         let _ = reporter.report(results, summary);
     }
+
+    #[test]
+    fn test_ndjson_one_line_per_finding() {
+        let reporter = Reporter::new(Format::Ndjson);
+        let results = vec![
+            make_finding("b.py", 1, Severity::Medium, PatternCategory::Stub, "b", "x"),
+            make_finding("a.py", 2, Severity::Medium, PatternCategory::Stub, "a", "x"),
+        ];
+        let summary = ScanSummary::summarize(&results, 2);
+        let mut out = Vec::new();
+        reporter.report_to(&mut out, results, summary).unwrap();
+
+        let lines: Vec<serde_json::Value> = String::from_utf8(out)
+            .unwrap()
+            .lines()
+            .map(|l| serde_json::from_str(l).unwrap())
+            .collect();
+        assert_eq!(lines.len(), 3);
+        assert_eq!(lines[0]["file"], "a.py");
+        assert_eq!(lines[1]["file"], "b.py");
+        assert_eq!(lines[2]["summary"]["total_findings"], 2);
+    }
+
+    #[test]
+    fn test_ndjson_sink_cap_keeps_most_severe() {
+        let reporter = Reporter::new(Format::Ndjson).with_max_findings(1);
+        let results = vec![
+            make_finding("a.py", 1, Severity::Low, PatternCategory::Stub, "a", "x"),
+            make_finding("b.py", 1, Severity::High, PatternCategory::Stub, "b", "x"),
+        ];
+        let summary = ScanSummary::summarize(&results, 2);
+        let mut out = Vec::new();
+        {
+            let mut sink = reporter.sink(&mut out);
+            for finding in results {
+                sink.report(finding).unwrap();
+            }
+            sink.finish(&summary).unwrap();
+        }
+
+        let lines: Vec<serde_json::Value> = String::from_utf8(out)
+            .unwrap()
+            .lines()
+            .map(|l| serde_json::from_str(l).unwrap())
+            .collect();
+        assert_eq!(lines.len(), 2);
+        assert_eq!(lines[0]["file"], "b.py");
+        assert_eq!(lines[1]["summary"]["total_findings"], 2);
+    }
+
+    #[test]
+    fn test_sections_leave_out_summary_or_findings() {
+        let results = vec![make_finding(
+            "a.py",
+            1,
+            Severity::Medium,
+            PatternCategory::Stub,
+            "a",
+            "x",
+        )];
+        let render = |format: Format, sections: Sections| {
+            let summary = ScanSummary::summarize(&results, 1);
+            let mut out = Vec::new();
+            Reporter::new(format)
+                .with_sections(sections)
+                .report_to(&mut out, results.clone(), summary)
+                .unwrap();
+            String::from_utf8(out).unwrap()
+        };
+
+        let json: serde_json::Value =
+            serde_json::from_str(&render(Format::Json, Sections::Findings)).unwrap();
+        assert!(json.get("summary").is_none());
+        assert_eq!(json["findings"].as_array().unwrap().len(), 1);
+        let json: serde_json::Value =
+            serde_json::from_str(&render(Format::Json, Sections::Summary)).unwrap();
+        assert!(json.get("findings").is_none());
+        assert_eq!(json["summary"]["total_findings"], 1);
+
+        let ndjson = render(Format::Ndjson, Sections::Findings);
+        assert_eq!(ndjson.lines().count(), 1);
+        assert!(!ndjson.contains("\"summary\""));
+        let ndjson = render(Format::Ndjson, Sections::Summary);
+        assert_eq!(ndjson.lines().count(), 1);
+        assert!(ndjson.starts_with("{\"summary\""));
+
+        assert!(!render(Format::Human, Sections::Findings).contains("total findings"));
+        let human = render(Format::Human, Sections::Summary);
+        assert!(human.contains("total findings") && !human.contains("a.py"));
+    }
+
+    #[test]
+    fn test_custom_sink_receives_sorted_findings() {
+        #[derive(Default)]
+        struct Collect {
+            files: Vec<String>,
+            total: Option<usize>,
+        }
+        impl Sink for Collect {
+            fn report(&mut self, finding: Finding) -> Result<()> {
+                self.files.push(finding.file);
+                Ok(())
+            }
+            fn finish(&mut self, summary: &ScanSummary) -> Result<()> {
+                self.total = Some(summary.total_findings);
+                Ok(())
+            }
+        }
+
+        let results = vec![
+            make_finding("c.py", 1, Severity::Low, PatternCategory::Stub, "c", "x"),
+            make_finding("a.py", 1, Severity::Low, PatternCategory::Stub, "a", "x"),
+        ];
+        let summary = ScanSummary::summarize(&results, 2);
+        let mut sink = Collect::default();
+        emit(&mut sink, results, summary).unwrap();
+        assert_eq!(sink.files, vec!["a.py", "c.py"]);
+        assert_eq!(sink.total, Some(2));
+    }
+
+    #[test]
+    fn test_count_trailer() {
+        let findings = [
+            make_finding(
+                "a.py",
+                1,
+                Severity::High,
+                PatternCategory::Stub,
+                "stub",
+                "pass",
+            ),
+            make_finding(
+                "a.py",
+                2,
+                Severity::Info,
+                PatternCategory::Placeholder,
+                "note",
+                "NOTE",
+            ),
+            make_finding(
+                "b.py",
+                3,
+                Severity::Info,
+                PatternCategory::Placeholder,
+                "note",
+                "NOTE",
+            ),
+        ];
+        let summary = ScanSummary::summarize(&findings, 4);
+        assert_eq!(
+            count_trailer(&summary),
+            "antislop: 3 findings (0 critical, 1 high, 0 medium, 0 low, 2 info) in 4 files"
+        );
+    }
 }
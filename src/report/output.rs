@@ -0,0 +1,84 @@
+//! Atomic report files.
+
+use crate::Result;
+use std::fs::{self, File};
+use std::io::BufWriter;
+use std::path::{Path, PathBuf};
+
+/// Write a file through `write`, replacing `path` only once it succeeds.
+///
+/// Output goes to a hidden sibling file, which is synced and then renamed
+/// over `path`. If `write` or any I/O step fails, the sibling file is
+/// removed and the previous contents of `path` remain.
+pub(crate) fn write_atomic<F>(path: &Path, write: F) -> Result<()>
+where
+    F: FnOnce(&mut BufWriter<File>) -> Result<()>,
+{
+    let tmp = temp_path(path);
+    let result = write_and_rename(&tmp, path, write);
+    if result.is_err() {
+        let _ = fs::remove_file(&tmp);
+    }
+    result
+}
+
+fn write_and_rename<F>(tmp: &Path, path: &Path, write: F) -> Result<()>
+where
+    F: FnOnce(&mut BufWriter<File>) -> Result<()>,
+{
+    let mut handle = BufWriter::new(File::create(tmp)?);
+    write(&mut handle)?;
+    let file = handle.into_inner().map_err(|e| e.into_error())?;
+    file.sync_all()?;
+    fs::rename(tmp, path)?;
+    Ok(())
+}
+
+/// Sibling of `path` so the final rename stays on one filesystem.
+fn temp_path(path: &Path) -> PathBuf {
+    let name = path
+        .file_name()
+        .map(|n| n.to_string_lossy().into_owned())
+        .unwrap_or_default();
+    path.with_file_name(format!(".{}.{}.tmp", name, std::process::id()))
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use crate::Error;
+    use std::io::Write;
+    use tempfile::TempDir;
+
+    #[test]
+    fn test_write_atomic_replaces_file() {
+        let dir = TempDir::new().unwrap();
+        let path = dir.path().join("report.json");
+        fs::write(&path, "old").unwrap();
+
+        write_atomic(&path, |w| {
+            w.write_all(b"new")?;
+            Ok(())
+        })
+        .unwrap();
+
+        assert_eq!(fs::read_to_string(&path).unwrap(), "new");
+        assert_eq!(fs::read_dir(dir.path()).unwrap().count(), 1);
+    }
+
+    #[test]
+    fn test_write_atomic_keeps_old_file_on_error() {
+        let dir = TempDir::new().unwrap();
+        let path = dir.path().join("report.json");
+        fs::write(&path, "old").unwrap();
+
+        let result = write_atomic(&path, |w| {
+            w.write_all(b"{\"partial\":")?;
+            Err(Error::ConfigInvalid("scan failed".to_string()))
+        });
+
+        assert!(result.is_err());
+        assert_eq!(fs::read_to_string(&path).unwrap(), "old");
+        assert_eq!(fs::read_dir(dir.path()).unwrap().count(), 1);
+    }
+}
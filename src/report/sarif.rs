@@ -1,11 +1,16 @@
-use crate::detector::{Finding, ScanSummary};
+use crate::detector::{Finding, ScanSummary, SuggestedFix};
 use crate::Result;
 use serde_sarif::sarif::{
-    ArtifactLocation, Location, Message, PhysicalLocation, Region, Result as SarifResult,
-    ResultLevel, Run, Sarif, Tool, ToolComponent,
+    ArtifactChange, ArtifactContent, ArtifactLocation, Fix, Location, Message, PhysicalLocation,
+    Region, Replacement, Result as SarifResult, ResultLevel, Run, Sarif, Tool, ToolComponent,
 };
+use std::io::Write;
 
-pub fn report_sarif(results: &[Finding], _summary: &ScanSummary) -> Result<()> {
+pub fn report_sarif(
+    handle: &mut impl Write,
+    results: &[Finding],
+    _summary: &ScanSummary,
+) -> Result<()> {
     let mut sarif_results = Vec::new();
 
     for finding in results {
@@ -34,12 +39,22 @@ pub fn report_sarif(results: &[Finding], _summary: &ScanSummary) -> Result<()> {
             _ => ResultLevel::Note,
         };
 
-        let result = SarifResult::builder()
+        let message = match finding.suggestion {
+            Some(ref suggestion) => Message::builder()
+                .text(finding.message.clone())
+                .markdown(format!("{}\n\n{}", finding.message, suggestion))
+                .build(),
+            None => Message::builder().text(finding.message.clone()).build(),
+        };
+        let mut result = SarifResult::builder()
             .rule_id(rule_id)
-            .message(Message::builder().text(finding.message.clone()).build())
+            .message(message)
             .level(level)
             .locations(vec![location])
             .build();
+        if let Some(ref fix) = finding.fix {
+            result.fixes = Some(vec![sarif_fix(&finding.file, fix)]);
+        }
 
         sarif_results.push(result);
     }
@@ -60,10 +75,40 @@ pub fn report_sarif(results: &[Finding], _summary: &ScanSummary) -> Result<()> {
     let json = serde_json::to_string_pretty(&sarif)
         .map_err(|e| crate::Error::ConfigInvalid(e.to_string()))?;
 
-    println!("{}", json);
+    writeln!(handle, "{}", json)?;
     Ok(())
 }
 
+/// Translate a suggested fix into a SARIF fix replacing one region.
+fn sarif_fix(file: &str, fix: &SuggestedFix) -> Fix {
+    let deleted_region = Region::builder()
+        .start_line(fix.start_line as i64)
+        .start_column(fix.start_column as i64)
+        .end_line(fix.end_line as i64)
+        .end_column(fix.end_column as i64)
+        .build();
+    let replacement = Replacement::builder()
+        .deleted_region(deleted_region)
+        .inserted_content(
+            ArtifactContent::builder()
+                .text(fix.replacement.clone())
+                .build(),
+        )
+        .build();
+    let change = ArtifactChange::builder()
+        .artifact_location(ArtifactLocation::builder().uri(file.to_string()).build())
+        .replacements(vec![replacement])
+        .build();
+    Fix::builder()
+        .description(
+            Message::builder()
+                .text(format!("Replace with `{}`", fix.replacement))
+                .build(),
+        )
+        .artifact_changes(vec![change])
+        .build()
+}
+
 #[cfg(test)]
 mod tests {
     use super::*;
@@ -87,9 +132,14 @@ mod tests {
             message: message.to_string(),
             match_text: match_text.to_string(),
             pattern_regex: "test".to_string(),
+            pattern_id: None,
+            tags: vec![],
+            confidence: Default::default(),
             source_line: None,
             context_before: None,
             context_after: None,
+            fix: None,
+            suggestion: None,
         }
     }
 
@@ -103,10 +153,12 @@ mod tests {
             total_score: 0,
             by_severity: Default::default(),
             by_category: Default::default(),
+            by_pattern: Default::default(),
+            roots: vec![],
         };
 
         // Just check it doesn't error
-        let _ = report_sarif(&results, &summary);
+        let _ = report_sarif(&mut Vec::new(), &results, &summary);
     }
 
     #[test]
@@ -160,10 +212,12 @@ mod tests {
             total_score: 71,
             by_severity: Default::default(),
             by_category: Default::default(),
+            by_pattern: Default::default(),
+            roots: vec![],
         };
 
         // Should not panic
-        let _ = report_sarif(&results, &summary);
+        let _ = report_sarif(&mut Vec::new(), &results, &summary);
     }
 
     #[test]
@@ -186,4 +240,37 @@ mod tests {
         assert_eq!(finding.message, "Test message");
         assert_eq!(finding.match_text, "TODO");
     }
+
+    #[test]
+    fn test_sarif_includes_suggested_fix() {
+        let mut finding = make_finding(
+            "main.go",
+            7,
+            9,
+            Severity::Info,
+            PatternCategory::Modernize,
+            "Modernize",
+            "interface{}",
+        );
+        finding.fix = Some(SuggestedFix {
+            start_line: 7,
+            start_column: 9,
+            end_line: 7,
+            end_column: 20,
+            replacement: "any".to_string(),
+        });
+        let summary = ScanSummary::summarize(&[], 1);
+
+        let mut out = Vec::new();
+        report_sarif(&mut out, &[finding], &summary).unwrap();
+        let sarif: serde_json::Value = serde_json::from_slice(&out).unwrap();
+
+        let fix = &sarif["runs"][0]["results"][0]["fixes"][0];
+        let change = &fix["artifactChanges"][0];
+        assert_eq!(change["artifactLocation"]["uri"], "main.go");
+        let replacement = &change["replacements"][0];
+        assert_eq!(replacement["deletedRegion"]["startColumn"], 9);
+        assert_eq!(replacement["deletedRegion"]["endColumn"], 20);
+        assert_eq!(replacement["insertedContent"]["text"], "any");
+    }
 }
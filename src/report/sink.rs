@@ -0,0 +1,163 @@
+//! Report sinks: destinations fed one finding at a time.
+
+use super::{json_summary, print_omitted_notice, select_findings, sort_findings, JsonFinding};
+use super::{Reporter, Sections};
+use crate::detector::{Finding, ScanSummary};
+use crate::{Error, Result};
+use std::io::{self, Write};
+
+/// Destination for a report, such as a file format, a webhook or a queue.
+///
+/// A report calls [`Sink::report`] once per finding, in report order, and
+/// then [`Sink::finish`] exactly once. Both take `&mut self`, so calls never
+/// overlap and a sink needs no locking of its own.
+///
+/// ```
+/// use antislop::{report, Finding, Result, ScanSummary, Sink};
+///
+/// #[derive(Default)]
+/// struct Count(usize);
+///
+/// impl Sink for Count {
+///     fn report(&mut self, _finding: Finding) -> Result<()> {
+///         self.0 += 1;
+///         Ok(())
+///     }
+///
+///     fn finish(&mut self, _summary: &ScanSummary) -> Result<()> {
+///         Ok(())
+///     }
+/// }
+///
+/// let mut count = Count::default();
+/// report::emit(&mut count, Vec::new(), ScanSummary::summarize(&[], 0)).unwrap();
+/// assert_eq!(count.0, 0);
+/// ```
+pub trait Sink {
+    /// Receive one finding.
+    fn report(&mut self, finding: Finding) -> Result<()>;
+
+    /// Receive the summary of every finding, after the last one.
+    fn finish(&mut self, summary: &ScanSummary) -> Result<()>;
+}
+
+/// Feed `findings`, in [`sort_findings`] order, and then `summary` to
+/// `sink`.
+pub fn emit(sink: &mut dyn Sink, mut findings: Vec<Finding>, summary: ScanSummary) -> Result<()> {
+    sort_findings(&mut findings);
+    for finding in findings {
+        sink.report(finding)?;
+    }
+    sink.finish(&summary)
+}
+
+/// The sink for a document format (human, JSON, SARIF or template).
+///
+/// These formats need every finding before they can write anything, so
+/// findings are held until [`Sink::finish`].
+pub(super) struct FormatSink<W> {
+    reporter: Reporter,
+    handle: W,
+    findings: Vec<Finding>,
+}
+
+impl<W: Write> FormatSink<W> {
+    pub(super) fn new(reporter: Reporter, handle: W) -> Self {
+        Self {
+            reporter,
+            handle,
+            findings: Vec::new(),
+        }
+    }
+}
+
+impl<W: Write> Sink for FormatSink<W> {
+    fn report(&mut self, finding: Finding) -> Result<()> {
+        self.findings.push(finding);
+        Ok(())
+    }
+
+    fn finish(&mut self, summary: &ScanSummary) -> Result<()> {
+        let mut findings = std::mem::take(&mut self.findings);
+        sort_findings(&mut findings);
+        let (findings, omitted) = select_findings(findings, self.reporter.max_findings);
+        self.reporter
+            .write(&mut self.handle, &findings, summary, omitted)?;
+        self.handle.flush()?;
+        Ok(())
+    }
+}
+
+/// Newline-delimited JSON: each finding is written as one JSON object
+/// line as soon as it is reported, and the summary follows as a final
+/// `{"summary": ...}` line.
+///
+/// Lines are flushed one at a time, so a reader on a pipe sees findings as
+/// they arrive. With a cap, findings are held until [`Sink::finish`] and
+/// the most severe are kept, as in the document formats.
+pub struct NdjsonSink<W> {
+    handle: W,
+    max_findings: usize,
+    sections: Sections,
+    /// Findings waiting for [`Sink::finish`] under a cap.
+    held: Vec<Finding>,
+}
+
+impl<W: Write> NdjsonSink<W> {
+    /// Write to `handle`.
+    pub fn new(handle: W) -> Self {
+        Self {
+            handle,
+            max_findings: 0,
+            sections: Sections::All,
+            held: Vec::new(),
+        }
+    }
+
+    /// Write at most `max_findings` findings, most severe first (0 =
+    /// unlimited). A cap stops findings streaming until the summary.
+    pub fn with_max_findings(mut self, max_findings: usize) -> Self {
+        self.max_findings = max_findings;
+        self
+    }
+
+    /// Write only the finding lines or only the summary line.
+    pub fn with_sections(mut self, sections: Sections) -> Self {
+        self.sections = sections;
+        self
+    }
+
+    fn write_line(&mut self, value: &impl serde::Serialize) -> Result<()> {
+        serde_json::to_writer(&mut self.handle, value)
+            .map_err(|e| Error::ConfigInvalid(e.to_string()))?;
+        writeln!(self.handle)?;
+        self.handle.flush()?;
+        Ok(())
+    }
+}
+
+impl<W: Write> Sink for NdjsonSink<W> {
+    fn report(&mut self, finding: Finding) -> Result<()> {
+        if !self.sections.findings() {
+            return Ok(());
+        }
+        if self.max_findings > 0 {
+            self.held.push(finding);
+            return Ok(());
+        }
+        self.write_line(&JsonFinding::from(&finding))
+    }
+
+    fn finish(&mut self, summary: &ScanSummary) -> Result<()> {
+        let mut held = std::mem::take(&mut self.held);
+        sort_findings(&mut held);
+        let (held, omitted) = select_findings(held, self.max_findings);
+        for finding in &held {
+            self.write_line(&JsonFinding::from(finding))?;
+        }
+        if self.sections.summary() {
+            self.write_line(&serde_json::json!({ "summary": json_summary(summary) }))?;
+        }
+        print_omitted_notice(&mut io::stderr(), omitted)
+    }
+}
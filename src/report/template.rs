@@ -0,0 +1,370 @@
+//! `--format template`: user templates in a subset of Go's text/template.
+//!
+//! Templates render the document `--json` writes, so field names match the
+//! JSON output (`.summary.total_findings`, `.file`, ...). Supported actions:
+//!
+//! - `{{.a.b}}` and `{{$.a.b}}`: a field of the current value (`.`) or of
+//!   the whole document (`$`)
+//! - `{{range PIPE}} ... {{else}} ... {{end}}`: repeat for each element of
+//!   an array, with `.` set to the element; `else` runs when it is empty
+//! - `{{if PIPE}} ... {{else}} ... {{end}}`: false, 0, "", null and empty
+//!   arrays or objects are false
+//! - `{{FUNC ARG}}` and `{{ARG | FUNC | FUNC}}` with the functions in
+//!   [`FUNCTIONS`]
+//! - `{{-` and `-}}` trim the whitespace before or after the action
+//!
+//! A missing field renders as nothing.
+
+use crate::{Error, Result};
+use serde_json::Value;
+use std::io::Write;
+use std::path::Path;
+
+/// Functions a template can call, each taking one value.
+pub const FUNCTIONS: &[&str] = &["upper", "lower", "relpath", "len", "json"];
+
+/// A parsed template, checked for syntax and unknown functions.
+#[derive(Debug, Clone)]
+pub struct Template {
+    nodes: Vec<Node>,
+}
+
+#[derive(Debug, Clone)]
+enum Node {
+    Text(String),
+    Output(Pipe),
+    If(Pipe, Vec<Node>, Vec<Node>),
+    Range(Pipe, Vec<Node>, Vec<Node>),
+}
+
+/// An operand followed by the functions its value is piped through.
+#[derive(Debug, Clone)]
+struct Pipe {
+    operand: Operand,
+    functions: Vec<String>,
+}
+
+#[derive(Debug, Clone)]
+enum Operand {
+    /// `.a.b` (`root` false) or `$.a.b`.
+    Field {
+        root: bool,
+        path: Vec<String>,
+    },
+    Literal(Value),
+}
+
+/// Template text split into text and `{{ }}` actions.
+enum Item<'a> {
+    Text(String),
+    Action { body: &'a str, line: usize },
+}
+
+impl Template {
+    /// Parse `text`, rejecting unbalanced blocks and unknown functions.
+    pub fn parse(text: &str) -> Result<Self> {
+        let items = split(text)?;
+        let mut at = 0;
+        let (nodes, end) = parse_list(&items, &mut at)?;
+        if let Some((keyword, line)) = end {
+            return Err(invalid(line, &format!("unexpected {{{{{}}}}}", keyword)));
+        }
+        Ok(Self { nodes })
+    }
+
+    /// Render the template with `data` as both `.` and `$`.
+    pub fn render(&self, handle: &mut impl Write, data: &Value) -> Result<()> {
+        render_list(handle, &self.nodes, data, data)
+    }
+}
+
+fn invalid(line: usize, message: &str) -> Error {
+    Error::ConfigInvalid(format!("template line {}: {}", line, message))
+}
+
+fn split(text: &str) -> Result<Vec<Item<'_>>> {
+    let mut items = Vec::new();
+    let mut rest = text;
+    let mut trim_next = false;
+    while let Some(open) = rest.find("{{") {
+        let line = text[..text.len() - rest.len() + open].matches('\n').count() + 1;
+        let mut before = &rest[..open];
+        if trim_next {
+            before = before.trim_start();
+        }
+        let after_open = &rest[open + 2..];
+        let close = after_open
+            .find("}}")
+            .ok_or_else(|| invalid(line, "unclosed action"))?;
+        let mut body = &after_open[..close];
+        if let Some(trimmed) = body.strip_prefix('-') {
+            before = before.trim_end();
+            body = trimmed;
+        }
+        trim_next = false;
+        if let Some(trimmed) = body.strip_suffix('-') {
+            trim_next = true;
+            body = trimmed;
+        }
+        items.push(Item::Text(before.to_string()));
+        items.push(Item::Action {
+            body: body.trim(),
+            line,
+        });
+        rest = &after_open[close + 2..];
+    }
+    items.push(Item::Text(if trim_next {
+        rest.trim_start().to_string()
+    } else {
+        rest.to_string()
+    }));
+    Ok(items)
+}
+
+/// Parse nodes up to an `else`, `end` or the end of input, returning the
+/// keyword and line it stopped at.
+#[allow(clippy::type_complexity)]
+fn parse_list(
+    items: &[Item],
+    at: &mut usize,
+) -> Result<(Vec<Node>, Option<(&'static str, usize)>)> {
+    let mut nodes = Vec::new();
+    while let Some(item) = items.get(*at) {
+        *at += 1;
+        let (body, line) = match item {
+            Item::Text(text) => {
+                if !text.is_empty() {
+                    nodes.push(Node::Text(text.clone()));
+                }
+                continue;
+            }
+            Item::Action { body, line } => (*body, *line),
+        };
+        let (keyword, rest) = body.split_once(' ').unwrap_or((body, ""));
+        match keyword {
+            "end" => return Ok((nodes, Some(("end", line)))),
+            "else" => return Ok((nodes, Some(("else", line)))),
+            "if" | "range" => {
+                let pipe = parse_pipe(rest.trim(), line)?;
+                let (then, end) = parse_list(items, at)?;
+                let otherwise = match end {
+                    Some(("else", _)) => match parse_list(items, at)? {
+                        (otherwise, Some(("end", _))) => otherwise,
+                        _ => {
+                            return Err(invalid(line, &format!("{{{{{}}}}} without end", keyword)))
+                        }
+                    },
+                    Some(("end", _)) => Vec::new(),
+                    _ => return Err(invalid(line, &format!("{{{{{}}}}} without end", keyword))),
+                };
+                nodes.push(if keyword == "if" {
+                    Node::If(pipe, then, otherwise)
+                } else {
+                    Node::Range(pipe, then, otherwise)
+                });
+            }
+            _ => nodes.push(Node::Output(parse_pipe(body, line)?)),
+        }
+    }
+    Ok((nodes, None))
+}
+
+fn parse_pipe(text: &str, line: usize) -> Result<Pipe> {
+    let mut stages = text.split('|').map(str::trim);
+    let first: Vec<&str> = stages.next().unwrap_or("").split_whitespace().collect();
+    let mut functions = Vec::new();
+    let operand = match first.as_slice() {
+        [operand] => parse_operand(operand, line)?,
+        // `FUNC ARG` is `ARG | FUNC`
+        [function, operand] => {
+            functions.push(function.to_string());
+            parse_operand(operand, line)?
+        }
+        _ => return Err(invalid(line, &format!("cannot parse `{}`", text))),
+    };
+    functions.extend(stages.map(str::to_string));
+    for function in &functions {
+        if !FUNCTIONS.contains(&function.as_str()) {
+            return Err(invalid(
+                line,
+                &format!(
+                    "unknown function `{}` (known: {})",
+                    function,
+                    FUNCTIONS.join(", ")
+                ),
+            ));
+        }
+    }
+    Ok(Pipe { operand, functions })
+}
+
+fn parse_operand(text: &str, line: usize) -> Result<Operand> {
+    let field = |root: bool, rest: &str| Operand::Field {
+        root,
+        path: rest
+            .split('.')
+            .filter(|s| !s.is_empty())
+            .map(str::to_string)
+            .collect(),
+    };
+    if let Some(rest) = text.strip_prefix('$') {
+        return Ok(field(true, rest));
+    }
+    if text.starts_with('.') {
+        return Ok(field(false, text));
+    }
+    if let Some(literal) = text.strip_prefix('"').and_then(|t| t.strip_suffix('"')) {
+        return Ok(Operand::Literal(Value::from(literal)));
+    }
+    serde_json::from_str::<Value>(text)
+        .ok()
+        .filter(|v| v.is_number() || v.is_boolean())
+        .map(Operand::Literal)
+        .ok_or_else(|| invalid(line, &format!("unknown operand `{}`", text)))
+}
+
+fn render_list(handle: &mut impl Write, nodes: &[Node], dot: &Value, root: &Value) -> Result<()> {
+    for node in nodes {
+        match node {
+            Node::Text(text) => handle.write_all(text.as_bytes())?,
+            Node::Output(pipe) => match eval(pipe, dot, root) {
+                Value::String(s) => handle.write_all(s.as_bytes())?,
+                Value::Null => {}
+                value => write!(handle, "{}", value)?,
+            },
+            Node::If(pipe, then, otherwise) => {
+                let branch = if truthy(&eval(pipe, dot, root)) {
+                    then
+                } else {
+                    otherwise
+                };
+                render_list(handle, branch, dot, root)?;
+            }
+            Node::Range(pipe, body, otherwise) => match eval(pipe, dot, root) {
+                Value::Array(items) if !items.is_empty() => {
+                    for item in &items {
+                        render_list(handle, body, item, root)?;
+                    }
+                }
+                _ => render_list(handle, otherwise, dot, root)?,
+            },
+        }
+    }
+    Ok(())
+}
+
+fn eval(pipe: &Pipe, dot: &Value, root: &Value) -> Value {
+    let mut value = match &pipe.operand {
+        Operand::Field {
+            root: from_root,
+            path,
+        } => {
+            let start = if *from_root { root } else { dot };
+            path.iter()
+                .try_fold(start, |v, key| v.get(key))
+                .cloned()
+                .unwrap_or(Value::Null)
+        }
+        Operand::Literal(value) => value.clone(),
+    };
+    for function in &pipe.functions {
+        value = apply(function, value);
+    }
+    value
+}
+
+fn apply(function: &str, value: Value) -> Value {
+    let text = || match &value {
+        Value::String(s) => s.clone(),
+        Value::Null => String::new(),
+        other => other.to_string(),
+    };
+    match function {
+        "upper" => Value::from(text().to_uppercase()),
+        "lower" => Value::from(text().to_lowercase()),
+        "relpath" => {
+            let path = text();
+            let relative = std::env::current_dir()
+                .ok()
+                .and_then(|cwd| {
+                    Path::new(&path)
+                        .strip_prefix(&cwd)
+                        .ok()
+                        .map(|p| p.to_string_lossy().into_owned())
+                })
+                .unwrap_or_else(|| path.trim_start_matches("./").to_string());
+            Value::from(relative)
+        }
+        "len" => Value::from(match &value {
+            Value::Array(items) => items.len(),
+            Value::Object(map) => map.len(),
+            Value::String(s) => s.chars().count(),
+            _ => 0,
+        }),
+        "json" => Value::from(value.to_string()),
+        _ => value,
+    }
+}
+
+fn truthy(value: &Value) -> bool {
+    match value {
+        Value::Null => false,
+        Value::Bool(b) => *b,
+        Value::Number(n) => n.as_f64() != Some(0.0),
+        Value::String(s) => !s.is_empty(),
+        Value::Array(items) => !items.is_empty(),
+        Value::Object(map) => !map.is_empty(),
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+    use serde_json::json;
+
+    fn render(template: &str, data: &Value) -> String {
+        let mut out = Vec::new();
+        Template::parse(template)
+            .unwrap()
+            .render(&mut out, data)
+            .unwrap();
+        String::from_utf8(out).unwrap()
+    }
+
+    #[test]
+    fn test_range_if_and_functions() {
+        let data = json!({
+            "summary": {"total_findings": 2},
+            "findings": [
+                {"file": "./a.go", "line": 3, "severity": "high"},
+                {"file": "./b.go", "line": 9, "severity": "low"}
+            ]
+        });
+        let template = "{{.summary.total_findings}} findings\n\
+                        {{- range .findings}}\n- {{.file | relpath}}:{{.line}} {{upper .severity}}\
+                        {{- if $.summary.total_findings}} of {{len $.findings}}{{end}}{{end}}\n";
+        assert_eq!(
+            render(template, &data),
+            "2 findings\n- a.go:3 HIGH of 2\n- b.go:9 LOW of 2\n"
+        );
+    }
+
+    #[test]
+    fn test_else_branches() {
+        let data = json!({"findings": []});
+        assert_eq!(
+            render("{{range .findings}}x{{else}}none{{end}}", &data),
+            "none"
+        );
+        assert_eq!(render("{{if .missing}}yes{{else}}no{{end}}", &data), "no");
+    }
+
+    #[test]
+    fn test_parse_errors() {
+        let error = |t: &str| Template::parse(t).unwrap_err().to_string();
+        assert!(error("{{range .findings}}").contains("without end"));
+        assert!(error("a\n{{end}}").contains("line 2: unexpected {{end}}"));
+        assert!(error("{{.file | shout}}").contains("unknown function `shout`"));
+        assert!(error("{{.file").contains("unclosed action"));
+    }
+}
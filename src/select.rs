@@ -0,0 +1,254 @@
+//! `--select` expressions: which patterns run.
+//!
+//! An expression combines terms with `and`, `or`, `not` and parentheses;
+//! `not` binds tightest, then `and`, then `or`. A term is true for a
+//! pattern carrying it as a tag or as its category, and `low`, `medium`
+//! and `high` (with `heuristic` for `low`) test its confidence:
+//!
+//! ```text
+//! expr   = and ("or" and)*
+//! and    = unary ("and" unary)*
+//! unary  = "not" unary | "(" expr ")" | term
+//! term   = [A-Za-z0-9_-]+
+//! ```
+//!
+//! Operators and terms are case-insensitive.
+
+use crate::config::{Confidence, Pattern};
+use crate::{Error, Result};
+use std::str::FromStr;
+
+/// A parsed `--select` expression.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Selector {
+    expr: Expr,
+}
+
+#[derive(Debug, Clone, PartialEq, Eq)]
+enum Expr {
+    Term(String),
+    Not(Box<Expr>),
+    And(Box<Expr>, Box<Expr>),
+    Or(Box<Expr>, Box<Expr>),
+}
+
+impl Selector {
+    /// Whether `pattern` satisfies the expression.
+    pub fn matches(&self, pattern: &Pattern) -> bool {
+        self.expr.eval(pattern)
+    }
+
+    /// Terms no pattern in `patterns` carries, in the order written. Such a
+    /// term is always false, which usually means a typo.
+    pub fn unknown_terms(&self, patterns: &[Pattern]) -> Vec<String> {
+        let mut terms = Vec::new();
+        self.expr.terms(&mut terms);
+        let mut unknown: Vec<String> = Vec::new();
+        for term in terms {
+            let known =
+                confidence(term).is_some() || patterns.iter().any(|p| Expr::has_term(p, term));
+            if !known && !unknown.iter().any(|u| u == term) {
+                unknown.push(term.to_string());
+            }
+        }
+        unknown
+    }
+}
+
+impl FromStr for Selector {
+    type Err = Error;
+
+    fn from_str(s: &str) -> Result<Self> {
+        let tokens = tokenize(s)?;
+        let mut parser = Parser {
+            input: s,
+            tokens,
+            pos: 0,
+        };
+        let expr = parser.or()?;
+        if let Some(token) = parser.tokens.get(parser.pos) {
+            return Err(invalid(s, &format!("unexpected `{}`", token)));
+        }
+        Ok(Selector { expr })
+    }
+}
+
+/// Confidence level a term names, if it names one.
+fn confidence(term: &str) -> Option<Confidence> {
+    match term {
+        "low" | "heuristic" => Some(Confidence::Low),
+        "medium" => Some(Confidence::Medium),
+        "high" => Some(Confidence::High),
+        _ => None,
+    }
+}
+
+impl Expr {
+    fn eval(&self, pattern: &Pattern) -> bool {
+        match self {
+            Expr::Term(term) => match confidence(term) {
+                Some(level) => pattern.confidence == level,
+                None => Expr::has_term(pattern, term),
+            },
+            Expr::Not(inner) => !inner.eval(pattern),
+            Expr::And(a, b) => a.eval(pattern) && b.eval(pattern),
+            Expr::Or(a, b) => a.eval(pattern) || b.eval(pattern),
+        }
+    }
+
+    /// Whether `pattern` has `term` as a tag or as its category.
+    fn has_term(pattern: &Pattern, term: &str) -> bool {
+        pattern.tags.iter().any(|t| t.eq_ignore_ascii_case(term))
+            || format!("{:?}", pattern.category).eq_ignore_ascii_case(term)
+    }
+
+    fn terms<'a>(&'a self, out: &mut Vec<&'a str>) {
+        match self {
+            Expr::Term(term) => out.push(term),
+            Expr::Not(inner) => inner.terms(out),
+            Expr::And(a, b) | Expr::Or(a, b) => {
+                a.terms(out);
+                b.terms(out);
+            }
+        }
+    }
+}
+
+fn invalid(expr: &str, reason: &str) -> Error {
+    Error::ConfigInvalid(format!("--select '{}': {}", expr, reason))
+}
+
+/// Split `s` into parentheses and lowercased words.
+fn tokenize(s: &str) -> Result<Vec<String>> {
+    let mut tokens = Vec::new();
+    let mut chars = s.chars().peekable();
+    while let Some(&c) = chars.peek() {
+        if c.is_whitespace() {
+            chars.next();
+        } else if c == '(' || c == ')' {
+            tokens.push(c.to_string());
+            chars.next();
+        } else if c.is_ascii_alphanumeric() || c == '_' || c == '-' {
+            let mut word = String::new();
+            while let Some(&c) = chars.peek() {
+                if !(c.is_ascii_alphanumeric() || c == '_' || c == '-') {
+                    break;
+                }
+                word.push(c.to_ascii_lowercase());
+                chars.next();
+            }
+            tokens.push(word);
+        } else {
+            return Err(invalid(s, &format!("unexpected character `{}`", c)));
+        }
+    }
+    if tokens.is_empty() {
+        return Err(invalid(s, "empty expression"));
+    }
+    Ok(tokens)
+}
+
+struct Parser<'a> {
+    input: &'a str,
+    tokens: Vec<String>,
+    pos: usize,
+}
+
+impl Parser<'_> {
+    fn peek(&self) -> Option<&str> {
+        self.tokens.get(self.pos).map(String::as_str)
+    }
+
+    fn or(&mut self) -> Result<Expr> {
+        let mut expr = self.and()?;
+        while self.peek() == Some("or") {
+            self.pos += 1;
+            expr = Expr::Or(Box::new(expr), Box::new(self.and()?));
+        }
+        Ok(expr)
+    }
+
+    fn and(&mut self) -> Result<Expr> {
+        let mut expr = self.unary()?;
+        while self.peek() == Some("and") {
+            self.pos += 1;
+            expr = Expr::And(Box::new(expr), Box::new(self.unary()?));
+        }
+        Ok(expr)
+    }
+
+    fn unary(&mut self) -> Result<Expr> {
+        let Some(token) = self.peek().map(str::to_string) else {
+            return Err(invalid(self.input, "expression ends early"));
+        };
+        self.pos += 1;
+        match token.as_str() {
+            "not" => Ok(Expr::Not(Box::new(self.unary()?))),
+            "(" => {
+                let expr = self.or()?;
+                if self.peek() != Some(")") {
+                    return Err(invalid(self.input, "missing `)`"));
+                }
+                self.pos += 1;
+                Ok(expr)
+            }
+            ")" | "and" | "or" => Err(invalid(
+                self.input,
+                &format!("expected a term, found `{}`", token),
+            )),
+            _ => Ok(Expr::Term(token)),
+        }
+    }
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn pattern(category: &str, tag: &str, confidence: &str) -> Pattern {
+        toml::from_str(&format!(
+            "regex = 'x'\nseverity = 'low'\nmessage = 'm'\ncategory = '{}'\n\
+             tags = ['{}']\nconfidence = '{}'",
+            category, tag, confidence
+        ))
+        .unwrap()
+    }
+
+    #[test]
+    fn test_select_expression() {
+        let selector: Selector = "security or (performance and not heuristic)"
+            .parse()
+            .unwrap();
+        let sql = pattern("stub", "security", "medium");
+        let regexp = pattern("stub", "performance", "high");
+        let guess = pattern("stub", "performance", "low");
+        let todo = pattern("placeholder", "maintainability", "high");
+        assert!(selector.matches(&sql));
+        assert!(selector.matches(&regexp));
+        assert!(!selector.matches(&guess));
+        assert!(!selector.matches(&todo));
+
+        let selector: Selector = "Placeholder AND NOT low".parse().unwrap();
+        assert!(selector.matches(&todo));
+        assert!(!selector.matches(&sql));
+        assert_eq!(selector.unknown_terms(&[sql, todo]), Vec::<String>::new());
+
+        let selector: Selector = "securty or style".parse().unwrap();
+        assert_eq!(selector.unknown_terms(&[regexp]), vec!["securty", "style"]);
+    }
+
+    #[test]
+    fn test_select_rejects_bad_syntax() {
+        for expr in [
+            "",
+            "security and",
+            "(security",
+            "security)",
+            "or style",
+            "a && b",
+        ] {
+            let err = expr.parse::<Selector>().unwrap_err();
+            assert!(err.to_string().contains("--select"), "{}: {}", expr, err);
+        }
+    }
+}
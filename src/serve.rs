@@ -0,0 +1,434 @@
+//! `antislop serve`: findings over HTTP for long-running callers such as
+//! review bots, without a process spawn per file.
+//!
+//! Endpoints, all answering JSON:
+//!
+//! - `POST /analyze` takes `{"filename", "source", "config"?}` and returns
+//!   the document `--json` writes for that one file. `config` is TOML in
+//!   the format of `antislop.toml`, used instead of the server's config for
+//!   this request (without `extends`, which would read server files).
+//! - `GET /detectors` lists the server's patterns.
+//! - `GET /health` answers `{"status": "ok"}`.
+//! - `POST /shutdown` stops accepting connections and returns once the
+//!   requests already accepted are answered. Only loopback peers may call it.
+//!
+//! Each connection carries one request and is closed after the response.
+//! Package-wide checks need the rest of the package, so they do not run.
+
+use crate::detector::Overrides;
+use crate::report::{Format, Reporter};
+use crate::{Config, Error, Finding, Result, ScanSummary, Scanner, VERSION};
+use serde::Deserialize;
+use serde_json::json;
+use std::io::{BufRead, BufReader, Read, Write};
+use std::net::{Ipv4Addr, Ipv6Addr, SocketAddr, TcpListener, TcpStream};
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::sync::{mpsc, Mutex};
+use std::thread;
+use std::time::Duration;
+
+/// Default cap on a request body, in bytes.
+pub const DEFAULT_MAX_BODY: usize = 1024 * 1024;
+
+/// Largest request line plus headers accepted, in bytes.
+const MAX_HEAD: usize = 16 * 1024;
+
+/// How long a connection may stay silent before it is dropped.
+const READ_TIMEOUT: Duration = Duration::from_secs(30);
+
+/// An HTTP request, as far as the server reads it.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Request {
+    /// Method, e.g. `POST`.
+    pub method: String,
+    /// Path without the query string.
+    pub path: String,
+    /// Body bytes.
+    pub body: Vec<u8>,
+}
+
+/// A JSON response.
+#[derive(Debug, Clone, PartialEq, Eq)]
+pub struct Response {
+    /// HTTP status code.
+    pub status: u16,
+    /// JSON body.
+    pub body: String,
+}
+
+impl Response {
+    fn json(status: u16, value: serde_json::Value) -> Self {
+        Self {
+            status,
+            body: value.to_string(),
+        }
+    }
+
+    fn error(status: u16, message: impl Into<String>) -> Self {
+        Self::json(status, json!({ "error": message.into() }))
+    }
+}
+
+/// Body of `POST /analyze`.
+#[derive(Debug, Deserialize)]
+struct AnalyzeRequest {
+    filename: String,
+    source: String,
+    #[serde(default)]
+    config: Option<String>,
+}
+
+/// The analysis server. Requests share one [`Scanner`], which is safe to
+/// use from several threads at once.
+pub struct Server {
+    config: Config,
+    scanner: Scanner,
+    overrides: Overrides,
+    max_body: usize,
+}
+
+impl Server {
+    /// A server scanning with `config`'s patterns.
+    pub fn new(config: Config) -> Result<Self> {
+        let scanner = Scanner::new(config.patterns.clone())?.with_test_rules(config.tests.clone());
+        let overrides = Overrides::new(&config.overrides)?;
+        Ok(Self {
+            config,
+            scanner,
+            overrides,
+            max_body: DEFAULT_MAX_BODY,
+        })
+    }
+
+    /// Reject request bodies over `bytes` with 413.
+    pub fn with_max_body(mut self, bytes: usize) -> Self {
+        self.max_body = bytes;
+        self
+    }
+
+    /// Accept connections on `listener` until `POST /shutdown`, answering
+    /// them on `workers` threads (at least one).
+    pub fn run(&self, listener: TcpListener, workers: usize) -> Result<()> {
+        let local = listener.local_addr()?;
+        let shutdown = AtomicBool::new(false);
+        let (sender, receiver) = mpsc::channel::<TcpStream>();
+        let receiver = Mutex::new(receiver);
+
+        thread::scope(|scope| {
+            for _ in 0..workers.max(1) {
+                scope.spawn(|| loop {
+                    // The lock is released as soon as a stream is taken
+                    let next = receiver.lock().map(|r| r.recv());
+                    match next {
+                        Ok(Ok(stream)) => self.serve_connection(stream, &shutdown, local),
+                        _ => break,
+                    }
+                });
+            }
+            for stream in listener.incoming() {
+                if shutdown.load(Ordering::SeqCst) {
+                    break;
+                }
+                match stream {
+                    Ok(stream) => {
+                        if sender.send(stream).is_err() {
+                            break;
+                        }
+                    }
+                    Err(e) => tracing::warn!("Failed to accept connection: {}", e),
+                }
+            }
+            // Workers finish what was queued, then see the channel close
+            drop(sender);
+        });
+        Ok(())
+    }
+
+    fn serve_connection(&self, mut stream: TcpStream, shutdown: &AtomicBool, local: SocketAddr) {
+        let _ = stream.set_read_timeout(Some(READ_TIMEOUT));
+        let peer = stream.peer_addr().ok();
+        let response = match read_request(&mut stream, self.max_body) {
+            Ok(request) if request.path == "/shutdown" => {
+                if request.method != "POST" {
+                    Response::error(405, "Use POST /shutdown")
+                } else if !peer.is_some_and(|p| p.ip().is_loopback()) {
+                    Response::error(403, "Shutdown is only accepted from loopback")
+                } else {
+                    shutdown.store(true, Ordering::SeqCst);
+                    // Wake the accept loop so it sees the flag
+                    let mut wake = local;
+                    if wake.ip().is_unspecified() {
+                        wake.set_ip(match wake {
+                            SocketAddr::V4(_) => Ipv4Addr::LOCALHOST.into(),
+                            SocketAddr::V6(_) => Ipv6Addr::LOCALHOST.into(),
+                        });
+                    }
+                    let _ = TcpStream::connect(wake);
+                    Response::json(200, json!({ "status": "shutting down" }))
+                }
+            }
+            Ok(request) => self.handle(&request),
+            Err(response) => response,
+        };
+        let _ = write_response(&mut stream, &response);
+    }
+
+    /// Answer one request. Does no I/O, so callers other than [`Server::run`]
+    /// can route requests here too.
+    pub fn handle(&self, request: &Request) -> Response {
+        match (request.method.as_str(), request.path.as_str()) {
+            ("GET", "/health") => {
+                Response::json(200, json!({ "status": "ok", "version": VERSION }))
+            }
+            ("GET", "/detectors") => Response::json(200, self.detectors()),
+            ("POST", "/analyze") => self.analyze(&request.body),
+            (_, "/health" | "/detectors" | "/analyze") => {
+                Response::error(405, "Method not allowed")
+            }
+            _ => Response::error(404, format!("No endpoint {}", request.path)),
+        }
+    }
+
+    fn detectors(&self) -> serde_json::Value {
+        let detectors: Vec<serde_json::Value> = self
+            .config
+            .patterns
+            .iter()
+            .map(|p| {
+                json!({
+                    "id": p.id,
+                    "severity": p.severity.as_str().to_lowercase(),
+                    "confidence": p.confidence.as_str().to_lowercase(),
+                    "category": format!("{:?}", p.category).to_lowercase(),
+                    "tags": p.tags,
+                    "languages": p.languages,
+                    "message": p.message,
+                })
+            })
+            .collect();
+        json!({ "detectors": detectors })
+    }
+
+    fn analyze(&self, body: &[u8]) -> Response {
+        let request: AnalyzeRequest = match serde_json::from_slice(body) {
+            Ok(request) => request,
+            Err(e) => return Response::error(400, format!("Invalid request: {}", e)),
+        };
+        let owned;
+        let (scanner, overrides) = match request.config {
+            Some(ref text) => match request_scanner(text) {
+                Ok(scanner) => {
+                    owned = scanner;
+                    (&owned.0, &owned.1)
+                }
+                Err(e) => return Response::error(400, e.to_string()),
+            },
+            None => (&self.scanner, &self.overrides),
+        };
+
+        let result = scanner.scan_file(&request.filename, &request.source);
+        let mut findings: Vec<Finding> = result
+            .findings
+            .into_iter()
+            .filter(|f| !scanner.is_package_finding(f))
+            .collect();
+        overrides.apply(&mut findings);
+        let summary = ScanSummary::summarize(&findings, 1);
+        let mut out = Vec::new();
+        match Reporter::new(Format::Json).report_to(&mut out, findings, summary) {
+            Ok(()) => Response {
+                status: 200,
+                body: String::from_utf8_lossy(&out).trim_end().to_string(),
+            },
+            Err(e) => Response::error(500, e.to_string()),
+        }
+    }
+}
+
+/// A scanner and overrides for the TOML config sent with one request.
+fn request_scanner(text: &str) -> Result<(Scanner, Overrides)> {
+    let table: toml::Table = toml::from_str(text)?;
+    if table.contains_key("extends") {
+        return Err(Error::ConfigInvalid(
+            "extends is not supported in request configs".to_string(),
+        ));
+    }
+    let mut config = Config::from_toml_str(text)?;
+    config.validate_patterns()?;
+    config.apply_detector_options()?;
+    config.retain_supported_patterns();
+    config.apply_info_only();
+    config.apply_exported_only();
+    let overrides = Overrides::new(&config.overrides)?;
+    let scanner = Scanner::new(config.patterns)?.with_test_rules(config.tests);
+    Ok((scanner, overrides))
+}
+
+/// Read one request, or the error response to send instead.
+fn read_request(stream: &mut TcpStream, max_body: usize) -> std::result::Result<Request, Response> {
+    let bad = |message: &str| Response::error(400, message);
+    let mut reader = BufReader::new(stream.take(MAX_HEAD as u64));
+    let mut line = String::new();
+    reader
+        .read_line(&mut line)
+        .map_err(|_| bad("Unreadable request"))?;
+    let mut parts = line.split_whitespace();
+    let (Some(method), Some(target)) = (parts.next(), parts.next()) else {
+        return Err(bad("Malformed request line"));
+    };
+    let method = method.to_string();
+    let path = target.split('?').next().unwrap_or(target).to_string();
+
+    let mut content_length = 0;
+    loop {
+        line.clear();
+        match reader.read_line(&mut line) {
+            Ok(0) => return Err(bad("Headers too large or incomplete")),
+            Ok(_) => {}
+            Err(_) => return Err(bad("Unreadable headers")),
+        }
+        let header = line.trim_end();
+        if header.is_empty() {
+            break;
+        }
+        if let Some((name, value)) = header.split_once(':') {
+            if name.trim().eq_ignore_ascii_case("content-length") {
+                content_length = value
+                    .trim()
+                    .parse()
+                    .map_err(|_| bad("Invalid Content-Length"))?;
+            }
+        }
+    }
+    if content_length > max_body {
+        return Err(Response::error(
+            413,
+            format!(
+                "Body of {} bytes is over the {} byte limit",
+                content_length, max_body
+            ),
+        ));
+    }
+
+    // Bytes past the headers may already sit in the reader's buffer
+    let mut body = reader.buffer().to_vec();
+    body.truncate(content_length);
+    let buffered = body.len();
+    let stream = reader.into_inner().into_inner();
+    body.resize(content_length, 0);
+    stream
+        .read_exact(&mut body[buffered..])
+        .map_err(|_| bad("Body shorter than Content-Length"))?;
+    Ok(Request { method, path, body })
+}
+
+fn write_response(stream: &mut TcpStream, response: &Response) -> std::io::Result<()> {
+    let reason = match response.status {
+        200 => "OK",
+        400 => "Bad Request",
+        403 => "Forbidden",
+        404 => "Not Found",
+        405 => "Method Not Allowed",
+        413 => "Payload Too Large",
+        _ => "Internal Server Error",
+    };
+    write!(
+        stream,
+        "HTTP/1.1 {} {}\r\nContent-Type: application/json\r\nContent-Length: {}\r\nConnection: close\r\n\r\n{}",
+        response.status,
+        reason,
+        response.body.len(),
+        response.body
+    )?;
+    stream.flush()
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    fn request(method: &str, path: &str, body: &str) -> Request {
+        Request {
+            method: method.to_string(),
+            path: path.to_string(),
+            body: body.as_bytes().to_vec(),
+        }
+    }
+
+    fn server() -> Server {
+        Server::new(Config::default()).unwrap()
+    }
+
+    #[test]
+    fn test_analyze_returns_json_findings() {
+        let body = json!({ "filename": "a.py", "source": "# TODO: fix\nx = 1\n" }).to_string();
+        let response = server().handle(&request("POST", "/analyze", &body));
+        assert_eq!(response.status, 200, "{}", response.body);
+        let json: serde_json::Value = serde_json::from_str(&response.body).unwrap();
+        assert!(json["summary"]["total_findings"].as_u64().unwrap() >= 1);
+        assert!(json["findings"]
+            .as_array()
+            .unwrap()
+            .iter()
+            .any(|f| f["id"] == "todo-marker"));
+    }
+
+    #[test]
+    fn test_analyze_with_request_config() {
+        let config = "[[patterns]]\nid = \"shout\"\nregex = \"LOUD\"\nseverity = \"low\"\n";
+        let body = json!({
+            "filename": "a.py",
+            "source": "# TODO: fix\n# LOUD\n",
+            "config": config,
+        })
+        .to_string();
+        let response = server().handle(&request("POST", "/analyze", &body));
+        let json: serde_json::Value = serde_json::from_str(&response.body).unwrap();
+        let ids: Vec<&str> = json["findings"]
+            .as_array()
+            .unwrap()
+            .iter()
+            .filter_map(|f| f["id"].as_str())
+            .collect();
+        assert!(ids.contains(&"shout"), "{:?}", ids);
+    }
+
+    #[test]
+    fn test_errors() {
+        let server = server();
+        assert_eq!(server.handle(&request("POST", "/analyze", "{")).status, 400);
+        assert_eq!(server.handle(&request("GET", "/analyze", "")).status, 405);
+        assert_eq!(server.handle(&request("GET", "/nope", "")).status, 404);
+        let health = server.handle(&request("GET", "/health", ""));
+        assert_eq!(health.status, 200);
+        assert!(health.body.contains("\"ok\""));
+    }
+
+    #[test]
+    fn test_run_over_tcp() {
+        let server = server().with_max_body(64);
+        let listener = TcpListener::bind("127.0.0.1:0").unwrap();
+        let addr = listener.local_addr().unwrap();
+        thread::scope(|scope| {
+            scope.spawn(|| server.run(listener, 2).unwrap());
+
+            let send = |raw: &str| {
+                let mut stream = TcpStream::connect(addr).unwrap();
+                stream.write_all(raw.as_bytes()).unwrap();
+                let mut out = String::new();
+                stream.read_to_string(&mut out).unwrap();
+                out
+            };
+            let detectors = send("GET /detectors HTTP/1.1\r\nHost: x\r\n\r\n");
+            assert!(detectors.starts_with("HTTP/1.1 200 OK"), "{}", detectors);
+            assert!(detectors.contains("todo-marker"));
+
+            // Rejected before the body is read, so none is sent
+            let too_big = send("POST /analyze HTTP/1.1\r\nContent-Length: 100\r\n\r\n");
+            assert!(too_big.starts_with("HTTP/1.1 413"), "{}", too_big);
+
+            let stopped = send("POST /shutdown HTTP/1.1\r\nContent-Length: 0\r\n\r\n");
+            assert!(stopped.starts_with("HTTP/1.1 200 OK"), "{}", stopped);
+        });
+    }
+}
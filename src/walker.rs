@@ -11,6 +11,8 @@ pub struct FileEntry {
     pub path: PathBuf,
     /// File extension with leading dot.
     pub extension: Option<String>,
+    /// The path passed to [`Walker::walk`] that this file was found under.
+    pub root: PathBuf,
 }
 
 /// Parallel file walker.
@@ -44,6 +46,7 @@ impl Walker {
                     entries.push(FileEntry {
                         path: base.clone(),
                         extension: Self::get_extension(base),
+                        root: base.clone(),
                     });
                 }
                 continue;
@@ -68,6 +71,7 @@ impl Walker {
                     entries.push(FileEntry {
                         path: path.to_path_buf(),
                         extension: Self::get_extension(path),
+                        root: base.clone(),
                     });
                 }
             }
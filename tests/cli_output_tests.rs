@@ -295,3 +295,1353 @@ fn test_hygiene_survey_flag() {
         "Should show recommendations section"
     );
 }
+
+#[test]
+fn test_max_findings_truncates_listing_but_not_summary() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("many.py");
+    fs::write(
+        &file,
+        r#"# TODO: first
+# TODO: second
+# TODO: third
+# XXX: urgent
+"#,
+    )
+    .unwrap();
+
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg("--max-findings")
+        .arg("2")
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+
+    let text = String::from_utf8_lossy(&output.stdout);
+    let json: serde_json::Value = serde_json::from_str(&text).expect("JSON should be valid");
+
+    let findings = json["findings"].as_array().unwrap();
+    assert_eq!(findings.len(), 2, "Listing should be capped");
+
+    // The most severe finding (XXX = HIGH) must survive the cap
+    assert!(findings.iter().any(|f| f["severity"] == "high"));
+
+    let total = json["summary"]["total_findings"].as_u64().unwrap();
+    assert!(total > 2, "Summary should count every finding");
+
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(
+        stderr.contains(&format!("... and {} more findings", total - 2)),
+        "Should print truncation notice: {}",
+        stderr
+    );
+}
+
+#[test]
+fn test_json_summary_per_root() {
+    let api = TempDir::new().unwrap();
+    let web = TempDir::new().unwrap();
+    fs::write(api.path().join("a.py"), "# TODO: first\n# TODO: second\n").unwrap();
+    fs::write(api.path().join("b.py"), "x = 1\n").unwrap();
+    fs::write(web.path().join("c.py"), "# TODO: third\n").unwrap();
+
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg(api.path())
+        .arg(web.path())
+        .output()
+        .unwrap();
+    let json: serde_json::Value =
+        serde_json::from_slice(&output.stdout).expect("JSON should be valid");
+
+    let summary = &json["summary"];
+    let roots = summary["roots"].as_array().expect("Should list roots");
+    assert_eq!(roots.len(), 2);
+    assert_eq!(roots[0]["path"], api.path().to_string_lossy().as_ref());
+    assert_eq!(roots[0]["files_scanned"], 2);
+    assert_eq!(roots[1]["files_scanned"], 1);
+
+    let per_root: u64 = roots
+        .iter()
+        .map(|r| r["total_findings"].as_u64().unwrap())
+        .sum();
+    assert_eq!(per_root, summary["total_findings"].as_u64().unwrap());
+
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg(api.path())
+        .output()
+        .unwrap();
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    assert!(json["summary"].get("roots").is_none());
+}
+
+#[test]
+fn test_count_trailer() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("one.py");
+    fs::write(&file, "# TODO: first\n").unwrap();
+
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    let last = stderr.lines().last().unwrap_or("");
+    assert!(
+        last.starts_with("antislop: 1 findings (") && last.ends_with(" in 1 files"),
+        "Should end stderr with the trailer: {}",
+        stderr
+    );
+
+    let output = Command::new(antislop_bin())
+        .arg("--count-trailer")
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(stdout
+        .lines()
+        .last()
+        .is_some_and(|l| l.starts_with("antislop: 1 findings (")));
+}
+
+#[test]
+fn test_tags_filter_limits_patterns() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("mixed.py");
+    fs::write(
+        &file,
+        r#"# TODO: tidy this up
+def f():
+    raise NotImplementedError
+"#,
+    )
+    .unwrap();
+
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg("--tags")
+        .arg("correctness")
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+
+    let text = String::from_utf8_lossy(&output.stdout);
+    let json: serde_json::Value = serde_json::from_str(&text).expect("JSON should be valid");
+
+    let findings = json["findings"].as_array().unwrap();
+    assert!(!findings.is_empty(), "Stub should still be reported");
+    for finding in findings {
+        let tags = finding["tags"].as_array().expect("findings carry tags");
+        assert!(tags.iter().any(|t| t == "correctness"), "{}", finding);
+        assert!(finding["id"].is_string(), "findings carry an id");
+    }
+}
+
+#[test]
+fn test_category_runs_only_that_category() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("store.go");
+    fs::write(
+        &file,
+        "package store\n\n// TODO: tidy this up\nfunc Find(db *sql.DB, name string) {\n\tdb.Query(\"SELECT * FROM users WHERE name = '\" + name + \"'\")\n}\n",
+    )
+    .unwrap();
+
+    let output = Command::new(antislop_bin())
+        .args(["--json", "--category", "security"])
+        .arg(&file)
+        .output()
+        .unwrap();
+
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    let findings = json["findings"].as_array().unwrap();
+    assert!(
+        findings.iter().any(|f| f["id"] == "go-sql-concat-query"),
+        "{:?}",
+        findings
+    );
+    for finding in findings {
+        assert_eq!(finding["category"], "security", "{}", finding);
+    }
+}
+
+#[test]
+fn test_select_expression_filters_patterns() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("mixed.py");
+    fs::write(
+        &file,
+        "# TODO: tidy this up\ndef f():\n    raise NotImplementedError\n",
+    )
+    .unwrap();
+
+    let output = Command::new(antislop_bin())
+        .args([
+            "--json",
+            "--select",
+            "correctness and not (placeholder or low)",
+        ])
+        .arg(&file)
+        .output()
+        .unwrap();
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    let findings = json["findings"].as_array().unwrap();
+    assert!(!findings.is_empty(), "Stub should still be reported");
+    for finding in findings {
+        let tags = finding["tags"].as_array().unwrap();
+        assert!(tags.iter().any(|t| t == "correctness"), "{}", finding);
+        assert_ne!(finding["confidence"], "low", "{}", finding);
+        assert!(
+            !finding["category"]
+                .as_str()
+                .unwrap()
+                .eq_ignore_ascii_case("placeholder"),
+            "{}",
+            finding
+        );
+    }
+
+    let output = Command::new(antislop_bin())
+        .args(["--select", "security and (style"])
+        .arg(&file)
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("missing `)`"), "{}", stderr);
+}
+
+#[test]
+fn test_only_detector_runs_named_patterns() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("mixed.py");
+    fs::write(
+        &file,
+        "# TODO: tidy this up\ndef f():\n    raise NotImplementedError\n",
+    )
+    .unwrap();
+
+    let output = Command::new(antislop_bin())
+        .args(["--json", "--only-detector", "todo-marker"])
+        .arg(&file)
+        .output()
+        .unwrap();
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    let findings = json["findings"].as_array().unwrap();
+    assert!(!findings.is_empty());
+    assert!(
+        findings.iter().all(|f| f["id"] == "todo-marker"),
+        "{:?}",
+        findings
+    );
+
+    let output = Command::new(antislop_bin())
+        .args(["--only-detector", "no-such-pattern"])
+        .arg(&file)
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(
+        stderr.contains("Unknown detector 'no-such-pattern'"),
+        "{}",
+        stderr
+    );
+}
+
+#[test]
+fn test_list_patterns_shows_ids_and_tags() {
+    let output = Command::new(antislop_bin())
+        .arg("--list-patterns")
+        .output()
+        .unwrap();
+
+    assert!(output.status.success());
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(stdout.contains("todo-marker"));
+    assert!(stdout.contains("[maintainability]"));
+    assert!(stdout.contains("go-defensive-recover"));
+}
+
+/// Run git in `dir` with a throwaway identity.
+fn git(dir: &std::path::Path, args: &[&str]) {
+    let status = Command::new("git")
+        .arg("-C")
+        .arg(dir)
+        .args(["-c", "user.name=test", "-c", "user.email=test@example.com"])
+        .args(args)
+        .status()
+        .expect("git should be installed");
+    assert!(status.success(), "git {:?} failed", args);
+}
+
+#[test]
+fn test_fail_on_new_reports_only_new_findings() {
+    let temp = TempDir::new().unwrap();
+    let dir = temp.path();
+    git(dir, &["init", "--quiet"]);
+    fs::write(dir.join("lib.py"), "# TODO: old one\nx = 1\n").unwrap();
+    git(dir, &["add", "."]);
+    git(dir, &["commit", "--quiet", "-m", "base"]);
+
+    // Moving the old finding down a line must not make it new
+    fs::write(
+        dir.join("lib.py"),
+        "y = 0\n# TODO: old one\nx = 1\n# TODO: new one\n",
+    )
+    .unwrap();
+
+    let bin = fs::canonicalize(antislop_bin()).unwrap();
+    let run = || {
+        Command::new(&bin)
+            .current_dir(dir)
+            .args(["--json", "--fail-on-new", "--base", "HEAD", "."])
+            .output()
+            .unwrap()
+    };
+
+    let output = run();
+    assert_eq!(output.status.code(), Some(1));
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    let findings = json["findings"].as_array().unwrap();
+    assert!(!findings.is_empty(), "New TODO should be reported");
+    assert!(
+        findings.iter().all(|f| f["line"] == 4),
+        "Only the new TODO should be reported: {:?}",
+        findings
+    );
+
+    // Once committed, nothing is new relative to HEAD
+    git(dir, &["commit", "--quiet", "-am", "more"]);
+    let output = run();
+    assert_eq!(
+        output.status.code(),
+        Some(0),
+        "{}",
+        String::from_utf8_lossy(&output.stderr)
+    );
+}
+
+#[test]
+fn test_todo_max_age_keeps_only_old_comments() {
+    let temp = TempDir::new().unwrap();
+    let dir = temp.path();
+    git(dir, &["init", "--quiet"]);
+    fs::write(
+        dir.join("lib.py"),
+        "# TODO old cleanup\nx = 1\n# TODO: flagged by core\n",
+    )
+    .unwrap();
+    git(dir, &["add", "."]);
+    git(
+        dir,
+        &[
+            "commit",
+            "--quiet",
+            "--date=2020-01-01T00:00:00",
+            "-m",
+            "old",
+        ],
+    );
+    fs::write(
+        dir.join("lib.py"),
+        "# TODO old cleanup\nx = 1\n# TODO: flagged by core\n# FIXME just written\n",
+    )
+    .unwrap();
+
+    let profile = concat!(
+        env!("CARGO_MANIFEST_DIR"),
+        "/.antislop/profiles/todo-comments.toml"
+    );
+    let bin = fs::canonicalize(antislop_bin()).unwrap();
+    let output = Command::new(&bin)
+        .current_dir(dir)
+        .args(["--json", "--profile", profile, "--todo-max-age", "30", "."])
+        .output()
+        .unwrap();
+
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    let todos: Vec<_> = json["findings"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .filter(|f| f["id"] == "todo-comment")
+        .collect();
+    assert_eq!(todos.len(), 1, "{:?}", todos);
+    assert_eq!(todos[0]["line"], 1);
+    assert_eq!(todos[0]["match_text"], "TODO old cleanup");
+    assert!(todos[0]["message"]
+        .as_str()
+        .unwrap()
+        .ends_with(" days old)"));
+    assert!(!String::from_utf8_lossy(&output.stderr).contains("TODO age"));
+}
+
+#[cfg(target_os = "linux")]
+#[test]
+fn test_memory_stays_bounded_on_large_tree() {
+    let temp = TempDir::new().unwrap();
+
+    // 48 files of ~512 KiB each: about 24 MiB of source in total
+    let mut body = String::from("package big\n\n");
+    let mut i = 0;
+    while body.len() < 512 * 1024 {
+        body.push_str(&format!(
+            "func F{i}(a, b int) int {{\n\treturn a + b + {i}\n}}\n\n"
+        ));
+        i += 1;
+    }
+    body.push_str("// TODO: one finding per file\n");
+    for n in 0..48 {
+        fs::write(temp.path().join(format!("gen{n}.go")), &body).unwrap();
+    }
+
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg("--profile-memory")
+        .arg("-j")
+        .arg("2")
+        .arg(temp.path().to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    assert_eq!(json["summary"]["files_scanned"], 48);
+
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    let mib: f64 = stderr
+        .lines()
+        .find_map(|l| l.strip_prefix("Peak memory: "))
+        .and_then(|rest| rest.trim_end_matches(" MiB").parse().ok())
+        .expect("peak memory should be reported");
+
+    // Two workers hold two trees at most; holding every tree would need
+    // several times the input size
+    assert!(mib < 24.0 * 4.0, "peak memory {mib} MiB");
+}
+
+#[test]
+fn test_fix_dry_run_prints_diff() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("fix.go");
+    let source = "package fix\n\n// TODO: tidy\nfunc f(s string) string {\n\treturn strings.Replace(s, \"a\", \"b\", -1)\n}\n";
+    fs::write(&file, source).unwrap();
+
+    let output = Command::new(antislop_bin())
+        .arg("--fix-dry-run")
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+
+    assert!(output.status.success(), "Dry run should always exit 0");
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(stdout.contains("-\treturn strings.Replace(s, \"a\", \"b\", -1)"));
+    assert!(stdout.contains("+\treturn strings.ReplaceAll(s, \"a\", \"b\")"));
+
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("1 findings would be fixed, "), "{}", stderr);
+    assert!(
+        !stderr.contains(", 0 unfixable"),
+        "TODO has no fix: {}",
+        stderr
+    );
+    assert_eq!(fs::read_to_string(&file).unwrap(), source);
+}
+
+#[test]
+fn test_fix_writes_files() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("fix.go");
+    fs::write(
+        &file,
+        "package fix\n\n// TODO: tidy\nfunc f(s string) string {\n\treturn strings.Replace(s, \"a\", \"b\", -1)\n}\n",
+    )
+    .unwrap();
+
+    let output = Command::new(antislop_bin())
+        .arg("--fix")
+        .arg(&file)
+        .output()
+        .unwrap();
+
+    assert!(output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("1 findings fixed, "), "{}", stderr);
+    assert_eq!(
+        fs::read_to_string(&file).unwrap(),
+        "package fix\n\n// TODO: tidy\nfunc f(s string) string {\n\treturn strings.ReplaceAll(s, \"a\", \"b\")\n}\n"
+    );
+    assert_eq!(fs::read_dir(temp.path()).unwrap().count(), 1);
+
+    let output = Command::new(antislop_bin())
+        .args(["--fix", "--fix-dry-run"])
+        .arg(&file)
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+}
+
+#[test]
+fn test_explain_prints_docs() {
+    let output = Command::new(antislop_bin())
+        .arg("--explain")
+        .arg("go-sql-concat-query")
+        .output()
+        .unwrap();
+
+    assert!(output.status.success());
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(stdout.contains("Severity:   high"));
+    assert!(stdout.contains("Confidence: medium"));
+    assert!(stdout.contains("Why:"));
+    assert!(stdout.contains("Flagged:"));
+    assert!(stdout.contains("antislop:ignore go-sql-concat-query"));
+
+    let output = Command::new(antislop_bin())
+        .arg("--explain")
+        .arg("no-such-pattern")
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+}
+
+#[test]
+fn test_compare_reports() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("todo.py");
+    let scan = |content: &str, report: &str| {
+        fs::write(&file, content).unwrap();
+        Command::new(antislop_bin())
+            .arg("--json")
+            .arg("--output")
+            .arg(temp.path().join(report))
+            .arg(&file)
+            .output()
+            .unwrap();
+    };
+    scan("# TODO: first\n# TODO: second\n", "old.json");
+    scan("# TODO: second\nx = 1\n# TODO: third\n", "new.json");
+
+    let compare = |extra: &[&str]| {
+        Command::new(antislop_bin())
+            .arg("compare")
+            .arg(temp.path().join("old.json"))
+            .arg(temp.path().join("new.json"))
+            .args(extra)
+            .output()
+            .unwrap()
+    };
+    let output = compare(&[]);
+    assert!(output.status.success());
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    let (introduced, fixed) = stdout.split_once("Fixed").expect("fixed findings listed");
+    assert!(introduced.starts_with("Introduced"), "{}", stdout);
+    assert!(introduced.contains("todo.py:3"), "{}", stdout);
+    assert!(fixed.contains("todo.py:1"), "{}", stdout);
+    assert!(
+        !fixed.contains("todo.py:2"),
+        "moved TODO is unchanged: {}",
+        stdout
+    );
+    assert!(stdout.contains("; score "), "{}", stdout);
+
+    assert_eq!(compare(&["--fail-on-regression"]).status.code(), Some(1));
+}
+
+#[test]
+fn test_scan_embed_strings() {
+    let temp = TempDir::new().unwrap();
+    fs::create_dir(temp.path().join("testdata")).unwrap();
+    fs::write(
+        temp.path().join("testdata/handler.go.txt"),
+        "package h\n\n// TODO: validate input\nfunc Handle() {}\n",
+    )
+    .unwrap();
+    let host = temp.path().join("tmpl.go");
+    fs::write(
+        &host,
+        "package tmpl\n\n//antislop:embed-go\n//go:embed testdata/handler.go.txt\nvar handler string\n\n\
+         //antislop:embed-go\nvar example = `func f() {\n\t// FIXME: broken\n}`\n",
+    )
+    .unwrap();
+
+    let run = |extra: &[&str]| {
+        let output = Command::new(antislop_bin())
+            .arg("--json")
+            .args(extra)
+            .arg(&host)
+            .output()
+            .unwrap();
+        let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+        json["findings"]
+            .as_array()
+            .unwrap()
+            .iter()
+            .map(|f| {
+                let file = f["file"].as_str().unwrap().to_string();
+                (file, f["line"].as_u64().unwrap())
+            })
+            .collect::<Vec<_>>()
+    };
+
+    let plain = run(&[]);
+    assert!(
+        plain.iter().all(|(f, l)| f.ends_with("tmpl.go") && *l != 9),
+        "{:?}",
+        plain
+    );
+    let found = run(&["--scan-embed-strings"]);
+    assert!(
+        found
+            .iter()
+            .any(|(f, l)| f.ends_with("handler.go.txt") && *l == 3),
+        "{:?}",
+        found
+    );
+    assert!(
+        found.iter().any(|(f, l)| f.ends_with("tmpl.go") && *l == 9),
+        "{:?}",
+        found
+    );
+}
+
+#[test]
+fn test_template_format() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("a.py");
+    fs::write(&file, "# TODO: fix\nx = 1\n").unwrap();
+
+    let output = Command::new(antislop_bin())
+        .args(["--format", "template", "--template"])
+        .arg("{{.summary.total_findings}}|{{range .findings}}{{.line}}:{{upper .severity}} {{end}}")
+        .arg(&file)
+        .output()
+        .unwrap();
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    let (total, listed) = stdout.split_once('|').unwrap();
+    let listed: Vec<&str> = listed.split_whitespace().collect();
+    assert_eq!(total.parse::<usize>().unwrap(), listed.len(), "{}", stdout);
+    assert!(listed.contains(&"1:MEDIUM"), "{}", stdout);
+
+    // A bad template fails before the scan
+    let output = Command::new(antislop_bin())
+        .args(["--format", "template", "--template", "{{range .findings}}"])
+        .arg(&file)
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    assert!(output.stdout.is_empty());
+    assert!(String::from_utf8_lossy(&output.stderr).contains("without end"));
+}
+
+#[test]
+fn test_fail_on_parse_error() {
+    let temp = TempDir::new().unwrap();
+    fs::write(
+        temp.path().join("good.go"),
+        "package lib\n\n// TODO: fix\nfunc F() {}\n",
+    )
+    .unwrap();
+    fs::write(
+        temp.path().join("broken.go"),
+        "package lib\n\n// TODO: fix\nfunc G() {\n\tx := \n",
+    )
+    .unwrap();
+
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg("--fail-on-parse-error")
+        .arg(temp.path())
+        .output()
+        .unwrap();
+    assert_eq!(output.status.code(), Some(3));
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.starts_with("Parse errors (1):"), "{}", stderr);
+    assert!(stderr.contains("broken.go:"), "{}", stderr);
+
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    let files: Vec<&str> = json["findings"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .map(|f| f["file"].as_str().unwrap())
+        .collect();
+    assert!(files.iter().all(|f| f.ends_with("good.go")), "{:?}", files);
+    assert!(!files.is_empty());
+}
+
+#[test]
+fn test_bench_accuracy() {
+    let temp = TempDir::new().unwrap();
+    fs::write(
+        temp.path().join("a.py"),
+        "# TODO: one\nx = 1\n# FIXME: two\n",
+    )
+    .unwrap();
+    fs::write(
+        temp.path().join("labels.toml"),
+        "[[files]]\npath = \"a.py\"\nexpect = [\"1:todo-marker\", \"3:fixme-marker\"]\n",
+    )
+    .unwrap();
+
+    let run = |extra: &[&str]| {
+        Command::new(antislop_bin())
+            .current_dir(temp.path())
+            .args(["bench", "--accuracy", "--labels", "labels.toml"])
+            .args(extra)
+            .output()
+            .unwrap()
+    };
+
+    let output = run(&[]);
+    assert!(output.status.success());
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    let row = stdout
+        .lines()
+        .find(|l| l.starts_with("todo-marker "))
+        .expect("todo-marker row");
+    assert!(row.contains("100.0%"), "{}", row);
+
+    // to-do-marker also matches `TODO:`, on a line nobody labeled
+    let output = run(&["--min-precision", "0.9"]);
+    assert_eq!(output.status.code(), Some(1));
+    assert!(String::from_utf8_lossy(&output.stderr).contains("to-do-marker"));
+}
+
+#[test]
+fn test_no_summary() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("one.py");
+    fs::write(&file, "# TODO: first\n").unwrap();
+
+    let output = Command::new(antislop_bin())
+        .args(["--format", "ndjson", "--no-summary"])
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(!stdout.is_empty());
+    for line in stdout.lines() {
+        let value: serde_json::Value = serde_json::from_str(line).unwrap();
+        assert!(
+            value.get("summary").is_none(),
+            "Unexpected summary: {}",
+            line
+        );
+    }
+
+    let output = Command::new(antislop_bin())
+        .args(["--no-summary", "--summary-only"])
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("cannot be used with"), "{}", stderr);
+}
+
+#[test]
+fn test_metrics_format() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("one.py");
+    fs::write(&file, "# TODO: first\n").unwrap();
+
+    let output = Command::new(antislop_bin())
+        .args(["--format", "metrics"])
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert_eq!(stdout.lines().count(), 1, "{}", stdout);
+    let parts: Vec<&str> = stdout.trim_end().split(' ').collect();
+    assert_eq!(parts[0], "antislop");
+    assert!(
+        parts[1].starts_with("files_scanned=1i,files_with_findings=1i,total_findings=1i,"),
+        "{}",
+        stdout
+    );
+    assert!(parts[1].contains(",placeholder=1i,"), "{}", stdout);
+    assert!(parts[2].parse::<u128>().unwrap() > 0);
+}
+
+#[test]
+fn test_suppress_by_fingerprint() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("two.py");
+    fs::write(&file, "# TODO: first\n# FIXME: second\n").unwrap();
+
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    let todo = json["findings"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .find(|f| f["id"] == "todo-marker")
+        .unwrap();
+    let fingerprint = todo["fingerprint"].as_str().unwrap().to_string();
+
+    let output = Command::new(antislop_bin())
+        .arg("--print-fingerprint")
+        .arg(file.to_string_lossy().as_ref())
+        .env("NO_COLOR", "1")
+        .output()
+        .unwrap();
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(
+        stdout.contains(&format!("fingerprint: {}", fingerprint)),
+        "{}",
+        stdout
+    );
+
+    // A config file replaces the built-in patterns, so start from them
+    let defaults = Command::new(antislop_bin())
+        .arg("--print-config")
+        .output()
+        .unwrap();
+    let config = temp.path().join("antislop.toml");
+    fs::write(
+        &config,
+        format!(
+            "{}\n[suppress]\nfingerprints = [\"{}\"]\n",
+            String::from_utf8_lossy(&defaults.stdout),
+            fingerprint
+        ),
+    )
+    .unwrap();
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg("--config")
+        .arg(&config)
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    let ids: Vec<_> = json["findings"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .map(|f| f["id"].as_str().unwrap().to_string())
+        .collect();
+    assert!(!ids.contains(&"todo-marker".to_string()), "{:?}", ids);
+    assert!(ids.contains(&"fixme-marker".to_string()), "{:?}", ids);
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(!stderr.contains("Suppressed by fingerprint"), "{}", stderr);
+
+    let output = Command::new(antislop_bin())
+        .arg("-v")
+        .arg("--json")
+        .arg("--config")
+        .arg(&config)
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(
+        stderr.contains("Suppressed by fingerprint: 1 findings"),
+        "{}",
+        stderr
+    );
+}
+
+#[test]
+fn test_test_file_rules() {
+    let temp = TempDir::new().unwrap();
+    let code = "# TODO: first\n# FIXME: second\n";
+    fs::write(temp.path().join("lib.py"), code).unwrap();
+    fs::write(temp.path().join("test_lib.py"), code).unwrap();
+    let findings = |output: &std::process::Output| -> Vec<serde_json::Value> {
+        let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+        json["findings"].as_array().unwrap().clone()
+    };
+
+    let output = Command::new(antislop_bin())
+        .args(["--json", "--tests=off"])
+        .arg(temp.path())
+        .output()
+        .unwrap();
+    let skipped = findings(&output);
+    assert!(!skipped.is_empty());
+    assert!(
+        skipped
+            .iter()
+            .all(|f| f["file"].as_str().unwrap().ends_with("/lib.py")),
+        "{:?}",
+        skipped
+    );
+
+    // A config file replaces the built-in patterns, so start from them
+    let defaults = Command::new(antislop_bin())
+        .arg("--print-config")
+        .output()
+        .unwrap();
+    let config = temp.path().join("rules.toml");
+    fs::write(
+        &config,
+        format!(
+            "{}\n[tests]\ndisable = [\"todo-marker\"]\nseverity = {{ fixme-marker = \"info\" }}\n",
+            String::from_utf8_lossy(&defaults.stdout)
+        ),
+    )
+    .unwrap();
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg("--config")
+        .arg(&config)
+        .arg(temp.path().join("lib.py"))
+        .arg(temp.path().join("test_lib.py"))
+        .output()
+        .unwrap();
+    let mut in_tests: Vec<(String, String)> = findings(&output)
+        .iter()
+        .filter(|f| f["file"].as_str().unwrap().ends_with("test_lib.py"))
+        .map(|f| {
+            (
+                f["id"].as_str().unwrap().to_string(),
+                f["severity"].as_str().unwrap().to_string(),
+            )
+        })
+        .collect();
+    in_tests.sort();
+    assert_eq!(
+        in_tests,
+        vec![("fixme-marker".to_string(), "info".to_string())]
+    );
+    let all = findings(&output);
+    assert!(all.iter().any(|f| f["id"] == "todo-marker"), "{:?}", all);
+}
+
+#[test]
+fn test_test_files_relative_to_scan_root() {
+    // A checkout that happens to live under a `test` directory
+    let temp = TempDir::new().unwrap();
+    let repo = temp.path().join("test").join("repo");
+    fs::create_dir_all(repo.join("tests")).unwrap();
+    fs::write(repo.join("lib.py"), "# TODO: first\n").unwrap();
+    fs::write(repo.join("tests").join("cli.py"), "# TODO: second\n").unwrap();
+
+    let output = Command::new(antislop_bin())
+        .args(["--json", "--tests=off"])
+        .arg(&repo)
+        .output()
+        .unwrap();
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    let files: Vec<String> = json["findings"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .map(|f| f["file"].as_str().unwrap().replace('\\', "/"))
+        .collect();
+    assert!(
+        !files.is_empty(),
+        "{}",
+        String::from_utf8_lossy(&output.stderr)
+    );
+    assert!(
+        files.iter().all(|f| f.ends_with("repo/lib.py")),
+        "{:?}",
+        files
+    );
+}
+
+#[test]
+fn test_overrides() {
+    let temp = TempDir::new().unwrap();
+    let code = "# TODO: first\n# FIXME: second\n";
+    for dir in ["payments", "legacy"] {
+        fs::create_dir(temp.path().join(dir)).unwrap();
+        fs::write(temp.path().join(dir).join("lib.py"), code).unwrap();
+    }
+
+    // A config file replaces the built-in patterns, so start from them
+    let defaults = Command::new(antislop_bin())
+        .arg("--print-config")
+        .output()
+        .unwrap();
+    let config = temp.path().join("rules.toml");
+    let write_config = |overrides: &str| {
+        fs::write(
+            &config,
+            format!(
+                "{}\n{}",
+                String::from_utf8_lossy(&defaults.stdout),
+                overrides
+            ),
+        )
+        .unwrap();
+    };
+    write_config(
+        "[[overrides]]\npath = \"payments/**\"\ncategory = \"placeholder\"\nseverity = \"critical\"\n\n\
+         [[overrides]]\npath = \"legacy/**\"\nid = \"fixme-marker\"\ndrop = true\n",
+    );
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg("--config")
+        .arg(&config)
+        .arg(temp.path())
+        .output()
+        .unwrap();
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    let mut findings: Vec<(String, String, String)> = json["findings"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .map(|f| {
+            let file = f["file"].as_str().unwrap().replace('\\', "/");
+            let dir = file.rsplit('/').nth(1).unwrap().to_string();
+            (
+                dir,
+                f["id"].as_str().unwrap().to_string(),
+                f["severity"].as_str().unwrap().to_string(),
+            )
+        })
+        .collect();
+    findings.sort();
+    let expected = [
+        ("legacy", "todo-marker", "medium"),
+        ("payments", "fixme-marker", "critical"),
+        ("payments", "todo-marker", "critical"),
+    ];
+    assert_eq!(
+        findings,
+        expected
+            .iter()
+            .map(|(d, i, s)| (d.to_string(), i.to_string(), s.to_string()))
+            .collect::<Vec<_>>()
+    );
+
+    write_config("[[overrides]]\npath = \"legacy/**\"\n");
+    let output = Command::new(antislop_bin())
+        .arg("--config")
+        .arg(&config)
+        .arg(temp.path())
+        .output()
+        .unwrap();
+    assert!(!output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("overrides[0]"), "{}", stderr);
+}
+
+#[test]
+fn test_init_writes_starter_config() {
+    let temp = TempDir::new().unwrap();
+    let init = |extra: &[&str]| {
+        Command::new(antislop_bin())
+            .arg("init")
+            .args(extra)
+            .current_dir(temp.path())
+            .output()
+            .unwrap()
+    };
+
+    let output = init(&[]);
+    assert!(output.status.success(), "{:?}", output);
+    let config = temp.path().join("antislop.toml");
+    let text = fs::read_to_string(&config).unwrap();
+    assert!(text.contains("#   todo-marker "), "{}", text);
+    assert!(text.contains("#   filename "), "{}", text);
+    assert!(text.contains("# [suppress]"), "{}", text);
+
+    // The starter config finds what the built-in defaults find
+    let file = temp.path().join("todo.py");
+    fs::write(&file, "# TODO: first\n").unwrap();
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg("--config")
+        .arg(&config)
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    assert!(json["findings"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .any(|f| f["id"] == "todo-marker"));
+
+    fs::write(&config, "max_file_size_kb = 1\n").unwrap();
+    let output = init(&[]);
+    assert!(!output.status.success());
+    assert!(String::from_utf8_lossy(&output.stderr).contains("--force"));
+    assert_eq!(
+        fs::read_to_string(&config).unwrap(),
+        "max_file_size_kb = 1\n"
+    );
+
+    let output = init(&["--force"]);
+    assert!(output.status.success(), "{:?}", output);
+    assert_eq!(fs::read_to_string(&config).unwrap(), text);
+}
+
+#[test]
+fn test_finding_suggestion() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("todo.py");
+    fs::write(&file, "# TODO: first\n").unwrap();
+
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg(file.to_string_lossy().as_ref())
+        .output()
+        .unwrap();
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    let todo = json["findings"]
+        .as_array()
+        .unwrap()
+        .iter()
+        .find(|f| f["id"] == "todo-marker")
+        .unwrap();
+    let suggestion = todo["suggestion"].as_str().unwrap();
+    assert!(suggestion.starts_with("Do the work"), "{}", suggestion);
+
+    let output = Command::new(antislop_bin())
+        .arg(file.to_string_lossy().as_ref())
+        .env("NO_COLOR", "1")
+        .output()
+        .unwrap();
+    let stdout = String::from_utf8_lossy(&output.stdout);
+    assert!(
+        stdout.contains(&format!("suggestion: {}", suggestion)),
+        "{}",
+        stdout
+    );
+}
+
+#[test]
+fn test_exit_code_count() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("mixed.py");
+    fs::write(
+        &file,
+        "# TODO: tidy this up\n# FIXME: and this\ndef f():\n    raise NotImplementedError\n",
+    )
+    .unwrap();
+
+    let output = Command::new(antislop_bin())
+        .arg("--json")
+        .arg(&file)
+        .output()
+        .unwrap();
+    let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+    let count = json["findings"].as_array().unwrap().len();
+    assert!(count > 1);
+    assert_eq!(output.status.code(), Some(1));
+
+    let output = Command::new(antislop_bin())
+        .args(["--json", "--exit-code-count"])
+        .arg(&file)
+        .output()
+        .unwrap();
+    assert_eq!(output.status.code(), Some(count as i32));
+
+    let clean = temp.path().join("clean.py");
+    fs::write(&clean, "def f():\n    return 1\n").unwrap();
+    let output = Command::new(antislop_bin())
+        .arg("--exit-code-count")
+        .arg(&clean)
+        .output()
+        .unwrap();
+    assert_eq!(output.status.code(), Some(0));
+
+    let output = Command::new(antislop_bin())
+        .args(["--exit-code-count", "--config"])
+        .arg(temp.path().join("missing.toml"))
+        .arg(&file)
+        .output()
+        .unwrap();
+    assert_eq!(output.status.code(), Some(126));
+}
+
+#[test]
+fn test_baseline_stale_check() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("todo.py");
+    fs::write(&file, "# TODO: first\n# TODO: second\n").unwrap();
+    let baseline = temp.path().join("baseline.json");
+
+    let run = |extra: &[&str]| {
+        Command::new(antislop_bin())
+            .arg("--baseline")
+            .arg(&baseline)
+            .args(extra)
+            .arg(&file)
+            .output()
+            .unwrap()
+    };
+
+    assert!(run(&["--update-baseline"]).status.success());
+    let output = run(&["--baseline-stale-check", "--max-stale", "0"]);
+    assert!(
+        output.status.success(),
+        "{}",
+        String::from_utf8_lossy(&output.stderr)
+    );
+    assert!(String::from_utf8_lossy(&output.stderr).contains("no stale entries"));
+
+    // Fixing one TODO leaves its entry stale
+    fs::write(&file, "# TODO: first\n").unwrap();
+    let output = run(&["--baseline-stale-check"]);
+    assert!(output.status.success());
+    let stderr = String::from_utf8_lossy(&output.stderr);
+    assert!(stderr.contains("1 stale entries"), "{}", stderr);
+    assert!(stderr.contains("todo.py:2"), "{}", stderr);
+
+    let output = run(&["--baseline-stale-check", "--max-stale", "0"]);
+    assert_eq!(output.status.code(), Some(1));
+}
+
+#[test]
+fn test_baseline_path_relative() {
+    let temp = TempDir::new().unwrap();
+    let baseline = temp.path().join("baseline.json");
+    let checkouts = [temp.path().join("ci"), temp.path().join("laptop")];
+    for root in &checkouts {
+        fs::create_dir_all(root.join("src")).unwrap();
+        fs::write(root.join("src").join("todo.py"), "# TODO: first\n").unwrap();
+    }
+    let run = |root: &std::path::Path, extra: &[&str]| {
+        Command::new(antislop_bin())
+            .arg("--json")
+            .arg("--baseline")
+            .arg(&baseline)
+            .args(extra)
+            .arg(root)
+            .output()
+            .unwrap()
+    };
+    let count = |output: std::process::Output| {
+        let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+        json["findings"].as_array().unwrap().len()
+    };
+
+    // As scanned, a baseline from one checkout does not match the other
+    assert!(run(&checkouts[0], &["--update-baseline"]).status.success());
+    assert!(count(run(&checkouts[1], &[])) > 0);
+
+    let relative = ["--baseline-path-relative"];
+    assert!(run(&checkouts[0], &["--update-baseline", relative[0]])
+        .status
+        .success());
+    let text = fs::read_to_string(&baseline).unwrap();
+    assert!(text.contains("\"src/todo.py\""), "{}", text);
+    assert_eq!(count(run(&checkouts[1], &relative)), 0);
+
+    let base = format!("--baseline-path-relative={}", checkouts[1].display());
+    assert_eq!(count(run(&checkouts[1].join("src"), &[&base])), 0);
+}
+
+#[test]
+fn test_output_file_replaced_only_on_success() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("todo.py");
+    fs::write(&file, "# TODO: finish\n").unwrap();
+    let report = temp.path().join("report.sarif");
+
+    let output = Command::new(antislop_bin())
+        .arg("--format")
+        .arg("sarif")
+        .arg("--output")
+        .arg(&report)
+        .arg(&file)
+        .output()
+        .unwrap();
+
+    assert!(output.stdout.is_empty(), "Report should not go to stdout");
+    let sarif: serde_json::Value =
+        serde_json::from_str(&fs::read_to_string(&report).unwrap()).unwrap();
+    assert_eq!(sarif["runs"][0]["results"].as_array().unwrap().len(), 1);
+
+    // A run that fails before reporting keeps the previous report
+    let output = Command::new(antislop_bin())
+        .arg("--format")
+        .arg("sarif")
+        .arg("--output")
+        .arg(&report)
+        .arg("--profile")
+        .arg(temp.path().join("missing.toml"))
+        .arg(&file)
+        .output()
+        .unwrap();
+
+    assert!(!output.status.success());
+    assert!(!output.stderr.is_empty());
+    assert_eq!(
+        serde_json::from_str::<serde_json::Value>(&fs::read_to_string(&report).unwrap()).unwrap(),
+        sarif
+    );
+    assert_eq!(fs::read_dir(temp.path()).unwrap().count(), 2);
+}
+
+#[test]
+fn test_min_confidence_drops_heuristic_patterns() {
+    let temp = TempDir::new().unwrap();
+    let file = temp.path().join("app.py");
+    fs::write(
+        &file,
+        "# TODO: wire up the database\n# In production this would be a real database\n",
+    )
+    .unwrap();
+
+    let scan = |min: Option<&str>| -> Vec<serde_json::Value> {
+        let mut cmd = Command::new(antislop_bin());
+        cmd.arg("--json");
+        if let Some(min) = min {
+            cmd.arg("--min-confidence").arg(min);
+        }
+        let output = cmd.arg(&file).output().unwrap();
+        let json: serde_json::Value = serde_json::from_slice(&output.stdout).unwrap();
+        json["findings"].as_array().unwrap().clone()
+    };
+
+    let all = scan(None);
+    assert!(all.iter().any(|f| f["confidence"] == "low"));
+
+    let confident = scan(Some("high"));
+    assert!(!confident.is_empty());
+    assert!(confident.iter().all(|f| f["confidence"] == "high"));
+    assert!(confident.len() < all.len());
+}
+
+#[test]
+fn test_output_is_identical_across_concurrency() {
+    let temp = TempDir::new().unwrap();
+    for i in 0..24 {
+        fs::write(
+            temp.path().join(format!("mod_{:02}.py", i)),
+            "# TODO: quick hack, FIXME later\nx = 1  # XXX: HACK: for now\n",
+        )
+        .unwrap();
+    }
+
+    let run = |format: &str, jobs: &str| -> Vec<u8> {
+        Command::new(antislop_bin())
+            .arg("--format")
+            .arg(format)
+            .arg("-j")
+            .arg(jobs)
+            .arg(temp.path())
+            .output()
+            .unwrap()
+            .stdout
+    };
+
+    for format in ["human", "json", "sarif"] {
+        let sequential = run(format, "1");
+        assert!(!sequential.is_empty());
+        for _ in 0..3 {
+            assert_eq!(
+                run(format, "8"),
+                sequential,
+                "{} output differs between -j 1 and -j 8",
+                format
+            );
+        }
+    }
+}
@@ -27,6 +27,11 @@ pub fn make_finding(
         message: message.to_string(),
         match_text: match_text.to_string(),
         pattern_regex: "test".to_string(),
+        pattern_id: None,
+        tags: vec![],
+        confidence: Default::default(),
+        fix: None,
+        suggestion: None,
     }
 }
 